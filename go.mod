@@ -3,6 +3,7 @@ module github.com/remiges-tech/alya
 go 1.21.3
 
 require (
+	github.com/alicebob/miniredis/v2 v2.37.0
 	github.com/bmatcuk/doublestar/v4 v4.6.1
 	github.com/coreos/go-oidc/v3 v3.7.0
 	github.com/gabriel-vasile/mimetype v1.4.3
@@ -95,6 +96,7 @@ require (
 	github.com/stretchr/objx v0.5.2 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.2.11 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	go.etcd.io/etcd/api/v3 v3.5.10 // indirect
 	go.etcd.io/etcd/client/pkg/v3 v3.5.10 // indirect
 	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.45.0 // indirect
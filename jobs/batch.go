@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
@@ -27,6 +29,7 @@ type BatchOutput_t struct {
 	Status   BatchStatus_t
 	Res      JSONstr
 	Messages JSONstr
+	Run      int // run of the row which produced the result: 1, plus the number of times it was retried by BatchRetry
 }
 
 // RegisterProcessorBatch allows applications to register a processing function for a specific batch operation type.
@@ -35,6 +38,12 @@ type BatchOutput_t struct {
 // Attempting to register a second processor for the same combination will result in an error.
 // The 'op' parameter is case-insensitive and will be converted to lowercase before registration.
 func (jm *JobManager) RegisterProcessorBatch(app string, op string, p BatchProcessor) error {
+	return jm.RegisterProcessorBatchCtx(app, op, batchProcessorAdapter{p})
+}
+
+// RegisterProcessorBatchCtx is like RegisterProcessorBatch, for processors which implement the
+// BatchProcessorCtx interface and can therefore be cancelled while processing a row.
+func (jm *JobManager) RegisterProcessorBatchCtx(app string, op string, p BatchProcessorCtx) error {
 	// Convert op to lowercase before inserting into the database
 	op = strings.ToLower(op)
 
@@ -55,12 +64,15 @@ func (jm *JobManager) RegisterProcessorBatch(app string, op string, p BatchProce
 // The 'waitabit' parameter determines the initial status of the batch. If 'waitabit' is true, the batch
 // status will be set to 'wait', indicating that the batch should be held back from immediate processing. If
 // 'waitabit' is false, the batch status will be set to 'queued', making it available for processing.
-func (jm *JobManager) BatchSubmit(app, op string, batchctx JSONstr, batchInput []BatchInput_t, waitabit bool) (batchID string, err error) {
-	// Generate a unique batch ID
-	batchUUID, err := uuid.NewUUID()
+// The batch has priority ALYA_BATCH_PRIORITY unless WithPriority is passed in opts, and its rows are
+// not processed before the time passed with WithNotBefore, if any. If WithIdempotencyKey is passed
+// and a batch of the (app, op) was already submitted with the key, its ID is returned instead, or
+// ErrIdempotencyConflict if its context or input were different.
+func (jm *JobManager) BatchSubmit(app, op string, batchctx JSONstr, batchInput []BatchInput_t, waitabit bool, opts ...SubmitOption) (batchID string, err error) {
+	submitOpts := newSubmitOptions(ALYA_BATCH_PRIORITY, opts)
 
 	// Start a transaction
-	tx, err := jm.Db.Begin(context.Background())
+	tx, err := jm.Store.Begin(context.Background())
 	if err != nil {
 		return "", err
 	}
@@ -72,88 +84,133 @@ func (jm *JobManager) BatchSubmit(app, op string, batchctx JSONstr, batchInput [
 		status = batchsqlc.StatusEnumWait
 	}
 
-	// Convert op to lowercase before inserting into the database
-	op = strings.ToLower(op)
+	batchUUID, created, err := insertBatch(context.Background(), tx.Queries(), app, op, batchctx, batchInput, status, submitOpts)
+	if err != nil {
+		return "", err
+	}
+	if !created {
+		log.Printf("Batch %s was already submitted with idempotency key %s", batchUUID, submitOpts.idempotencyKey)
+		return batchUUID.String(), nil
+	}
 
-	// Insert a record into the batches table
-	_, err = jm.Queries.InsertIntoBatches(context.Background(), batchsqlc.InsertIntoBatchesParams{
-		ID:      batchUUID,
-		App:     app,
-		Op:      op,
-		Context: []byte(batchctx.String()),
-		Status:  status,
-		Reqat:   pgtype.Timestamp{Time: time.Now(), Valid: true},
-	})
+	// Commit the transaction
+	err = tx.Commit(context.Background())
 	if err != nil {
 		return "", err
 	}
 
+	if status == batchsqlc.StatusEnumQueued {
+		jm.publishEvent(BatchEvent{BatchID: batchUUID.String(), Type: BatchEventQueued, Status: status})
+	}
+
+	return batchUUID.String(), nil
+}
+
+// insertBatch inserts a batch and its rows through txQueries, which is expected to be bound to a
+// transaction, and returns the ID of the batch. If a batch was already submitted with the idempotency
+// key in submitOpts, nothing is inserted, and the ID of that batch is returned with created false.
+func insertBatch(ctx context.Context, txQueries batchsqlc.Querier, app, op string, batchctx JSONstr, batchInput []BatchInput_t, status batchsqlc.StatusEnum, submitOpts submitOptions) (batchUUID uuid.UUID, created bool, err error) {
+	var payloadHash []byte
+	if submitOpts.idempotencyKey != "" {
+		payloadHash = hashPayload(batchctx, batchInput)
+	}
+	batchUUID, created, err = insertBatchRecord(ctx, txQueries, app, op, batchctx, status, submitOpts, payloadHash, nil)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	if !created {
+		batchUUID, err = submittedBatch(ctx, txQueries, app, op, submitOpts.idempotencyKey, payloadHash)
+		return batchUUID, false, err
+	}
+
 	// Insert records into the batchrows table
 	batchRowsParam := batchsqlc.BulkInsertIntoBatchRowsParams{
-		Batch: make([]uuid.UUID, len(batchInput)),
-		Line:  make([]int32, len(batchInput)),
-		Input: make([][]byte, len(batchInput)),
-		Reqat: make([]pgtype.Timestamp, len(batchInput)),
+		Batch:   make([]uuid.UUID, len(batchInput)),
+		Line:    make([]int32, len(batchInput)),
+		Input:   make([][]byte, len(batchInput)),
+		Reqat:   make([]pgtype.Timestamp, len(batchInput)),
+		Partkey: make([]string, len(batchInput)),
 	}
 	for i, input := range batchInput {
 		batchRowsParam.Batch[i] = batchUUID
 		batchRowsParam.Line[i] = int32(input.Line)
 		batchRowsParam.Input[i] = []byte(input.Input.String())
 		batchRowsParam.Reqat[i] = pgtype.Timestamp{Time: time.Now(), Valid: true}
+		batchRowsParam.Partkey[i] = input.PartitionKey
 	}
-	_, err = jm.Queries.BulkInsertIntoBatchRows(context.Background(), batchRowsParam)
+	_, err = txQueries.BulkInsertIntoBatchRows(ctx, batchRowsParam)
 	if err != nil {
-		return "", err
+		return uuid.Nil, false, err
 	}
 
-	// Commit the transaction
-	err = tx.Commit(context.Background())
+	// Wake up idle workers once the batch is committed; a batch in wait is notified by WaitOff
+	if status == batchsqlc.StatusEnumQueued {
+		if err := notifyBatchQueued(ctx, txQueries, batchUUID); err != nil {
+			return uuid.Nil, false, err
+		}
+	}
+	return batchUUID, true, nil
+}
+
+// insertBatchRecord inserts a batch without any rows into the batches table, and returns its ID.
+// payloadHash is recorded along with the idempotency key in submitOpts, if any, and memoHash for a
+// memoized slow query. If a batch of the (app, op) already has the key, nothing is inserted and created
+// is false.
+func insertBatchRecord(ctx context.Context, txQueries batchsqlc.Querier, app, op string, batchctx JSONstr, status batchsqlc.StatusEnum, submitOpts submitOptions, payloadHash, memoHash []byte) (batchUUID uuid.UUID, created bool, err error) {
+	// Generate a unique batch ID
+	batchUUID, err = uuid.NewUUID()
 	if err != nil {
-		return "", err
+		return uuid.Nil, false, err
 	}
 
-	return batchUUID.String(), nil
+	// Convert op to lowercase before inserting into the database
+	op = strings.ToLower(op)
+
+	// Insert a record into the batches table
+	_, err = txQueries.InsertIntoBatches(ctx, batchsqlc.InsertIntoBatchesParams{
+		ID:             batchUUID,
+		App:            app,
+		Op:             op,
+		Context:        []byte(batchctx.String()),
+		Status:         status,
+		Reqat:          pgtype.Timestamp{Time: time.Now(), Valid: true},
+		Priority:       int32(submitOpts.priority),
+		Notbefore:      pgtype.Timestamp{Time: submitOpts.notBefore, Valid: !submitOpts.notBefore.IsZero()},
+		Idempotencykey: pgtype.Text{String: submitOpts.idempotencyKey, Valid: submitOpts.idempotencyKey != ""},
+		Payloadhash:    payloadHash,
+		Memohash:       memoHash,
+	})
+	if errors.Is(err, pgx.ErrNoRows) && submitOpts.idempotencyKey != "" {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	return batchUUID, true, nil
 }
 
 func (jm *JobManager) BatchDone(batchID string) (status batchsqlc.StatusEnum, batchOutput []BatchOutput_t, outputFiles map[string]string, nsuccess, nfailed, naborted int, err error) {
-	var batch batchsqlc.Batch
-	// Check REDIS for the batch status
-	redisKey := fmt.Sprintf("ALYA_BATCHSTATUS_%s", batchID)
-	statusVal, err := jm.RedisClient.Get(context.Background(), redisKey).Result()
-	if err == redis.Nil {
-		// Key does not exist in REDIS, check the database
-		batch, err = jm.Queries.GetBatchByID(context.Background(), uuid.MustParse(batchID))
-		if err != nil {
-			return batchsqlc.StatusEnumWait, nil, nil, 0, 0, 0, err
-		}
-		status = batch.Status
+	batchUUID := uuid.MustParse(batchID)
 
-		// Update REDIS with batches.status and an expiry duration
-		expirySec := jm.Config.BatchStatusCacheDurSec
-		if status == batchsqlc.StatusEnumSuccess || status == batchsqlc.StatusEnumFailed || status == batchsqlc.StatusEnumAborted {
-			// final status, set expiry to 100 times the cache duration
-			expirySec = 100 * jm.Config.BatchStatusCacheDurSec
-		}
-		err = updateStatusInRedis(jm.RedisClient, uuid.MustParse(batchID), status, expirySec)
-		if err != nil {
-			log.Printf("Error setting REDIS key %s: %v", redisKey, err)
-		}
-	} else if err != nil {
+	// Fetch the batch record from the database
+	batch, err := jm.Queries.GetBatchByID(context.Background(), batchUUID)
+	if err != nil {
 		return batchsqlc.StatusEnumWait, nil, nil, 0, 0, 0, err
+	}
+
+	// Use the cached status if there is one, otherwise cache batches.status
+	cached, found := jm.getCachedStatus(batchUUID)
+	if found {
+		status = cached.Status
 	} else {
-		// Key exists in REDIS, use the status value from REDIS
-		status = batchsqlc.StatusEnum(statusVal)
-		// Fetch the batch record from the database
-		batch, err = jm.Queries.GetBatchByID(context.Background(), uuid.MustParse(batchID))
-		if err != nil {
-			return batchsqlc.StatusEnumWait, nil, nil, 0, 0, 0, err
-		}
+		status = batch.Status
+		jm.cacheStatus(batchUUID, CachedStatus{Status: status})
 	}
 
 	switch status {
 	case batchsqlc.StatusEnumAborted, batchsqlc.StatusEnumFailed, batchsqlc.StatusEnumSuccess:
 		// Fetch batch rows data
-		batchRowsData, err := jm.Queries.FetchBatchRowsForBatchDone(context.Background(), uuid.MustParse(batchID))
+		batchRowsData, err := jm.Queries.FetchBatchRowsForBatchDone(context.Background(), batchUUID)
 		if err != nil {
 			return status, nil, nil, 0, 0, 0, err
 		}
@@ -161,19 +218,9 @@ func (jm *JobManager) BatchDone(batchID string) (status batchsqlc.StatusEnum, ba
 		// Convert batchRowsData to BatchOutput_t
 		batchOutput = make([]BatchOutput_t, len(batchRowsData))
 		for i, row := range batchRowsData {
-			res, err := NewJSONstr(string(row.Res))
-			if err != nil {
-				return status, nil, nil, 0, 0, 0, fmt.Errorf("failed to parse Res JSON for line %d: %v", row.Line, err)
-			}
-			messages, err := NewJSONstr(string(row.Messages))
+			batchOutput[i], err = newBatchOutput(row.Line, row.Status, row.Res, row.Messages, row.Runno)
 			if err != nil {
-				return status, nil, nil, 0, 0, 0, fmt.Errorf("failed to parse Messages JSON for line %d: %v", row.Line, err)
-			}
-			batchOutput[i] = BatchOutput_t{
-				Line:     int(row.Line),
-				Status:   mapStatusEnum(row.Status),
-				Res:      res,
-				Messages: messages,
+				return status, nil, nil, 0, 0, 0, err
 			}
 		}
 
@@ -186,7 +233,7 @@ func (jm *JobManager) BatchDone(batchID string) (status batchsqlc.StatusEnum, ba
 		nfailed = int(batch.Nfailed.Int32)
 		naborted = int(batch.Naborted.Int32)
 
-	case batchsqlc.StatusEnumQueued, batchsqlc.StatusEnumInprog, batchsqlc.StatusEnumWait:
+	case batchsqlc.StatusEnumQueued, batchsqlc.StatusEnumInprog, batchsqlc.StatusEnumWait, batchsqlc.StatusEnumPaused:
 		// Return with status indicating to try later
 		return status, nil, nil, 0, 0, 0, nil
 	}
@@ -194,6 +241,25 @@ func (jm *JobManager) BatchDone(batchID string) (status batchsqlc.StatusEnum, ba
 	return status, batchOutput, outputFiles, nsuccess, nfailed, naborted, nil
 }
 
+// newBatchOutput converts the result recorded for a batch row into a BatchOutput_t
+func newBatchOutput(line int32, status batchsqlc.StatusEnum, res, messages []byte, runno int32) (BatchOutput_t, error) {
+	resJSON, err := NewJSONstr(string(res))
+	if err != nil {
+		return BatchOutput_t{}, fmt.Errorf("failed to parse Res JSON for line %d: %v", line, err)
+	}
+	messagesJSON, err := NewJSONstr(string(messages))
+	if err != nil {
+		return BatchOutput_t{}, fmt.Errorf("failed to parse Messages JSON for line %d: %v", line, err)
+	}
+	return BatchOutput_t{
+		Line:     int(line),
+		Status:   mapStatusEnum(status),
+		Res:      resJSON,
+		Messages: messagesJSON,
+		Run:      int(runno),
+	}, nil
+}
+
 func (jm *JobManager) BatchAbort(batchID string) (status batchsqlc.StatusEnum, nsuccess, nfailed, naborted int, err error) {
 	fmt.Printf("jobs.abort inside abort\n")
 	// Parse the batch ID as a UUID
@@ -203,13 +269,13 @@ func (jm *JobManager) BatchAbort(batchID string) (status batchsqlc.StatusEnum, n
 	}
 
 	// Start a transaction
-	tx, err := jm.Db.Begin(context.Background())
+	tx, err := jm.Store.Begin(context.Background())
 	if err != nil {
 		return "", 0, 0, 0, fmt.Errorf("failed to start transaction: %v", err)
 	}
 	defer tx.Rollback(context.Background())
 
-	queries := batchsqlc.New(tx)
+	queries := tx.Queries()
 
 	// Perform SELECT FOR UPDATE on batches and batchrows for the given batch ID
 	fmt.Printf("jobs.abort before getbatchbyid\n")
@@ -258,24 +324,11 @@ func (jm *JobManager) BatchAbort(batchID string) (status batchsqlc.StatusEnum, n
 		return "", 0, 0, 0, fmt.Errorf("failed to update batchrows status: %v", err)
 	}
 
-	// Fetch the updated batchrows records for the batch
-	updatedBatchRows, err := queries.GetBatchRowsByBatchID(context.Background(), batchUUID)
-	if err != nil {
-		return "", 0, 0, 0, fmt.Errorf("failed to get updated batchrows: %v", err)
-	}
-
-	// Count the number of rows with each status
-	var successCount, failedCount, abortedCount int
-	for _, row := range updatedBatchRows {
-		switch row.Status {
-		case batchsqlc.StatusEnumSuccess:
-			successCount++
-		case batchsqlc.StatusEnumFailed:
-			failedCount++
-		case batchsqlc.StatusEnumAborted:
-			abortedCount++
-		}
-	}
+	// The pending rows are added to the aborted counter; the rows which have finished are
+	// already counted in the batch
+	successCount := int(batch.Nsuccess.Int32)
+	failedCount := int(batch.Nfailed.Int32)
+	abortedCount := int(batch.Naborted.Int32) + len(rowids)
 
 	// Update the batch status to aborted and set doneat timestamp
 	fmt.Printf("jobs.abort before updatebatchsummary\n")
@@ -298,11 +351,12 @@ func (jm *JobManager) BatchAbort(batchID string) (status batchsqlc.StatusEnum, n
 		return "", 0, 0, 0, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	// Update status in Redis
-	err = updateStatusInRedis(jm.RedisClient, batchUUID, batchsqlc.StatusEnumAborted, 100*jm.Config.BatchStatusCacheDurSec)
-	if err != nil {
-		log.Printf("failed to update status in Redis: %v", err)
-	}
+	// Stop the rows of the batch being processed by this instance; other instances notice
+	// the abort through their abort watcher
+	jm.cancelBatch(batchUUID)
+	jm.publishEvent(doneEvent(batchUUID, batchsqlc.StatusEnumAborted, successCount, failedCount, abortedCount))
+
+	jm.cacheStatus(batchUUID, CachedStatus{Status: batchsqlc.StatusEnumAborted})
 	return batchsqlc.StatusEnumAborted, successCount, failedCount, abortedCount, nil
 }
 
@@ -322,7 +376,7 @@ func (jm *JobManager) BatchAppend(batchID string, batchinput []batchsqlc.InsertI
 	}
 
 	// Start a transaction
-	tx, err := jm.Db.Begin(context.Background())
+	tx, err := jm.Store.Begin(context.Background())
 	if err != nil {
 		return 0, fmt.Errorf("failed to start transaction: %v", err)
 	}
@@ -342,7 +396,7 @@ func (jm *JobManager) BatchAppend(batchID string, batchinput []batchsqlc.InsertI
 
 	// Update the batch status to "queued" if waitabit is false
 	if !waitabit {
-		err = jm.Queries.UpdateBatchStatus(context.Background(), batchsqlc.UpdateBatchStatusParams{
+		err = jm.Queries.SetBatchStatus(context.Background(), batchsqlc.SetBatchStatusParams{
 			ID:     uuid.MustParse(batchID),
 			Status: batchsqlc.StatusEnumQueued,
 		})
@@ -375,7 +429,7 @@ func (jm *JobManager) WaitOff(batchID string) (string, int, error) {
 	}
 
 	// Start a transaction
-	tx, err := jm.Db.Begin(context.Background())
+	tx, err := jm.Store.Begin(context.Background())
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to start transaction: %v", err)
 	}
@@ -408,13 +462,16 @@ func (jm *JobManager) WaitOff(batchID string) (string, int, error) {
 	}
 
 	// Update the batch status to "queued"
-	err = jm.Queries.UpdateBatchStatus(context.Background(), batchsqlc.UpdateBatchStatusParams{
+	err = jm.Queries.SetBatchStatus(context.Background(), batchsqlc.SetBatchStatusParams{
 		ID:     batchUUID,
 		Status: batchsqlc.StatusEnumQueued,
 	})
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to update batch status: %v", err)
 	}
+	if err := notifyBatchQueued(context.Background(), jm.Queries, batchUUID); err != nil {
+		return "", 0, err
+	}
 
 	// Get the total count of rows in batchrows for the batch
 	nrows, err := jm.Queries.GetBatchRowsCount(context.Background(), batchUUID)
@@ -427,14 +484,11 @@ func (jm *JobManager) WaitOff(batchID string) (string, int, error) {
 	if err != nil {
 		return "", 0, fmt.Errorf("failed to commit transaction: %v", err)
 	}
+	jm.publishEvent(BatchEvent{BatchID: batchID, Type: BatchEventQueued, Status: batchsqlc.StatusEnumQueued})
 
 	return batchID, int(nrows), nil
 }
 
-func GetBatchStatusRedisKey(batchID string) string {
-	return fmt.Sprintf("batch:%s:status", batchID)
-}
-
 func mapStatusEnum(status batchsqlc.StatusEnum) BatchStatus_t {
 	switch status {
 	case batchsqlc.StatusEnumWait:
@@ -449,7 +503,80 @@ func mapStatusEnum(status batchsqlc.StatusEnum) BatchStatus_t {
 		return BatchFailed
 	case batchsqlc.StatusEnumAborted:
 		return BatchAborted
+	case batchsqlc.StatusEnumDeadletter:
+		return BatchDeadLetter
+	case batchsqlc.StatusEnumPaused:
+		return BatchPaused
 	default:
 		return BatchTryLater
 	}
 }
+
+// BatchList returns the batches of an app which were requested in the last age days, newest first.
+// If op is not empty only the batches for that op are returned. opts may be nil; it can be used to
+// return only the batches in certain statuses or in a narrower time window, and to page through a
+// long history. A page token is returned if there may be more batches than were returned; it is
+// passed in opts.PageToken to fetch the next page, and is empty on the last page.
+func (jm *JobManager) BatchList(app, op string, age int, opts *ListOptions) (batchlist []BatchDetails_t, nextPageToken string, err error) {
+	params, err := newListParams(op, age, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := jm.Queries.ListBatches(context.Background(), batchsqlc.ListBatchesParams{
+		App:        app,
+		Op:         params.op,
+		Statuses:   params.statuses,
+		Reqfrom:    params.reqFrom,
+		Requntil:   params.reqUntil,
+		Afterreqat: params.afterReqAt,
+		Afterid:    params.afterID,
+		Pagesize:   params.pageSize,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list batches: %v", err)
+	}
+
+	batchlist = make([]BatchDetails_t, len(rows))
+	for i, row := range rows {
+		batchContext, err := NewJSONstr(string(row.Context))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse context of batch %s: %v", row.ID, err)
+		}
+		outputFiles := make(map[string]string)
+		if row.Outputfiles != nil {
+			if err := json.Unmarshal(row.Outputfiles, &outputFiles); err != nil {
+				return nil, "", fmt.Errorf("failed to unmarshal output files of batch %s: %v", row.ID, err)
+			}
+		}
+		var aggregate JSONstr
+		if row.Aggregate != nil {
+			if aggregate, err = NewJSONstr(string(row.Aggregate)); err != nil {
+				return nil, "", fmt.Errorf("failed to parse aggregate of batch %s: %v", row.ID, err)
+			}
+		}
+		batchlist[i] = BatchDetails_t{
+			ID:           row.ID.String(),
+			App:          row.App,
+			Op:           row.Op,
+			Context:      batchContext,
+			InputFile:    row.Inputfile.String,
+			Status:       row.Status,
+			ReqAt:        row.Reqat.Time,
+			DoneAt:       row.Doneat.Time,
+			OutputFiles:  outputFiles,
+			NRows:        int(row.Nrows),
+			NSuccess:     int(row.Nsuccess.Int32),
+			NFailed:      int(row.Nfailed.Int32),
+			NAborted:     int(row.Naborted.Int32),
+			Aggregate:    aggregate,
+			AggregateErr: row.Aggregateerr.String,
+		}
+	}
+
+	if len(rows) == int(params.pageSize) {
+		last := rows[len(rows)-1]
+		nextPageToken = encodePageToken(last.Reqat.Time, last.ID)
+	}
+	return batchlist, nextPageToken, nil
+}
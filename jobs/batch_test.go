@@ -75,7 +75,7 @@ func TestBatchDone(t *testing.T) {
 	// Create a JobManager instance with the database and Redis dependencies
 	jm := &JobManager{
 		Queries:     batchsqlc.New(db),
-		RedisClient: redisClient,
+		StatusCache: NewRedisStatusCache(redisClient, ""),
 	}
 
 	// Generate a random batch ID
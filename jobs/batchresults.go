@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// BatchResultOptions narrows down the rows of a batch whose results are returned by BatchResults and
+// BatchResultIter, and pages through them. Rows are returned in the order of their lines. When more
+// rows are available than fit in a page, a page token is returned along with the page; passing it in
+// PageToken fetches the next page.
+type BatchResultOptions struct {
+	Statuses  []batchsqlc.StatusEnum // only return rows in one of these statuses; all rows if empty
+	FromLine  int                    // only return lines from this one on; no lower limit if 0
+	ToLine    int                    // only return lines up to this one; no upper limit if 0
+	PageSize  int                    // maximum number of rows to return, ALYA_LIST_PAGESIZE if 0
+	PageToken string                 // token returned with the previous page, empty for the first page
+}
+
+// BatchResults returns a page of the results of the rows of a batch, ordered by line, so that large
+// batches can be paged through rather than loaded whole with BatchDone. Unlike BatchDone, it can be
+// called while the batch is being processed: rows which have not been processed yet are returned in
+// the queued or inprog status without a result, unless opts.Statuses leaves them out.
+//
+// nextPageToken is empty once the last page has been returned.
+func (jm *JobManager) BatchResults(batchID string, opts *BatchResultOptions) (results []BatchOutput_t, nextPageToken string, err error) {
+	batchUUID, err := uuid.Parse(batchID)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid batch ID: %v", err)
+	}
+	if opts == nil {
+		opts = &BatchResultOptions{}
+	}
+
+	params := batchsqlc.ListBatchRowResultsParams{
+		Batch:    batchUUID,
+		Statuses: make([]string, len(opts.Statuses)),
+		Fromline: int32(opts.FromLine),
+		Toline:   math.MaxInt32,
+		Pagesize: int32(opts.PageSize),
+	}
+	for i, status := range opts.Statuses {
+		params.Statuses[i] = string(status)
+	}
+	if opts.ToLine != 0 {
+		params.Toline = int32(opts.ToLine)
+	}
+	if params.Pagesize <= 0 {
+		params.Pagesize = ALYA_LIST_PAGESIZE
+	}
+
+	if opts.PageToken == "" {
+		// Later pages were returned for a batch which exists, so only the first one is checked
+		if _, err := jm.Queries.GetBatchStatus(context.Background(), batchUUID); err != nil {
+			return nil, "", fmt.Errorf("failed to get batch %s: %v", batchID, err)
+		}
+	} else {
+		line, rowid, err := decodeResultPageToken(opts.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		params.Afterline = pgtype.Int4{Int32: line, Valid: true}
+		params.Afterrowid = pgtype.Int8{Int64: rowid, Valid: true}
+	}
+
+	rows, err := jm.Queries.ListBatchRowResults(context.Background(), params)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list results of batch %s: %v", batchID, err)
+	}
+
+	results = make([]BatchOutput_t, len(rows))
+	for i, row := range rows {
+		results[i], err = newBatchOutput(row.Line, row.Status, row.Res, row.Messages, row.Runno)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	// A full page may be followed by more rows
+	if len(rows) == int(params.Pagesize) {
+		last := rows[len(rows)-1]
+		nextPageToken = encodeResultPageToken(last.Line, last.Rowid)
+	}
+	return results, nextPageToken, nil
+}
+
+// BatchResultIterator goes through the results of a batch a page at a time; see BatchResultIter.
+type BatchResultIterator struct {
+	jm      *JobManager
+	batchID string
+	opts    BatchResultOptions
+	page    []BatchOutput_t
+	next    int // index in page of the result returned by the next call to Next
+	done    bool
+	err     error
+}
+
+// BatchResultIter returns an iterator over the results of the rows of a batch which match opts,
+// ordered by line, for exporters which need to go through the results of a large batch without
+// holding all of them in memory. opts.PageSize sets the number of results fetched at a time.
+//
+//	it := jm.BatchResultIter(batchID, nil)
+//	for it.Next() {
+//		result := it.Result()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+func (jm *JobManager) BatchResultIter(batchID string, opts *BatchResultOptions) *BatchResultIterator {
+	it := &BatchResultIterator{jm: jm, batchID: batchID}
+	if opts != nil {
+		it.opts = *opts
+	}
+	return it
+}
+
+// Next moves to the next result, fetching the next page of results if needed. It returns false
+// when there are no more results, or when fetching a page failed, in which case Err returns the
+// error.
+func (it *BatchResultIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for it.next >= len(it.page) {
+		if it.done {
+			return false
+		}
+		it.page, it.opts.PageToken, it.err = it.jm.BatchResults(it.batchID, &it.opts)
+		if it.err != nil {
+			return false
+		}
+		it.next = 0
+		it.done = it.opts.PageToken == ""
+	}
+	it.next++
+	return true
+}
+
+// Result returns the result which the last call to Next moved to
+func (it *BatchResultIterator) Result() BatchOutput_t {
+	return it.page[it.next-1]
+}
+
+// Err returns the error which made Next return false, if any
+func (it *BatchResultIterator) Err() error {
+	return it.err
+}
+
+// encodeResultPageToken returns the token for the page which follows the row with the given line
+// and rowid.
+func encodeResultPageToken(line int32, rowid int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d_%d", line, rowid)))
+}
+
+// decodeResultPageToken extracts the line and rowid of the last row of the previous page from a
+// page token.
+func decodeResultPageToken(token string) (int32, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, 0, ErrInvalidPageToken
+	}
+	lineStr, rowidStr, found := strings.Cut(string(raw), "_")
+	if !found {
+		return 0, 0, ErrInvalidPageToken
+	}
+	line, err := strconv.ParseInt(lineStr, 10, 32)
+	if err != nil {
+		return 0, 0, ErrInvalidPageToken
+	}
+	rowid, err := strconv.ParseInt(rowidStr, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidPageToken
+	}
+	return int32(line), rowid, nil
+}
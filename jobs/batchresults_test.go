@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchResults(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+
+	// Lines are submitted out of order, and every third one fails
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for line := 10; line >= 1; line-- {
+		value := fmt.Sprintf(`"row %d"`, line)
+		if line%3 == 0 {
+			value = `"fail"`
+		}
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: line, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, input, false)
+	assert.NoError(t, err)
+
+	// Results can be listed before the batch is processed
+	results, token, err := jm.BatchResults(batchID, nil)
+	assert.NoError(t, err)
+	assert.Len(t, results, 10)
+	assert.Empty(t, token)
+	assert.Equal(t, BatchQueued, results[0].Status)
+
+	runJobManager(t, jm)
+	waitForBatch(t, jm, batchID)
+	<-p.markDoneCalled
+
+	// Pages of lines 2 to 9, in order
+	var lines []int
+	opts := &BatchResultOptions{FromLine: 2, ToLine: 9, PageSize: 3}
+	for {
+		results, token, err = jm.BatchResults(batchID, opts)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(results), 3)
+		for _, result := range results {
+			lines = append(lines, result.Line)
+		}
+		if token == "" {
+			break
+		}
+		opts.PageToken = token
+	}
+	assert.Equal(t, []int{2, 3, 4, 5, 6, 7, 8, 9}, lines)
+
+	// Only the failed lines, through the iterator
+	lines = nil
+	it := jm.BatchResultIter(batchID, &BatchResultOptions{Statuses: []batchsqlc.StatusEnum{batchsqlc.StatusEnumFailed}, PageSize: 2})
+	for it.Next() {
+		assert.Equal(t, BatchFailed, it.Result().Status)
+		lines = append(lines, it.Result().Line)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []int{3, 6, 9}, lines)
+
+	_, _, err = jm.BatchResults(batchID, &BatchResultOptions{PageToken: "not-a-token"})
+	assert.True(t, errors.Is(err, ErrInvalidPageToken))
+
+	it = jm.BatchResultIter("00000000-0000-0000-0000-000000000000", nil)
+	assert.False(t, it.Next())
+	assert.Error(t, it.Err())
+}
+
+func TestResultPageToken(t *testing.T) {
+	line, rowid, err := decodeResultPageToken(encodeResultPageToken(42, 1234567890123))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(42), line)
+	assert.Equal(t, int64(1234567890123), rowid)
+
+	_, _, err = decodeResultPageToken(encodeResultPageToken(42, 1)[1:])
+	assert.True(t, errors.Is(err, ErrInvalidPageToken))
+}
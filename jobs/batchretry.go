@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ErrBatchNotDone is returned by BatchRetry for a batch which has not reached a final status yet.
+var ErrBatchNotDone = errors.New("batch is not done")
+
+// ErrInvalidRetryFilter is returned by BatchRetry when the filter selects rows by a status other
+// than failed, deadletter or aborted.
+var ErrInvalidRetryFilter = errors.New("only failed, dead-lettered or aborted rows can be retried")
+
+// BatchRetryFilter selects the rows of a batch which are queued again by BatchRetry.
+type BatchRetryFilter struct {
+	Statuses []batchsqlc.StatusEnum // retry the rows in one of these statuses; failed rows only if empty
+	Lines    []int                  // retry only these lines; all the lines in the statuses if empty
+}
+
+// BatchRowResult_t is a result recorded for a batch row in an earlier run, before the row was
+// queued again by BatchRetry.
+type BatchRowResult_t struct {
+	Line     int
+	Run      int // run of the row which produced the result, starting at 1
+	Status   BatchStatus_t
+	DoneAt   time.Time
+	Res      JSONstr
+	Messages JSONstr
+}
+
+// BatchRetry queues the failed rows of a batch which is done again, in place, without the whole
+// input being resubmitted. filter may be nil; it can be used to retry aborted or dead-lettered rows
+// as well, or to retry only some lines. The batch is reopened: its status goes back to queued, its
+// counters are recounted without the retried rows and its cached status is removed from the
+// StatusCache. If its (app, op) has a BatchReducer, its aggregate is computed again from the rows
+// which are not retried. Once the retried rows are done the batch is summarized again and MarkDone
+// is called with the new summary.
+//
+// The result each retried row had so far is kept, and can be listed with BatchRetryHistory. The run
+// of each row is returned by BatchDone in BatchOutput_t.Run. It returns the number of rows queued;
+// if no row matches the filter the batch is left as it is.
+func (jm *JobManager) BatchRetry(batchID string, filter *BatchRetryFilter) (nrows int, err error) {
+	batchUUID, err := uuid.Parse(batchID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid batch ID: %v", err)
+	}
+	if filter == nil {
+		filter = &BatchRetryFilter{}
+	}
+	statuses := []string{string(batchsqlc.StatusEnumFailed)}
+	if len(filter.Statuses) > 0 {
+		statuses = make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			if !hasStatus(status, batchsqlc.StatusEnumFailed, batchsqlc.StatusEnumDeadletter, batchsqlc.StatusEnumAborted) {
+				return 0, fmt.Errorf("%w: %s", ErrInvalidRetryFilter, status)
+			}
+			statuses[i] = string(status)
+		}
+	}
+	lines := make([]int32, len(filter.Lines))
+	for i, line := range filter.Lines {
+		lines[i] = int32(line)
+	}
+
+	tx, err := jm.Store.Begin(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
+
+	batch, err := txQueries.GetBatchByID(context.Background(), batchUUID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get batch by ID: %v", err)
+	}
+	if !isFinalStatus(batch.Status) {
+		return 0, fmt.Errorf("%w: batch %s is %s", ErrBatchNotDone, batchID, batch.Status)
+	}
+
+	retried, err := txQueries.RetryBatchRows(context.Background(), batchsqlc.RetryBatchRowsParams{
+		Batch:    batchUUID,
+		Statuses: statuses,
+		Lines:    lines,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to retry rows of batch %s: %v", batchID, err)
+	}
+	if len(retried) == 0 {
+		return 0, nil
+	}
+
+	if err := txQueries.ReopenBatch(context.Background(), batchUUID); err != nil {
+		return 0, fmt.Errorf("failed to reopen batch %s: %v", batchID, err)
+	}
+	if err := jm.reduceBatchAgain(txQueries, batch); err != nil {
+		return 0, fmt.Errorf("failed to aggregate results of batch %s again: %v", batchID, err)
+	}
+	if err := notifyBatchQueued(context.Background(), txQueries, batchUUID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	jm.uncacheStatus(batchUUID)
+	jm.publishEvent(BatchEvent{BatchID: batchID, Type: BatchEventQueued, Status: batchsqlc.StatusEnumQueued})
+
+	log.Printf("Retrying %d rows of batch %s", len(retried), batchID)
+	return len(retried), nil
+}
+
+// BatchRetryHistory returns the results which the rows of a batch had before they were retried by
+// BatchRetry, sorted by line and run. The current result of each row is returned by BatchDone.
+func (jm *JobManager) BatchRetryHistory(batchID string) ([]BatchRowResult_t, error) {
+	batchUUID, err := uuid.Parse(batchID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid batch ID: %v", err)
+	}
+
+	rows, err := jm.Queries.GetBatchRowResults(context.Background(), batchUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get earlier results of batch %s: %v", batchID, err)
+	}
+
+	results := make([]BatchRowResult_t, len(rows))
+	for i, row := range rows {
+		res, err := NewJSONstr(string(row.Res))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Res JSON for line %d: %v", row.Line, err)
+		}
+		messages, err := NewJSONstr(string(row.Messages))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Messages JSON for line %d: %v", row.Line, err)
+		}
+		results[i] = BatchRowResult_t{
+			Line:     int(row.Line),
+			Run:      int(row.Runno),
+			Status:   mapStatusEnum(row.Status),
+			DoneAt:   row.Doneat.Time,
+			Res:      res,
+			Messages: messages,
+		}
+	}
+	return results, nil
+}
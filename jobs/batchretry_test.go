@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/wscutils"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyBatchProcessor fails the rows whose input is "flaky" until fixed is set
+type flakyBatchProcessor struct {
+	echoBatchProcessor
+	fixed atomic.Bool
+}
+
+func (p *flakyBatchProcessor) DoBatchJob(ctx context.Context, initBlock InitBlock, batchctx JSONstr, line int, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	if input.String() == `"flaky"` && !p.fixed.Load() {
+		return batchsqlc.StatusEnumFailed, input, []wscutils.ErrorMessage{{MsgID: 1, ErrCode: "unavailable"}}, nil, nil
+	}
+	return p.echoBatchProcessor.DoBatchJob(ctx, initBlock, batchctx, line, input)
+}
+
+func waitForBatch(t *testing.T, jm *JobManager, batchID string) (batchsqlc.StatusEnum, []BatchOutput_t, int, int) {
+	var status batchsqlc.StatusEnum
+	var output []BatchOutput_t
+	var nsuccess, nfailed int
+	var err error
+	assert.Eventually(t, func() bool {
+		status, output, _, nsuccess, nfailed, _, err = jm.BatchDone(batchID)
+		return err != nil || isFinalStatus(status)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, err)
+	return status, output, nsuccess, nfailed
+}
+
+func TestBatchRetry(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	p := &flakyBatchProcessor{echoBatchProcessor: echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 2)}}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "flaky", p))
+	runJobManager(t, jm)
+
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for i, value := range []string{`"a"`, `"flaky"`, `"fail"`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "flaky", batchctx, input, false)
+	assert.NoError(t, err)
+
+	status, _, nsuccess, nfailed := waitForBatch(t, jm, batchID)
+	assert.Equal(t, batchsqlc.StatusEnumFailed, status)
+	assert.Equal(t, 1, nsuccess)
+	assert.Equal(t, 2, nfailed)
+	<-p.markDoneCalled
+
+	// Only the row which failed on line 2 is retried
+	p.fixed.Store(true)
+	nrows, err := jm.BatchRetry(batchID, &BatchRetryFilter{Lines: []int{2}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, nrows)
+
+	status, output, nsuccess, nfailed := waitForBatch(t, jm, batchID)
+	assert.Equal(t, batchsqlc.StatusEnumFailed, status)
+	assert.Equal(t, 2, nsuccess)
+	assert.Equal(t, 1, nfailed)
+	runs := make(map[int]int)
+	for _, row := range output {
+		runs[row.Line] = row.Run
+	}
+	assert.Equal(t, map[int]int{1: 1, 2: 2, 3: 1}, runs)
+
+	details := <-p.markDoneCalled
+	assert.Equal(t, 2, details.NSuccess)
+
+	history, err := jm.BatchRetryHistory(batchID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+	assert.Equal(t, 2, history[0].Line)
+	assert.Equal(t, 1, history[0].Run)
+	assert.Equal(t, BatchFailed, history[0].Status)
+
+	// No row matches, so the batch is left as it is
+	nrows, err = jm.BatchRetry(batchID, &BatchRetryFilter{Statuses: []batchsqlc.StatusEnum{batchsqlc.StatusEnumAborted}})
+	assert.NoError(t, err)
+	assert.Zero(t, nrows)
+}
+
+func TestBatchRetryErrors(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	batchctx, _ := NewJSONstr(`{}`)
+	rowInput, _ := NewJSONstr(`"a"`)
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, []BatchInput_t{{Line: 1, Input: rowInput}}, true)
+	assert.NoError(t, err)
+
+	_, err = jm.BatchRetry(batchID, nil)
+	assert.True(t, errors.Is(err, ErrBatchNotDone))
+
+	_, err = jm.BatchRetry(batchID, &BatchRetryFilter{Statuses: []batchsqlc.StatusEnum{batchsqlc.StatusEnumSuccess}})
+	assert.True(t, errors.Is(err, ErrInvalidRetryFilter))
+}
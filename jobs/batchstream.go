@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// BatchInputStream is a source of the rows of a batch submitted with BatchSubmitStream, such as a
+// reader which parses them out of a file one at a time. Next returns io.EOF once there are no more
+// rows.
+type BatchInputStream interface {
+	Next() (BatchInput_t, error)
+}
+
+// BatchInputChan returns a BatchInputStream which returns the rows received on ch until it is closed
+func BatchInputChan(ch <-chan BatchInput_t) BatchInputStream {
+	return batchInputChan(ch)
+}
+
+type batchInputChan <-chan BatchInput_t
+
+func (ch batchInputChan) Next() (BatchInput_t, error) {
+	input, ok := <-ch
+	if !ok {
+		return BatchInput_t{}, io.EOF
+	}
+	return input, nil
+}
+
+// BatchSubmitStream is like BatchSubmit, for batches too large to be held in memory or inserted in
+// a single statement. The rows are read from input and copied into the batchrows table with COPY,
+// Config.BatchStreamCopyNRows at a time, in the same transaction as the batch itself. The batch is
+// in the wait status until every row has been written; it is then queued, unless waitabit is true,
+// and the transaction is committed. If progress is not nil, it is called with the number of rows
+// written so far after each chunk.
+//
+// If input returns an error other than io.EOF, nothing is submitted and the error is returned. If
+// WithIdempotencyKey is passed and a batch was already submitted with the key, input is read to the
+// end, to compare it with the input of that batch, and the ID of that batch is returned.
+func (jm *JobManager) BatchSubmitStream(app, op string, batchctx JSONstr, input BatchInputStream, waitabit bool, progress func(nrows int), opts ...SubmitOption) (batchID string, err error) {
+	submitOpts := newSubmitOptions(ALYA_BATCH_PRIORITY, opts)
+
+	tx, err := jm.Store.Begin(context.Background())
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
+
+	// The payload is hashed as it is copied, so its hash is only recorded once the input is closed
+	var hasher *payloadHasher
+	if submitOpts.idempotencyKey != "" {
+		hasher = newPayloadHasher(batchctx)
+	}
+	batchUUID, created, err := insertBatchRecord(context.Background(), txQueries, app, op, batchctx, batchsqlc.StatusEnumWait, submitOpts, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if !created {
+		if err := hashBatchRows(input, hasher); err != nil {
+			return "", err
+		}
+		batchUUID, err = submittedBatch(context.Background(), txQueries, app, op, submitOpts.idempotencyKey, hasher.sum())
+		if err != nil {
+			return "", err
+		}
+		log.Printf("Batch %s was already submitted with idempotency key %s", batchUUID, submitOpts.idempotencyKey)
+		return batchUUID.String(), nil
+	}
+
+	nrows, err := copyBatchRows(context.Background(), txQueries, batchUUID, input, jm.Config.BatchStreamCopyNRows, hasher, progress)
+	if err != nil {
+		return "", err
+	}
+	var payloadHash []byte
+	if hasher != nil {
+		payloadHash = hasher.sum()
+	}
+
+	status := batchsqlc.StatusEnumQueued
+	if waitabit {
+		status = batchsqlc.StatusEnumWait
+	}
+	err = txQueries.CloseBatchInput(context.Background(), batchsqlc.CloseBatchInputParams{
+		ID:          batchUUID,
+		Status:      status,
+		Nrows:       int32(nrows),
+		Payloadhash: payloadHash,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to update batch %s: %v", batchUUID, err)
+	}
+	if status == batchsqlc.StatusEnumQueued {
+		if err := notifyBatchQueued(context.Background(), txQueries, batchUUID); err != nil {
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return "", err
+	}
+
+	if status == batchsqlc.StatusEnumQueued {
+		jm.publishEvent(BatchEvent{BatchID: batchUUID.String(), Type: BatchEventQueued, Status: status})
+	}
+	log.Printf("Submitted batch %s with %d rows", batchUUID, nrows)
+	return batchUUID.String(), nil
+}
+
+// copyBatchRows copies the rows read from input into the batch, chunkSize rows at a time, and
+// returns the number of rows copied. The rows are added to hasher, if it is not nil.
+func copyBatchRows(ctx context.Context, txQueries batchsqlc.Querier, batchID uuid.UUID, input BatchInputStream, chunkSize int, hasher *payloadHasher, progress func(nrows int)) (int, error) {
+	chunk := make([]batchsqlc.CopyIntoBatchRowsParams, 0, chunkSize)
+	nrows := 0
+	for eof := false; !eof; {
+		chunk = chunk[:0]
+		reqat := pgtype.Timestamp{Time: time.Now(), Valid: true}
+		for len(chunk) < chunkSize {
+			row, err := input.Next()
+			if errors.Is(err, io.EOF) {
+				eof = true
+				break
+			}
+			if err != nil {
+				return nrows, fmt.Errorf("failed to read row %d of batch %s: %v", nrows+len(chunk)+1, batchID, err)
+			}
+			if hasher != nil {
+				hasher.addRow(row)
+			}
+			chunk = append(chunk, batchsqlc.CopyIntoBatchRowsParams{
+				Batch:   batchID,
+				Line:    int32(row.Line),
+				Input:   []byte(row.Input.String()),
+				Reqat:   reqat,
+				Partkey: pgtype.Text{String: row.PartitionKey, Valid: row.PartitionKey != ""},
+			})
+		}
+		if len(chunk) == 0 {
+			break
+		}
+
+		n, err := txQueries.CopyIntoBatchRows(ctx, chunk)
+		if err != nil {
+			return nrows, fmt.Errorf("failed to copy rows of batch %s: %v", batchID, err)
+		}
+		nrows += int(n)
+		if progress != nil {
+			progress(nrows)
+		}
+	}
+	return nrows, nil
+}
+
+// hashBatchRows adds the rows read from input to hasher, without copying them anywhere
+func hashBatchRows(input BatchInputStream, hasher *payloadHasher) error {
+	for nrows := 0; ; nrows++ {
+		row, err := input.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read row %d: %v", nrows+1, err)
+		}
+		hasher.addRow(row)
+	}
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingInputStream returns nrows rows and then an error
+type failingInputStream struct {
+	nrows int
+	line  int
+}
+
+func (s *failingInputStream) Next() (BatchInput_t, error) {
+	if s.line == s.nrows {
+		return BatchInput_t{}, errors.New("unreadable line")
+	}
+	s.line++
+	input, _ := NewJSONstr(`"a"`)
+	return BatchInput_t{Line: s.line, Input: input}, nil
+}
+
+func TestBatchSubmitStream(t *testing.T) {
+	jm := NewJobManagerWithStore(NewMemJobStore(), nil, nil, nil, &JobManagerConfig{BatchStreamCopyNRows: 4})
+	assert.NoError(t, jm.RegisterInitializer("app1", &MockInitializer{}))
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+
+	ch := make(chan BatchInput_t)
+	go func() {
+		defer close(ch)
+		for line := 1; line <= 10; line++ {
+			input, _ := NewJSONstr(fmt.Sprintf(`"row %d"`, line))
+			ch <- BatchInput_t{Line: line, Input: input}
+		}
+	}()
+
+	var progress []int
+	batchctx, _ := NewJSONstr(`{}`)
+	batchID, err := jm.BatchSubmitStream("app1", "echo", batchctx, BatchInputChan(ch), true, func(nrows int) {
+		progress = append(progress, nrows)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{4, 8, 10}, progress)
+
+	batch, err := jm.Queries.GetBatchByID(context.Background(), uuid.MustParse(batchID))
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumWait, batch.Status)
+	assert.Equal(t, int32(10), batch.Nrows)
+
+	runJobManager(t, jm)
+	_, _, err = jm.WaitOff(batchID)
+	assert.NoError(t, err)
+	status, output, nsuccess, _ := waitForBatch(t, jm, batchID)
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, status)
+	assert.Equal(t, 10, nsuccess)
+	assert.Len(t, output, 10)
+	<-p.markDoneCalled
+}
+
+func TestBatchSubmitStreamInputError(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	batchctx, _ := NewJSONstr(`{}`)
+	_, err := jm.BatchSubmitStream("app1", "echo", batchctx, &failingInputStream{nrows: 3}, false, nil)
+	assert.ErrorContains(t, err, "failed to read row 4")
+
+	// Nothing is left behind
+	batches, _, err := jm.BatchList("app1", "echo", 1, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, batches)
+}
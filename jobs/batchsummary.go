@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/remiges-tech/alya/jobs/objstore"
@@ -30,33 +29,17 @@ func (jm *JobManager) summarizeBatch(q batchsqlc.Querier, batchID uuid.UUID) err
 		return nil
 	}
 
-	// fetch count of records where status = queued or inprogress
-	count, err := q.CountBatchRowsByBatchIDAndStatus(ctx, batchsqlc.CountBatchRowsByBatchIDAndStatusParams{
-		Batch:    batchID,
-		Status:   batchsqlc.StatusEnumQueued,
-		Status_2: batchsqlc.StatusEnumInprog,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to count batch rows by batch ID and status: %v", err)
-	}
-
-	if count > 0 {
+	// The summary counters are maintained as rows finish, so the batch is done once they add up to
+	// the number of its rows
+	nsuccess, nfailed, naborted := int64(batch.Nsuccess.Int32), int64(batch.Nfailed.Int32), int64(batch.Naborted.Int32)
+	if nsuccess+nfailed+naborted < int64(batch.Nrows) {
 		return nil
 	}
 
-	// Fetch all batchrows records for the batch, sorted by "line"
-	batchRows, err := q.GetBatchRowsByBatchIDSorted(ctx, batchID)
-	if err != nil {
-		return fmt.Errorf("failed to get batch rows sorted: %v", err)
-	}
-
-	// Calculate the summary counters
-	nsuccess, nfailed, naborted := calculateSummaryCounters(batchRows)
-
 	// Determine the overall batch status based on the counter values
 	batchStatus := determineBatchStatus(nsuccess, nfailed, naborted)
 
-	// Fetch processed batchrows records for the batch to create temporary files
+	// Fetch the processed batchrows records of the batch which have blobrows, to create temporary files
 	processedBatchRows, err := q.GetProcessedBatchRowsByBatchIDSorted(ctx, batchID)
 	if err != nil {
 		return fmt.Errorf("failed to get processed batch rows sorted: %v", err)
@@ -81,22 +64,29 @@ func (jm *JobManager) summarizeBatch(q batchsqlc.Querier, batchID uuid.UUID) err
 		return fmt.Errorf("failed to move files to object store: %v", err)
 	}
 
+	// The reducer of the batch, if any, produces the final aggregate before MarkDone is called
+	aggregate, aggregateErr, err := jm.finalizeAggregate(q, batch)
+	if err != nil {
+		return fmt.Errorf("failed to finalize aggregate: %v", err)
+	}
+
 	// Update the batches record with summarized information
 	err = updateBatchSummary(q, ctx, batchID, batchStatus, objStoreFiles, nsuccess, nfailed, naborted)
 	if err != nil {
 		return fmt.Errorf("failed to update batch summary: %v", err)
 	}
 
-	// Update status in redis
-	err = updateStatusInRedis(jm.RedisClient, batchID, batchStatus, 100*jm.Config.BatchStatusCacheDurSec)
-	if err != nil {
-		return fmt.Errorf("failed to update status in redis: %v", err)
-	}
+	jm.cacheStatus(batchID, CachedStatus{Status: batchStatus})
+	jm.publishEvent(doneEvent(batchID, batchStatus, int(nsuccess), int(nfailed), int(naborted)))
 
-	// After successful batch completion and Redis update, call MarkDone
+	// After successful batch completion and status cache update, call MarkDone
 	// Get the processor for this app+op
 	processor, exists := jm.batchprocessorfuncs[batch.App+batch.Op]
 	if !exists {
+		if _, isSlowQuery := jm.slowqueryprocessorfuncs[batch.App+batch.Op]; isSlowQuery {
+			// slow queries have no MarkDone
+			return nil
+		}
 		return fmt.Errorf("no processor found for app %s and op %s", batch.App, batch.Op)
 	}
 
@@ -105,37 +95,27 @@ func (jm *JobManager) summarizeBatch(q batchsqlc.Querier, batchID uuid.UUID) err
 		return fmt.Errorf("failed to parse context for MarkDone: %v", err)
 	}
 
-	var batchOutputFiles map[string]string
-	if batch.Outputfiles != nil {
-		if err := json.Unmarshal(batch.Outputfiles, &batchOutputFiles); err != nil {
-			return fmt.Errorf("failed to unmarshal output files: %v", err)
-		}
-	}
-
+	// The batch record was read before it was summarized, so the summary is passed as computed here
 	details := BatchDetails_t{
-		ID:          batchID.String(),
-		App:         batch.App,
-		Op:          batch.Op,
-		Context:     context,
-		Status:      batch.Status,
-		OutputFiles: batchOutputFiles,
-		NSuccess:    int(batch.Nsuccess.Int32),
-		NFailed:     int(batch.Nfailed.Int32),
-		NAborted:    int(batch.Naborted.Int32),
+		ID:           batchID.String(),
+		App:          batch.App,
+		Op:           batch.Op,
+		Context:      context,
+		InputFile:    batch.Inputfile.String,
+		Status:       batchStatus,
+		ReqAt:        batch.Reqat.Time,
+		OutputFiles:  objStoreFiles,
+		NSuccess:     int(nsuccess),
+		NFailed:      int(nfailed),
+		NAborted:     int(naborted),
+		Aggregate:    aggregate,
+		AggregateErr: aggregateErr,
 	}
 
 	// Get or create InitBlock
-	initBlock, exists := jm.initblocks[batch.App]
-	if !exists || initBlock == nil {
-		if initializer, exists := jm.initfuncs[batch.App]; exists {
-			initBlock, err = initializer.Init(batch.App)
-			if err != nil {
-				return fmt.Errorf("failed to initialize app %s for MarkDone: %v", batch.App, err)
-			}
-			jm.initblocks[batch.App] = initBlock
-		} else {
-			return fmt.Errorf("no initializer found for app %s", batch.App)
-		}
+	initBlock, err := jm.getOrCreateInitBlock(batch.App)
+	if err != nil {
+		return fmt.Errorf("failed to initialize app %s for MarkDone: %v", batch.App, err)
 	}
 
 	// Call MarkDone
@@ -148,20 +128,6 @@ func (jm *JobManager) summarizeBatch(q batchsqlc.Querier, batchID uuid.UUID) err
 	return nil
 }
 
-func calculateSummaryCounters(batchRows []batchsqlc.GetBatchRowsByBatchIDSortedRow) (nsuccess, nfailed, naborted int64) {
-	for _, row := range batchRows {
-		switch row.Status {
-		case batchsqlc.StatusEnumSuccess:
-			nsuccess++
-		case batchsqlc.StatusEnumFailed:
-			nfailed++
-		case batchsqlc.StatusEnumAborted:
-			naborted++
-		}
-	}
-	return
-}
-
 func determineBatchStatus(nsuccess, nfailed, naborted int64) batchsqlc.StatusEnum {
 	if naborted > 0 {
 		return batchsqlc.StatusEnumAborted
@@ -243,7 +209,7 @@ func appendBlobRowsToFiles(batchRows []batchsqlc.GetProcessedBatchRowsByBatchIDS
 func moveFilesToObjectStore(tmpFiles map[string]*os.File, store objstore.ObjectStore, bucket string) (map[string]string, error) {
 	outputFiles := make(map[string]string)
 	for logicalFile, file := range tmpFiles {
-		objectID, err := moveToObjectStore(file.Name(), store, bucket)
+		objectID, err := moveToObjectStore(file.Name(), store, bucket, "application/octet-stream")
 		if err != nil {
 			return nil, fmt.Errorf("failed to move file to object store: %v", err)
 		}
@@ -273,93 +239,7 @@ func updateBatchSummary(q batchsqlc.Querier, ctx context.Context, batchID uuid.U
 	return nil
 }
 
-// updateStatusInRedis updates the batch status in Redis using a transaction because multiple jobmanager
-// instances could be executed parallely and without atomic update there could be incorrect update.
-//
-// Explanation of the transaction:
-//  1. The `Watch` function is called with the batch specific key to monitor.
-//     If the key's value changes after this point and before the transaction block executes, the transaction will be aborted.
-//  2. Inside the transaction block, we first fetch the current status of the batch from Redis. If fetching fails or if the key does not exist (`redis.Nil`), an error is returned.
-//  3. If the current status in Redis matches the new status, there is no need to update, and we exit.
-//  4. If the status is different, we proceed to update the status within a pipeline. Pipelining commands means they are queued up and executed at once
-//
-// This is equivalent to the following Redis commands:
-// 127.0.0.1:6379> WATCH ALYA_BATCHSTATUS_batchID // Monitor the key for changes
-// 127.0.0.1:6379> GET ALYA_BATCHSTATUS_batchID
-// 127.0.0.1:6379> MULTI // Start a transaction
-// 127.0.0.1:6379> SET ALYA_BATCHSTATUS_batchID 110
-// 127.0.0.1:6379> EXEC // Execute the transaction
-func updateStatusInRedis(redisClient *redis.Client, batchID uuid.UUID, status batchsqlc.StatusEnum, expirySec int) error {
-	redisKey := fmt.Sprintf("ALYA_BATCHSTATUS_%s", batchID)
-
-	expiry := time.Duration(expirySec) * time.Second
-
-	err := redisClient.Watch(context.Background(), func(tx *redis.Tx) error {
-		// Check the current status of the batch in Redis
-		currentStatus, err := tx.Get(context.Background(), redisKey).Result()
-		if err != nil && err != redis.Nil {
-			return err
-		}
-
-		// If the current status is already the same as the new status, no need to update
-		if currentStatus == string(status) {
-			return nil
-		}
-
-		// Update the batch status in Redis within the transaction
-		_, err = tx.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
-			pipe.Set(context.Background(), redisKey, string(status), expiry)
-			return nil
-		})
-		return err
-	}, redisKey)
-
-	if err != nil {
-		return fmt.Errorf("failed to update status in Redis: %v", err)
-	}
-	return nil
-}
-
-func updateStatusAndOutputFilesDataInRedis(redisClient *redis.Client, batchID uuid.UUID, status batchsqlc.StatusEnum, outputFiles map[string]string, result string, expirySec int) error {
-	redisKey := fmt.Sprintf("ALYA_BATCHSTATUS_%s", batchID)
-	redisResultKey := fmt.Sprintf("ALYA_BATCHRESULT_%s", batchID)
-	redisOutputFilesKey := fmt.Sprintf("ALYA_BATCHOUTFILES_%s", batchID)
-	expiry := time.Duration(expirySec) * time.Second
-
-	err := redisClient.Watch(context.Background(), func(tx *redis.Tx) error {
-		// Check the current status of the batch in Redis
-		currentStatus, err := tx.Get(context.Background(), redisKey).Result()
-		if err != nil && err != redis.Nil {
-			return err
-		}
-
-		// If the current status is already the same as the new status, no need to update
-		if currentStatus == string(status) {
-			return nil
-		}
-		// Convert outputFiles to JSON
-		outputFilesJSON, err := json.Marshal(outputFiles)
-		if err != nil {
-			return fmt.Errorf("failed to marshal output files: %v", err)
-		}
-
-		// Update the batch status ,outputfiles and result in Redis within the transaction
-		_, err = tx.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
-			pipe.Set(context.Background(), redisKey, string(status), expiry)
-			pipe.Set(context.Background(), redisResultKey, result, expiry)
-			pipe.Set(context.Background(), redisOutputFilesKey, outputFilesJSON, expiry)
-			return nil
-		})
-		return err
-	}, redisKey)
-
-	if err != nil {
-		return fmt.Errorf("failed to update status,outputfiles and result in Redis: %v", err)
-	}
-	return nil
-}
-
-func moveToObjectStore(filePath string, store objstore.ObjectStore, bucket string) (string, error) {
+func moveToObjectStore(filePath string, store objstore.ObjectStore, bucket, contentType string) (string, error) {
 	// Open the file
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -377,7 +257,7 @@ func moveToObjectStore(filePath string, store objstore.ObjectStore, bucket strin
 	objectName := uuid.New().String()
 
 	// Put the object in the object store
-	err = store.Put(context.Background(), bucket, objectName, file, fileInfo.Size(), "application/octet-stream")
+	err = store.Put(context.Background(), bucket, objectName, file, fileInfo.Size(), contentType)
 	if err != nil {
 		return "", fmt.Errorf("failed to put object in store: %v", err)
 	}
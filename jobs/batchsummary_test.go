@@ -1,9 +1,11 @@
 package jobs
 
 import (
+	"context"
 	"os"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
 	"github.com/stretchr/testify/require"
 )
@@ -63,3 +65,63 @@ func TestBatchProcessingLogicalFiles(t *testing.T) {
 	expectedErrorLog := "Invalid transaction amount\n"
 	require.Equal(t, expectedErrorLog, string(errorLogContents))
 }
+
+// TestBatchCounters checks that the counters of a batch are maintained as the results of its rows
+// are recorded, and that the batch is summarized once they add up to its number of rows.
+func TestBatchCounters(t *testing.T) {
+	ctx := context.Background()
+	jm := newMemTestJobManager(t)
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	require.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for i, value := range []string{`"a"`, `"fail"`, `"c"`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, input, false)
+	require.NoError(t, err)
+	batchUUID := uuid.MustParse(batchID)
+
+	batch, err := jm.Queries.GetBatchByID(ctx, batchUUID)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), batch.Nrows)
+
+	rows, err := jm.fetchBlock(ctx)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	results := &rowResults{}
+	for _, row := range rows {
+		_, err := jm.processRow(ctx, jm.Queries, row, results)
+		require.NoError(t, err)
+	}
+	require.NoError(t, jm.recordRowResults(jm.Queries, results))
+
+	// Rows which are no longer leased to this instance are not recorded, or counted, again
+	require.NoError(t, jm.recordRowResults(jm.Queries, results))
+
+	batch, err = jm.Queries.GetBatchByID(ctx, batchUUID)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), batch.Nsuccess.Int32)
+	require.Equal(t, int32(1), batch.Nfailed.Int32)
+	require.Zero(t, batch.Naborted.Int32)
+
+	require.NoError(t, jm.summarizeBatch(jm.Queries, batchUUID))
+	details := <-p.markDoneCalled
+	require.Equal(t, batchsqlc.StatusEnumFailed, details.Status)
+	require.Equal(t, 2, details.NSuccess)
+
+	// Retrying the failed row takes it out of the counters until it is done again
+	nrows, err := jm.BatchRetry(batchID, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, nrows)
+	batch, err = jm.Queries.GetBatchByID(ctx, batchUUID)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), batch.Nsuccess.Int32)
+	require.Zero(t, batch.Nfailed.Int32)
+	require.NoError(t, jm.summarizeBatch(jm.Queries, batchUUID))
+	batch, err = jm.Queries.GetBatchByID(ctx, batchUUID)
+	require.NoError(t, err)
+	require.Equal(t, batchsqlc.StatusEnumQueued, batch.Status)
+}
@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ErrRowAborted is the cause of the cancellation of a row's context when its batch or slow query is aborted.
+var ErrRowAborted = errors.New("batch aborted while the row was being processed")
+
+// ErrRowTimedOut is the cause of the cancellation of a row's context when the row timeout registered
+// for its (app, op) elapses. A row which times out is retried or recorded as failed, like a row for
+// which the processor returned an error, and IsRetryable of the retry policy is passed an error
+// wrapping ErrRowTimedOut.
+var ErrRowTimedOut = errors.New("row processing timed out")
+
+// ErrRowTimeoutAlreadyRegistered is returned when attempting to register a second row timeout
+// for the same (app, op) combination.
+var ErrRowTimeoutAlreadyRegistered = errors.New("row timeout already registered for this app and operation")
+
+// ErrInvalidRowTimeout is returned when registering a row timeout which is not greater than 0.
+var ErrInvalidRowTimeout = errors.New("row timeout must be greater than 0")
+
+// inflightRow is a row being processed by this instance
+type inflightRow struct {
+	batch  uuid.UUID
+	cancel context.CancelCauseFunc
+}
+
+// RegisterRowTimeout sets the maximum time for which the processor of a specific (app, op) combination
+// may run on a single row, for both batch jobs and slow queries. When it elapses the context passed to a
+// BatchProcessorCtx or SlowQueryProcessorCtx is cancelled. Processors registered without a context
+// cannot be interrupted, so the timeout has no effect on them.
+// Each (app, op) combination can only have one registered row timeout.
+// The 'op' parameter is case-insensitive and will be converted to lowercase before registration.
+func (jm *JobManager) RegisterRowTimeout(app string, op string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return ErrInvalidRowTimeout
+	}
+
+	// Convert op to lowercase, as it is stored in the database
+	op = strings.ToLower(op)
+
+	key := app + op
+	_, exists := jm.rowtimeouts[key]
+	if exists {
+		return fmt.Errorf("%w: app=%s, op=%s", ErrRowTimeoutAlreadyRegistered, app, op)
+	}
+	jm.rowtimeouts[key] = timeout
+	return nil
+}
+
+// startRow returns the context to be passed to the processor of a row, and records the row as in flight
+// so that it can be cancelled if its batch is aborted. The context is derived from ctx, which is cancelled
+// when the JobManager shuts down, and carries the row timeout for the (app, op) if there is one. The
+// returned function must be called once the processor has returned.
+func (jm *JobManager) startRow(ctx context.Context, row batchsqlc.FetchBlockOfRowsRow) (context.Context, func()) {
+	rowCtx, cancel := context.WithCancelCause(ctx)
+	stopTimer := func() {}
+	if timeout, exists := jm.rowtimeouts[row.App+row.Op]; exists {
+		rowCtx, stopTimer = context.WithTimeoutCause(rowCtx, timeout, fmt.Errorf("%w after %v", ErrRowTimedOut, timeout))
+	}
+
+	jm.inflightmu.Lock()
+	jm.inflight[row.Rowid] = inflightRow{batch: row.Batch, cancel: cancel}
+	jm.inflightmu.Unlock()
+
+	return rowCtx, func() {
+		jm.inflightmu.Lock()
+		delete(jm.inflight, row.Rowid)
+		jm.inflightmu.Unlock()
+		stopTimer()
+		cancel(nil)
+	}
+}
+
+// rowCancelCause returns the reason for which the context of a row was cancelled, if the processor
+// returned an error after it was: ErrRowAborted, an error wrapping ErrRowTimedOut, or the cause of
+// the cancellation of the context passed to Run when the JobManager is shutting down.
+// It returns nil if the context is still live, or if the processor completed the row regardless.
+func rowCancelCause(rowCtx context.Context, err error) error {
+	if err == nil || rowCtx.Err() == nil {
+		return nil
+	}
+	return context.Cause(rowCtx)
+}
+
+// cancelBatch cancels the contexts of the rows of a batch being processed by this instance.
+// It returns the number of rows cancelled.
+func (jm *JobManager) cancelBatch(batchID uuid.UUID) int {
+	jm.inflightmu.Lock()
+	defer jm.inflightmu.Unlock()
+
+	n := 0
+	for _, row := range jm.inflight {
+		if row.batch == batchID {
+			row.cancel(ErrRowAborted)
+			n++
+		}
+	}
+	return n
+}
+
+// runAbortWatcher checks every Config.AbortCheckIntervalSec seconds whether the batches of the rows
+// being processed by this instance have been aborted, possibly through another instance, and cancels
+// those rows. It returns when ctx is cancelled.
+func (jm *JobManager) runAbortWatcher(ctx context.Context) {
+	interval := time.Duration(jm.Config.AbortCheckIntervalSec) * time.Second
+	for {
+		sleepWithContext(ctx, interval)
+		if ctx.Err() != nil {
+			return
+		}
+		if err := jm.cancelAbortedRows(); err != nil {
+			log.Printf("Error checking for aborted batches: %v", err)
+		}
+	}
+}
+
+// cancelAbortedRows cancels the rows being processed by this instance whose batch has been aborted.
+func (jm *JobManager) cancelAbortedRows() error {
+	jm.inflightmu.Lock()
+	batchSet := make(map[uuid.UUID]bool)
+	for _, row := range jm.inflight {
+		batchSet[row.batch] = true
+	}
+	jm.inflightmu.Unlock()
+
+	if len(batchSet) == 0 {
+		return nil
+	}
+	batchIDs := make([]uuid.UUID, 0, len(batchSet))
+	for batchID := range batchSet {
+		batchIDs = append(batchIDs, batchID)
+	}
+
+	aborted, err := jm.Queries.GetAbortedBatches(context.Background(), batchIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get aborted batches: %v", err)
+	}
+	for _, batchID := range aborted {
+		n := jm.cancelBatch(batchID)
+		log.Printf("Cancelled %d rows of aborted batch %s", n, batchID)
+	}
+	return nil
+}
+
+// releaseRow puts a row which this instance was processing when it started shutting down back in the
+// queue, so that another instance can take it up. The release does not count as an attempt.
+func (jm *JobManager) releaseRow(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow, cause error) error {
+	err := txQueries.ReleaseBatchRows(context.Background(), batchsqlc.ReleaseBatchRowsParams{
+		Lasterr: pgtype.Text{String: fmt.Sprintf("released on shutdown: %v", cause), Valid: true},
+		Rowids:  []int64{row.Rowid},
+		Doneby:  jm.doneBy(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release row %d: %v", row.Rowid, err)
+	}
+	log.Printf("Released row %d of batch %s on shutdown", row.Rowid, row.Batch)
+	return nil
+}
@@ -0,0 +1,264 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc/mocks"
+	"github.com/remiges-tech/alya/wscutils"
+	"github.com/remiges-tech/logharbour/logharbour"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingBatchProcessor waits until its context is cancelled, or until release is closed
+type blockingBatchProcessor struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingBatchProcessor() *blockingBatchProcessor {
+	return &blockingBatchProcessor{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (p *blockingBatchProcessor) DoBatchJob(ctx context.Context, initBlock InitBlock, context JSONstr, line int, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	p.started <- struct{}{}
+	select {
+	case <-ctx.Done():
+		return batchsqlc.StatusEnumFailed, JSONstr{}, nil, nil, ctx.Err()
+	case <-p.release:
+		result, _ := NewJSONstr("")
+		return batchsqlc.StatusEnumSuccess, result, nil, nil, nil
+	}
+}
+
+func (p *blockingBatchProcessor) MarkDone(initBlock InitBlock, context JSONstr, details BatchDetails_t) error {
+	return nil
+}
+
+func newCancelQuerierMock() *mocks.QuerierMock {
+	return &mocks.QuerierMock{
+		ReleaseBatchRowsFunc: func(ctx context.Context, arg batchsqlc.ReleaseBatchRowsParams) error {
+			return nil
+		},
+		RetryBatchRowFunc: func(ctx context.Context, arg batchsqlc.RetryBatchRowParams) error {
+			return nil
+		},
+	}
+}
+
+func newTestLogger() *logharbour.Logger {
+	return logharbour.NewLogger(logharbour.NewLoggerContext(logharbour.DefaultPriority), "jobs_test", io.Discard)
+}
+
+func newCancelTestJobManager(t *testing.T, p BatchProcessorCtx) *JobManager {
+	jm := NewJobManager(nil, nil, nil, newTestLogger(), nil)
+	assert.NoError(t, jm.RegisterInitializer("app1", &MockInitializer{}))
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "op1", p))
+	return jm
+}
+
+func TestRowTimeout(t *testing.T) {
+	jm := newCancelTestJobManager(t, newBlockingBatchProcessor())
+	assert.NoError(t, jm.RegisterRowTimeout("app1", "OP1", 10*time.Millisecond))
+
+	mockQuerier := newCancelQuerierMock()
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Batch: uuid.New(), Rowid: 7, Line: 1}
+
+	results := &rowResults{}
+	status, err := jm.processRow(context.Background(), mockQuerier, row, results)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumFailed, status)
+	assert.Len(t, results.results, 1)
+	result := results.results[0]
+	assert.Equal(t, batchsqlc.StatusEnumFailed, result.Status)
+	assert.Equal(t, MsgIDRowTimedOut, result.Messages[0].MsgID)
+	assert.Equal(t, ErrcodeRowTimedOut, result.Messages[0].ErrCode)
+	assert.Empty(t, jm.inflight)
+}
+
+func TestRowTimeoutIsRetried(t *testing.T) {
+	jm := newCancelTestJobManager(t, newBlockingBatchProcessor())
+	assert.NoError(t, jm.RegisterRowTimeout("app1", "op1", 10*time.Millisecond))
+	assert.NoError(t, jm.RegisterRetryPolicy("app1", "op1", RetryPolicy{
+		MaxAttempts: 3,
+		IsRetryable: func(err error) bool { return errors.Is(err, ErrRowTimedOut) },
+	}))
+
+	mockQuerier := newCancelQuerierMock()
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Batch: uuid.New(), Rowid: 7, Line: 1}
+
+	status, err := jm.processRow(context.Background(), mockQuerier, row, &rowResults{})
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumQueued, status)
+	assert.Len(t, mockQuerier.RetryBatchRowCalls(), 1)
+	assert.Contains(t, mockQuerier.RetryBatchRowCalls()[0].Arg.Lasterr.String, ErrRowTimedOut.Error())
+}
+
+func TestRowCancelledOnAbort(t *testing.T) {
+	p := newBlockingBatchProcessor()
+	jm := newCancelTestJobManager(t, p)
+
+	mockQuerier := newCancelQuerierMock()
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Batch: uuid.New(), Rowid: 7, Line: 1}
+
+	go func() {
+		<-p.started
+		assert.Equal(t, 0, jm.cancelBatch(uuid.New()))
+		assert.Equal(t, 1, jm.cancelBatch(row.Batch))
+	}()
+	results := &rowResults{}
+	status, err := jm.processRow(context.Background(), mockQuerier, row, results)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumAborted, status)
+
+	// The row has already been recorded as aborted by BatchAbort
+	assert.Empty(t, results.rows)
+	assert.Len(t, mockQuerier.RetryBatchRowCalls(), 0)
+}
+
+func TestRowReleasedOnShutdown(t *testing.T) {
+	p := newBlockingBatchProcessor()
+	jm := newCancelTestJobManager(t, p)
+
+	mockQuerier := newCancelQuerierMock()
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Batch: uuid.New(), Rowid: 7, Line: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-p.started
+		cancel()
+	}()
+	status, err := jm.processRow(ctx, mockQuerier, row, &rowResults{})
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumQueued, status)
+	assert.Len(t, mockQuerier.RetryBatchRowCalls(), 0)
+	assert.Len(t, mockQuerier.ReleaseBatchRowsCalls(), 1)
+	call := mockQuerier.ReleaseBatchRowsCalls()[0].Arg
+	assert.Equal(t, []int64{7}, call.Rowids)
+	assert.Contains(t, call.Lasterr.String, "released on shutdown")
+}
+
+func TestRowsReleasedOnShutdown(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", &echoBatchProcessor{}))
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for line := 1; line <= 3; line++ {
+		rowInput, _ := NewJSONstr(`1`)
+		input = append(input, BatchInput_t{Line: line, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, input, false)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	rows, err := jm.fetchBlock(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+
+	// Meanwhile, the second row is aborted and the third is taken over by another worker
+	assert.NoError(t, jm.Queries.UpdateBatchRowsStatus(ctx, batchsqlc.UpdateBatchRowsStatusParams{
+		Status:  batchsqlc.StatusEnumAborted,
+		Column2: []int64{rows[1].Rowid},
+	}))
+	assert.NoError(t, jm.Queries.LeaseBatchRows(ctx, batchsqlc.LeaseBatchRowsParams{
+		Rowids: []int64{rows[2].Rowid},
+		Doneby: pgtype.Text{String: "other", Valid: true},
+	}))
+
+	// Only the row still leased to this instance is queued again
+	jm.releaseRows(rows)
+	batchRows, err := jm.Queries.GetBatchRowsByBatchID(ctx, uuid.MustParse(batchID))
+	assert.NoError(t, err)
+	statuses := map[int32]batchsqlc.StatusEnum{}
+	for _, row := range batchRows {
+		statuses[row.Line] = row.Status
+	}
+	assert.Equal(t, map[int32]batchsqlc.StatusEnum{
+		1: batchsqlc.StatusEnumQueued,
+		2: batchsqlc.StatusEnumAborted,
+		3: batchsqlc.StatusEnumInprog,
+	}, statuses)
+}
+
+func TestSlowQueryAbortedWhileProcessorRuns(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	p := progressSlowQueryProcessor{reported: make(chan struct{}), release: make(chan struct{})}
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "report", p))
+
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"n":42}`)
+	reqID, err := jm.SlowQuerySubmit("app1", "report", queryctx, input)
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		processQueuedRows(t, jm)
+	}()
+	<-p.reported
+	assert.NoError(t, jm.SlowQueryAbort(reqID))
+
+	// The processor ignores its context and succeeds, which does not undo the abort
+	close(p.release)
+	<-done
+	batch, err := jm.Queries.GetBatchByID(context.Background(), uuid.MustParse(reqID))
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumAborted, batch.Status)
+}
+
+func TestRowCompletedDespiteCancellation(t *testing.T) {
+	// Processors registered without a context are not interrupted
+	jm := NewJobManager(nil, nil, nil, newTestLogger(), nil)
+	assert.NoError(t, jm.RegisterInitializer("app1", &MockInitializer{}))
+	assert.NoError(t, jm.RegisterProcessorBatch("app1", "op1", &mockBatchProcessor{t: t}))
+
+	mockQuerier := newCancelQuerierMock()
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Batch: uuid.New(), Rowid: 7, Line: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := &rowResults{}
+	status, err := jm.processRow(ctx, mockQuerier, row, results)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, status)
+	assert.Len(t, results.rows, 1)
+	assert.Len(t, mockQuerier.RetryBatchRowCalls(), 0)
+}
+
+func TestCancelAbortedRows(t *testing.T) {
+	p := newBlockingBatchProcessor()
+	jm := newCancelTestJobManager(t, p)
+
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Batch: uuid.New(), Rowid: 7, Line: 1}
+	mockQuerier := newCancelQuerierMock()
+	mockQuerier.GetAbortedBatchesFunc = func(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+		return ids, nil
+	}
+	jm.Queries = mockQuerier
+
+	// Nothing in flight, nothing to check
+	assert.NoError(t, jm.cancelAbortedRows())
+	assert.Len(t, mockQuerier.GetAbortedBatchesCalls(), 0)
+
+	go func() {
+		<-p.started
+		assert.NoError(t, jm.cancelAbortedRows())
+	}()
+	status, err := jm.processRow(context.Background(), mockQuerier, row, &rowResults{})
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumAborted, status)
+	assert.Equal(t, []uuid.UUID{row.Batch}, mockQuerier.GetAbortedBatchesCalls()[0].IDs)
+}
+
+func TestRegisterRowTimeout(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+	assert.NoError(t, jm.RegisterRowTimeout("app1", "op1", time.Minute))
+	assert.True(t, errors.Is(jm.RegisterRowTimeout("app1", "OP1", time.Second), ErrRowTimeoutAlreadyRegistered))
+	assert.True(t, errors.Is(jm.RegisterRowTimeout("app1", "op2", 0), ErrInvalidRowTimeout))
+}
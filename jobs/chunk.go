@@ -0,0 +1,229 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/wscutils"
+)
+
+// BatchChunkRow_t is a row of a batch passed to a BatchChunkProcessor
+type BatchChunkRow_t struct {
+	Line  int
+	Input JSONstr
+}
+
+// BatchChunkResult_t is the result of a row processed by a BatchChunkProcessor, as DoBatchJob would
+// have returned it for the row. A row with Err set is retried, dead-lettered or recorded as failed
+// as per the retry policy for the (app, op), like a row for which DoBatchJob returned an error.
+type BatchChunkResult_t struct {
+	Line     int
+	Status   batchsqlc.StatusEnum
+	Result   JSONstr
+	Messages []wscutils.ErrorMessage
+	BlobRows map[string]string
+	Err      error
+}
+
+// BatchChunkProcessor is a batch processor which processes the rows of a batch a chunk at a time
+// rather than one by one, so that it can do a single multi-row INSERT or bulk API call for them.
+// DoBatchChunk is called with the rows of each batch in a block fetched by a worker, up to
+// ALYA_BATCHCHUNK_NROWS of them, and returns a result for each line. The results are written back
+// in one statement. A line without a result is recorded as failed.
+//
+// If DoBatchChunk returns an error, every row of the chunk is handled as if DoBatchJob had returned
+// it, and if it panics every row is moved to the dead-letter state. The context is cancelled as it is
+// for a BatchProcessorCtx; a row timeout registered for the (app, op) applies to the whole chunk.
+type BatchChunkProcessor interface {
+	DoBatchChunk(ctx context.Context, initBlock InitBlock, context JSONstr, rows []BatchChunkRow_t) (results []BatchChunkResult_t, err error)
+	MarkDone(initBlock InitBlock, context JSONstr, details BatchDetails_t) error
+}
+
+// batchChunker is implemented by the processors registered with RegisterProcessorBatchChunk, and by
+// any BatchProcessorCtx which also implements DoBatchChunk
+type batchChunker interface {
+	DoBatchChunk(ctx context.Context, initBlock InitBlock, context JSONstr, rows []BatchChunkRow_t) ([]BatchChunkResult_t, error)
+}
+
+// RegisterProcessorBatchChunk is like RegisterProcessorBatch, for processors which implement the
+// BatchChunkProcessor interface and process the rows of a batch a chunk at a time.
+func (jm *JobManager) RegisterProcessorBatchChunk(app string, op string, p BatchChunkProcessor) error {
+	return jm.RegisterProcessorBatchCtx(app, op, batchChunkProcessorAdapter{p})
+}
+
+// batchChunkProcessorAdapter lets a BatchChunkProcessor be used as a BatchProcessorCtx
+type batchChunkProcessorAdapter struct {
+	BatchChunkProcessor
+}
+
+// DoBatchJob processes a single row as a chunk of one
+func (a batchChunkProcessorAdapter) DoBatchJob(ctx context.Context, initBlock InitBlock, batchctx JSONstr, line int, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	results, err := a.DoBatchChunk(ctx, initBlock, batchctx, []BatchChunkRow_t{{Line: line, Input: input}})
+	if err != nil {
+		return batchsqlc.StatusEnumFailed, JSONstr{}, nil, nil, err
+	}
+	for _, result := range results {
+		if result.Line == line {
+			return result.Status, result.Result, result.Messages, result.BlobRows, result.Err
+		}
+	}
+	return batchsqlc.StatusEnumFailed, JSONstr{}, nil, nil, fmt.Errorf("no result returned for line %d", line)
+}
+
+// chunksOf returns the indexes in the block of the rows of each batch whose processor processes chunks
+func (jm *JobManager) chunksOf(blockOfRows []batchsqlc.FetchBlockOfRowsRow) map[uuid.UUID][]int {
+	chunks := make(map[uuid.UUID][]int)
+	for i, row := range blockOfRows {
+		if row.Line == 0 {
+			continue
+		}
+		if _, isChunker := jm.batchprocessorfuncs[row.App+row.Op].(batchChunker); isChunker {
+			chunks[row.Batch] = append(chunks[row.Batch], i)
+		}
+	}
+	return chunks
+}
+
+// startChunk is like startRow, for the rows of a chunk, which share a context
+func (jm *JobManager) startChunk(ctx context.Context, rows []batchsqlc.FetchBlockOfRowsRow) (context.Context, func()) {
+	chunkCtx, cancel := context.WithCancelCause(ctx)
+	stopTimer := func() {}
+	if timeout, exists := jm.rowtimeouts[rows[0].App+rows[0].Op]; exists {
+		chunkCtx, stopTimer = context.WithTimeoutCause(chunkCtx, timeout, fmt.Errorf("%w after %v", ErrRowTimedOut, timeout))
+	}
+
+	jm.inflightmu.Lock()
+	for _, row := range rows {
+		jm.inflight[row.Rowid] = inflightRow{batch: row.Batch, cancel: cancel}
+	}
+	jm.inflightmu.Unlock()
+
+	return chunkCtx, func() {
+		jm.inflightmu.Lock()
+		for _, row := range rows {
+			delete(jm.inflight, row.Rowid)
+		}
+		jm.inflightmu.Unlock()
+		stopTimer()
+		cancel(nil)
+	}
+}
+
+// processChunk processes rows of a single batch with its BatchChunkProcessor and adds their results to
+// results, to be recorded along with those of the rest of the block. It returns the new status of each
+// row. If the processor panics, every row of the chunk is moved to the dead-letter state along with the
+// stack trace.
+func (jm *JobManager) processChunk(ctx context.Context, txQueries batchsqlc.Querier, rows []batchsqlc.FetchBlockOfRowsRow, results *rowResults) (statuses []batchsqlc.StatusEnum, err error) {
+	first := rows[0]
+	statuses = make([]batchsqlc.StatusEnum, len(rows))
+	failAll := func(err error) ([]batchsqlc.StatusEnum, error) {
+		for i := range statuses {
+			statuses[i] = batchsqlc.StatusEnumFailed
+		}
+		return statuses, err
+	}
+
+	processor, isChunker := jm.batchprocessorfuncs[first.App+first.Op].(batchChunker)
+	if !isChunker {
+		return failAll(fmt.Errorf("no BatchChunkProcessor registered for app %s and op %s", first.App, first.Op))
+	}
+
+	// Get or create the initblock for the app
+	initBlock, err := jm.getOrCreateInitBlock(first.App)
+	if err != nil {
+		log.Printf("error getting or creating initblock for app %s: %v", first.App, err)
+		return failAll(err)
+	}
+
+	batchContext, err := NewJSONstr(string(first.Context))
+	if err != nil {
+		return failAll(fmt.Errorf("error processing batch chunk for app %s and op %s: %v", first.App, first.Op, err))
+	}
+	chunkRows := make([]BatchChunkRow_t, len(rows))
+	for i, row := range rows {
+		input, err := NewJSONstr(string(row.Input))
+		if err != nil {
+			return failAll(fmt.Errorf("error processing batch chunk for app %s and op %s: %v", first.App, first.Op, err))
+		}
+		chunkRows[i] = BatchChunkRow_t{Line: int(row.Line), Input: input}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("chunk processor for app %s and op %s panicked on %d rows of batch %s: %v", first.App, first.Op, len(rows), first.Batch, r)
+			trace := string(debug.Stack())
+			err = nil
+			for i, row := range rows {
+				var dlErr error
+				statuses[i], dlErr = jm.deadLetterRow(txQueries, row, fmt.Errorf("panic: %v", r), trace)
+				if dlErr != nil {
+					err = dlErr
+				}
+			}
+		}
+	}()
+
+	chunkCtx, done := jm.startChunk(ctx, rows)
+	defer done()
+	chunkResults, err := processor.DoBatchChunk(chunkCtx, initBlock, batchContext, chunkRows)
+	if cause := rowCancelCause(chunkCtx, err); cause != nil {
+		if errors.Is(cause, ErrRowAborted) {
+			// BatchAbort has already recorded the rows as aborted
+			log.Printf("batch chunk for app %s and op %s aborted while being processed", first.App, first.Op)
+			for i := range statuses {
+				statuses[i] = batchsqlc.StatusEnumAborted
+			}
+			return statuses, nil
+		}
+		if !errors.Is(cause, ErrRowTimedOut) {
+			// The JobManager is shutting down, leave the rows to another instance
+			for i, row := range rows {
+				statuses[i] = batchsqlc.StatusEnumQueued
+				if releaseErr := jm.releaseRow(txQueries, row, cause); releaseErr != nil {
+					err = releaseErr
+				}
+			}
+			return statuses, err
+		}
+		err = cause
+	}
+
+	resultsByLine := make(map[int]BatchChunkResult_t, len(chunkResults))
+	for _, result := range chunkResults {
+		resultsByLine[result.Line] = result
+	}
+
+	// Rows which are retried or dead-lettered are updated one by one, the others with the block
+	for i, row := range rows {
+		result, exists := resultsByLine[int(row.Line)]
+		rowErr := err
+		if rowErr == nil {
+			rowErr = result.Err
+			if !exists {
+				rowErr = fmt.Errorf("no result returned for line %d", row.Line)
+			} else if rowErr == nil && result.Status == "" {
+				rowErr = fmt.Errorf("no status returned for line %d", row.Line)
+			}
+		}
+		if rowErr != nil {
+			status, handled, handleErr := jm.handleRowError(txQueries, row, rowErr)
+			if handled {
+				statuses[i] = status
+				if handleErr != nil {
+					log.Printf("error handling failed row %d of batch %s: %v", row.Rowid, row.Batch, handleErr)
+				}
+				continue
+			}
+			log.Printf("error processing batch chunk for app %s and op %s, line %d: %v", row.App, row.Op, row.Line, rowErr)
+			result = BatchChunkResult_t{Line: int(row.Line), Status: batchsqlc.StatusEnumFailed, Result: result.Result, Messages: append(result.Messages, rowErrorMessage(rowErr))}
+		}
+		results.add(row, result)
+		statuses[i] = result.Status
+	}
+	return statuses, nil
+}
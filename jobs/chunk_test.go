@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoChunkProcessor returns the input of each row as its result. It fails the rows whose input is
+// "fail", returns an error for those whose input is "error" and no result for those whose input is
+// "skip".
+type echoChunkProcessor struct {
+	echoBatchProcessor
+	chunkSizes chan int
+}
+
+func (p *echoChunkProcessor) DoBatchChunk(ctx context.Context, initBlock InitBlock, batchctx JSONstr, rows []BatchChunkRow_t) ([]BatchChunkResult_t, error) {
+	p.chunkSizes <- len(rows)
+	var results []BatchChunkResult_t
+	for _, row := range rows {
+		result := BatchChunkResult_t{Line: row.Line, Status: batchsqlc.StatusEnumSuccess, Result: row.Input}
+		switch row.Input.String() {
+		case `"fail"`:
+			result.Status = batchsqlc.StatusEnumFailed
+		case `"error"`:
+			result.Err = errors.New("downstream unavailable")
+		case `"skip"`:
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func TestBatchChunkProcessor(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	p := &echoChunkProcessor{
+		echoBatchProcessor: echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)},
+		chunkSizes:         make(chan int, 10),
+	}
+	assert.NoError(t, jm.RegisterProcessorBatchChunk("app1", "chunk", p))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for i, value := range []string{`"a"`, `"fail"`, `"b"`, `"error"`, `"skip"`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "chunk", batchctx, input, false)
+	assert.NoError(t, err)
+	runJobManager(t, jm)
+
+	status, output, nsuccess, nfailed := waitForBatch(t, jm, batchID)
+	assert.Equal(t, batchsqlc.StatusEnumFailed, status)
+	assert.Equal(t, 2, nsuccess)
+	assert.Equal(t, 3, nfailed)
+	for _, row := range output {
+		switch row.Line {
+		case 1, 3:
+			assert.Equal(t, BatchSuccess, row.Status)
+		default:
+			assert.Equal(t, BatchFailed, row.Status)
+		}
+	}
+	assert.Equal(t, 5, <-p.chunkSizes, "all the rows of the batch are processed in one chunk")
+	<-p.markDoneCalled
+}
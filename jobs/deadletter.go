@@ -0,0 +1,209 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/wscutils"
+	"github.com/remiges-tech/logharbour/logharbour"
+)
+
+// DeadLetter_t describes a row which has been moved to the dead-letter state, either because the
+// processor panicked while processing it, or because it kept failing until its retry policy was
+// exhausted, or because the instances which took it up repeatedly died before recording a result.
+type DeadLetter_t struct {
+	RowID    int64
+	BatchID  string
+	App      string
+	Op       string
+	Line     int // 0 for slow queries
+	Input    JSONstr
+	Attempts int
+	LastErr  string
+	ErrTrace string // stack trace captured when the processor panicked
+	DeadAt   time.Time
+}
+
+// retriesExhausted reports whether a row for which the processor returned err has failed on every
+// attempt allowed by the retry policy for its (app, op). Errors which the policy does not consider
+// retryable, and rows of an (app, op) which is only attempted once, are not counted as exhausted.
+func (jm *JobManager) retriesExhausted(row batchsqlc.FetchBlockOfRowsRow, err error) bool {
+	policy, exists := jm.retrypolicies[row.App+row.Op]
+	if !exists || policy.MaxAttempts < 2 {
+		return false
+	}
+	attempt := int(row.Attempts) + 1
+	return attempt >= policy.MaxAttempts && (policy.IsRetryable == nil || policy.IsRetryable(err))
+}
+
+// deadLetterRow moves a row leased to this instance to the dead-letter state, recording err and
+// the stack trace (if any) with it. For a slow query the batch is marked failed as well, since
+// it has no other rows which could complete it. A row which is no longer leased to this instance,
+// because it was aborted or its lease was reclaimed, is left as it is and reported as inprog.
+func (jm *JobManager) deadLetterRow(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow, err error, trace string) (batchsqlc.StatusEnum, error) {
+	messagesJSON, marshalErr := json.Marshal([]wscutils.ErrorMessage{rowErrorMessage(err)})
+	if marshalErr != nil {
+		return batchsqlc.StatusEnumFailed, fmt.Errorf("failed to marshal messages to JSON: %v", marshalErr)
+	}
+
+	now := pgtype.Timestamp{Time: time.Now(), Valid: true}
+	updated, updateErr := txQueries.DeadLetterBatchRow(context.Background(), batchsqlc.DeadLetterBatchRowParams{
+		Rowid:    row.Rowid,
+		Doneat:   now,
+		Messages: messagesJSON,
+		Lasterr:  pgtype.Text{String: err.Error(), Valid: true},
+		Errtrace: pgtype.Text{String: trace, Valid: trace != ""},
+		Doneby:   jm.doneBy(),
+	})
+	if updateErr != nil {
+		return batchsqlc.StatusEnumFailed, fmt.Errorf("failed to dead-letter row %d: %v", row.Rowid, updateErr)
+	}
+	if updated == 0 {
+		log.Printf("Row %d of batch %s is no longer leased to this instance and was not dead-lettered: %v", row.Rowid, row.Batch, err)
+		return batchsqlc.StatusEnumInprog, nil
+	}
+
+	if row.Line == 0 {
+		updateErr = txQueries.UpdateBatchResult(context.Background(), batchsqlc.UpdateBatchResultParams{
+			Status: batchsqlc.StatusEnumFailed,
+			Doneat: now,
+			ID:     row.Batch,
+		})
+		if updateErr != nil {
+			return batchsqlc.StatusEnumFailed, fmt.Errorf("failed to update status of batch %s: %v", row.Batch, updateErr)
+		}
+		jm.cacheStatus(row.Batch, CachedStatus{Status: batchsqlc.StatusEnumFailed})
+		jm.publishEvent(slowQueryDoneEvent(row.Batch, batchsqlc.StatusEnumFailed))
+	}
+
+	if jm.Logger != nil {
+		jm.Logger.LogDataChange("Batch row moved to dead-letter state", logharbour.ChangeInfo{
+			Entity: "BatchRow",
+			Op:     "DeadLettered",
+			Changes: []logharbour.ChangeDetail{
+				{Field: "status", OldVal: batchsqlc.StatusEnumInprog, NewVal: batchsqlc.StatusEnumDeadletter},
+			},
+		})
+	}
+	log.Printf("Row %d of batch %s moved to dead-letter state after %d attempts: %v", row.Rowid, row.Batch, row.Attempts+1, err)
+	return batchsqlc.StatusEnumDeadletter, nil
+}
+
+// DeadLetterList returns the dead-lettered rows of batch jobs and slow queries for the given
+// (app, op), in the order in which they were submitted.
+// The 'op' parameter is case-insensitive.
+func (jm *JobManager) DeadLetterList(app, op string) ([]DeadLetter_t, error) {
+	rows, err := jm.Queries.ListDeadLetterRows(context.Background(), batchsqlc.ListDeadLetterRowsParams{
+		App: app,
+		Op:  strings.ToLower(op),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-lettered rows: %v", err)
+	}
+
+	deadLetters := make([]DeadLetter_t, 0, len(rows))
+	for _, row := range rows {
+		dl, err := newDeadLetter(batchsqlc.GetDeadLetterRowRow(row))
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, dl)
+	}
+	return deadLetters, nil
+}
+
+// DeadLetterGet returns a single dead-lettered row, including the error and stack trace recorded with it.
+func (jm *JobManager) DeadLetterGet(rowID int64) (DeadLetter_t, error) {
+	row, err := jm.Queries.GetDeadLetterRow(context.Background(), rowID)
+	if err != nil {
+		return DeadLetter_t{}, fmt.Errorf("failed to get dead-lettered row %d: %v", rowID, err)
+	}
+	return newDeadLetter(row)
+}
+
+// DeadLetterRequeue puts dead-lettered rows back in the queue with a fresh attempt counter, typically
+// after a fix for the cause of their failure has been deployed. The batches they belong to are reopened
+// so that they are summarized again once the requeued rows are done, and their cached status is removed
+// from the StatusCache. A batch which is paused or waiting keeps its status. Rows which are not dead-lettered, or which belong to an aborted batch, are skipped.
+// It returns the number of rows requeued.
+func (jm *JobManager) DeadLetterRequeue(rowIDs []int64) (int, error) {
+	if len(rowIDs) == 0 {
+		return 0, nil
+	}
+
+	tx, err := jm.Store.Begin(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
+
+	// Lock the batches before their rows are requeued and their counters recounted, in the same order
+	// as processBlock, so that results recorded meanwhile by a worker are not lost from the counters
+	batchIDs, err := txQueries.GetDeadLetterBatches(context.Background(), rowIDs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get batches of dead-lettered rows: %v", err)
+	}
+	for _, batchID := range batchIDs {
+		if _, err := txQueries.GetBatchByID(context.Background(), batchID); err != nil {
+			return 0, fmt.Errorf("failed to lock batch %s: %v", batchID, err)
+		}
+	}
+
+	requeued, err := txQueries.RequeueDeadLetterRows(context.Background(), rowIDs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue dead-lettered rows: %v", err)
+	}
+
+	batchSet := make(map[uuid.UUID]bool)
+	for _, row := range requeued {
+		batchSet[row.Batch] = true
+	}
+	for batchID := range batchSet {
+		if err := txQueries.ReopenBatch(context.Background(), batchID); err != nil {
+			return 0, fmt.Errorf("failed to reopen batch %s: %v", batchID, err)
+		}
+		if err := notifyBatchQueued(context.Background(), txQueries, batchID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	// Remove the final status cached for the reopened batches
+	for batchID := range batchSet {
+		jm.uncacheStatus(batchID)
+	}
+
+	log.Printf("Requeued %d dead-lettered rows of %d batches", len(requeued), len(batchSet))
+	return len(requeued), nil
+}
+
+func newDeadLetter(row batchsqlc.GetDeadLetterRowRow) (DeadLetter_t, error) {
+	input, err := NewJSONstr(string(row.Input))
+	if err != nil {
+		return DeadLetter_t{}, fmt.Errorf("failed to parse input JSON for row %d: %v", row.Rowid, err)
+	}
+	return DeadLetter_t{
+		RowID:    row.Rowid,
+		BatchID:  row.Batch.String(),
+		App:      row.App,
+		Op:       row.Op,
+		Line:     int(row.Line),
+		Input:    input,
+		Attempts: int(row.Attempts),
+		LastErr:  row.Lasterr.String,
+		ErrTrace: row.Errtrace.String,
+		DeadAt:   row.Doneat.Time,
+	}, nil
+}
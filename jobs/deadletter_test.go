@@ -0,0 +1,259 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc/mocks"
+	"github.com/remiges-tech/alya/wscutils"
+	"github.com/stretchr/testify/assert"
+)
+
+// panickingBatchProcessor panics on every row
+type panickingBatchProcessor struct{}
+
+func (p *panickingBatchProcessor) DoBatchJob(initBlock InitBlock, context JSONstr, line int, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	var accounts map[string]int
+	accounts["x"]++ // assignment to entry in nil map
+	return batchsqlc.StatusEnumSuccess, JSONstr{}, nil, nil, nil
+}
+
+func (p *panickingBatchProcessor) MarkDone(initBlock InitBlock, context JSONstr, details BatchDetails_t) error {
+	return nil
+}
+
+// failingBatchProcessor returns an error for every row
+type failingBatchProcessor struct{}
+
+func (p *failingBatchProcessor) DoBatchJob(initBlock InitBlock, context JSONstr, line int, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	return batchsqlc.StatusEnumFailed, JSONstr{}, nil, nil, errors.New("ledger unavailable")
+}
+
+func (p *failingBatchProcessor) MarkDone(initBlock InitBlock, context JSONstr, details BatchDetails_t) error {
+	return nil
+}
+
+func newDeadLetterQuerierMock() *mocks.QuerierMock {
+	return &mocks.QuerierMock{
+		DeadLetterBatchRowFunc: func(ctx context.Context, arg batchsqlc.DeadLetterBatchRowParams) (int64, error) {
+			return 1, nil
+		},
+		RetryBatchRowFunc: func(ctx context.Context, arg batchsqlc.RetryBatchRowParams) error {
+			return nil
+		},
+	}
+}
+
+func TestProcessRowRecoversPanic(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+	assert.NoError(t, jm.RegisterInitializer("app1", &MockInitializer{}))
+	assert.NoError(t, jm.RegisterProcessorBatch("app1", "op1", &panickingBatchProcessor{}))
+
+	mockQuerier := newDeadLetterQuerierMock()
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Batch: uuid.New(), Rowid: 7, Line: 3}
+
+	status, err := jm.processRow(context.Background(), mockQuerier, row, &rowResults{})
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumDeadletter, status)
+
+	assert.Len(t, mockQuerier.DeadLetterBatchRowCalls(), 1)
+	call := mockQuerier.DeadLetterBatchRowCalls()[0].Arg
+	assert.Equal(t, int64(7), call.Rowid)
+	assert.Equal(t, jm.WorkerID, call.Doneby.String)
+	assert.Contains(t, call.Lasterr.String, "assignment to entry in nil map")
+	assert.True(t, call.Errtrace.Valid)
+	assert.Contains(t, call.Errtrace.String, "DoBatchJob")
+	assert.Contains(t, string(call.Messages), ErrcodeRowProcessingFailed)
+}
+
+func TestProcessRowDeadLettersExhaustedRetries(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+	assert.NoError(t, jm.RegisterInitializer("app1", &MockInitializer{}))
+	assert.NoError(t, jm.RegisterProcessorBatch("app1", "op1", &failingBatchProcessor{}))
+	assert.NoError(t, jm.RegisterRetryPolicy("app1", "op1", RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}))
+
+	mockQuerier := newDeadLetterQuerierMock()
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Batch: uuid.New(), Rowid: 7, Line: 3, Attempts: 1}
+
+	// Second attempt: the row is retried
+	status, err := jm.processRow(context.Background(), mockQuerier, row, &rowResults{})
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumQueued, status)
+	assert.Len(t, mockQuerier.DeadLetterBatchRowCalls(), 0)
+
+	// Last attempt: the row is dead-lettered without a stack trace
+	row.Attempts = 2
+	status, err = jm.processRow(context.Background(), mockQuerier, row, &rowResults{})
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumDeadletter, status)
+	assert.Len(t, mockQuerier.DeadLetterBatchRowCalls(), 1)
+	call := mockQuerier.DeadLetterBatchRowCalls()[0].Arg
+	assert.Equal(t, "ledger unavailable", call.Lasterr.String)
+	assert.False(t, call.Errtrace.Valid)
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+	assert.NoError(t, jm.RegisterRetryPolicy("app1", "op1", RetryPolicy{
+		MaxAttempts: 2,
+		IsRetryable: func(err error) bool { return errors.Is(err, errTimeout) },
+	}))
+	assert.NoError(t, jm.RegisterRetryPolicy("app1", "op2", RetryPolicy{MaxAttempts: 1}))
+
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Attempts: 1}
+	assert.True(t, jm.retriesExhausted(row, errTimeout))
+	// Errors which are not retryable are business failures, not poison rows
+	assert.False(t, jm.retriesExhausted(row, errors.New("invalid account")))
+
+	row.Attempts = 0
+	assert.False(t, jm.retriesExhausted(row, errTimeout))
+
+	// Ops attempted only once, with or without a policy, are never dead-lettered for failing
+	assert.False(t, jm.retriesExhausted(batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op2"}, errTimeout))
+	assert.False(t, jm.retriesExhausted(batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op3", Attempts: 5}, errTimeout))
+}
+
+func TestDeadLetterRowNoLongerLeased(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "statement", echoSlowQueryProcessor{}))
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"account":"A1"}`)
+	reqID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, input)
+	assert.NoError(t, err)
+	rows, err := jm.fetchBlock(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+
+	// A slow query aborted before its row is dead-lettered stays aborted
+	assert.NoError(t, jm.SlowQueryAbort(reqID))
+	status, err := jm.deadLetterRow(jm.Queries, rows[0], errors.New("ledger unavailable"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumInprog, status)
+	batch, err := jm.Queries.GetBatchByID(context.Background(), uuid.MustParse(reqID))
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumAborted, batch.Status)
+}
+
+func TestReclaimExpiredLeasesRetryPolicy(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	jm.Config.LeaseDurSec = -1 // leases expire as soon as they are taken
+	batchIDs := make(map[string]uuid.UUID)
+	for op, maxAttempts := range map[string]int{"once": 1, "twice": 2} {
+		p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+		assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", op, p))
+		assert.NoError(t, jm.RegisterRetryPolicy("app1", op, RetryPolicy{MaxAttempts: maxAttempts}))
+		batchctx, _ := NewJSONstr(`{}`)
+		input, _ := NewJSONstr(`1`)
+		batchID, err := jm.BatchSubmit("app1", op, batchctx, []BatchInput_t{{Line: 1, Input: input}}, false)
+		assert.NoError(t, err)
+		batchIDs[op] = uuid.MustParse(batchID)
+	}
+
+	// Releasing the rows on shutdown does not count as an attempt
+	rows, err := jm.fetchBlock(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	jm.releaseRows(rows)
+	rows, err = jm.fetchBlock(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+
+	// The lease of each row has expired once, which is all the retry policy of "once" allows
+	assert.NoError(t, jm.reclaimExpiredLeases())
+	for op, status := range map[string]batchsqlc.StatusEnum{"once": batchsqlc.StatusEnumDeadletter, "twice": batchsqlc.StatusEnumQueued} {
+		batchRows, err := jm.Queries.GetBatchRowsByBatchID(context.Background(), batchIDs[op])
+		assert.NoError(t, err)
+		assert.Equal(t, status, batchRows[0].Status, op)
+	}
+}
+
+func TestDeadLetterList(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+	batchID := uuid.New()
+	mockQuerier := &mocks.QuerierMock{
+		ListDeadLetterRowsFunc: func(ctx context.Context, arg batchsqlc.ListDeadLetterRowsParams) ([]batchsqlc.ListDeadLetterRowsRow, error) {
+			return []batchsqlc.ListDeadLetterRowsRow{{
+				Rowid:    9,
+				Batch:    batchID,
+				App:      arg.App,
+				Op:       arg.Op,
+				Line:     4,
+				Input:    []byte(`{"account":"A1"}`),
+				Attempts: 3,
+			}}, nil
+		},
+	}
+	jm.Queries = mockQuerier
+
+	deadLetters, err := jm.DeadLetterList("app1", "OP1")
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, "op1", mockQuerier.ListDeadLetterRowsCalls()[0].Arg.Op)
+	assert.Equal(t, int64(9), deadLetters[0].RowID)
+	assert.Equal(t, batchID.String(), deadLetters[0].BatchID)
+	assert.Equal(t, 4, deadLetters[0].Line)
+	assert.Equal(t, 3, deadLetters[0].Attempts)
+	assert.Equal(t, `{"account":"A1"}`, deadLetters[0].Input.String())
+}
+
+// fixableBatchProcessor panics on every row until it is fixed, and then echoes them
+type fixableBatchProcessor struct {
+	echoBatchProcessor
+	fixed bool
+}
+
+func (p *fixableBatchProcessor) DoBatchJob(ctx context.Context, initBlock InitBlock, batchctx JSONstr, line int, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	if !p.fixed {
+		panic("ledger unavailable")
+	}
+	return p.echoBatchProcessor.DoBatchJob(ctx, initBlock, batchctx, line, input)
+}
+
+func TestDeadLetterRequeueKeepsPausedBatch(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	jm.Config.BatchChunkNRows = 1
+	p := &fixableBatchProcessor{echoBatchProcessor: echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for i, value := range []string{`1`, `2`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, input, false)
+	assert.NoError(t, err)
+
+	// The first row is dead-lettered, and the batch is paused before the second one is processed
+	nrows, err := jm.processBlock(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, nrows)
+	assert.NoError(t, jm.BatchPause(batchID))
+	deadLetters, err := jm.DeadLetterList("app1", "echo")
+	assert.NoError(t, err)
+	if !assert.Len(t, deadLetters, 1) {
+		return
+	}
+
+	// Requeuing the row does not resume the batch
+	p.fixed = true
+	n, err := jm.DeadLetterRequeue([]int64{deadLetters[0].RowID})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	status, _, _, _, _, _, err := jm.BatchDone(batchID)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumPaused, status)
+
+	assert.NoError(t, jm.BatchResume(batchID))
+	processQueuedRows(t, jm)
+	status, _, nsuccess, nfailed := waitForBatch(t, jm, batchID)
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, status)
+	assert.Equal(t, 2, nsuccess)
+	assert.Equal(t, 0, nfailed)
+	<-p.markDoneCalled
+}
@@ -0,0 +1,257 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ALYA_NOTIFY_CHANNEL is the Postgres channel on which the submission of batches and slow queries
+// is notified, to wake up idle workers on all instances.
+const ALYA_NOTIFY_CHANNEL = "alya_jobs"
+
+// ErrEventsNeedRedis is returned by Subscribe when the JobManager has no Redis client.
+var ErrEventsNeedRedis = errors.New("batch events need a Redis client")
+
+// BatchEventType is the kind of a BatchEvent
+type BatchEventType string
+
+const (
+	BatchEventQueued   BatchEventType = "queued"   // the batch was submitted, or became available for processing again
+	BatchEventProgress BatchEventType = "progress" // some rows of the batch have been processed
+	BatchEventDone     BatchEventType = "done"     // the batch reached its final status
+	BatchEventPaused   BatchEventType = "paused"   // the batch was paused by BatchPause
+)
+
+// BatchEvent is delivered to the subscribers of a batch or slow query. In a progress event, the counts
+// are those of the rows finished by the block of rows processed by one worker; in a done event, they
+// are the totals for the batch.
+type BatchEvent struct {
+	BatchID  string               `json:"batchid"`
+	Type     BatchEventType       `json:"type"`
+	Status   batchsqlc.StatusEnum `json:"status"`
+	NSuccess int                  `json:"nsuccess"`
+	NFailed  int                  `json:"nfailed"`
+	NAborted int                  `json:"naborted"`
+	At       time.Time            `json:"at"`
+}
+
+// batchEventsChannel returns the Redis channel on which the events of a batch are published
+func (jm *JobManager) batchEventsChannel(batchID string) string {
+	return cacheKey(jm.Config.CacheNamespace, batchID, "events")
+}
+
+// Subscribe returns a channel on which the events of a batch or slow query are delivered as they are
+// published by any JobManager instance. The channel is closed after the done event, or when ctx is
+// cancelled. If the batch is already done when Subscribe is called, its done event is delivered
+// straight away. Events are delivered through Redis pub/sub, and may be dropped if the subscriber
+// does not keep up; the state in the database remains authoritative.
+func (jm *JobManager) Subscribe(ctx context.Context, batchID string) (<-chan BatchEvent, error) {
+	batchUUID, err := uuid.Parse(batchID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid batch ID: %v", err)
+	}
+	if jm.RedisClient == nil {
+		return nil, ErrEventsNeedRedis
+	}
+
+	pubsub := jm.RedisClient.Subscribe(ctx, jm.batchEventsChannel(batchID))
+	// Wait for the subscription to be confirmed, so that no event published from here on is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to events of batch %s: %v", batchID, err)
+	}
+
+	counts, err := jm.Queries.GetBatchCounts(ctx, batchUUID)
+	if err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to get status of batch %s: %v", batchID, err)
+	}
+
+	events := make(chan BatchEvent, 16)
+	go func() {
+		defer close(events)
+		defer pubsub.Close()
+
+		if isFinalStatus(counts.Status) {
+			select {
+			case events <- BatchEvent{
+				BatchID:  batchID,
+				Type:     BatchEventDone,
+				Status:   counts.Status,
+				NSuccess: int(counts.Nsuccess.Int32),
+				NFailed:  int(counts.Nfailed.Int32),
+				NAborted: int(counts.Naborted.Int32),
+				At:       time.Now(),
+			}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event BatchEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					log.Printf("Error decoding event of batch %s: %v", batchID, err)
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+				if event.Type == BatchEventDone {
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+// publishEvent publishes an event of a batch to its subscribers. Errors are logged, since the
+// events only inform subscribers of changes which are already recorded.
+func (jm *JobManager) publishEvent(event BatchEvent) {
+	if jm.RedisClient == nil {
+		return
+	}
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding event of batch %s: %v", event.BatchID, err)
+		return
+	}
+	if err := jm.RedisClient.Publish(context.Background(), jm.batchEventsChannel(event.BatchID), payload).Err(); err != nil {
+		log.Printf("Error publishing event of batch %s: %v", event.BatchID, err)
+	}
+}
+
+// publishProgress publishes a progress event for each batch job with rows in a processed block
+func (jm *JobManager) publishProgress(rows []batchsqlc.FetchBlockOfRowsRow, statuses []batchsqlc.StatusEnum) {
+	for _, event := range progressEvents(rows, statuses) {
+		jm.publishEvent(event)
+	}
+}
+
+// progressEvents returns a progress event for each batch job with rows in a processed block, counting
+// the rows of the block which finished with each status. statuses holds the outcome of each row
+// processed, which may be fewer than the rows of the block if processing was interrupted.
+func progressEvents(rows []batchsqlc.FetchBlockOfRowsRow, statuses []batchsqlc.StatusEnum) []BatchEvent {
+	events := make(map[uuid.UUID]*BatchEvent)
+	var order []uuid.UUID
+	for i, row := range rows {
+		if row.Line == 0 || i >= len(statuses) {
+			// slow queries only have a done event
+			continue
+		}
+		event, exists := events[row.Batch]
+		if !exists {
+			event = &BatchEvent{BatchID: row.Batch.String(), Type: BatchEventProgress, Status: batchsqlc.StatusEnumInprog}
+			events[row.Batch] = event
+			order = append(order, row.Batch)
+		}
+		switch statuses[i] {
+		case batchsqlc.StatusEnumSuccess:
+			event.NSuccess++
+		case batchsqlc.StatusEnumFailed, batchsqlc.StatusEnumDeadletter:
+			event.NFailed++
+		case batchsqlc.StatusEnumAborted:
+			event.NAborted++
+		}
+	}
+	progress := make([]BatchEvent, len(order))
+	for i, batchID := range order {
+		progress[i] = *events[batchID]
+	}
+	return progress
+}
+
+// notifyBatchQueued notifies the instances listening on ALYA_NOTIFY_CHANNEL that a batch has rows
+// to be processed. The notification is sent when the transaction of txQueries commits.
+func notifyBatchQueued(ctx context.Context, txQueries batchsqlc.Querier, batchID uuid.UUID) error {
+	err := txQueries.NotifyBatchQueued(ctx, batchsqlc.NotifyBatchQueuedParams{
+		Channel: ALYA_NOTIFY_CHANNEL,
+		Batch:   batchID.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to notify queued batch %s: %v", batchID, err)
+	}
+	return nil
+}
+
+// runListener listens on ALYA_NOTIFY_CHANNEL and wakes up the idle workers of this instance whenever
+// a batch is queued, on any instance. It returns when ctx is cancelled.
+func (jm *JobManager) runListener(ctx context.Context) {
+	if jm.Store == nil {
+		return
+	}
+	for ctx.Err() == nil {
+		if err := jm.Store.Listen(ctx, jm.wakeWorkers); err != nil && ctx.Err() == nil {
+			log.Printf("Error listening for queued batches: %v", err)
+			// Workers fall back to polling until the listener is back
+			sleepWithContext(ctx, time.Duration(jm.Config.HeartbeatIntervalSec)*time.Second)
+		}
+	}
+}
+
+// wakeChan returns a channel which is closed the next time wakeWorkers is called
+func (jm *JobManager) wakeChan() <-chan struct{} {
+	jm.wakemu.Lock()
+	defer jm.wakemu.Unlock()
+	return jm.wakech
+}
+
+// wakeWorkers wakes up the workers of this instance which are waiting for rows to process
+func (jm *JobManager) wakeWorkers() {
+	jm.wakemu.Lock()
+	defer jm.wakemu.Unlock()
+	close(jm.wakech)
+	jm.wakech = make(chan struct{})
+}
+
+// isFinalStatus reports whether a batch with the given status is done
+func isFinalStatus(status batchsqlc.StatusEnum) bool {
+	return status == batchsqlc.StatusEnumSuccess || status == batchsqlc.StatusEnumFailed || status == batchsqlc.StatusEnumAborted
+}
+
+// doneEvent returns the done event of a batch
+func doneEvent(batchID uuid.UUID, status batchsqlc.StatusEnum, nsuccess, nfailed, naborted int) BatchEvent {
+	return BatchEvent{
+		BatchID:  batchID.String(),
+		Type:     BatchEventDone,
+		Status:   status,
+		NSuccess: nsuccess,
+		NFailed:  nfailed,
+		NAborted: naborted,
+	}
+}
+
+// slowQueryDoneEvent returns the done event of a slow query, whose single row is counted under its status
+func slowQueryDoneEvent(reqID uuid.UUID, status batchsqlc.StatusEnum) BatchEvent {
+	event := doneEvent(reqID, status, 0, 0, 0)
+	switch status {
+	case batchsqlc.StatusEnumSuccess:
+		event.NSuccess = 1
+	case batchsqlc.StatusEnumAborted:
+		event.NAborted = 1
+	default:
+		event.NFailed = 1
+	}
+	return event
+}
@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc/mocks"
+	"github.com/remiges-tech/alya/wscutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressEvents(t *testing.T) {
+	batch1, batch2, query := uuid.New(), uuid.New(), uuid.New()
+	rows := []batchsqlc.FetchBlockOfRowsRow{
+		{Batch: batch1, Line: 1},
+		{Batch: query, Line: 0},
+		{Batch: batch2, Line: 1},
+		{Batch: batch1, Line: 2},
+		{Batch: batch1, Line: 3},
+		{Batch: batch2, Line: 2},
+	}
+	// The last row was released on shutdown, and the one before it is waiting for a retry
+	statuses := []batchsqlc.StatusEnum{
+		batchsqlc.StatusEnumSuccess,
+		batchsqlc.StatusEnumSuccess,
+		batchsqlc.StatusEnumFailed,
+		batchsqlc.StatusEnumDeadletter,
+		batchsqlc.StatusEnumQueued,
+	}
+
+	events := progressEvents(rows, statuses)
+	assert.Len(t, events, 2)
+	assert.Equal(t, BatchEvent{BatchID: batch1.String(), Type: BatchEventProgress, Status: batchsqlc.StatusEnumInprog, NSuccess: 1, NFailed: 1}, events[0])
+	assert.Equal(t, BatchEvent{BatchID: batch2.String(), Type: BatchEventProgress, Status: batchsqlc.StatusEnumInprog, NFailed: 1}, events[1])
+}
+
+func TestWakeWorkers(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+
+	wake := jm.wakeChan()
+	done := make(chan struct{})
+	go func() {
+		sleepOrWake(context.Background(), time.Minute, wake)
+		close(done)
+	}()
+
+	jm.wakeWorkers()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker was not woken up")
+	}
+
+	// A new channel is handed out for the next wait
+	select {
+	case <-jm.wakeChan():
+		t.Fatal("wake channel should only be closed by the next wakeWorkers")
+	default:
+	}
+}
+
+func TestNotifyBatchQueued(t *testing.T) {
+	batchID := uuid.New()
+	mockQuerier := &mocks.QuerierMock{
+		NotifyBatchQueuedFunc: func(ctx context.Context, arg batchsqlc.NotifyBatchQueuedParams) error {
+			return nil
+		},
+	}
+
+	assert.NoError(t, notifyBatchQueued(context.Background(), mockQuerier, batchID))
+	assert.Len(t, mockQuerier.NotifyBatchQueuedCalls(), 1)
+	assert.Equal(t, ALYA_NOTIFY_CHANNEL, mockQuerier.NotifyBatchQueuedCalls()[0].Arg.Channel)
+	assert.Equal(t, batchID.String(), mockQuerier.NotifyBatchQueuedCalls()[0].Arg.Batch)
+}
+
+func TestSubscribeNeedsRedis(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+
+	_, err := jm.Subscribe(context.Background(), uuid.New().String())
+	assert.True(t, errors.Is(err, ErrEventsNeedRedis))
+
+	_, err = jm.Subscribe(context.Background(), "not-a-uuid")
+	assert.Error(t, err)
+}
+
+// panickingSlowQueryProcessor panics on every query
+type panickingSlowQueryProcessor struct{}
+
+func (p panickingSlowQueryProcessor) DoSlowQuery(ctx context.Context, initBlock InitBlock, queryctx JSONstr, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	panic("statement template missing")
+}
+
+func TestSubscribeSlowQueryDone(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	jm.RedisClient = redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "echo", echoSlowQueryProcessor{}))
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "panic", panickingSlowQueryProcessor{}))
+
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"n":1}`)
+	succeededID, err := jm.SlowQuerySubmit("app1", "echo", queryctx, input)
+	assert.NoError(t, err)
+	deadID, err := jm.SlowQuerySubmit("app1", "panic", queryctx, input)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	succeededEvents, err := jm.Subscribe(ctx, succeededID)
+	assert.NoError(t, err)
+	deadEvents, err := jm.Subscribe(ctx, deadID)
+	assert.NoError(t, err)
+
+	// A slow query which finishes, or whose row is dead-lettered, ends with a done event
+	processQueuedRows(t, jm)
+	for events, want := range map[<-chan BatchEvent]BatchEvent{
+		succeededEvents: {BatchID: succeededID, Type: BatchEventDone, Status: batchsqlc.StatusEnumSuccess, NSuccess: 1},
+		deadEvents:      {BatchID: deadID, Type: BatchEventDone, Status: batchsqlc.StatusEnumFailed, NFailed: 1},
+	} {
+		var last BatchEvent
+		for event := range events {
+			last = event
+		}
+		last.At = time.Time{}
+		assert.Equal(t, want, last)
+	}
+}
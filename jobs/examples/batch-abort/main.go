@@ -125,7 +125,7 @@ func main() {
 	fmt.Println("Batch submitted. Batch ID:", batchID)
 
 	// Start the JobManager in a separate goroutine
-	go jm.Run()
+	go jm.Run(context.Background())
 
 	// Wait for a short duration before aborting the batch
 	time.Sleep(15 * time.Second)
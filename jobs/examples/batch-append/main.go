@@ -137,7 +137,7 @@ func main() {
 
 	// Start the JobManager in a separate goroutine
 	fmt.Println("Starting the JobManager...")
-	go jm.Run()
+	go jm.Run(context.Background())
 
 	// sleep for 1 minute
 	fmt.Println("Sleeping for 1 minute...")
@@ -198,7 +198,7 @@ func main() {
 	fmt.Println("Batch submitted. Batch ID:", batchID)
 
 	// Start the JobManager in a separate goroutine
-	go jm.Run()
+	go jm.Run(context.Background())
 
 	// Poll for the batch completion status
 	for {
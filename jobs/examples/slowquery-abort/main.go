@@ -117,9 +117,9 @@ func main() {
 	}
 
 	// Submit a slow query request
-	context, _ := jobs.NewJSONstr(`{"userId": 123}`)
+	reqContext, _ := jobs.NewJSONstr(`{"userId": 123}`)
 	input, _ := jobs.NewJSONstr(`{"startDate": "2023-01-01", "endDate": "2023-12-31"}`)
-	reqID, err := jm.SlowQuerySubmit("broadside", "slowreport", context, input)
+	reqID, err := jm.SlowQuerySubmit("broadside", "slowreport", reqContext, input)
 	if err != nil {
 		fmt.Println("Failed to submit slow query:", err)
 		return
@@ -128,7 +128,7 @@ func main() {
 	fmt.Println("Slow query submitted. Request ID:", reqID)
 
 	// Start the JobManager in a separate goroutine
-	go jm.Run()
+	go jm.Run(context.Background())
 
 	// Wait for a short duration before aborting the slow query
 	time.Sleep(5 * time.Second)
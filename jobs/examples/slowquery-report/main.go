@@ -178,9 +178,9 @@ func main() {
 	}
 
 	// Submit a slow query request
-	context, _ := jobs.NewJSONstr(`{"userId": 123}`)
+	reqContext, _ := jobs.NewJSONstr(`{"userId": 123}`)
 	input, _ := jobs.NewJSONstr(`{"startDate": "2023-01-01", "endDate": "2023-12-31"}`)
-	reqID, err := jm.SlowQuerySubmit("broadside", "bouncerpt", context, input)
+	reqID, err := jm.SlowQuerySubmit("broadside", "bouncerpt", reqContext, input)
 	if err != nil {
 		fmt.Println("Failed to submit slow query:", err)
 		return
@@ -189,7 +189,7 @@ func main() {
 	fmt.Println("Slow query submitted. Request ID:", reqID)
 
 	// Start the JobManager in a separate goroutine
-	go jm.Run()
+	go jm.Run(context.Background())
 
 	// Poll for the slow query result
 	for {
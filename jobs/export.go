@@ -0,0 +1,411 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/wscutils"
+)
+
+// ALYA_EXPORT_PAGESIZE is the number of results read at a time by BatchExport
+const ALYA_EXPORT_PAGESIZE = 1000
+
+// ErrExportMappingAlreadyRegistered is returned by RegisterExportMapping when a mapping is already
+// registered for the (app, op).
+var ErrExportMappingAlreadyRegistered = errors.New("export mapping already registered for this app and op")
+
+// ErrExportTooLarge is returned by BatchExport when a batch has more rows than fit in the format.
+var ErrExportTooLarge = errors.New("batch has too many rows for the export format")
+
+// ExportFormat is the format of a results file written by BatchExport
+type ExportFormat string
+
+const (
+	ExportCSV   ExportFormat = "csv"   // comma-separated values, with a header line
+	ExportJSONL ExportFormat = "jsonl" // JSON Lines, one object per row with the columns as keys
+	ExportXLSX  ExportFormat = "xlsx"  // Excel workbook with a single sheet, with a header row
+)
+
+// xlsxMaxRows is the number of rows in an Excel worksheet, including the header row
+const xlsxMaxRows = 1048576
+
+// ExportColumn is a column of a results file, taken from a field of the result of each row.
+type ExportColumn struct {
+	Name  string // header of the column
+	Field string // field of the result, with the names of nested fields separated by dots
+}
+
+// ExportMapping sets how the results of the rows of an (app, op) are flattened into the columns of
+// the results files written by BatchExport.
+type ExportMapping struct {
+	// Columns are taken from the result of each row, between its line and status and its messages.
+	// If there are none, the whole result is written in a single "result" column.
+	Columns []ExportColumn
+	// Translate returns the text of an error message of a row, in the language of the users of the
+	// results file. If it is nil, the error code is written along with the field and values.
+	Translate func(msg wscutils.ErrorMessage) string
+}
+
+// RegisterExportMapping registers the mapping used by BatchExport for the batches of an (app, op).
+// Batches of an (app, op) without a mapping are exported with their results as they are.
+// Each (app, op) combination can only have one registered export mapping.
+// The 'op' parameter is case-insensitive and will be converted to lowercase before registration.
+func (jm *JobManager) RegisterExportMapping(app string, op string, mapping ExportMapping) error {
+	op = strings.ToLower(op)
+
+	key := app + op
+	if _, exists := jm.exportmappings[key]; exists {
+		return fmt.Errorf("%w: app=%s, op=%s", ErrExportMappingAlreadyRegistered, app, op)
+	}
+	jm.exportmappings[key] = mapping
+	return nil
+}
+
+// BatchExport writes the results of the rows of a batch which is done to a results file in the given
+// format: the line and status of each row, the columns of the export mapping registered for its
+// (app, op), and its translated error messages. The rows are read from batchrows a page at a time,
+// ordered by line, and written to a temporary file, which is then uploaded to the object store. The
+// file is recorded in the outputfiles of the batch as "results.<format>", along with the files built
+// from blobrows, and its object ID is returned.
+//
+// Output files are replaced when a batch is summarized again after BatchRetry, so a batch which has
+// been retried needs to be exported again.
+func (jm *JobManager) BatchExport(batchID string, format ExportFormat) (objectID string, err error) {
+	batchUUID, err := uuid.Parse(batchID)
+	if err != nil {
+		return "", fmt.Errorf("invalid batch ID: %v", err)
+	}
+
+	batch, err := jm.Queries.GetBatchByID(context.Background(), batchUUID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get batch by ID: %v", err)
+	}
+	if !isFinalStatus(batch.Status) {
+		return "", fmt.Errorf("%w: batch %s is %s", ErrBatchNotDone, batchID, batch.Status)
+	}
+	if format == ExportXLSX && int(batch.Nrows) >= xlsxMaxRows {
+		return "", fmt.Errorf("%w: %d rows in batch %s", ErrExportTooLarge, batch.Nrows, batchID)
+	}
+
+	file, err := os.CreateTemp("", "batch-export-*."+string(format))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	mapping := jm.exportmappings[batch.App+batch.Op]
+	if err := jm.writeResults(file, batchID, format, mapping); err != nil {
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write results file: %v", err)
+	}
+
+	objectID, err = moveToObjectStore(file.Name(), jm.ObjStore, "batch-output", format.contentType())
+	if err != nil {
+		return "", err
+	}
+	if err := jm.addOutputFile(batchUUID, "results."+string(format), objectID); err != nil {
+		return "", err
+	}
+
+	log.Printf("Exported results of batch %s to %s", batchID, objectID)
+	return objectID, nil
+}
+
+// writeResults writes the results of the rows of a batch to w in the given format
+func (jm *JobManager) writeResults(w io.Writer, batchID string, format ExportFormat, mapping ExportMapping) error {
+	var rw resultWriter
+	switch format {
+	case ExportCSV:
+		rw = &csvResultWriter{w: csv.NewWriter(w)}
+	case ExportJSONL:
+		rw = &jsonlResultWriter{w: w}
+	case ExportXLSX:
+		rw = &xlsxResultWriter{w: newXLSXWriter(w)}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	names := []string{"line", "status"}
+	if len(mapping.Columns) == 0 {
+		names = append(names, "result")
+	}
+	for _, column := range mapping.Columns {
+		names = append(names, column.Name)
+	}
+	names = append(names, "messages")
+	if err := rw.writeHeader(names); err != nil {
+		return fmt.Errorf("failed to write results file: %v", err)
+	}
+
+	it := jm.BatchResultIter(batchID, &BatchResultOptions{PageSize: ALYA_EXPORT_PAGESIZE})
+	for it.Next() {
+		values, err := exportValues(it.Result(), mapping)
+		if err != nil {
+			return err
+		}
+		if err := rw.writeRecord(values); err != nil {
+			return fmt.Errorf("failed to write results file: %v", err)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	if err := rw.close(); err != nil {
+		return fmt.Errorf("failed to write results file: %v", err)
+	}
+	return nil
+}
+
+// exportValues flattens the result of a row into the values of its columns. Values taken from the
+// result are kept as decoded from JSON, so that JSON Lines files keep their types.
+func exportValues(result BatchOutput_t, mapping ExportMapping) ([]any, error) {
+	values := []any{result.Line, statusName(result.Status)}
+
+	var res any
+	if resJSON := result.Res.String(); resJSON != "" {
+		decoder := json.NewDecoder(strings.NewReader(resJSON))
+		decoder.UseNumber()
+		if err := decoder.Decode(&res); err != nil {
+			return nil, fmt.Errorf("failed to parse Res JSON for line %d: %v", result.Line, err)
+		}
+	}
+	if len(mapping.Columns) == 0 {
+		values = append(values, res)
+	}
+	for _, column := range mapping.Columns {
+		values = append(values, lookupField(res, column.Field))
+	}
+
+	messages, err := decodeMessages(result.Messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Messages JSON for line %d: %v", result.Line, err)
+	}
+	texts := make([]string, len(messages))
+	for i, msg := range messages {
+		texts[i] = translateMessage(msg, mapping.Translate)
+	}
+	return append(values, texts), nil
+}
+
+// lookupField returns the value of a field of a decoded JSON object, or nil if it has no such field
+func lookupField(value any, field string) any {
+	for _, name := range strings.Split(field, ".") {
+		object, isObject := value.(map[string]any)
+		if !isObject {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
+
+// translateMessage returns the text of an error message, through translate if it is not nil
+func translateMessage(msg wscutils.ErrorMessage, translate func(wscutils.ErrorMessage) string) string {
+	if translate != nil {
+		return translate(msg)
+	}
+	text := msg.ErrCode
+	if msg.Field != "" {
+		text += " (" + msg.Field + ")"
+	}
+	if len(msg.Vals) > 0 {
+		text += ": " + strings.Join(msg.Vals, ", ")
+	}
+	return text
+}
+
+// statusName returns the name of a row status in a results file
+func statusName(status BatchStatus_t) string {
+	switch status {
+	case BatchSuccess:
+		return "success"
+	case BatchFailed:
+		return "failed"
+	case BatchAborted:
+		return "aborted"
+	case BatchQueued:
+		return "queued"
+	case BatchInProgress:
+		return "inprog"
+	case BatchDeadLetter:
+		return "deadletter"
+	case BatchWait:
+		return "wait"
+	case BatchPaused:
+		return "paused"
+	default:
+		return "unknown"
+	}
+}
+
+// exportCell formats a value of a column as the text of a CSV field or spreadsheet cell
+func exportCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, "; ")
+	case map[string]any, []any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (f ExportFormat) contentType() string {
+	switch f {
+	case ExportCSV:
+		return "text/csv"
+	case ExportJSONL:
+		return "application/jsonl"
+	case ExportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// addOutputFile records an output file of a batch under the given name, keeping the other ones
+func (jm *JobManager) addOutputFile(batchID uuid.UUID, name, objectID string) error {
+	tx, err := jm.Store.Begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
+
+	// Lock the batch, so that output files added at the same time are not lost
+	batch, err := txQueries.GetBatchByID(context.Background(), batchID)
+	if err != nil {
+		return fmt.Errorf("failed to get batch by ID: %v", err)
+	}
+	outputFiles := make(map[string]string)
+	if len(batch.Outputfiles) > 0 {
+		if err := json.Unmarshal(batch.Outputfiles, &outputFiles); err != nil {
+			return fmt.Errorf("failed to parse output files of batch %s: %v", batchID, err)
+		}
+	}
+	outputFiles[name] = objectID
+	outputFilesJSON, err := json.Marshal(outputFiles)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output files: %v", err)
+	}
+
+	err = txQueries.UpdateBatchOutputFiles(context.Background(), batchsqlc.UpdateBatchOutputFilesParams{
+		ID:          batchID,
+		Outputfiles: outputFilesJSON,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update output files of batch %s: %v", batchID, err)
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// resultWriter writes the rows of a results file in one of the export formats
+type resultWriter interface {
+	writeHeader(names []string) error
+	writeRecord(values []any) error
+	close() error
+}
+
+type csvResultWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvResultWriter) writeHeader(names []string) error {
+	return c.w.Write(names)
+}
+
+func (c *csvResultWriter) writeRecord(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = exportCell(value)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvResultWriter) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlResultWriter writes each row as an object whose keys are the column names, in column order
+type jsonlResultWriter struct {
+	w     io.Writer
+	names [][]byte
+	buf   bytes.Buffer
+}
+
+func (j *jsonlResultWriter) writeHeader(names []string) error {
+	j.names = make([][]byte, len(names))
+	for i, name := range names {
+		j.names[i], _ = json.Marshal(name)
+	}
+	return nil
+}
+
+func (j *jsonlResultWriter) writeRecord(values []any) error {
+	j.buf.Reset()
+	j.buf.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			j.buf.WriteByte(',')
+		}
+		j.buf.Write(j.names[i])
+		j.buf.WriteByte(':')
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		j.buf.Write(b)
+	}
+	j.buf.WriteString("}\n")
+	_, err := j.w.Write(j.buf.Bytes())
+	return err
+}
+
+func (j *jsonlResultWriter) close() error {
+	return nil
+}
+
+type xlsxResultWriter struct {
+	w *xlsxWriter
+}
+
+func (x *xlsxResultWriter) writeHeader(names []string) error {
+	return x.w.writeRow(names)
+}
+
+func (x *xlsxResultWriter) writeRecord(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = exportCell(value)
+	}
+	return x.w.writeRow(record)
+}
+
+func (x *xlsxResultWriter) close() error {
+	return x.w.close()
+}
@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/remiges-tech/alya/jobs/objstore"
+	"github.com/remiges-tech/alya/wscutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchExport(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	objects := make(map[string][]byte)
+	jm.ObjStore = &objstore.ObjectStoreMock{
+		PutFunc: func(ctx context.Context, bucket, obj string, reader io.Reader, size int64, contentType string) error {
+			data, err := io.ReadAll(reader)
+			objects[obj] = data
+			return err
+		},
+	}
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+	mapping := ExportMapping{
+		Columns: []ExportColumn{{Name: "account", Field: "acct.id"}, {Name: "amount", Field: "amount"}},
+		Translate: func(msg wscutils.ErrorMessage) string {
+			return "Invalid input"
+		},
+	}
+	assert.NoError(t, jm.RegisterExportMapping("app1", "ECHO", mapping))
+	err := jm.RegisterExportMapping("app1", "echo", mapping)
+	assert.True(t, errors.Is(err, ErrExportMappingAlreadyRegistered))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	row1, _ := NewJSONstr(`{"acct": {"id": "A1"}, "amount": 12.5}`)
+	row2, _ := NewJSONstr(`"fail"`)
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, []BatchInput_t{{Line: 2, Input: row2}, {Line: 1, Input: row1}}, false)
+	assert.NoError(t, err)
+
+	_, err = jm.BatchExport(batchID, ExportCSV)
+	assert.True(t, errors.Is(err, ErrBatchNotDone))
+
+	runJobManager(t, jm)
+	waitForBatch(t, jm, batchID)
+	<-p.markDoneCalled
+
+	csvID, err := jm.BatchExport(batchID, ExportCSV)
+	assert.NoError(t, err)
+	assert.Equal(t, "line,status,account,amount,messages\n1,success,A1,12.5,\n2,failed,,,Invalid input\n", string(objects[csvID]))
+
+	jsonlID, err := jm.BatchExport(batchID, ExportJSONL)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"line":1,"status":"success","account":"A1","amount":12.5,"messages":[]}`+"\n"+
+		`{"line":2,"status":"failed","account":null,"amount":null,"messages":["Invalid input"]}`+"\n", string(objects[jsonlID]))
+
+	xlsxID, err := jm.BatchExport(batchID, ExportXLSX)
+	assert.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(objects[xlsxID]), int64(len(objects[xlsxID])))
+	assert.NoError(t, err)
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, err := f.Open()
+			assert.NoError(t, err)
+			data, _ := io.ReadAll(r)
+			sheet = string(data)
+		}
+	}
+	assert.Equal(t, 3, strings.Count(sheet, "<row "))
+	assert.Contains(t, sheet, `<t xml:space="preserve">A1</t>`)
+	assert.Contains(t, sheet, `<t xml:space="preserve">Invalid input</t>`)
+
+	_, _, outputFiles, _, _, _, err := jm.BatchDone(batchID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"results.csv": csvID, "results.jsonl": jsonlID, "results.xlsx": xlsxID}, outputFiles)
+}
+
+func TestExportValuesWithoutMapping(t *testing.T) {
+	res, _ := NewJSONstr(`{"b": [1, 2], "a": "x"}`)
+	messages, _ := NewJSONstr(`[{"msgid": 1, "errcode": "too_large", "field": "amount", "vals": ["100"]}]`)
+	values, err := exportValues(BatchOutput_t{Line: 3, Status: BatchFailed, Res: res, Messages: messages}, ExportMapping{})
+	assert.NoError(t, err)
+	assert.Len(t, values, 4)
+	assert.Equal(t, `{"a":"x","b":[1,2]}`, exportCell(values[2]))
+	assert.Equal(t, "too_large (amount): 100", exportCell(values[3]))
+}
@@ -110,7 +110,7 @@ func main() {
 	}
 
 	// Start the JobManager
-	go jm.Run()
+	go jm.Run(context.Background())
 
 	// Wait for batch processing to complete
 	time.Sleep(10 * time.Second)
@@ -218,7 +218,7 @@ func main() {
 
 	// Start the JobManager in a separate goroutine.
 	log.Println("Starting JobManager")
-	go jm.Run()
+	go jm.Run(context.Background())
 
 	// Run the Infiled daemon.
 	log.Println("Running Infiled daemon")
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ErrIdempotencyConflict is returned by BatchSubmit, BatchSubmitStream and SlowQuerySubmit when the
+// idempotency key passed with WithIdempotencyKey was used before to submit a different context or
+// input for the same (app, op).
+var ErrIdempotencyConflict = errors.New("idempotency key already used with a different payload")
+
+// payloadHasher hashes the context and rows of a submission, to tell a repeated submission from a
+// different one with the same idempotency key
+type payloadHasher struct {
+	h hash.Hash
+}
+
+func newPayloadHasher(batchctx JSONstr) *payloadHasher {
+	p := &payloadHasher{h: sha256.New()}
+	p.write(batchctx.String())
+	return p
+}
+
+func (p *payloadHasher) addRow(row BatchInput_t) {
+	binary.Write(p.h, binary.BigEndian, int64(row.Line))
+	p.write(row.Input.String())
+	p.write(row.PartitionKey)
+}
+
+// write adds s along with its length, so that where each part of the payload ends is hashed too
+func (p *payloadHasher) write(s string) {
+	binary.Write(p.h, binary.BigEndian, int64(len(s)))
+	io.WriteString(p.h, s)
+}
+
+func (p *payloadHasher) sum() []byte {
+	return p.h.Sum(nil)
+}
+
+// hashPayload returns the hash of the context and rows of a batch
+func hashPayload(batchctx JSONstr, batchInput []BatchInput_t) []byte {
+	p := newPayloadHasher(batchctx)
+	for _, input := range batchInput {
+		p.addRow(input)
+	}
+	return p.sum()
+}
+
+// submittedBatch returns the ID of the batch of the (app, op) submitted earlier with an idempotency
+// key, or ErrIdempotencyConflict if its payload had another hash
+func submittedBatch(ctx context.Context, q batchsqlc.Querier, app, op, key string, payloadHash []byte) (uuid.UUID, error) {
+	batchID, found, err := findSubmittedBatch(ctx, q, app, op, key, payloadHash)
+	if err == nil && !found {
+		err = fmt.Errorf("failed to get batch with idempotency key %s: %v", key, pgx.ErrNoRows)
+	}
+	return batchID, err
+}
+
+// findSubmittedBatch is like submittedBatch, but reports whether a batch was submitted with the key
+// instead of failing when none was
+func findSubmittedBatch(ctx context.Context, q batchsqlc.Querier, app, op, key string, payloadHash []byte) (uuid.UUID, bool, error) {
+	batch, err := q.GetBatchByIdempotencyKey(ctx, batchsqlc.GetBatchByIdempotencyKeyParams{
+		App:            app,
+		Op:             strings.ToLower(op),
+		Idempotencykey: pgtype.Text{String: key, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to get batch with idempotency key %s: %v", key, err)
+	}
+	if !bytes.Equal(batch.Payloadhash, payloadHash) {
+		return uuid.Nil, false, fmt.Errorf("%w: key %s was used for batch %s", ErrIdempotencyConflict, key, batch.ID)
+	}
+	return batch.ID, true, nil
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchSubmitIdempotencyKey(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	batchctx, _ := NewJSONstr(`{"day": 1}`)
+	row1, _ := NewJSONstr(`"a"`)
+	row2, _ := NewJSONstr(`"b"`)
+	input := []BatchInput_t{{Line: 1, Input: row1}, {Line: 2, Input: row2}}
+
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, input, true, WithIdempotencyKey("day-1"))
+	assert.NoError(t, err)
+
+	// A repeated submission returns the batch already submitted, without adding rows to it
+	againID, err := jm.BatchSubmit("app1", "ECHO", batchctx, input, true, WithIdempotencyKey("day-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, batchID, againID)
+	batch, err := jm.Queries.GetBatchByID(context.Background(), uuid.MustParse(batchID))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), batch.Nrows)
+
+	// A different payload with the same key is refused
+	_, err = jm.BatchSubmit("app1", "echo", batchctx, input[:1], true, WithIdempotencyKey("day-1"))
+	assert.True(t, errors.Is(err, ErrIdempotencyConflict))
+
+	// Keys are scoped by (app, op), and batches without a key are never deduplicated
+	otherID, err := jm.BatchSubmit("app1", "other", batchctx, input, true, WithIdempotencyKey("day-1"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, batchID, otherID)
+	id1, err := jm.BatchSubmit("app1", "echo", batchctx, input, true)
+	assert.NoError(t, err)
+	id2, err := jm.BatchSubmit("app1", "echo", batchctx, input, true)
+	assert.NoError(t, err)
+	assert.NotEqual(t, id1, id2)
+}
+
+func TestBatchSubmitStreamIdempotencyKey(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	batchctx, _ := NewJSONstr(`{}`)
+	stream := func(values ...string) BatchInputStream {
+		ch := make(chan BatchInput_t, len(values))
+		for i, value := range values {
+			input, _ := NewJSONstr(value)
+			ch <- BatchInput_t{Line: i + 1, Input: input}
+		}
+		close(ch)
+		return BatchInputChan(ch)
+	}
+
+	batchID, err := jm.BatchSubmitStream("app1", "echo", batchctx, stream(`"a"`, `"b"`), true, nil, WithIdempotencyKey("k"))
+	assert.NoError(t, err)
+
+	// The stream hashes the same as the equivalent BatchSubmit
+	row1, _ := NewJSONstr(`"a"`)
+	row2, _ := NewJSONstr(`"b"`)
+	againID, err := jm.BatchSubmit("app1", "echo", batchctx, []BatchInput_t{{Line: 1, Input: row1}, {Line: 2, Input: row2}}, true, WithIdempotencyKey("k"))
+	assert.NoError(t, err)
+	assert.Equal(t, batchID, againID)
+
+	againID, err = jm.BatchSubmitStream("app1", "echo", batchctx, stream(`"a"`, `"b"`), true, nil, WithIdempotencyKey("k"))
+	assert.NoError(t, err)
+	assert.Equal(t, batchID, againID)
+
+	_, err = jm.BatchSubmitStream("app1", "echo", batchctx, stream(`"a"`, `"c"`), true, nil, WithIdempotencyKey("k"))
+	assert.True(t, errors.Is(err, ErrIdempotencyConflict))
+}
+
+func TestSlowQuerySubmitIdempotencyKey(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"account": "A1"}`)
+
+	reqID, err := jm.SlowQuerySubmit("app1", "balance", queryctx, input, WithIdempotencyKey("req-7"))
+	assert.NoError(t, err)
+	againID, err := jm.SlowQuerySubmit("app1", "balance", queryctx, input, WithIdempotencyKey("req-7"))
+	assert.NoError(t, err)
+	assert.Equal(t, reqID, againID)
+
+	otherInput, _ := NewJSONstr(`{"account": "A2"}`)
+	_, err = jm.SlowQuerySubmit("app1", "balance", queryctx, otherInput, WithIdempotencyKey("req-7"))
+	assert.True(t, errors.Is(err, ErrIdempotencyConflict))
+}
+
+func TestHashPayload(t *testing.T) {
+	ctx, _ := NewJSONstr(`{}`)
+	ab, _ := NewJSONstr(`"ab"`)
+	a, _ := NewJSONstr(`"a"`)
+
+	// Moving a row to another line changes the hash
+	assert.NotEqual(t, hashPayload(ctx, []BatchInput_t{{Line: 1, Input: ab}}), hashPayload(ctx, []BatchInput_t{{Line: 2, Input: ab}}))
+	assert.NotEqual(t, hashPayload(ctx, []BatchInput_t{{Line: 1, Input: ab}}), hashPayload(ctx, []BatchInput_t{{Line: 1, Input: a}}))
+	assert.Equal(t, hashPayload(ctx, []BatchInput_t{{Line: 1, Input: a}}), hashPayload(ctx, []BatchInput_t{{Line: 1, Input: a}}))
+	assert.NotEqual(t, hashPayload(ctx, []BatchInput_t{{Line: 1, Input: a}}), hashPayload(ctx, []BatchInput_t{{Line: 1, Input: a, PartitionKey: "k"}}))
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"runtime/debug"
+	"sort"
 	"sync"
 	"time"
 
@@ -22,13 +24,20 @@ import (
 )
 
 const ALYA_BATCHCHUNK_NROWS = 10
+const ALYA_BATCHSTREAM_COPY_NROWS = 10000
 const ALYA_BATCHSTATUS_CACHEDUR_SEC = 60
+const ALYA_JOBMANAGER_NWORKERS = 1
+const ALYA_LEASE_DUR_SEC = 300
+const ALYA_HEARTBEAT_INTERVAL_SEC = 30
+const ALYA_MAX_ROW_ATTEMPTS = 5
+const ALYA_ABORT_CHECK_INTERVAL_SEC = 5
+const ALYA_SCHEDULER_INTERVAL_SEC = 15
+const ALYA_PIPELINE_INTERVAL_SEC = 5
 
 // Assuming global variables are defined elsewhere
 // make all the maps sync maps to make them thread safe
 var (
-	mu     sync.Mutex // Ensures thread-safe access to the initfuncs map
-	doneBy pgtype.Text
+	mu sync.Mutex // Ensures thread-safe access to the initfuncs map
 )
 
 // JobManager is the main struct that manages the processing of batch jobs and slow queries.
@@ -39,21 +48,45 @@ var (
 // 3. Update the corresponding batchrows and batches records with the results
 // 4. Check for completed batches and summarize them
 type JobManager struct {
-	Db                      *pgxpool.Pool
+	Db                      *pgxpool.Pool // nil unless created with NewJobManager
+	Store                   JobStore
 	Queries                 batchsqlc.Querier
-	RedisClient             *redis.Client
+	RedisClient             *redis.Client // used for batch events
+	StatusCache             StatusCache   // nil if statuses are not cached
 	ObjStore                objstore.ObjectStore
 	initblocks              map[string]InitBlock
 	initfuncs               map[string]Initializer
-	slowqueryprocessorfuncs map[string]SlowQueryProcessor
-	batchprocessorfuncs     map[string]BatchProcessor
+	slowqueryprocessorfuncs map[string]SlowQueryProcessorCtx
+	batchprocessorfuncs     map[string]BatchProcessorCtx
+	retrypolicies           map[string]RetryPolicy
+	exportmappings          map[string]ExportMapping
+	reducers                map[string]BatchReducer
+	rowtimeouts             map[string]time.Duration
+	memottls                map[string]time.Duration
+	recurringbatches        map[string]recurringBatch
+	pipelines               map[string][]PipelineStep
+	inflight                map[int64]inflightRow // rows being processed, to cancel them if their batch is aborted
+	inflightmu              sync.Mutex
+	wakech                  chan struct{} // closed to wake up idle workers when a batch is queued
+	wakemu                  sync.Mutex
 	Logger                  *logharbour.Logger
 	Config                  JobManagerConfig
+	WorkerID                string // recorded in batchrows.doneby for the rows leased by this instance
 }
 
-// NewJobManager creates a new instance of JobManager.
+// NewJobManager creates a new instance of JobManager which keeps its jobs in Postgres.
 // It initializes the necessary fields and returns a pointer to the JobManager.
 func NewJobManager(db *pgxpool.Pool, redisClient *redis.Client, minioClient *minio.Client, logger *logharbour.Logger, config *JobManagerConfig) *JobManager {
+	jm := NewJobManagerWithStore(NewPgJobStore(db), redisClient, minioClient, logger, config)
+	jm.Db = db
+	return jm
+}
+
+// NewJobManagerWithStore creates a new instance of JobManager which keeps its jobs in store, such as
+// a MemJobStore for tests. Batch statuses are cached in Redis under Config.CacheNamespace; set
+// StatusCache to use another cache. redisClient may be nil, in which case batch statuses are not
+// cached and batch events are not published.
+func NewJobManagerWithStore(store JobStore, redisClient *redis.Client, minioClient *minio.Client, logger *logharbour.Logger, config *JobManagerConfig) *JobManager {
 	if config == nil {
 		config = &JobManagerConfig{}
 	}
@@ -61,22 +94,63 @@ func NewJobManager(db *pgxpool.Pool, redisClient *redis.Client, minioClient *min
 	if config.BatchChunkNRows == 0 {
 		config.BatchChunkNRows = ALYA_BATCHCHUNK_NROWS
 	}
+	if config.BatchStreamCopyNRows == 0 {
+		config.BatchStreamCopyNRows = ALYA_BATCHSTREAM_COPY_NROWS
+	}
 	if config.BatchStatusCacheDurSec == 0 {
 		config.BatchStatusCacheDurSec = ALYA_BATCHSTATUS_CACHEDUR_SEC
 	}
+	if config.NumWorkers == 0 {
+		config.NumWorkers = ALYA_JOBMANAGER_NWORKERS
+	}
+	if config.LeaseDurSec == 0 {
+		config.LeaseDurSec = ALYA_LEASE_DUR_SEC
+	}
+	if config.HeartbeatIntervalSec == 0 {
+		config.HeartbeatIntervalSec = ALYA_HEARTBEAT_INTERVAL_SEC
+	}
+	if config.MaxRowAttempts == 0 {
+		config.MaxRowAttempts = ALYA_MAX_ROW_ATTEMPTS
+	}
+	if config.AbortCheckIntervalSec == 0 {
+		config.AbortCheckIntervalSec = ALYA_ABORT_CHECK_INTERVAL_SEC
+	}
+	if config.SchedulerIntervalSec == 0 {
+		config.SchedulerIntervalSec = ALYA_SCHEDULER_INTERVAL_SEC
+	}
+	if config.PipelineIntervalSec == 0 {
+		config.PipelineIntervalSec = ALYA_PIPELINE_INTERVAL_SEC
+	}
+	if config.CacheNamespace == "" {
+		config.CacheNamespace = ALYA_CACHE_NAMESPACE
+	}
 
-	return &JobManager{
-		Db:                      db,
-		Queries:                 batchsqlc.New(db),
+	jm := &JobManager{
+		Store:                   store,
+		Queries:                 store.Queries(),
 		RedisClient:             redisClient,
 		ObjStore:                objstore.NewMinioObjectStore(minioClient),
 		initblocks:              make(map[string]InitBlock),
 		initfuncs:               make(map[string]Initializer),
-		slowqueryprocessorfuncs: make(map[string]SlowQueryProcessor),
-		batchprocessorfuncs:     make(map[string]BatchProcessor),
+		slowqueryprocessorfuncs: make(map[string]SlowQueryProcessorCtx),
+		batchprocessorfuncs:     make(map[string]BatchProcessorCtx),
+		retrypolicies:           make(map[string]RetryPolicy),
+		exportmappings:          make(map[string]ExportMapping),
+		reducers:                make(map[string]BatchReducer),
+		rowtimeouts:             make(map[string]time.Duration),
+		memottls:                make(map[string]time.Duration),
+		recurringbatches:        make(map[string]recurringBatch),
+		pipelines:               make(map[string][]PipelineStep),
+		inflight:                make(map[int64]inflightRow),
+		wakech:                  make(chan struct{}),
 		Logger:                  logger,
 		Config:                  *config,
+		WorkerID:                newWorkerID(),
+	}
+	if redisClient != nil {
+		jm.StatusCache = NewRedisStatusCache(redisClient, config.CacheNamespace)
 	}
+	return jm
 }
 
 var ErrInitializerAlreadyRegistered = errors.New("initializer already registered for this app")
@@ -136,169 +210,335 @@ func (jm *JobManager) getOrCreateInitBlock(app string) (InitBlock, error) {
 	return initBlock, nil
 }
 
-// Run is the main loop of the JobManager. It continuously fetches a block of rows from the database,
-// processes each row either as a slow query or a batch job. After processing a block, it checks for
-// completed batches and summarizes them. Fetching, processing and updating happens in the same transaction.
-// This method should be called in a separate goroutine. It is thread safe -- updates to database and Redis
-// are executed atomically (check updateStatusInRedis()).
-func (jm *JobManager) Run() {
-	for {
-		ctx := context.Background()
-
-		// Begin a transaction
-		tx, err := jm.Db.Begin(ctx)
+// Run is the main loop of the JobManager. It starts Config.NumWorkers worker goroutines which share the
+// queue of batchrows (rows are claimed with FOR UPDATE SKIP LOCKED, so workers in this process and in other
+// processes never pick up the same row). Each worker continuously fetches a block of rows from the database,
+// processes each row either as a slow query or a batch job and then checks for completed batches and
+// summarizes them.
+//
+// Every row taken up by a worker is leased to this JobManager instance (see WorkerID) for
+// Config.LeaseDurSec seconds. While Run is active the leases are renewed by a heartbeat, and rows whose
+// lease has expired because the instance holding them died are put back in the queue (see runHeartbeat()).
+//
+// Processors registered with a context (see BatchProcessorCtx and SlowQueryProcessorCtx) are cancelled
+// when the batch of the row they are processing is aborted, either through this instance or through
+// another one (see runAbortWatcher()), and when the row timeout registered for the (app, op) elapses.
+//
+// Run blocks until ctx is cancelled. On cancellation no new blocks are fetched, and the context of the
+// row currently being processed by each worker is cancelled. A row whose processor gives up because of
+// this is put back in the queue; processors registered without a context are allowed to finish. Rows of
+// the current block which have not been started yet are put back in the queue, the InitBlocks are closed
+// and Run returns. It is thread safe -- updates to
+// the JobStore and the StatusCache are executed atomically (check StatusCache.Set()).
+func (jm *JobManager) Run(ctx context.Context) {
+	// The heartbeat is stopped only after all workers have drained, so that the leases
+	// on the rows still being processed are renewed until the very end.
+	hbCtx, stopHeartbeat := context.WithCancel(context.Background())
+	hbDone := make(chan struct{})
+	go func() {
+		defer close(hbDone)
+		jm.runHeartbeat(hbCtx)
+	}()
+	abortWatcherDone := make(chan struct{})
+	go func() {
+		defer close(abortWatcherDone)
+		jm.runAbortWatcher(hbCtx)
+	}()
+
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		jm.runScheduler(ctx)
+	}()
+	pipelinesDone := make(chan struct{})
+	go func() {
+		defer close(pipelinesDone)
+		jm.runPipelines(ctx)
+	}()
+	listenerDone := make(chan struct{})
+	go func() {
+		defer close(listenerDone)
+		jm.runListener(ctx)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < jm.Config.NumWorkers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			jm.runWorker(ctx, worker)
+		}(i)
+	}
+	wg.Wait()
+	stopHeartbeat()
+	<-hbDone
+	<-abortWatcherDone
+	<-schedulerDone
+	<-pipelinesDone
+	<-listenerDone
+
+	// Close and clean up initblocks once all workers have drained
+	jm.closeInitBlocks()
+	log.Println("JobManager stopped")
+}
+
+// runWorker is the loop executed by each worker goroutine started by Run. It returns when ctx is cancelled.
+func (jm *JobManager) runWorker(ctx context.Context, worker int) {
+	for ctx.Err() == nil {
+		// Taken before fetching, so that a batch queued while the block is fetched is not missed
+		wake := jm.wakeChan()
+		nrows, err := jm.processBlock(ctx)
 		if err != nil {
-			log.Println("Error starting transaction:", err)
-			time.Sleep(getRandomSleepDuration())
-			continue
+			log.Printf("worker %d: %v", worker, err)
+			// Something went wrong, back off before trying again
+			sleepWithContext(ctx, getRandomSleepDuration())
+		} else if nrows == 0 {
+			// Nothing to do, wait until a batch is queued or the next poll
+			sleepOrWake(ctx, getRandomSleepDuration(), wake)
 		}
+	}
+}
 
-		// Create a new Queries instance using the transaction
-		txQueries := batchsqlc.New(tx)
+// processBlock fetches a block of queued rows, marks them inprog, processes them and summarizes the
+// batches they belong to. It returns the number of rows fetched.
+func (jm *JobManager) processBlock(ctx context.Context) (int, error) {
+	blockOfRows, err := jm.fetchBlock(ctx)
+	if err != nil {
+		return 0, err
+	}
 
-		// Fetch a block of rows from the database
-		blockOfRows, err := txQueries.FetchBlockOfRows(ctx, batchsqlc.FetchBlockOfRowsParams{
-			Status: batchsqlc.StatusEnumQueued,
-			Limit:  int32(jm.Config.BatchChunkNRows),
-		})
-		if err != nil {
-			log.Println("Error fetching block of rows:", err)
-			tx.Rollback(ctx)
-			time.Sleep(getRandomSleepDuration())
-			continue
-		}
+	// If no rows are found, let the caller sleep
+	if len(blockOfRows) == 0 {
+		log.Println("No rows found, sleeping...")
+		return 0, nil
+	}
 
-		// If no rows are found, sleep and continue
-		if len(blockOfRows) == 0 {
-			log.Println("No rows found, sleeping...")
-			tx.Rollback(ctx)
-			time.Sleep(getRandomSleepDuration())
+	// Process the rows. The rows of a batch whose processor is a BatchChunkProcessor are processed
+	// together, when the first of them comes up. Once ctx is cancelled, the remaining rows of the
+	// block are released back to the queue.
+	chunks := jm.chunksOf(blockOfRows)
+	statuses := make([]batchsqlc.StatusEnum, len(blockOfRows))
+	results := &rowResults{}
+	for i, row := range blockOfRows {
+		if statuses[i] != "" {
+			// processed along with its chunk
 			continue
 		}
-
-		// Process each row in the block
-		for _, row := range blockOfRows {
-			// Update the status of the batch row to "inprog"
-			err := txQueries.UpdateBatchRowStatus(ctx, batchsqlc.UpdateBatchRowStatusParams{
-				Rowid:  row.Rowid,
-				Status: batchsqlc.StatusEnumInprog,
-			})
-			if err != nil {
-				log.Println("Error updating batch row status:", err)
-				tx.Rollback(ctx)
-				time.Sleep(getRandomSleepDuration())
-				continue
-			}
-
-			if row.Status == batchsqlc.StatusEnumQueued {
-				// Update the status of the batch to "inprog"
-				// Log the status change
-				changeDetails := logharbour.ChangeInfo{
-					Entity: "BatchRow",
-					Op:     "StatusUpdated",
-					Changes: []logharbour.ChangeDetail{
-						{"status", batchsqlc.StatusEnumQueued, batchsqlc.StatusEnumInprog},
-					},
-				}
-				jm.Logger.LogDataChange("Batch row status updated to inprog", changeDetails)
-				err := txQueries.UpdateBatchStatus(ctx, batchsqlc.UpdateBatchStatusParams{
-					ID:     row.Batch,
-					Status: batchsqlc.StatusEnumInprog,
-				})
-				if err != nil {
-					log.Println("Error updating batch status:", err)
-					tx.Rollback(ctx)
-					time.Sleep(getRandomSleepDuration())
-					continue
+		if ctx.Err() != nil {
+			var unprocessed []batchsqlc.FetchBlockOfRowsRow
+			for j := i; j < len(blockOfRows); j++ {
+				if statuses[j] == "" {
+					unprocessed = append(unprocessed, blockOfRows[j])
+					statuses[j] = batchsqlc.StatusEnumQueued
 				}
 			}
-
+			jm.releaseRows(unprocessed)
+			break
 		}
-
-		// let us commit the transaction
-		err = tx.Commit(ctx)
-		if err != nil {
-			log.Println("Error committing transaction:", err)
-			time.Sleep(getRandomSleepDuration())
-			continue
-		}
-
-		// Process the rows
-		for _, row := range blockOfRows {
-			// send queries instance, not transaction
-			q := jm.Queries
-			_, err = jm.processRow(q, row)
+		// send queries instance, not transaction
+		q := jm.Queries
+		if chunk, isChunk := chunks[row.Batch]; isChunk {
+			chunkRows := make([]batchsqlc.FetchBlockOfRowsRow, len(chunk))
+			for k, j := range chunk {
+				chunkRows[k] = blockOfRows[j]
+			}
+			chunkStatuses, err := jm.processChunk(ctx, q, chunkRows, results)
+			for k, j := range chunk {
+				statuses[j] = chunkStatuses[k]
+			}
 			if err != nil {
-				log.Println("Error processing row:", err)
-				time.Sleep(getRandomSleepDuration())
-				continue
+				log.Println("Error processing chunk:", err)
 			}
+			continue
 		}
-
-		// create a new transaction for the summarizeCompletedBatches
-		tx, err = jm.Db.Begin(ctx)
+		status, err := jm.processRow(ctx, q, row, results)
+		statuses[i] = status
 		if err != nil {
-			log.Println("Error starting transaction:", err)
-			time.Sleep(getRandomSleepDuration())
+			log.Println("Error processing row:", err)
 			continue
 		}
+	}
 
-		txQueries = batchsqlc.New(tx)
+	// create a new transaction to record the results of the block and summarizeCompletedBatches
+	// (a background context is used so that the block is recorded even during shutdown)
+	tx, err := jm.Store.Begin(context.Background())
+	if err != nil {
+		return len(blockOfRows), fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
 
-		// Create a map to store unique batch IDs
-		batchSet := make(map[uuid.UUID]bool)
-		for _, row := range blockOfRows {
+	// Create a map to store unique batch IDs
+	batchSet := make(map[uuid.UUID]bool)
+	var batchIDs []uuid.UUID
+	for _, row := range blockOfRows {
+		if !batchSet[row.Batch] {
 			batchSet[row.Batch] = true
+			batchIDs = append(batchIDs, row.Batch)
 		}
+	}
 
-		// Check for completed batches and summarize them
-		if err := jm.summarizeCompletedBatches(txQueries, batchSet); err != nil {
-			log.Println("Error summarizing completed batches:", err)
+	// Lock the batches before their rows are updated, as BatchAbort does, and in the same order in
+	// every instance, so that concurrent updates of their counters cannot deadlock
+	sort.Slice(batchIDs, func(i, j int) bool { return uuidLess(batchIDs[i], batchIDs[j]) })
+	for _, batchID := range batchIDs {
+		if _, err := txQueries.GetBatchByID(context.Background(), batchID); err != nil {
+			return len(blockOfRows), fmt.Errorf("error locking batch %s: %v", batchID, err)
 		}
+	}
 
-		// Commit the transaction after processing the entire block
-		err = tx.Commit(ctx)
-		if err != nil {
-			log.Println("Error committing transaction:", err)
-			time.Sleep(getRandomSleepDuration())
+	// Record the results of the batch rows of the block. If this fails, the rows stay inprog until
+	// their lease expires, and are then processed again.
+	if err := jm.recordRowResults(txQueries, results); err != nil {
+		return len(blockOfRows), fmt.Errorf("error recording results of block: %v", err)
+	}
+	jm.publishProgress(blockOfRows, statuses)
+
+	// Check for completed batches and summarize them
+	if err := jm.summarizeCompletedBatches(txQueries, batchSet); err != nil {
+		log.Println("Error summarizing completed batches:", err)
+	}
+
+	// Commit the transaction after processing the entire block
+	if err = tx.Commit(context.Background()); err != nil {
+		return len(blockOfRows), fmt.Errorf("error committing transaction: %v", err)
+	}
+	return len(blockOfRows), nil
+}
+
+// fetchBlock fetches a block of queued rows from the database, leases them to this instance and marks
+// them (and their batches, if they are still queued) inprog, all in one transaction.
+func (jm *JobManager) fetchBlock(ctx context.Context) ([]batchsqlc.FetchBlockOfRowsRow, error) {
+	// Begin a transaction
+	tx, err := jm.Store.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	// Get the Queries instance of the transaction
+	txQueries := tx.Queries()
+
+	// Fetch a block of rows from the database
+	// Rows waiting for a retry are skipped until their backoff has elapsed
+	blockOfRows, err := txQueries.FetchBlockOfRows(ctx, batchsqlc.FetchBlockOfRowsParams{
+		Status:   batchsqlc.StatusEnumQueued,
+		Nexttry:  pgtype.Timestamp{Time: time.Now(), Valid: true},
+		Perbatch: int32(jm.Config.BatchChunkNRows),
+		Maxrows:  int32(jm.Config.BatchChunkNRows),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching block of rows: %v", err)
+	}
+	if len(blockOfRows) == 0 {
+		return nil, nil
+	}
+
+	// Update the status of the batch rows to "inprog" and record the lease, in one statement
+	rowids := make([]int64, len(blockOfRows))
+	for i, row := range blockOfRows {
+		rowids[i] = row.Rowid
+	}
+	err = txQueries.LeaseBatchRows(ctx, batchsqlc.LeaseBatchRowsParams{
+		Doneby:      jm.doneBy(),
+		Leaseexpiry: pgtype.Timestamp{Time: time.Now().Add(jm.leaseDuration()), Valid: true},
+		Rowids:      rowids,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error updating batch row status: %v", err)
+	}
+
+	// Update the status of the queued batches to "inprog", once for each batch
+	marked := make(map[uuid.UUID]bool)
+	for _, row := range blockOfRows {
+		if row.Status != batchsqlc.StatusEnumQueued || marked[row.Batch] {
 			continue
 		}
+		marked[row.Batch] = true
+
+		// Log the status change
+		changeDetails := logharbour.ChangeInfo{
+			Entity: "Batch",
+			Op:     "StatusUpdated",
+			Changes: []logharbour.ChangeDetail{
+				{Field: "status", OldVal: batchsqlc.StatusEnumQueued, NewVal: batchsqlc.StatusEnumInprog},
+			},
+		}
+		if jm.Logger != nil {
+			jm.Logger.LogDataChange("Batch status updated to inprog", changeDetails)
+		}
+		err := txQueries.MarkBatchInprog(ctx, batchsqlc.MarkBatchInprogParams{
+			ID:        row.Batch,
+			Startedat: pgtype.Timestamp{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error updating batch status: %v", err)
+		}
+	}
+
+	// let us commit the transaction
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return blockOfRows, nil
+}
 
-		// Close and clean up initblocks
-		jm.closeInitBlocks()
+// releaseRows puts rows which were fetched but not processed back in the queue, so that
+// they can be picked up by another worker. It is used when the JobManager is shutting down.
+func (jm *JobManager) releaseRows(rows []batchsqlc.FetchBlockOfRowsRow) {
+	rowids := make([]int64, len(rows))
+	for i, row := range rows {
+		rowids[i] = row.Rowid
+	}
+	err := jm.Queries.ReleaseBatchRows(context.Background(), batchsqlc.ReleaseBatchRowsParams{
+		Lasterr: pgtype.Text{String: "released on shutdown before being processed", Valid: true},
+		Rowids:  rowids,
+		Doneby:  jm.doneBy(),
+	})
+	if err != nil {
+		log.Printf("Error releasing %d unprocessed rows: %v", len(rowids), err)
 	}
 }
 
-func (jm *JobManager) processRow(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow) (batchsqlc.StatusEnum, error) {
+// processRow processes a single row. The result of a batch job is added to results, to be recorded
+// along with the rest of the block; other updates are made through txQueries. If the processor
+// panics, the panic is recovered and the row is moved to the dead-letter state along with the stack
+// trace, so that one poison row cannot bring down the worker.
+func (jm *JobManager) processRow(ctx context.Context, txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow, results *rowResults) (status batchsqlc.StatusEnum, err error) {
 	fmt.Printf("jobmanager inside processrow\n")
 
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("processor for app %s and op %s panicked on row %d: %v", row.App, row.Op, row.Rowid, r)
+			status, err = jm.deadLetterRow(txQueries, row, fmt.Errorf("panic: %v", r), string(debug.Stack()))
+		}
+	}()
+
 	// Process the row based on its type (slow query or batch job)
 	if row.Line == 0 {
-		return jm.processSlowQuery(txQueries, row)
+		return jm.processSlowQuery(ctx, txQueries, row)
 	} else {
-		return jm.processBatchJob(txQueries, row)
+		return jm.processBatchJob(ctx, txQueries, row, results)
 	}
 }
 
 // processSlowQuery processes a single slow query job. It retrieves the registered SlowQueryProcessor
 // for the given app and op, fetches the associated InitBlock, and invokes the processor's DoSlowQuery
 // method. It then calls updateSlowQueryResult to update the corresponding batchrows and batches records
-// with the processing results. If the processor is not found or the processing fails, an error is returned.
+// with the processing results. If DoSlowQuery returns an error, the row is either requeued as per the
+// retry policy for the app and op, moved to the dead-letter state if the policy has been exhausted, or
+// recorded as failed with the error in its messages. If the processor is not found or the results cannot
+// be recorded, an error is returned.
 
-func (jm *JobManager) processSlowQuery(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow) (batchsqlc.StatusEnum, error) {
+func (jm *JobManager) processSlowQuery(ctx context.Context, txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow) (batchsqlc.StatusEnum, error) {
 	log.Printf("processing slow query for app %s and op %s", row.App, row.Op)
 	// Retrieve the SlowQueryProcessor for the app and op
-	p, exists := jm.slowqueryprocessorfuncs[string(row.App)+row.Op]
+	processor, exists := jm.slowqueryprocessorfuncs[string(row.App)+row.Op]
 	if !exists {
 		return batchsqlc.StatusEnumFailed, fmt.Errorf("no SlowQueryProcessor registered for app %s and op %s", row.App, row.Op)
 	}
 
-	// Assert that the processor is of the correct type
-	processor, ok := p.(SlowQueryProcessor)
-	if !ok {
-		return batchsqlc.StatusEnumFailed, fmt.Errorf("invalid SlowQueryProcessor type for app %s and op %s", row.App, row.Op)
-	}
-
 	// Get or create the initblock for the app
 	initBlock, err := jm.getOrCreateInitBlock(string(row.App))
 	if err != nil {
@@ -315,36 +555,88 @@ func (jm *JobManager) processSlowQuery(txQueries batchsqlc.Querier, row batchsql
 	if err != nil {
 		return batchsqlc.StatusEnumFailed, fmt.Errorf("error processing slow query for app %s and op %s: %v", row.App, row.Op, err)
 	}
-	status, result, messages, outputFiles, err := processor.DoSlowQuery(initBlock, rowContext, rowInput)
+	rowCtx, done := jm.startRow(ctx, row)
+	defer done()
+	rowCtx = jm.withProgressReporter(rowCtx, row.Batch)
+	status, result, messages, outputFiles, err := processor.DoSlowQuery(rowCtx, initBlock, rowContext, rowInput)
+	if cause := rowCancelCause(rowCtx, err); cause != nil {
+		if errors.Is(cause, ErrRowAborted) {
+			// SlowQueryAbort has already recorded the row as aborted
+			log.Printf("slow query for app %s and op %s aborted while being processed", row.App, row.Op)
+			return batchsqlc.StatusEnumAborted, nil
+		}
+		if !errors.Is(cause, ErrRowTimedOut) {
+			// The JobManager is shutting down, leave the row to another instance
+			return batchsqlc.StatusEnumQueued, jm.releaseRow(txQueries, row, cause)
+		}
+		err = cause
+	}
 	if err != nil {
-		return batchsqlc.StatusEnumFailed, fmt.Errorf("error processing slow query for app %s and op %s: %v", row.App, row.Op, err)
+		if status, handled, handleErr := jm.handleRowError(txQueries, row, err); handled {
+			return status, handleErr
+		}
+		log.Printf("error processing slow query for app %s and op %s: %v", row.App, row.Op, err)
+		status = batchsqlc.StatusEnumFailed
+		messages = append(messages, rowErrorMessage(err))
 	}
 
 	// Update the corresponding batchrows and batches records with the results
-	if err := updateSlowQueryResult(txQueries, row, status, result, messages, outputFiles); err != nil {
+	recorded, err := jm.recordSlowQueryResult(row, status, result, messages, outputFiles)
+	if err != nil {
 		return batchsqlc.StatusEnumFailed, fmt.Errorf("error updating slow query result for app %s and op %s: %v", row.App, row.Op, err)
 	}
+	if !recorded {
+		log.Printf("slow query %s is no longer leased to this instance, its result is dropped", row.Batch)
+	}
 
 	return status, nil
 }
 
+// recordSlowQueryResult records the results of a processed slow query with updateSlowQueryResult, in a
+// transaction of its own. The transaction is rolled back, and false returned, if the row of the slow
+// query is no longer leased to this instance because it was aborted or its lease was reclaimed.
+// Once the results are committed, they are cached and the done event of the slow query is published.
+func (jm *JobManager) recordSlowQueryResult(row batchsqlc.FetchBlockOfRowsRow, status batchsqlc.StatusEnum, result JSONstr, messages []wscutils.ErrorMessage, outputFiles map[string]string) (bool, error) {
+	ctx := context.Background()
+	tx, err := jm.Store.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	txQueries := tx.Queries()
+
+	// Lock the batch before its row is updated, as BatchAbort does
+	if _, err := txQueries.GetBatchByID(ctx, row.Batch); err != nil {
+		return false, fmt.Errorf("error locking batch %s: %v", row.Batch, err)
+	}
+	recorded, err := updateSlowQueryResult(txQueries, row, status, result, messages, outputFiles, jm.doneBy())
+	if err != nil || !recorded {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	jm.cacheStatus(row.Batch, CachedStatus{Status: status, Result: result.String(), OutputFiles: outputFiles})
+	jm.publishEvent(slowQueryDoneEvent(row.Batch, status))
+	return true, nil
+}
+
 // processBatchJob processes a single batch job. It retrieves the registered BatchProcessor for the
 // given app and op, fetches the associated InitBlock, and invokes the processor's DoBatchJob method.
-// It then calls updateBatchJobResult to update the corresponding batchrows record with the processing results.
-// If the processor is not found or the processing fails, an error is returned.
-func (jm *JobManager) processBatchJob(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow) (batchsqlc.StatusEnum, error) {
+// It then adds the processing results to results, which are recorded in the batchrows records by
+// recordRowResults once the whole block has been processed.
+// If DoBatchJob returns an error, the row is either requeued as per the retry policy for the app and op,
+// moved to the dead-letter state if the policy has been exhausted, or recorded as failed with the error
+// in its messages.
+// If the processor is not found, an error is returned.
+func (jm *JobManager) processBatchJob(ctx context.Context, txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow, results *rowResults) (batchsqlc.StatusEnum, error) {
 	// Retrieve the BatchProcessor for the app and op
-	p, exists := jm.batchprocessorfuncs[string(row.App)+row.Op]
+	processor, exists := jm.batchprocessorfuncs[string(row.App)+row.Op]
 	if !exists {
 		return batchsqlc.StatusEnumFailed, fmt.Errorf("no BatchProcessor registered for app %s and op %s", row.App, row.Op)
 	}
 
-	// Assert that the processor is of the correct type
-	processor, ok := p.(BatchProcessor)
-	if !ok {
-		return batchsqlc.StatusEnumFailed, fmt.Errorf("invalid BatchProcessor type for app %s and op %s", row.App, row.Op)
-	}
-
 	// Get or create the initblock for the app
 	initBlock, err := jm.getOrCreateInitBlock(string(row.App))
 	if err != nil {
@@ -361,36 +653,53 @@ func (jm *JobManager) processBatchJob(txQueries batchsqlc.Querier, row batchsqlc
 	if err != nil {
 		return batchsqlc.StatusEnumFailed, fmt.Errorf("error processing batch job for app %s and op %s: %v", row.App, row.Op, err)
 	}
-	status, result, messages, blobRows, err := processor.DoBatchJob(initBlock, rowContext, int(row.Line), rowInput)
-	// TODO: check if it should return the error and what its effects are
-	// if err != nil {
-		// return batchsqlc.StatusEnumFailed, fmt.Errorf("error processing batch job for app %s and op %s: %v", row.App, row.Op, err)
-	// }
-
-	// Update the corresponding batchrows record with the results
-	if err := jm.updateBatchJobResult(txQueries, row, status, result, messages, blobRows); err != nil {
-		return batchsqlc.StatusEnumFailed, fmt.Errorf("error updating batch job result for app %s and op %s: %v", row.App, row.Op, err)
+	rowCtx, done := jm.startRow(ctx, row)
+	defer done()
+	status, result, messages, blobRows, err := processor.DoBatchJob(rowCtx, initBlock, rowContext, int(row.Line), rowInput)
+	if cause := rowCancelCause(rowCtx, err); cause != nil {
+		if errors.Is(cause, ErrRowAborted) {
+			// BatchAbort has already recorded the row as aborted
+			log.Printf("batch job for app %s and op %s, line %d aborted while being processed", row.App, row.Op, row.Line)
+			return batchsqlc.StatusEnumAborted, nil
+		}
+		if !errors.Is(cause, ErrRowTimedOut) {
+			// The JobManager is shutting down, leave the row to another instance
+			return batchsqlc.StatusEnumQueued, jm.releaseRow(txQueries, row, cause)
+		}
+		err = cause
 	}
+	if err != nil {
+		if status, handled, handleErr := jm.handleRowError(txQueries, row, err); handled {
+			return status, handleErr
+		}
+		log.Printf("error processing batch job for app %s and op %s, line %d: %v", row.App, row.Op, row.Line, err)
+		status = batchsqlc.StatusEnumFailed
+		messages = append(messages, rowErrorMessage(err))
+	}
+
+	// The batchrows record is updated along with those of the rest of the block
+	results.add(row, BatchChunkResult_t{Line: int(row.Line), Status: status, Result: result, Messages: messages, BlobRows: blobRows})
 
 	return status, nil
 }
 
 // updateSlowQueryResult updates the batchrows and batches records with the results of a processed
-// slow query.
+// slow query. The records are updated only if the batchrows record is still leased to doneBy, which
+// is reported by the bool returned.
 // This function is called after a slow query has been processed by the registered SlowQueryProcessor.
-func updateSlowQueryResult(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow, status batchsqlc.StatusEnum, result JSONstr, messages []wscutils.ErrorMessage, outputFiles map[string]string) error {
+func updateSlowQueryResult(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow, status batchsqlc.StatusEnum, result JSONstr, messages []wscutils.ErrorMessage, outputFiles map[string]string, doneBy pgtype.Text) (bool, error) {
 	// Marshal messages to JSON
 	var messagesJSON, outputFilesJSON []byte
 	if len(messages) > 0 {
 		var err error
 		messagesJSON, err = json.Marshal(messages)
 		if err != nil {
-			return fmt.Errorf("failed to marshal messages to JSON: %v", err)
+			return false, fmt.Errorf("failed to marshal messages to JSON: %v", err)
 		}
 	}
 
 	// Update the batchrows record with the results
-	err := txQueries.UpdateBatchRowsSlowQuery(context.Background(), batchsqlc.UpdateBatchRowsSlowQueryParams{
+	updated, err := txQueries.UpdateBatchRowsSlowQuery(context.Background(), batchsqlc.UpdateBatchRowsSlowQueryParams{
 		Rowid:    int64(row.Rowid),
 		Status:   batchsqlc.StatusEnum(status),
 		Doneat:   pgtype.Timestamp{Time: time.Now(), Valid: true},
@@ -399,7 +708,11 @@ func updateSlowQueryResult(txQueries batchsqlc.Querier, row batchsqlc.FetchBlock
 		Doneby:   doneBy,
 	})
 	if err != nil {
-		return err
+		return false, err
+	}
+	if updated == 0 {
+		// The slow query was aborted or taken over by another instance, whose outcome stands
+		return false, nil
 	}
 	// Marshal outputFiles to JSON
 	outputFilesJSON, err = json.Marshal(outputFiles)
@@ -415,60 +728,85 @@ func updateSlowQueryResult(txQueries batchsqlc.Querier, row batchsqlc.FetchBlock
 		ID:          row.Batch,
 	})
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return true, nil
 }
 
-// updateBatchJobResult updates the batchrows record with the results of a processed batch job.
-// This function is called after a batch job has been processed by the registered BatchProcessor.
-func (jm *JobManager) updateBatchJobResult(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow, status batchsqlc.StatusEnum, result JSONstr, messages []wscutils.ErrorMessage, blobRows map[string]string) error {
-	// Marshal messages to JSON
-	var messagesJSON []byte
-	if len(messages) > 0 {
-		var err error
-		messagesJSON, err = json.Marshal(messages)
-		if err != nil {
-			return fmt.Errorf("failed to marshal messages to JSON: %v", err)
-		}
+// rowResults collects the results of the batch rows processed in a block, so that they are recorded
+// with a single statement once the whole block has been processed
+type rowResults struct {
+	rows    []batchsqlc.FetchBlockOfRowsRow
+	results []BatchChunkResult_t
+}
+
+func (r *rowResults) add(row batchsqlc.FetchBlockOfRowsRow, result BatchChunkResult_t) {
+	r.rows = append(r.rows, row)
+	r.results = append(r.results, result)
+}
+
+// recordRowResults records the results collected for the batch rows of a block in a single statement,
+// for the rows which are still leased to this instance. The statement also adds the rows to the
+// counters of their batches, and the results of the rows recorded are passed to the reducers of their
+// batches.
+func (jm *JobManager) recordRowResults(txQueries batchsqlc.Querier, results *rowResults) error {
+	rows := results.rows
+	if len(rows) == 0 {
+		return nil
 	}
 
-	// Marshal blobRows to JSON
-	var blobRowsJSON []byte
-	if len(blobRows) > 0 {
-		var err error
-		blobRowsJSON, err = json.Marshal(blobRows)
-		if err != nil {
-			return fmt.Errorf("failed to marshal blobRows to JSON: %v", err)
+	params := batchsqlc.BulkUpdateBatchRowsBatchJobParams{
+		Doneat:   pgtype.Timestamp{Time: time.Now(), Valid: true},
+		Rowid:    make([]int64, len(rows)),
+		Status:   make([]string, len(rows)),
+		Res:      make([][]byte, len(rows)),
+		Blobrows: make([][]byte, len(rows)),
+		Messages: make([][]byte, len(rows)),
+		Doneby:   jm.doneBy(),
+	}
+	for i, row := range rows {
+		result := results.results[i]
+		if len(result.Messages) > 0 {
+			messagesJSON, err := json.Marshal(result.Messages)
+			if err != nil {
+				return fmt.Errorf("failed to marshal messages to JSON: %v", err)
+			}
+			params.Messages[i] = messagesJSON
+		}
+		if len(result.BlobRows) > 0 {
+			blobRowsJSON, err := json.Marshal(result.BlobRows)
+			if err != nil {
+				return fmt.Errorf("failed to marshal blobRows to JSON: %v", err)
+			}
+			params.Blobrows[i] = blobRowsJSON
+		}
+		params.Rowid[i] = row.Rowid
+		params.Status[i] = string(result.Status)
+		if result.Result.IsValid() {
+			params.Res[i] = []byte(result.Result.String())
+		}
+
+		if jm.Logger != nil {
+			jm.Logger.LogDataChange("Batch row updated", logharbour.ChangeInfo{
+				Entity: "BatchRow",
+				Op:     "Update",
+				Changes: []logharbour.ChangeDetail{
+					{Field: "status", OldVal: row.Status, NewVal: result.Status},
+				},
+			})
 		}
 	}
 
-	// Update the batchrows record with the results
-	jm.Logger.LogDataChange("Batch row updated", logharbour.ChangeInfo{
-		Entity: "BatchRow",
-		Op:     "Update",
-		Changes: []logharbour.ChangeDetail{
-			{"status", row.Status, batchsqlc.StatusEnum(status)},
-		},
-	})
-	err := txQueries.UpdateBatchRowsBatchJob(context.Background(), batchsqlc.UpdateBatchRowsBatchJobParams{
-		Rowid:    int64(row.Rowid),
-		Status:   batchsqlc.StatusEnum(status),
-		Doneat:   pgtype.Timestamp{Time: time.Now(), Valid: true},
-		Res:      []byte(result.String()),
-		Blobrows: blobRowsJSON,
-		Messages: messagesJSON,
-		Doneby:   doneBy,
-	})
+	recorded, err := txQueries.BulkUpdateBatchRowsBatchJob(context.Background(), params)
 	if err != nil {
 		return err
 	}
-
+	jm.reduceRowResults(txQueries, results, params, recorded)
 	return nil
 }
 
-func (jm *JobManager) summarizeCompletedBatches(q *batchsqlc.Queries, batchSet map[uuid.UUID]bool) error {
+func (jm *JobManager) summarizeCompletedBatches(q batchsqlc.Querier, batchSet map[uuid.UUID]bool) error {
 	fmt.Printf("jobmanager inside summarizecompletedbatches\n")
 	for batchID := range batchSet {
 		if err := jm.summarizeBatch(q, batchID); err != nil {
@@ -481,6 +819,9 @@ func (jm *JobManager) summarizeCompletedBatches(q *batchsqlc.Queries, batchSet m
 }
 
 func (jm *JobManager) closeInitBlocks() {
+	mu.Lock()
+	defer mu.Unlock()
+
 	for app, initBlock := range jm.initblocks {
 		if initBlock != nil {
 			if closer, ok := initBlock.(interface{ Close() error }); ok {
@@ -497,3 +838,25 @@ func getRandomSleepDuration() time.Duration {
 	// Generate a random sleep duration between 30 and 60 seconds
 	return time.Duration(rand.Intn(31)+30) * time.Second
 }
+
+// sleepOrWake sleeps for the given duration, until ctx is cancelled or until wake is closed,
+// whichever comes first.
+func sleepOrWake(ctx context.Context, d time.Duration, wake <-chan struct{}) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	case <-wake:
+	}
+}
+
+// sleepWithContext sleeps for the given duration or until ctx is cancelled, whichever comes first.
+func sleepWithContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
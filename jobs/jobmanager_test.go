@@ -1,10 +1,15 @@
 package jobs_test
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/remiges-tech/alya/jobs"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc/mocks"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,3 +35,67 @@ type MockInitializer struct{}
 func (i *MockInitializer) Init(app string) (jobs.InitBlock, error) {
 	return nil, nil
 }
+
+func TestRunStopsOnCancel(t *testing.T) {
+	jm := jobs.NewJobManager(nil, nil, nil, nil, &jobs.JobManagerConfig{NumWorkers: 4})
+	mockQuerier := newHeartbeatQuerierMock()
+	jm.Queries = mockQuerier
+
+	// With an already cancelled context no worker should fetch rows; only the
+	// heartbeat touches the database
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		jm.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+
+	// The worker must have registered itself and removed itself again on shutdown
+	assert.Len(t, mockQuerier.RecordWorkerHeartbeatCalls(), 1)
+	assert.Equal(t, jm.WorkerID, mockQuerier.RecordWorkerHeartbeatCalls()[0].Arg.ID)
+	assert.Len(t, mockQuerier.DeleteWorkerCalls(), 1)
+	assert.Equal(t, jm.WorkerID, mockQuerier.DeleteWorkerCalls()[0].ID)
+}
+
+func TestNewJobManagerDefaults(t *testing.T) {
+	jm := jobs.NewJobManager(nil, nil, nil, nil, nil)
+
+	assert.Equal(t, jobs.ALYA_JOBMANAGER_NWORKERS, jm.Config.NumWorkers)
+	assert.Equal(t, jobs.ALYA_LEASE_DUR_SEC, jm.Config.LeaseDurSec)
+	assert.Equal(t, jobs.ALYA_HEARTBEAT_INTERVAL_SEC, jm.Config.HeartbeatIntervalSec)
+	assert.Equal(t, jobs.ALYA_MAX_ROW_ATTEMPTS, jm.Config.MaxRowAttempts)
+	assert.Equal(t, jobs.ALYA_ABORT_CHECK_INTERVAL_SEC, jm.Config.AbortCheckIntervalSec)
+	assert.NotEmpty(t, jm.WorkerID)
+
+	// Each instance gets its own worker ID
+	assert.NotEqual(t, jm.WorkerID, jobs.NewJobManager(nil, nil, nil, nil, nil).WorkerID)
+}
+
+// newHeartbeatQuerierMock returns a QuerierMock which accepts the queries issued by the heartbeat.
+func newHeartbeatQuerierMock() *mocks.QuerierMock {
+	return &mocks.QuerierMock{
+		RecordWorkerHeartbeatFunc: func(ctx context.Context, arg batchsqlc.RecordWorkerHeartbeatParams) error {
+			return nil
+		},
+		ExtendWorkerLeasesFunc: func(ctx context.Context, arg batchsqlc.ExtendWorkerLeasesParams) (int64, error) {
+			return 0, nil
+		},
+		ReclaimExpiredLeasesFunc: func(ctx context.Context, arg batchsqlc.ReclaimExpiredLeasesParams) ([]batchsqlc.ReclaimExpiredLeasesRow, error) {
+			return nil, nil
+		},
+		DeleteStaleWorkersFunc: func(ctx context.Context, heartbeat pgtype.Timestamp) (int64, error) {
+			return 0, nil
+		},
+		DeleteWorkerFunc: func(ctx context.Context, id string) error {
+			return nil
+		},
+	}
+}
@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// JobStore is where a JobManager keeps its batches, slow queries and their rows. It runs the queries
+// through which jobs are submitted, fetched and locked by workers, updated with their results,
+// summarized and listed, either directly or within a transaction. PgJobStore keeps them in Postgres;
+// MemJobStore keeps them in memory, for tests.
+type JobStore interface {
+	// Queries returns the queries of the store, each run in a transaction of its own
+	Queries() batchsqlc.Querier
+	// Begin starts a transaction
+	Begin(ctx context.Context) (JobStoreTx, error)
+	// Listen calls wake whenever a batch is queued through NotifyBatchQueued, by this or any other
+	// JobManager instance sharing the store, until ctx is cancelled or the store fails.
+	Listen(ctx context.Context, wake func()) error
+}
+
+// JobStoreTx is a transaction on a JobStore. Rollback after Commit has no effect on the store, so
+// that it can be deferred.
+type JobStoreTx interface {
+	// Queries returns the queries of the store, run within the transaction
+	Queries() batchsqlc.Querier
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// PgJobStore is an implementation of JobStore using Postgres
+type PgJobStore struct {
+	db      *pgxpool.Pool
+	queries *batchsqlc.Queries
+}
+
+// NewPgJobStore creates a new instance of PgJobStore with the provided connection pool
+func NewPgJobStore(db *pgxpool.Pool) *PgJobStore {
+	return &PgJobStore{db: db, queries: batchsqlc.New(db)}
+}
+
+// Queries returns the queries of the store, run on connections from the pool
+func (s *PgJobStore) Queries() batchsqlc.Querier {
+	return s.queries
+}
+
+// Begin starts a database transaction
+func (s *PgJobStore) Begin(ctx context.Context) (JobStoreTx, error) {
+	if s.db == nil {
+		return nil, errors.New("no database connection pool")
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pgJobStoreTx{tx: tx, queries: batchsqlc.New(tx)}, nil
+}
+
+// Listen listens on ALYA_NOTIFY_CHANNEL on a connection taken out of the pool
+func (s *PgJobStore) Listen(ctx context.Context, wake func()) error {
+	if s.db == nil {
+		return errors.New("no database connection pool")
+	}
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %v", err)
+	}
+	// The connection is taken out of the pool and closed when done, since it is still listening
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err := pgConn.Exec(ctx, "LISTEN "+ALYA_NOTIFY_CHANNEL); err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", ALYA_NOTIFY_CHANNEL, err)
+	}
+	for {
+		if _, err := pgConn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		wake()
+	}
+}
+
+// pgJobStoreTx is a transaction on a PgJobStore
+type pgJobStoreTx struct {
+	tx      pgx.Tx
+	queries *batchsqlc.Queries
+}
+
+func (t *pgJobStoreTx) Queries() batchsqlc.Querier {
+	return t.queries
+}
+
+func (t *pgJobStoreTx) Commit(ctx context.Context) error {
+	return t.tx.Commit(ctx)
+}
+
+func (t *pgJobStoreTx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback(ctx)
+}
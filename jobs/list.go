@@ -0,0 +1,117 @@
+package jobs
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+const ALYA_LIST_PAGESIZE = 100
+
+// ErrInvalidListAge is returned by BatchList and SlowQueryList when age is not greater than 0.
+var ErrInvalidListAge = errors.New("age must be greater than 0")
+
+// ErrInvalidPageToken is returned by BatchList, SlowQueryList and BatchResults when the page token
+// was not returned by an earlier call.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// ListOptions narrows down the entries returned by BatchList and SlowQueryList, and pages through them.
+// Entries are returned newest first. When more entries are available than fit in a page, a page token
+// is returned along with the page; passing it in PageToken fetches the next page.
+type ListOptions struct {
+	Statuses  []batchsqlc.StatusEnum // only return entries in one of these statuses; all statuses if empty
+	From      time.Time              // only return entries requested at or after this time, if later than the age limit
+	Until     time.Time              // only return entries requested before this time; no limit if zero
+	PageSize  int                    // maximum number of entries to return, ALYA_LIST_PAGESIZE if 0
+	PageToken string                 // token returned with the previous page, empty for the first page
+}
+
+// listParams holds the query parameters shared by ListBatches and ListSlowQueries.
+type listParams struct {
+	op         string
+	statuses   []string
+	reqFrom    pgtype.Timestamp
+	reqUntil   pgtype.Timestamp
+	afterReqAt pgtype.Timestamp
+	afterID    pgtype.UUID
+	pageSize   int32
+}
+
+// newListParams validates the age and options passed to BatchList or SlowQueryList and converts them
+// into query parameters. The time window starts age days before now, or at opts.From if that is later.
+func newListParams(op string, age int, opts *ListOptions) (listParams, error) {
+	if age <= 0 {
+		return listParams{}, ErrInvalidListAge
+	}
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -age)
+	if opts.From.After(from) {
+		from = opts.From
+	}
+	// An open-ended window still needs an upper bound for the query, so use one far in the future
+	until := now.AddDate(100, 0, 0)
+	if !opts.Until.IsZero() {
+		until = opts.Until
+	}
+
+	params := listParams{
+		op:       strings.ToLower(op),
+		statuses: make([]string, len(opts.Statuses)),
+		reqFrom:  pgtype.Timestamp{Time: from, Valid: true},
+		reqUntil: pgtype.Timestamp{Time: until, Valid: true},
+		pageSize: int32(opts.PageSize),
+	}
+	for i, status := range opts.Statuses {
+		params.statuses[i] = string(status)
+	}
+	if params.pageSize <= 0 {
+		params.pageSize = ALYA_LIST_PAGESIZE
+	}
+
+	if opts.PageToken != "" {
+		reqAt, id, err := decodePageToken(opts.PageToken)
+		if err != nil {
+			return listParams{}, err
+		}
+		params.afterReqAt = pgtype.Timestamp{Time: reqAt, Valid: true}
+		params.afterID = pgtype.UUID{Bytes: id, Valid: true}
+	}
+	return params, nil
+}
+
+// encodePageToken returns the token for the page which follows the entry with the given reqat and ID.
+func encodePageToken(reqAt time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d_%s", reqAt.UnixMicro(), id)))
+}
+
+// decodePageToken extracts the reqat and ID of the last entry of the previous page from a page token.
+func decodePageToken(token string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, ErrInvalidPageToken
+	}
+	micros, idStr, found := strings.Cut(string(raw), "_")
+	if !found {
+		return time.Time{}, uuid.UUID{}, ErrInvalidPageToken
+	}
+	usec, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, ErrInvalidPageToken
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, ErrInvalidPageToken
+	}
+	return time.UnixMicro(usec).UTC(), id, nil
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewListParams(t *testing.T) {
+	_, err := newListParams("op1", 0, nil)
+	assert.True(t, errors.Is(err, ErrInvalidListAge))
+
+	before := time.Now()
+	params, err := newListParams("OP1", 7, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "op1", params.op)
+	assert.Empty(t, params.statuses)
+	assert.WithinDuration(t, before.AddDate(0, 0, -7), params.reqFrom.Time, 5*time.Second)
+	assert.True(t, params.reqUntil.Time.After(before))
+	assert.Equal(t, int32(ALYA_LIST_PAGESIZE), params.pageSize)
+	assert.False(t, params.afterReqAt.Valid)
+	assert.False(t, params.afterID.Valid)
+
+	// A From later than the age limit narrows the window, an earlier one does not widen it
+	midnight := time.Now().Truncate(24 * time.Hour)
+	params, err = newListParams("", 7, &ListOptions{
+		Statuses: []batchsqlc.StatusEnum{batchsqlc.StatusEnumFailed},
+		From:     midnight,
+		PageSize: 20,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"failed"}, params.statuses)
+	assert.Equal(t, midnight, params.reqFrom.Time)
+	assert.Equal(t, int32(20), params.pageSize)
+
+	params, err = newListParams("", 1, &ListOptions{From: before.AddDate(0, 0, -7)})
+	assert.NoError(t, err)
+	assert.WithinDuration(t, before.AddDate(0, 0, -1), params.reqFrom.Time, 5*time.Second)
+
+	_, err = newListParams("", 1, &ListOptions{PageToken: "not-a-token"})
+	assert.True(t, errors.Is(err, ErrInvalidPageToken))
+}
+
+func TestPageToken(t *testing.T) {
+	reqAt := time.Date(2024, 3, 1, 10, 30, 0, 123456000, time.UTC)
+	id := uuid.New()
+
+	gotReqAt, gotID, err := decodePageToken(encodePageToken(reqAt, id))
+	assert.NoError(t, err)
+	assert.True(t, reqAt.Equal(gotReqAt))
+	assert.Equal(t, id, gotID)
+
+	params, err := newListParams("", 1, &ListOptions{PageToken: encodePageToken(reqAt, id)})
+	assert.NoError(t, err)
+	assert.True(t, params.afterReqAt.Valid)
+	assert.Equal(t, pgtype.UUID{Bytes: id, Valid: true}, params.afterID)
+}
+
+func TestBatchList(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	reqAt := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	mockQuerier := &mocks.QuerierMock{
+		ListBatchesFunc: func(ctx context.Context, arg batchsqlc.ListBatchesParams) ([]batchsqlc.ListBatchesRow, error) {
+			return []batchsqlc.ListBatchesRow{
+				{ID: ids[0], App: "app1", Op: "op1", Context: []byte(`{}`), Status: batchsqlc.StatusEnumFailed,
+					Reqat: pgtype.Timestamp{Time: reqAt, Valid: true}, Outputfiles: []byte(`{"errlist":"obj1"}`),
+					Nrows: 10, Nsuccess: pgtype.Int4{Int32: 8, Valid: true}, Nfailed: pgtype.Int4{Int32: 2, Valid: true}},
+				{ID: ids[1], App: "app1", Op: "op1", Context: []byte(`{}`), Status: batchsqlc.StatusEnumInprog,
+					Reqat: pgtype.Timestamp{Time: reqAt.Add(-time.Hour), Valid: true}, Nrows: 5},
+			}, nil
+		},
+	}
+	jm.Queries = mockQuerier
+
+	// A full page comes with a token for the next one
+	batchlist, token, err := jm.BatchList("app1", "op1", 1, &ListOptions{PageSize: 2})
+	assert.NoError(t, err)
+	assert.Len(t, batchlist, 2)
+	assert.Equal(t, ids[0].String(), batchlist[0].ID)
+	assert.Equal(t, 10, batchlist[0].NRows)
+	assert.Equal(t, 2, batchlist[0].NFailed)
+	assert.Equal(t, "obj1", batchlist[0].OutputFiles["errlist"])
+	assert.True(t, batchlist[1].DoneAt.IsZero())
+	assert.NotEmpty(t, token)
+
+	// The token points at the last batch of the page
+	_, _, err = jm.BatchList("app1", "op1", 1, &ListOptions{PageSize: 2, PageToken: token})
+	assert.NoError(t, err)
+	call := mockQuerier.ListBatchesCalls()[1].Arg
+	assert.True(t, reqAt.Add(-time.Hour).Equal(call.Afterreqat.Time))
+	assert.Equal(t, pgtype.UUID{Bytes: ids[1], Valid: true}, call.Afterid)
+
+	// A short page is the last one
+	_, token, err = jm.BatchList("app1", "op1", 1, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, token)
+}
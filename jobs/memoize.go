@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ErrMemoTTLAlreadyRegistered is returned when attempting to register a second memoization TTL
+// for the same (app, op) combination.
+var ErrMemoTTLAlreadyRegistered = errors.New("memoization TTL already registered for this app and operation")
+
+// ErrInvalidMemoTTL is returned by RegisterSlowQueryMemo when the TTL is not positive.
+var ErrInvalidMemoTTL = errors.New("memoization TTL must be greater than 0")
+
+// RegisterSlowQueryMemo makes SlowQuerySubmit reuse the slow queries of an (app, op) instead of
+// queueing the same work again. When a slow query is submitted with the same context and input as an
+// earlier one which is still queued, in progress or paused, or which succeeded less than ttl ago, the
+// ID of the earlier one is returned. Queries which failed or were aborted are not reused. Queries
+// submitted at the same moment may still both be queued, since they do not see each other.
+// Each (app, op) combination can only have one registered memoization TTL.
+// The 'op' parameter is case-insensitive and will be converted to lowercase before registration.
+func (jm *JobManager) RegisterSlowQueryMemo(app string, op string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrInvalidMemoTTL
+	}
+
+	// Convert op to lowercase, as it is stored in the database
+	op = strings.ToLower(op)
+
+	key := app + op
+	_, exists := jm.memottls[key]
+	if exists {
+		return fmt.Errorf("%w: app=%s, op=%s", ErrMemoTTLAlreadyRegistered, app, op)
+	}
+	jm.memottls[key] = ttl
+	return nil
+}
+
+// SlowQueryInvalidate stops the slow queries of an (app, op) with the given context and input from
+// being reused by SlowQuerySubmit, for instance because the data they read has changed. Queries which
+// are running are not stopped. It returns the number of slow queries invalidated.
+func (jm *JobManager) SlowQueryInvalidate(app, op string, inputContext, input JSONstr) (int, error) {
+	return jm.forgetMemoizedQueries(app, op, hashSlowQuery(inputContext, input))
+}
+
+// SlowQueryInvalidateAll stops all the slow queries of an (app, op) from being reused by
+// SlowQuerySubmit. It returns the number of slow queries invalidated.
+func (jm *JobManager) SlowQueryInvalidateAll(app, op string) (int, error) {
+	return jm.forgetMemoizedQueries(app, op, nil)
+}
+
+func (jm *JobManager) forgetMemoizedQueries(app, op string, memoHash []byte) (int, error) {
+	n, err := jm.Queries.ForgetMemoizedQueries(context.Background(), batchsqlc.ForgetMemoizedQueriesParams{
+		App:      app,
+		Op:       strings.ToLower(op),
+		Memohash: memoHash,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to invalidate slow queries of app %s and op %s: %v", app, op, err)
+	}
+	return int(n), nil
+}
+
+// hashSlowQuery returns the hash of the context and input of a slow query, which is its row with line 0
+func hashSlowQuery(inputContext, input JSONstr) []byte {
+	return hashPayload(inputContext, []BatchInput_t{{Line: 0, Input: input}})
+}
+
+// memoizedQuery returns the ID of the latest slow query of the (app, op) with the memo hash which
+// can be reused, if any
+func memoizedQuery(ctx context.Context, q batchsqlc.Querier, app, op string, memoHash []byte, ttl time.Duration) (uuid.UUID, bool, error) {
+	reqID, err := q.GetMemoizedQuery(ctx, batchsqlc.GetMemoizedQueryParams{
+		App:       app,
+		Op:        strings.ToLower(op),
+		Memohash:  memoHash,
+		Doneafter: pgtype.Timestamp{Time: time.Now().Add(-ttl), Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to look up memoized slow query: %v", err)
+	}
+	return reqID, true, nil
+}
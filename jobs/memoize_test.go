@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterSlowQueryMemo(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	assert.Equal(t, ErrInvalidMemoTTL, jm.RegisterSlowQueryMemo("app1", "statement", 0))
+	assert.NoError(t, jm.RegisterSlowQueryMemo("app1", "STATEMENT", time.Minute))
+	err := jm.RegisterSlowQueryMemo("app1", "statement", time.Hour)
+	assert.True(t, errors.Is(err, ErrMemoTTLAlreadyRegistered))
+}
+
+func TestSlowQueryMemo(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "statement", echoSlowQueryProcessor{}))
+	assert.NoError(t, jm.RegisterSlowQueryMemo("app1", "statement", time.Hour))
+
+	queryctx, _ := NewJSONstr(`{"month":"2024-03"}`)
+	input, _ := NewJSONstr(`{"account":"A1"}`)
+	otherInput, _ := NewJSONstr(`{"account":"A2"}`)
+
+	// An equivalent query which is queued is reused
+	reqID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, input)
+	assert.NoError(t, err)
+	sameID, err := jm.SlowQuerySubmit("app1", "Statement", queryctx, input)
+	assert.NoError(t, err)
+	assert.Equal(t, reqID, sameID)
+	otherID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, otherInput)
+	assert.NoError(t, err)
+	assert.NotEqual(t, reqID, otherID)
+
+	// and so is one which has succeeded within the TTL
+	processQueuedRows(t, jm)
+	status, _, _, _, err := jm.SlowQueryDone(reqID)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchSuccess, status)
+	sameID, err = jm.SlowQuerySubmit("app1", "statement", queryctx, input)
+	assert.NoError(t, err)
+	assert.Equal(t, reqID, sameID)
+
+	// WithoutMemo queues a new query, which is then the one reused
+	newID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, input, WithoutMemo())
+	assert.NoError(t, err)
+	assert.NotEqual(t, reqID, newID)
+	sameID, err = jm.SlowQuerySubmit("app1", "statement", queryctx, input)
+	assert.NoError(t, err)
+	assert.Equal(t, newID, sameID)
+
+	// An invalidated query is not reused
+	n, err := jm.SlowQueryInvalidate("app1", "statement", queryctx, input)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	freshID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, input)
+	assert.NoError(t, err)
+	assert.NotEqual(t, newID, freshID)
+	sameID, err = jm.SlowQuerySubmit("app1", "statement", queryctx, otherInput)
+	assert.NoError(t, err)
+	assert.Equal(t, otherID, sameID)
+
+	n, err = jm.SlowQueryInvalidateAll("app1", "statement")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	freshOtherID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, otherInput)
+	assert.NoError(t, err)
+	assert.NotEqual(t, otherID, freshOtherID)
+}
+
+func TestSlowQueryMemoExpires(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "statement", echoSlowQueryProcessor{}))
+	assert.NoError(t, jm.RegisterSlowQueryMemo("app1", "statement", 10*time.Millisecond))
+
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"account":"A1"}`)
+	reqID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, input)
+	assert.NoError(t, err)
+	processQueuedRows(t, jm)
+
+	time.Sleep(20 * time.Millisecond)
+	newID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, input)
+	assert.NoError(t, err)
+	assert.NotEqual(t, reqID, newID)
+}
+
+func TestSlowQueryWithoutMemo(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "echo", echoSlowQueryProcessor{}))
+
+	// Slow queries of an (app, op) which is not memoized are always queued
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"n":1}`)
+	reqID, err := jm.SlowQuerySubmit("app1", "echo", queryctx, input)
+	assert.NoError(t, err)
+	otherID, err := jm.SlowQuerySubmit("app1", "echo", queryctx, input)
+	assert.NoError(t, err)
+	assert.NotEqual(t, reqID, otherID)
+}
+
+func TestSlowQueryMemoIdempotencyKey(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "statement", echoSlowQueryProcessor{}))
+	assert.NoError(t, jm.RegisterSlowQueryMemo("app1", "statement", time.Hour))
+
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"account":"A1"}`)
+	reqID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, input)
+	assert.NoError(t, err)
+
+	// A new key is recorded with a query of its own rather than dropped on the memoized one
+	keyedID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, input, WithIdempotencyKey("stmt-1"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, reqID, keyedID)
+
+	// so that the key still resolves to it once it is no longer memoized
+	_, err = jm.SlowQueryInvalidateAll("app1", "statement")
+	assert.NoError(t, err)
+	againID, err := jm.SlowQuerySubmit("app1", "statement", queryctx, input, WithIdempotencyKey("stmt-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, keyedID, againID)
+
+	otherInput, _ := NewJSONstr(`{"account":"A2"}`)
+	_, err = jm.SlowQuerySubmit("app1", "statement", queryctx, otherInput, WithIdempotencyKey("stmt-1"))
+	assert.True(t, errors.Is(err, ErrIdempotencyConflict))
+}
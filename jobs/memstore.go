@@ -0,0 +1,1589 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// MemJobStore is an implementation of JobStore which keeps batches, slow queries and their rows in
+// memory. It is meant for tests: processors can be run end-to-end through a JobManager, from submit
+// to Run to BatchDone, without a database. Its data is lost when the process exits, and it cannot be
+// shared between processes.
+//
+// Transactions are serialized with each other, so a goroutine must not begin a transaction while it
+// has one in progress. A transaction is rolled back by undoing its changes; queries run outside a
+// transaction are not isolated from a transaction in progress.
+type MemJobStore struct {
+	txmu sync.Mutex // held from the beginning to the end of each transaction
+
+	mu           sync.Mutex // guards the fields below
+	batches      map[uuid.UUID]batchsqlc.Batch
+	rows         map[int64]batchsqlc.Batchrow
+	batchrowids  map[uuid.UUID][]int64 // rowids of the rows of each batch, in insertion order
+	lastrowid    int64
+	results      map[uuid.UUID][]batchsqlc.Batchrowresult // results replaced by RetryBatchRows, by batch
+	files        map[int32]batchsqlc.BatchFile
+	lastfileid   int32
+	workers      map[string]batchsqlc.Worker
+	recurring    map[string]batchsqlc.Recurringjob
+	pipelines    map[uuid.UUID]batchsqlc.Pipeline
+	steps        map[uuid.UUID][]batchsqlc.Pipelinestep // steps of each pipeline, in order
+	listeners    map[int]func()
+	lastlistener int
+}
+
+// NewMemJobStore creates a new, empty instance of MemJobStore
+func NewMemJobStore() *MemJobStore {
+	return &MemJobStore{
+		batches:     make(map[uuid.UUID]batchsqlc.Batch),
+		rows:        make(map[int64]batchsqlc.Batchrow),
+		batchrowids: make(map[uuid.UUID][]int64),
+		results:     make(map[uuid.UUID][]batchsqlc.Batchrowresult),
+		files:       make(map[int32]batchsqlc.BatchFile),
+		workers:     make(map[string]batchsqlc.Worker),
+		recurring:   make(map[string]batchsqlc.Recurringjob),
+		pipelines:   make(map[uuid.UUID]batchsqlc.Pipeline),
+		steps:       make(map[uuid.UUID][]batchsqlc.Pipelinestep),
+		listeners:   make(map[int]func()),
+	}
+}
+
+// Queries returns the queries of the store, each applied to the store straight away
+func (s *MemJobStore) Queries() batchsqlc.Querier {
+	return &memQueries{s: s}
+}
+
+// Begin starts a transaction, waiting for the transaction in progress, if any, to end
+func (s *MemJobStore) Begin(ctx context.Context) (JobStoreTx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.txmu.Lock()
+	tx := &memJobStoreTx{s: s}
+	tx.queries = &memQueries{s: s, tx: tx}
+	return tx, nil
+}
+
+// Listen calls wake whenever NotifyBatchQueued is run on the store, or when the transaction which
+// ran it commits. It returns when ctx is cancelled.
+func (s *MemJobStore) Listen(ctx context.Context, wake func()) error {
+	s.mu.Lock()
+	s.lastlistener++
+	id := s.lastlistener
+	s.listeners[id] = wake
+	s.mu.Unlock()
+
+	<-ctx.Done()
+
+	s.mu.Lock()
+	delete(s.listeners, id)
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+// notify calls the listeners of the store
+func (s *MemJobStore) notify() {
+	s.mu.Lock()
+	wakes := make([]func(), 0, len(s.listeners))
+	for _, wake := range s.listeners {
+		wakes = append(wakes, wake)
+	}
+	s.mu.Unlock()
+
+	for _, wake := range wakes {
+		wake()
+	}
+}
+
+// memJobStoreTx is a transaction on a MemJobStore
+type memJobStoreTx struct {
+	s        *MemJobStore
+	queries  *memQueries
+	undo     []func() // restores the entries changed by the transaction, guarded by s.mu
+	notified bool     // NotifyBatchQueued was run in the transaction
+	done     bool
+}
+
+func (t *memJobStoreTx) Queries() batchsqlc.Querier {
+	return t.queries
+}
+
+func (t *memJobStoreTx) Commit(ctx context.Context) error {
+	if t.done {
+		return pgx.ErrTxClosed
+	}
+	t.done = true
+	t.s.mu.Lock()
+	t.undo = nil
+	t.s.mu.Unlock()
+	t.s.txmu.Unlock()
+
+	if t.notified {
+		t.s.notify()
+	}
+	return nil
+}
+
+func (t *memJobStoreTx) Rollback(ctx context.Context) error {
+	if t.done {
+		return pgx.ErrTxClosed
+	}
+	t.done = true
+	t.s.mu.Lock()
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		t.undo[i]()
+	}
+	t.undo = nil
+	t.s.mu.Unlock()
+	t.s.txmu.Unlock()
+	return nil
+}
+
+// memQueries runs the queries of pg/queries/batch.sql on a MemJobStore, within tx if it is not nil.
+// Each query holds s.mu while it runs.
+type memQueries struct {
+	s  *MemJobStore
+	tx *memJobStoreTx
+}
+
+var _ batchsqlc.Querier = (*memQueries)(nil)
+
+// remember records the current entry of m for key, to be restored if the transaction of q is
+// rolled back. It must be called with s.mu held, before the entry is changed.
+func remember[K comparable, V any](q *memQueries, m map[K]V, key K) {
+	if q.tx == nil {
+		return
+	}
+	old, existed := m[key]
+	q.tx.undo = append(q.tx.undo, func() {
+		if existed {
+			m[key] = old
+		} else {
+			delete(m, key)
+		}
+	})
+}
+
+func (q *memQueries) putBatch(batch batchsqlc.Batch) {
+	remember(q, q.s.batches, batch.ID)
+	q.s.batches[batch.ID] = batch
+}
+
+func (q *memQueries) putRow(row batchsqlc.Batchrow) {
+	remember(q, q.s.rows, row.Rowid)
+	q.s.rows[row.Rowid] = row
+}
+
+// insertRow inserts a queued row in the batch, which must exist, and counts it in nrows
+func (q *memQueries) insertRow(batch uuid.UUID, line int32, input []byte, reqat pgtype.Timestamp, partkey pgtype.Text) error {
+	b, exists := q.s.batches[batch]
+	if !exists {
+		return fmt.Errorf("batch %s does not exist", batch)
+	}
+	b.Nrows++
+	q.putBatch(b)
+	q.copyRow(batch, line, input, reqat, partkey)
+	return nil
+}
+
+// copyRow inserts a queued row in the batch without counting it, as COPY does
+func (q *memQueries) copyRow(batch uuid.UUID, line int32, input []byte, reqat pgtype.Timestamp, partkey pgtype.Text) {
+	q.s.lastrowid++
+	remember(q, q.s.batchrowids, batch)
+	q.s.batchrowids[batch] = append(q.s.batchrowids[batch], q.s.lastrowid)
+	q.putRow(batchsqlc.Batchrow{
+		Rowid:     q.s.lastrowid,
+		Batch:     batch,
+		Line:      line,
+		Input:     input,
+		Status:    batchsqlc.StatusEnumQueued,
+		Reqat:     reqat,
+		CreatedAt: memNow(),
+		Runno:     1,
+		Partkey:   partkey,
+	})
+}
+
+// batchByIdempotencyKey returns the batch of the (app, op) submitted with key, if there is one
+func (q *memQueries) batchByIdempotencyKey(app, op string, key pgtype.Text) (batchsqlc.Batch, bool) {
+	if !key.Valid {
+		return batchsqlc.Batch{}, false
+	}
+	for _, batch := range q.s.batches {
+		if batch.App == app && batch.Op == op && batch.Idempotencykey == key {
+			return batch, true
+		}
+	}
+	return batchsqlc.Batch{}, false
+}
+
+// countFinishedRow adds a row which has finished with the given status to the counters of its batch
+func (q *memQueries) countFinishedRow(batchID uuid.UUID, status batchsqlc.StatusEnum) {
+	batch, exists := q.s.batches[batchID]
+	if !exists {
+		return
+	}
+	one := pgtype.Int4{Int32: 1, Valid: true}
+	switch status {
+	case batchsqlc.StatusEnumSuccess:
+		batch.Nsuccess = addInt4(batch.Nsuccess, one)
+	case batchsqlc.StatusEnumFailed, batchsqlc.StatusEnumDeadletter:
+		batch.Nfailed = addInt4(batch.Nfailed, one)
+	case batchsqlc.StatusEnumAborted:
+		batch.Naborted = addInt4(batch.Naborted, one)
+	}
+	q.putBatch(batch)
+}
+
+// rowsOf returns the rows of a batch, in insertion order
+func (q *memQueries) rowsOf(batch uuid.UUID) []batchsqlc.Batchrow {
+	rowids := q.s.batchrowids[batch]
+	rows := make([]batchsqlc.Batchrow, 0, len(rowids))
+	for _, rowid := range rowids {
+		if row, exists := q.s.rows[rowid]; exists {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// sortedRowsOf returns the rows of a batch which have one of the given statuses, or all of them if
+// none is given, sorted by line
+func (q *memQueries) sortedRowsOf(batch uuid.UUID, statuses ...batchsqlc.StatusEnum) []batchsqlc.Batchrow {
+	var rows []batchsqlc.Batchrow
+	for _, row := range q.rowsOf(batch) {
+		if len(statuses) == 0 || hasStatus(row.Status, statuses...) {
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Line < rows[j].Line })
+	return rows
+}
+
+// sortedRowids returns the rowids of all the rows in the store, in ascending order
+func (q *memQueries) sortedRowids() []int64 {
+	rowids := make([]int64, 0, len(q.s.rows))
+	for rowid := range q.s.rows {
+		rowids = append(rowids, rowid)
+	}
+	sort.Slice(rowids, func(i, j int) bool { return rowids[i] < rowids[j] })
+	return rowids
+}
+
+// isSlowQuery reports whether a batch is a slow query, i.e. has a row with line 0
+func (q *memQueries) isSlowQuery(batch uuid.UUID) bool {
+	for _, row := range q.rowsOf(batch) {
+		if row.Line == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// leasedTo reports whether a row is in progress and leased to doneby
+func leasedTo(row batchsqlc.Batchrow, doneby pgtype.Text) bool {
+	return row.Status == batchsqlc.StatusEnumInprog && row.Doneby.Valid && doneby.Valid && row.Doneby.String == doneby.String
+}
+
+func hasStatus(status batchsqlc.StatusEnum, statuses ...batchsqlc.StatusEnum) bool {
+	for _, s := range statuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// notAfter reports whether the timestamp t is NULL or not after limit
+func notAfter(t, limit pgtype.Timestamp) bool {
+	return !t.Valid || !t.Time.After(limit.Time)
+}
+
+// addInt4 adds n to the counter c, treating a NULL counter as 0
+func addInt4(c, n pgtype.Int4) pgtype.Int4 {
+	if !n.Valid {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: c.Int32 + n.Int32, Valid: true}
+}
+
+// uuidLess orders UUIDs as Postgres does
+func uuidLess(a, b uuid.UUID) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+func memNow() pgtype.Timestamp {
+	return pgtype.Timestamp{Time: time.Now(), Valid: true}
+}
+
+func (q *memQueries) BulkInsertIntoBatchRows(ctx context.Context, arg batchsqlc.BulkInsertIntoBatchRowsParams) (int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	for i := range arg.Batch {
+		partkey := pgtype.Text{String: arg.Partkey[i], Valid: arg.Partkey[i] != ""}
+		if err := q.insertRow(arg.Batch[i], arg.Line[i], arg.Input[i], arg.Reqat[i], partkey); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(arg.Batch)), nil
+}
+
+func (q *memQueries) BulkUpdateBatchRowsBatchJob(ctx context.Context, arg batchsqlc.BulkUpdateBatchRowsBatchJobParams) ([]int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var updated []int64
+	for i, rowid := range arg.Rowid {
+		row, exists := q.s.rows[rowid]
+		if !exists || !leasedTo(row, arg.Doneby) {
+			continue
+		}
+		row.Status = batchsqlc.StatusEnum(arg.Status[i])
+		row.Doneat = arg.Doneat
+		row.Res = arg.Res[i]
+		row.Blobrows = arg.Blobrows[i]
+		row.Messages = arg.Messages[i]
+		row.Leaseexpiry = pgtype.Timestamp{}
+		q.putRow(row)
+		q.countFinishedRow(row.Batch, row.Status)
+		updated = append(updated, rowid)
+	}
+	return updated, nil
+}
+
+func (q *memQueries) CloseBatchInput(ctx context.Context, arg batchsqlc.CloseBatchInputParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists {
+		return nil
+	}
+	batch.Status = arg.Status
+	batch.Nrows += arg.Nrows
+	batch.Payloadhash = arg.Payloadhash
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) CopyIntoBatchRows(ctx context.Context, arg []batchsqlc.CopyIntoBatchRowsParams) (int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	for _, row := range arg {
+		if _, exists := q.s.batches[row.Batch]; !exists {
+			return 0, fmt.Errorf("batch %s does not exist", row.Batch)
+		}
+		q.copyRow(row.Batch, row.Line, row.Input, row.Reqat, row.Partkey)
+	}
+	return int64(len(arg)), nil
+}
+
+func (q *memQueries) CountBatchRowsByBatchIDAndStatus(ctx context.Context, arg batchsqlc.CountBatchRowsByBatchIDAndStatusParams) (int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var count int64
+	for _, row := range q.rowsOf(arg.Batch) {
+		if hasStatus(row.Status, arg.Status, arg.Status_2) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (q *memQueries) DeadLetterBatchRow(ctx context.Context, arg batchsqlc.DeadLetterBatchRowParams) (int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	row, exists := q.s.rows[arg.Rowid]
+	if !exists || !leasedTo(row, arg.Doneby) {
+		return 0, nil
+	}
+	row.Status = batchsqlc.StatusEnumDeadletter
+	row.Doneat = arg.Doneat
+	row.Messages = arg.Messages
+	row.Lasterr = arg.Lasterr
+	row.Errtrace = arg.Errtrace
+	row.Leaseexpiry = pgtype.Timestamp{}
+	q.putRow(row)
+	q.countFinishedRow(row.Batch, row.Status)
+	return 1, nil
+}
+
+func (q *memQueries) DeleteStaleWorkers(ctx context.Context, heartbeat pgtype.Timestamp) (int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var count int64
+	for id, worker := range q.s.workers {
+		if worker.Heartbeat.Valid && heartbeat.Valid && worker.Heartbeat.Time.Before(heartbeat.Time) {
+			remember(q, q.s.workers, id)
+			delete(q.s.workers, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (q *memQueries) DeleteWorker(ctx context.Context, id string) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	remember(q, q.s.workers, id)
+	delete(q.s.workers, id)
+	return nil
+}
+
+func (q *memQueries) ExtendWorkerLeases(ctx context.Context, arg batchsqlc.ExtendWorkerLeasesParams) (int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var count int64
+	for _, rowid := range q.sortedRowids() {
+		row := q.s.rows[rowid]
+		if leasedTo(row, arg.Doneby) {
+			row.Leaseexpiry = arg.Leaseexpiry
+			q.putRow(row)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (q *memQueries) FetchBatchRowsForBatchDone(ctx context.Context, batch uuid.UUID) ([]batchsqlc.FetchBatchRowsForBatchDoneRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.FetchBatchRowsForBatchDoneRow
+	for _, row := range q.sortedRowsOf(batch) {
+		items = append(items, batchsqlc.FetchBatchRowsForBatchDoneRow{
+			Line:     row.Line,
+			Status:   row.Status,
+			Res:      row.Res,
+			Messages: row.Messages,
+			Runno:    row.Runno,
+		})
+	}
+	return items, nil
+}
+
+// FetchBlockOfRows follows the ordering of the query in pg/queries/batch.sql: the rows of the batches
+// with the highest priority come first, and within a priority the apps take turns, as do the batches
+// of each app.
+func (q *memQueries) FetchBlockOfRows(ctx context.Context, arg batchsqlc.FetchBlockOfRowsParams) ([]batchsqlc.FetchBlockOfRowsRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+
+	type candidate struct {
+		batch              batchsqlc.Batch
+		row                batchsqlc.Batchrow
+		batchrank, apprank int
+	}
+	var candidates []*candidate
+	for _, batch := range q.s.batches {
+		if !hasStatus(batch.Status, batchsqlc.StatusEnumQueued, batchsqlc.StatusEnumInprog) || !notAfter(batch.Notbefore, arg.Nexttry) {
+			continue
+		}
+		// A row with a partition key waits for the rows before it with the same key
+		var rows []batchsqlc.Batchrow
+		pending := make(map[string]bool)
+		for _, row := range q.sortedRowsOf(batch.ID) {
+			waiting := row.Partkey.Valid && pending[row.Partkey.String]
+			if row.Status == arg.Status && notAfter(row.Nexttry, arg.Nexttry) && !waiting {
+				rows = append(rows, row)
+			}
+			if row.Partkey.Valid && hasStatus(row.Status, batchsqlc.StatusEnumQueued, batchsqlc.StatusEnumInprog) {
+				pending[row.Partkey.String] = true
+			}
+		}
+		for i, row := range rows {
+			if i >= int(arg.Perbatch) {
+				break
+			}
+			candidates = append(candidates, &candidate{batch: batch, row: row, batchrank: i + 1})
+		}
+	}
+
+	// Rank the rows of each app within a priority by their rank within their batch
+	type appKey struct {
+		priority int32
+		app      string
+	}
+	byApp := make(map[appKey][]*candidate)
+	for _, c := range candidates {
+		key := appKey{c.batch.Priority, c.batch.App}
+		byApp[key] = append(byApp[key], c)
+	}
+	for _, group := range byApp {
+		sort.Slice(group, func(i, j int) bool {
+			a, b := group[i], group[j]
+			if a.batchrank != b.batchrank {
+				return a.batchrank < b.batchrank
+			}
+			if !a.batch.Reqat.Time.Equal(b.batch.Reqat.Time) {
+				return a.batch.Reqat.Time.Before(b.batch.Reqat.Time)
+			}
+			return uuidLess(a.batch.ID, b.batch.ID)
+		})
+		for i, c := range group {
+			c.apprank = i + 1
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.batch.Priority != b.batch.Priority {
+			return a.batch.Priority > b.batch.Priority
+		}
+		if a.apprank != b.apprank {
+			return a.apprank < b.apprank
+		}
+		if !a.batch.Reqat.Time.Equal(b.batch.Reqat.Time) {
+			return a.batch.Reqat.Time.Before(b.batch.Reqat.Time)
+		}
+		if a.batch.ID != b.batch.ID {
+			return uuidLess(a.batch.ID, b.batch.ID)
+		}
+		return a.batchrank < b.batchrank
+	})
+	if len(candidates) > int(arg.Maxrows) {
+		candidates = candidates[:arg.Maxrows]
+	}
+
+	items := make([]batchsqlc.FetchBlockOfRowsRow, len(candidates))
+	for i, c := range candidates {
+		items[i] = batchsqlc.FetchBlockOfRowsRow{
+			App:      c.batch.App,
+			Status:   c.batch.Status,
+			Op:       c.batch.Op,
+			Context:  c.batch.Context,
+			Batch:    c.batch.ID,
+			Rowid:    c.row.Rowid,
+			Line:     c.row.Line,
+			Input:    c.row.Input,
+			Attempts: c.row.Attempts,
+		}
+	}
+	return items, nil
+}
+
+func (q *memQueries) ForgetMemoizedQueries(ctx context.Context, arg batchsqlc.ForgetMemoizedQueriesParams) (int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var count int64
+	for _, batch := range q.s.batches {
+		if batch.App != arg.App || batch.Op != arg.Op || batch.Memohash == nil {
+			continue
+		}
+		if arg.Memohash != nil && !bytes.Equal(batch.Memohash, arg.Memohash) {
+			continue
+		}
+		batch.Memohash = nil
+		q.putBatch(batch)
+		count++
+	}
+	return count, nil
+}
+
+func (q *memQueries) GetAbortedBatches(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var aborted []uuid.UUID
+	for _, id := range ids {
+		if batch, exists := q.s.batches[id]; exists && batch.Status == batchsqlc.StatusEnumAborted {
+			aborted = append(aborted, id)
+		}
+	}
+	return aborted, nil
+}
+
+func (q *memQueries) GetBatchByID(ctx context.Context, id uuid.UUID) (batchsqlc.Batch, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[id]
+	if !exists {
+		return batchsqlc.Batch{}, pgx.ErrNoRows
+	}
+	return batch, nil
+}
+
+func (q *memQueries) GetBatchByIdempotencyKey(ctx context.Context, arg batchsqlc.GetBatchByIdempotencyKeyParams) (batchsqlc.GetBatchByIdempotencyKeyRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.batchByIdempotencyKey(arg.App, arg.Op, arg.Idempotencykey)
+	if !exists {
+		return batchsqlc.GetBatchByIdempotencyKeyRow{}, pgx.ErrNoRows
+	}
+	return batchsqlc.GetBatchByIdempotencyKeyRow{ID: batch.ID, Payloadhash: batch.Payloadhash}, nil
+}
+
+func (q *memQueries) GetBatchCounts(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchCountsRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[id]
+	if !exists {
+		return batchsqlc.GetBatchCountsRow{}, pgx.ErrNoRows
+	}
+	return batchsqlc.GetBatchCountsRow{
+		Status:   batch.Status,
+		Nsuccess: batch.Nsuccess,
+		Nfailed:  batch.Nfailed,
+		Naborted: batch.Naborted,
+	}, nil
+}
+
+func (q *memQueries) GetBatchProgress(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchProgressRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[id]
+	if !exists {
+		return batchsqlc.GetBatchProgressRow{}, pgx.ErrNoRows
+	}
+	return batchsqlc.GetBatchProgressRow{
+		Status:    batch.Status,
+		Nrows:     batch.Nrows,
+		Nsuccess:  batch.Nsuccess,
+		Nfailed:   batch.Nfailed,
+		Naborted:  batch.Naborted,
+		Startedat: batch.Startedat,
+		Progress:  batch.Progress,
+	}, nil
+}
+
+func (q *memQueries) GetBatchRowsByBatchID(ctx context.Context, batch uuid.UUID) ([]batchsqlc.Batchrow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	return q.rowsOf(batch), nil
+}
+
+func (q *memQueries) GetBatchRowResults(ctx context.Context, batch uuid.UUID) ([]batchsqlc.Batchrowresult, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	items := append([]batchsqlc.Batchrowresult(nil), q.s.results[batch]...)
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Line != items[j].Line {
+			return items[i].Line < items[j].Line
+		}
+		return items[i].Runno < items[j].Runno
+	})
+	return items, nil
+}
+
+func (q *memQueries) GetBatchRowsByBatchIDSorted(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetBatchRowsByBatchIDSortedRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.GetBatchRowsByBatchIDSortedRow
+	for _, row := range q.sortedRowsOf(batch) {
+		items = append(items, batchsqlc.GetBatchRowsByBatchIDSortedRow{
+			Rowid:    row.Rowid,
+			Line:     row.Line,
+			Input:    row.Input,
+			Status:   row.Status,
+			Reqat:    row.Reqat,
+			Doneat:   row.Doneat,
+			Res:      row.Res,
+			Blobrows: row.Blobrows,
+			Messages: row.Messages,
+			Doneby:   row.Doneby,
+		})
+	}
+	return items, nil
+}
+
+func (q *memQueries) GetBatchRowsCount(ctx context.Context, batch uuid.UUID) (int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	return int64(len(q.rowsOf(batch))), nil
+}
+
+func (q *memQueries) GetBatchStatus(ctx context.Context, id uuid.UUID) (batchsqlc.StatusEnum, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[id]
+	if !exists {
+		return "", pgx.ErrNoRows
+	}
+	return batch.Status, nil
+}
+
+func (q *memQueries) GetBatchStatusAndOutputFiles(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchStatusAndOutputFilesRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[id]
+	rows := q.rowsOf(id)
+	if !exists || len(rows) == 0 {
+		return batchsqlc.GetBatchStatusAndOutputFilesRow{}, pgx.ErrNoRows
+	}
+	return batchsqlc.GetBatchStatusAndOutputFilesRow{
+		Status:      batch.Status,
+		Outputfiles: batch.Outputfiles,
+		Res:         rows[0].Res,
+	}, nil
+}
+
+func (q *memQueries) GetCompletedBatches(ctx context.Context) ([]uuid.UUID, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var ids []uuid.UUID
+	for id, batch := range q.s.batches {
+		if isFinalStatus(batch.Status) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return uuidLess(ids[i], ids[j]) })
+	return ids, nil
+}
+
+// deadLetterRow returns a dead-lettered row along with the app and op of its batch
+func (q *memQueries) deadLetterRow(row batchsqlc.Batchrow) batchsqlc.GetDeadLetterRowRow {
+	batch := q.s.batches[row.Batch]
+	return batchsqlc.GetDeadLetterRowRow{
+		Rowid:    row.Rowid,
+		Batch:    row.Batch,
+		App:      batch.App,
+		Op:       batch.Op,
+		Line:     row.Line,
+		Input:    row.Input,
+		Attempts: row.Attempts,
+		Lasterr:  row.Lasterr,
+		Errtrace: row.Errtrace,
+		Doneat:   row.Doneat,
+	}
+}
+
+func (q *memQueries) GetDeadLetterBatches(ctx context.Context, rowids []int64) ([]uuid.UUID, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	seen := make(map[uuid.UUID]bool)
+	var items []uuid.UUID
+	for _, rowid := range rowids {
+		row, exists := q.s.rows[rowid]
+		if !exists || row.Status != batchsqlc.StatusEnumDeadletter || seen[row.Batch] {
+			continue
+		}
+		seen[row.Batch] = true
+		items = append(items, row.Batch)
+	}
+	sort.Slice(items, func(i, j int) bool { return uuidLess(items[i], items[j]) })
+	return items, nil
+}
+
+func (q *memQueries) GetDeadLetterRow(ctx context.Context, rowid int64) (batchsqlc.GetDeadLetterRowRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	row, exists := q.s.rows[rowid]
+	if !exists || row.Status != batchsqlc.StatusEnumDeadletter {
+		return batchsqlc.GetDeadLetterRowRow{}, pgx.ErrNoRows
+	}
+	return q.deadLetterRow(row), nil
+}
+
+func (q *memQueries) GetDueRecurringJob(ctx context.Context, arg batchsqlc.GetDueRecurringJobParams) (batchsqlc.GetDueRecurringJobRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	job, exists := q.s.recurring[arg.Name]
+	if !exists || job.Nextrun.Time.After(arg.Nextrun.Time) {
+		return batchsqlc.GetDueRecurringJobRow{}, pgx.ErrNoRows
+	}
+	return batchsqlc.GetDueRecurringJobRow{Name: job.Name, Nextrun: job.Nextrun}, nil
+}
+
+func (q *memQueries) GetMemoizedQuery(ctx context.Context, arg batchsqlc.GetMemoizedQueryParams) (uuid.UUID, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var latest batchsqlc.Batch
+	found := false
+	for _, batch := range q.s.batches {
+		if batch.App != arg.App || batch.Op != arg.Op || batch.Memohash == nil || !bytes.Equal(batch.Memohash, arg.Memohash) {
+			continue
+		}
+		pending := hasStatus(batch.Status, batchsqlc.StatusEnumQueued, batchsqlc.StatusEnumInprog, batchsqlc.StatusEnumPaused)
+		succeeded := batch.Status == batchsqlc.StatusEnumSuccess && batch.Doneat.Time.After(arg.Doneafter.Time)
+		if !pending && !succeeded {
+			continue
+		}
+		if !found || batch.Reqat.Time.After(latest.Reqat.Time) {
+			latest, found = batch, true
+		}
+	}
+	if !found {
+		return uuid.Nil, pgx.ErrNoRows
+	}
+	return latest.ID, nil
+}
+
+func (q *memQueries) GetPendingBatchRows(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetPendingBatchRowsRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.GetPendingBatchRowsRow
+	for _, row := range q.rowsOf(batch) {
+		if !hasStatus(row.Status, batchsqlc.StatusEnumQueued, batchsqlc.StatusEnumInprog) {
+			continue
+		}
+		items = append(items, batchsqlc.GetPendingBatchRowsRow{
+			Rowid:    row.Rowid,
+			Line:     row.Line,
+			Input:    row.Input,
+			Status:   row.Status,
+			Reqat:    row.Reqat,
+			Doneat:   row.Doneat,
+			Res:      row.Res,
+			Blobrows: row.Blobrows,
+			Messages: row.Messages,
+			Doneby:   row.Doneby,
+		})
+	}
+	return items, nil
+}
+
+func (q *memQueries) GetPipelineByID(ctx context.Context, id uuid.UUID) (batchsqlc.Pipeline, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	pipeline, exists := q.s.pipelines[id]
+	if !exists {
+		return batchsqlc.Pipeline{}, pgx.ErrNoRows
+	}
+	return pipeline, nil
+}
+
+func (q *memQueries) GetPipelineSteps(ctx context.Context, pipeline uuid.UUID) ([]batchsqlc.GetPipelineStepsRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.GetPipelineStepsRow
+	for _, step := range q.s.steps[pipeline] {
+		batch := q.s.batches[step.Batch]
+		items = append(items, batchsqlc.GetPipelineStepsRow{
+			Step:   step.Step,
+			Batch:  step.Batch,
+			App:    batch.App,
+			Op:     batch.Op,
+			Status: batch.Status,
+		})
+	}
+	return items, nil
+}
+
+func (q *memQueries) GetPipelineToAdvance(ctx context.Context, arg batchsqlc.GetPipelineToAdvanceParams) (batchsqlc.GetPipelineToAdvanceRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var due []batchsqlc.GetPipelineToAdvanceRow
+	var doneat []time.Time
+	for id, pipeline := range q.s.pipelines {
+		if pipeline.Status != batchsqlc.StatusEnumInprog || !slices.Contains(arg.Names, pipeline.Name) || slices.Contains(arg.Skipped, id) {
+			continue
+		}
+		steps := q.s.steps[id]
+		if len(steps) == 0 {
+			continue
+		}
+		batch := q.s.batches[steps[len(steps)-1].Batch]
+		if !isFinalStatus(batch.Status) {
+			continue
+		}
+		due = append(due, batchsqlc.GetPipelineToAdvanceRow{
+			ID:          id,
+			Name:        pipeline.Name,
+			Context:     pipeline.Context,
+			Priority:    pipeline.Priority,
+			Step:        pipeline.Step,
+			Batch:       batch.ID,
+			Batchstatus: batch.Status,
+		})
+		doneat = append(doneat, batch.Doneat.Time)
+	}
+	if len(due) == 0 {
+		return batchsqlc.GetPipelineToAdvanceRow{}, pgx.ErrNoRows
+	}
+	first := 0
+	for i := range due {
+		if doneat[i].Before(doneat[first]) || (doneat[i].Equal(doneat[first]) && uuidLess(due[i].ID, due[first].ID)) {
+			first = i
+		}
+	}
+	return due[first], nil
+}
+
+func (q *memQueries) GetProcessedBatchRowsByBatchIDSorted(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow
+	for _, row := range q.sortedRowsOf(batch, batchsqlc.StatusEnumSuccess, batchsqlc.StatusEnumFailed) {
+		if row.Blobrows == nil {
+			continue
+		}
+		items = append(items, batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow{
+			Rowid:    row.Rowid,
+			Line:     row.Line,
+			Input:    row.Input,
+			Status:   row.Status,
+			Reqat:    row.Reqat,
+			Doneat:   row.Doneat,
+			Res:      row.Res,
+			Blobrows: row.Blobrows,
+			Messages: row.Messages,
+			Doneby:   row.Doneby,
+		})
+	}
+	return items, nil
+}
+
+func (q *memQueries) InsertBatchFile(ctx context.Context, arg batchsqlc.InsertBatchFileParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	if _, exists := q.s.batches[arg.BatchID]; !exists {
+		return fmt.Errorf("batch %s does not exist", arg.BatchID)
+	}
+	q.s.lastfileid++
+	remember(q, q.s.files, q.s.lastfileid)
+	q.s.files[q.s.lastfileid] = batchsqlc.BatchFile{
+		ID:          q.s.lastfileid,
+		BatchID:     arg.BatchID,
+		ObjectID:    arg.ObjectID,
+		Filename:    arg.Filename,
+		Size:        arg.Size,
+		Checksum:    arg.Checksum,
+		ContentType: arg.ContentType,
+		Status:      arg.Status,
+		ReceivedAt:  arg.ReceivedAt,
+		Metadata:    arg.Metadata,
+		CreatedAt:   memNow(),
+	}
+	return nil
+}
+
+func (q *memQueries) InsertIntoBatchRows(ctx context.Context, arg batchsqlc.InsertIntoBatchRowsParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	return q.insertRow(arg.Batch, arg.Line, arg.Input, arg.Reqat, arg.Partkey)
+}
+
+func (q *memQueries) InsertIntoBatches(ctx context.Context, arg batchsqlc.InsertIntoBatchesParams) (uuid.UUID, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	if _, exists := q.s.batches[arg.ID]; exists {
+		return uuid.Nil, fmt.Errorf("batch %s already exists", arg.ID)
+	}
+	if _, exists := q.batchByIdempotencyKey(arg.App, arg.Op, arg.Idempotencykey); exists {
+		return uuid.Nil, pgx.ErrNoRows
+	}
+	q.putBatch(batchsqlc.Batch{
+		ID:             arg.ID,
+		App:            arg.App,
+		Op:             arg.Op,
+		Context:        arg.Context,
+		Status:         arg.Status,
+		Reqat:          arg.Reqat,
+		CreatedAt:      memNow(),
+		Priority:       arg.Priority,
+		Notbefore:      arg.Notbefore,
+		Idempotencykey: arg.Idempotencykey,
+		Payloadhash:    arg.Payloadhash,
+		Memohash:       arg.Memohash,
+	})
+	return arg.ID, nil
+}
+
+func (q *memQueries) InsertPipeline(ctx context.Context, arg batchsqlc.InsertPipelineParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	if _, exists := q.s.pipelines[arg.ID]; exists {
+		return fmt.Errorf("pipeline %s already exists", arg.ID)
+	}
+	remember(q, q.s.pipelines, arg.ID)
+	q.s.pipelines[arg.ID] = batchsqlc.Pipeline{
+		ID:       arg.ID,
+		Name:     arg.Name,
+		Context:  arg.Context,
+		Priority: arg.Priority,
+		Status:   batchsqlc.StatusEnumInprog,
+		Reqat:    arg.Reqat,
+	}
+	return nil
+}
+
+func (q *memQueries) InsertPipelineStep(ctx context.Context, arg batchsqlc.InsertPipelineStepParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	pipeline, exists := q.s.pipelines[arg.Pipeline]
+	if !exists {
+		return fmt.Errorf("pipeline %s does not exist", arg.Pipeline)
+	}
+	remember(q, q.s.steps, arg.Pipeline)
+	q.s.steps[arg.Pipeline] = append(slices.Clip(q.s.steps[arg.Pipeline]), batchsqlc.Pipelinestep{
+		Pipeline: arg.Pipeline,
+		Step:     arg.Step,
+		Batch:    arg.Batch,
+	})
+	pipeline.Step = arg.Step
+	remember(q, q.s.pipelines, arg.Pipeline)
+	q.s.pipelines[arg.Pipeline] = pipeline
+	return nil
+}
+
+func (q *memQueries) LeaseBatchRows(ctx context.Context, arg batchsqlc.LeaseBatchRowsParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	for _, rowid := range arg.Rowids {
+		row, exists := q.s.rows[rowid]
+		if !exists {
+			continue
+		}
+		row.Status = batchsqlc.StatusEnumInprog
+		row.Doneby = arg.Doneby
+		row.Leaseexpiry = arg.Leaseexpiry
+		row.Attempts++
+		q.putRow(row)
+	}
+	return nil
+}
+
+// listedBatches returns the batches matching the filters of ListBatches and ListSlowQueries, most
+// recent first
+func (q *memQueries) listedBatches(arg batchsqlc.ListBatchesParams, slowQueries bool) []batchsqlc.Batch {
+	var batches []batchsqlc.Batch
+	for _, batch := range q.s.batches {
+		if batch.App != arg.App || (arg.Op != "" && batch.Op != arg.Op) {
+			continue
+		}
+		if len(arg.Statuses) > 0 && !hasStatus(batch.Status, statusEnums(arg.Statuses)...) {
+			continue
+		}
+		if !arg.Reqfrom.Valid || !arg.Requntil.Valid || batch.Reqat.Time.Before(arg.Reqfrom.Time) || !batch.Reqat.Time.Before(arg.Requntil.Time) {
+			continue
+		}
+		if arg.Afterreqat.Valid && !batchBefore(batch, arg.Afterreqat.Time, arg.Afterid.Bytes) {
+			continue
+		}
+		if q.isSlowQuery(batch.ID) != slowQueries {
+			continue
+		}
+		batches = append(batches, batch)
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batchBefore(batches[j], batches[i].Reqat.Time, batches[i].ID)
+	})
+	if len(batches) > int(arg.Pagesize) {
+		batches = batches[:arg.Pagesize]
+	}
+	return batches
+}
+
+// batchBefore reports whether (batch.reqat, batch.id) < (reqat, id)
+func batchBefore(batch batchsqlc.Batch, reqat time.Time, id uuid.UUID) bool {
+	if !batch.Reqat.Time.Equal(reqat) {
+		return batch.Reqat.Time.Before(reqat)
+	}
+	return uuidLess(batch.ID, id)
+}
+
+func statusEnums(statuses []string) []batchsqlc.StatusEnum {
+	enums := make([]batchsqlc.StatusEnum, len(statuses))
+	for i, status := range statuses {
+		enums[i] = batchsqlc.StatusEnum(status)
+	}
+	return enums
+}
+
+func (q *memQueries) ListBatches(ctx context.Context, arg batchsqlc.ListBatchesParams) ([]batchsqlc.ListBatchesRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.ListBatchesRow
+	for _, batch := range q.listedBatches(arg, false) {
+		items = append(items, batchsqlc.ListBatchesRow{
+			ID:           batch.ID,
+			App:          batch.App,
+			Op:           batch.Op,
+			Context:      batch.Context,
+			Inputfile:    batch.Inputfile,
+			Status:       batch.Status,
+			Reqat:        batch.Reqat,
+			Doneat:       batch.Doneat,
+			Outputfiles:  batch.Outputfiles,
+			Nsuccess:     batch.Nsuccess,
+			Nfailed:      batch.Nfailed,
+			Naborted:     batch.Naborted,
+			Aggregate:    batch.Aggregate,
+			Aggregateerr: batch.Aggregateerr,
+			Nrows:        int64(len(q.rowsOf(batch.ID))),
+		})
+	}
+	return items, nil
+}
+
+func (q *memQueries) ListBatchRowResults(ctx context.Context, arg batchsqlc.ListBatchRowResultsParams) ([]batchsqlc.ListBatchRowResultsRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.ListBatchRowResultsRow
+	for _, row := range q.sortedRowsOf(arg.Batch) {
+		if len(items) == int(arg.Pagesize) {
+			break
+		}
+		if len(arg.Statuses) > 0 && !slices.Contains(arg.Statuses, string(row.Status)) {
+			continue
+		}
+		if row.Line < arg.Fromline || row.Line > arg.Toline {
+			continue
+		}
+		if arg.Afterline.Valid && (row.Line < arg.Afterline.Int32 || row.Line == arg.Afterline.Int32 && row.Rowid <= arg.Afterrowid.Int64) {
+			continue
+		}
+		items = append(items, batchsqlc.ListBatchRowResultsRow{
+			Rowid:    row.Rowid,
+			Line:     row.Line,
+			Status:   row.Status,
+			Res:      row.Res,
+			Messages: row.Messages,
+			Runno:    row.Runno,
+		})
+	}
+	return items, nil
+}
+
+func (q *memQueries) ListDeadLetterRows(ctx context.Context, arg batchsqlc.ListDeadLetterRowsParams) ([]batchsqlc.ListDeadLetterRowsRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.ListDeadLetterRowsRow
+	for _, rowid := range q.sortedRowids() {
+		row := q.s.rows[rowid]
+		batch := q.s.batches[row.Batch]
+		if row.Status != batchsqlc.StatusEnumDeadletter || batch.App != arg.App || batch.Op != arg.Op {
+			continue
+		}
+		items = append(items, batchsqlc.ListDeadLetterRowsRow(q.deadLetterRow(row)))
+	}
+	return items, nil
+}
+
+func (q *memQueries) ListSlowQueries(ctx context.Context, arg batchsqlc.ListSlowQueriesParams) ([]batchsqlc.ListSlowQueriesRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.ListSlowQueriesRow
+	for _, batch := range q.listedBatches(batchsqlc.ListBatchesParams(arg), true) {
+		items = append(items, batchsqlc.ListSlowQueriesRow{
+			ID:          batch.ID,
+			App:         batch.App,
+			Op:          batch.Op,
+			Context:     batch.Context,
+			Status:      batch.Status,
+			Reqat:       batch.Reqat,
+			Doneat:      batch.Doneat,
+			Outputfiles: batch.Outputfiles,
+		})
+	}
+	return items, nil
+}
+
+// NotifyBatchQueued wakes up the listeners of the store, on whichever channel it is sent
+func (q *memQueries) MarkBatchInprog(ctx context.Context, arg batchsqlc.MarkBatchInprogParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists || batch.Status != batchsqlc.StatusEnumQueued {
+		return nil
+	}
+	batch.Status = batchsqlc.StatusEnumInprog
+	if !batch.Startedat.Valid {
+		batch.Startedat = arg.Startedat
+	}
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) NotifyBatchQueued(ctx context.Context, arg batchsqlc.NotifyBatchQueuedParams) error {
+	if q.tx != nil {
+		q.s.mu.Lock()
+		q.tx.notified = true
+		q.s.mu.Unlock()
+		return nil
+	}
+	q.s.notify()
+	return nil
+}
+
+func (q *memQueries) ReclaimExpiredLeases(ctx context.Context, arg batchsqlc.ReclaimExpiredLeasesParams) ([]batchsqlc.ReclaimExpiredLeasesRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	policyMaxAttempts := make(map[string]int32, len(arg.Policykeys))
+	for i, key := range arg.Policykeys {
+		policyMaxAttempts[key] = arg.Policymaxattempts[i]
+	}
+	var items []batchsqlc.ReclaimExpiredLeasesRow
+	for _, rowid := range q.sortedRowids() {
+		row := q.s.rows[rowid]
+		if row.Status != batchsqlc.StatusEnumInprog || !row.Leaseexpiry.Valid || !row.Leaseexpiry.Time.Before(arg.Leaseexpiry.Time) {
+			continue
+		}
+		batch := q.s.batches[row.Batch]
+		maxAttempts, exists := policyMaxAttempts[batch.App+batch.Op]
+		if !exists {
+			maxAttempts = arg.Maxattempts
+		}
+		if row.Attempts >= maxAttempts {
+			row.Status = batchsqlc.StatusEnumDeadletter
+			row.Doneat = arg.Leaseexpiry
+		} else {
+			row.Status = batchsqlc.StatusEnumQueued
+			row.Doneat = pgtype.Timestamp{}
+		}
+		row.Lasterr = pgtype.Text{String: "lease expired before a result was recorded", Valid: true}
+		row.Doneby = pgtype.Text{}
+		row.Leaseexpiry = pgtype.Timestamp{}
+		q.putRow(row)
+		q.countFinishedRow(row.Batch, row.Status)
+		items = append(items, batchsqlc.ReclaimExpiredLeasesRow{Rowid: row.Rowid, Batch: row.Batch, Status: row.Status})
+	}
+	return items, nil
+}
+
+func (q *memQueries) RecordWorkerHeartbeat(ctx context.Context, arg batchsqlc.RecordWorkerHeartbeatParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	worker, exists := q.s.workers[arg.ID]
+	if !exists {
+		worker = batchsqlc.Worker{ID: arg.ID, Startedat: arg.Startedat}
+	}
+	worker.Heartbeat = arg.Heartbeat
+	remember(q, q.s.workers, arg.ID)
+	q.s.workers[arg.ID] = worker
+	return nil
+}
+
+func (q *memQueries) ReleaseBatchRows(ctx context.Context, arg batchsqlc.ReleaseBatchRowsParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	for _, rowid := range arg.Rowids {
+		row, exists := q.s.rows[rowid]
+		if !exists || !leasedTo(row, arg.Doneby) {
+			continue
+		}
+		row.Status = batchsqlc.StatusEnumQueued
+		row.Attempts--
+		row.Lasterr = arg.Lasterr
+		row.Doneby = pgtype.Text{}
+		row.Leaseexpiry = pgtype.Timestamp{}
+		q.putRow(row)
+	}
+	return nil
+}
+
+func (q *memQueries) ReopenBatch(ctx context.Context, id uuid.UUID) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[id]
+	if !exists {
+		return nil
+	}
+	if !hasStatus(batch.Status, batchsqlc.StatusEnumPaused, batchsqlc.StatusEnumWait) {
+		batch.Status = batchsqlc.StatusEnumQueued
+	}
+	batch.Doneat = pgtype.Timestamp{}
+	batch.Nsuccess = pgtype.Int4{Valid: true}
+	batch.Nfailed = pgtype.Int4{Valid: true}
+	batch.Naborted = pgtype.Int4{Valid: true}
+	q.putBatch(batch)
+	for _, row := range q.rowsOf(id) {
+		q.countFinishedRow(id, row.Status)
+	}
+	return nil
+}
+
+func (q *memQueries) RequeueDeadLetterRows(ctx context.Context, rowids []int64) ([]batchsqlc.RequeueDeadLetterRowsRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	var items []batchsqlc.RequeueDeadLetterRowsRow
+	for _, rowid := range rowids {
+		row, exists := q.s.rows[rowid]
+		if !exists || row.Status != batchsqlc.StatusEnumDeadletter || q.s.batches[row.Batch].Status == batchsqlc.StatusEnumAborted {
+			continue
+		}
+		row.Status = batchsqlc.StatusEnumQueued
+		row.Attempts = 0
+		row.Nexttry = pgtype.Timestamp{}
+		row.Doneat = pgtype.Timestamp{}
+		row.Res = nil
+		row.Messages = nil
+		row.Lasterr = pgtype.Text{}
+		row.Errtrace = pgtype.Text{}
+		row.Doneby = pgtype.Text{}
+		row.Leaseexpiry = pgtype.Timestamp{}
+		q.putRow(row)
+		items = append(items, batchsqlc.RequeueDeadLetterRowsRow{Rowid: row.Rowid, Batch: row.Batch})
+	}
+	return items, nil
+}
+
+func (q *memQueries) RetryBatchRow(ctx context.Context, arg batchsqlc.RetryBatchRowParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	row, exists := q.s.rows[arg.Rowid]
+	if !exists || !leasedTo(row, arg.Doneby) {
+		return nil
+	}
+	row.Status = batchsqlc.StatusEnumQueued
+	row.Nexttry = arg.Nexttry
+	row.Lasterr = arg.Lasterr
+	row.Doneby = pgtype.Text{}
+	row.Leaseexpiry = pgtype.Timestamp{}
+	q.putRow(row)
+	return nil
+}
+
+func (q *memQueries) RetryBatchRows(ctx context.Context, arg batchsqlc.RetryBatchRowsParams) ([]batchsqlc.RetryBatchRowsRow, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	statuses := statusEnums(arg.Statuses)
+	var items []batchsqlc.RetryBatchRowsRow
+	for _, row := range q.rowsOf(arg.Batch) {
+		if !hasStatus(row.Status, statuses...) || (len(arg.Lines) > 0 && !slices.Contains(arg.Lines, row.Line)) {
+			continue
+		}
+		remember(q, q.s.results, arg.Batch)
+		q.s.results[arg.Batch] = append(q.s.results[arg.Batch], batchsqlc.Batchrowresult{
+			Rowid:    row.Rowid,
+			Runno:    row.Runno,
+			Batch:    row.Batch,
+			Line:     row.Line,
+			Status:   row.Status,
+			Doneat:   row.Doneat,
+			Res:      row.Res,
+			Blobrows: row.Blobrows,
+			Messages: row.Messages,
+			Doneby:   row.Doneby,
+		})
+		row.Status = batchsqlc.StatusEnumQueued
+		row.Runno++
+		row.Attempts = 0
+		row.Nexttry = pgtype.Timestamp{}
+		row.Doneat = pgtype.Timestamp{}
+		row.Res = nil
+		row.Blobrows = nil
+		row.Messages = nil
+		row.Lasterr = pgtype.Text{}
+		row.Errtrace = pgtype.Text{}
+		row.Doneby = pgtype.Text{}
+		row.Leaseexpiry = pgtype.Timestamp{}
+		q.putRow(row)
+		items = append(items, batchsqlc.RetryBatchRowsRow{Rowid: row.Rowid, Line: row.Line, Runno: row.Runno})
+	}
+	return items, nil
+}
+
+func (q *memQueries) SetBatchStatus(ctx context.Context, arg batchsqlc.SetBatchStatusParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists {
+		return nil
+	}
+	batch.Status = arg.Status
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) UpdateBatchAggregate(ctx context.Context, arg batchsqlc.UpdateBatchAggregateParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists {
+		return nil
+	}
+	batch.Aggregate = arg.Aggregate
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) UpdateBatchAggregateErr(ctx context.Context, arg batchsqlc.UpdateBatchAggregateErrParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists {
+		return nil
+	}
+	batch.Aggregateerr = arg.Aggregateerr
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) UpdateBatchCounters(ctx context.Context, arg batchsqlc.UpdateBatchCountersParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists {
+		return nil
+	}
+	batch.Nsuccess = addInt4(batch.Nsuccess, arg.Nsuccess)
+	batch.Nfailed = addInt4(batch.Nfailed, arg.Nfailed)
+	batch.Naborted = addInt4(batch.Naborted, arg.Naborted)
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) UpdateBatchOutputFiles(ctx context.Context, arg batchsqlc.UpdateBatchOutputFilesParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists {
+		return nil
+	}
+	batch.Outputfiles = arg.Outputfiles
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) UpdateBatchProgress(ctx context.Context, arg batchsqlc.UpdateBatchProgressParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists || batch.Doneat.Valid {
+		return nil
+	}
+	batch.Progress = arg.Progress
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) UpdateBatchResult(ctx context.Context, arg batchsqlc.UpdateBatchResultParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists {
+		return nil
+	}
+	batch.Outputfiles = arg.Outputfiles
+	batch.Status = arg.Status
+	batch.Doneat = arg.Doneat
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) UpdateBatchRowStatus(ctx context.Context, arg batchsqlc.UpdateBatchRowStatusParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	row, exists := q.s.rows[arg.Rowid]
+	if !exists {
+		return nil
+	}
+	row.Status = arg.Status
+	q.putRow(row)
+	return nil
+}
+
+func (q *memQueries) UpdateBatchRowsSlowQuery(ctx context.Context, arg batchsqlc.UpdateBatchRowsSlowQueryParams) (int64, error) {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	row, exists := q.s.rows[arg.Rowid]
+	if !exists || !leasedTo(row, arg.Doneby) {
+		return 0, nil
+	}
+	row.Status = arg.Status
+	row.Doneat = arg.Doneat
+	row.Res = arg.Res
+	row.Messages = arg.Messages
+	row.Leaseexpiry = pgtype.Timestamp{}
+	q.putRow(row)
+	return 1, nil
+}
+
+func (q *memQueries) UpdateBatchRowsStatus(ctx context.Context, arg batchsqlc.UpdateBatchRowsStatusParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	for _, rowid := range arg.Column2 {
+		row, exists := q.s.rows[rowid]
+		if !exists {
+			continue
+		}
+		row.Status = arg.Status
+		q.putRow(row)
+	}
+	return nil
+}
+
+// setBatchSummary sets the status, doneat, output files and counters of a batch
+func (q *memQueries) setBatchSummary(arg batchsqlc.UpdateBatchSummaryParams) {
+	batch, exists := q.s.batches[arg.ID]
+	if !exists {
+		return
+	}
+	batch.Status = arg.Status
+	batch.Doneat = arg.Doneat
+	batch.Outputfiles = arg.Outputfiles
+	batch.Nsuccess = arg.Nsuccess
+	batch.Nfailed = arg.Nfailed
+	batch.Naborted = arg.Naborted
+	q.putBatch(batch)
+}
+
+func (q *memQueries) UpdateBatchStatus(ctx context.Context, arg batchsqlc.UpdateBatchStatusParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	q.setBatchSummary(batchsqlc.UpdateBatchSummaryParams(arg))
+	return nil
+}
+
+func (q *memQueries) UpdateBatchSummary(ctx context.Context, arg batchsqlc.UpdateBatchSummaryParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	q.setBatchSummary(arg)
+	return nil
+}
+
+func (q *memQueries) UpdateBatchSummaryOnAbort(ctx context.Context, arg batchsqlc.UpdateBatchSummaryOnAbortParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	batch, exists := q.s.batches[arg.ID]
+	if !exists {
+		return nil
+	}
+	batch.Status = arg.Status
+	batch.Doneat = arg.Doneat
+	batch.Naborted = arg.Naborted
+	q.putBatch(batch)
+	return nil
+}
+
+func (q *memQueries) UpdatePipelineStatus(ctx context.Context, arg batchsqlc.UpdatePipelineStatusParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	pipeline, exists := q.s.pipelines[arg.ID]
+	if !exists {
+		return nil
+	}
+	pipeline.Status = arg.Status
+	pipeline.Doneat = arg.Doneat
+	remember(q, q.s.pipelines, arg.ID)
+	q.s.pipelines[arg.ID] = pipeline
+	return nil
+}
+
+func (q *memQueries) UpdateRecurringJobRun(ctx context.Context, arg batchsqlc.UpdateRecurringJobRunParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	job, exists := q.s.recurring[arg.Name]
+	if !exists {
+		return nil
+	}
+	job.Lastrun = arg.Lastrun
+	job.Lastbatch = arg.Lastbatch
+	job.Nextrun = arg.Nextrun
+	remember(q, q.s.recurring, arg.Name)
+	q.s.recurring[arg.Name] = job
+	return nil
+}
+
+func (q *memQueries) UpsertRecurringJob(ctx context.Context, arg batchsqlc.UpsertRecurringJobParams) error {
+	q.s.mu.Lock()
+	defer q.s.mu.Unlock()
+	job, exists := q.s.recurring[arg.Name]
+	if !exists || job.Cronspec != arg.Cronspec {
+		job.Nextrun = arg.Nextrun
+	}
+	job.Name = arg.Name
+	job.App = arg.App
+	job.Op = arg.Op
+	job.Cronspec = arg.Cronspec
+	remember(q, q.s.recurring, arg.Name)
+	q.s.recurring[arg.Name] = job
+	return nil
+}
@@ -0,0 +1,297 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/wscutils"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoBatchProcessor returns the input of each row as its result, and fails the rows whose input
+// is "fail"
+type echoBatchProcessor struct {
+	markDoneCalled chan BatchDetails_t
+}
+
+func (p *echoBatchProcessor) DoBatchJob(ctx context.Context, initBlock InitBlock, batchctx JSONstr, line int, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	if input.String() == `"fail"` {
+		return batchsqlc.StatusEnumFailed, input, []wscutils.ErrorMessage{{MsgID: 1, ErrCode: "bad_input"}}, nil, nil
+	}
+	return batchsqlc.StatusEnumSuccess, input, nil, nil, nil
+}
+
+func (p *echoBatchProcessor) MarkDone(initBlock InitBlock, batchctx JSONstr, details BatchDetails_t) error {
+	p.markDoneCalled <- details
+	return nil
+}
+
+func newMemTestJobManager(t *testing.T) *JobManager {
+	jm := NewJobManagerWithStore(NewMemJobStore(), nil, nil, nil, nil)
+	assert.NoError(t, jm.RegisterInitializer("app1", &MockInitializer{}))
+	return jm
+}
+
+// runJobManager runs jm until the test ends
+func runJobManager(t *testing.T, jm *JobManager) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		jm.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestMemJobStoreBatchEndToEnd(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+	runJobManager(t, jm)
+
+	batchctx, _ := NewJSONstr(`{"k":"v"}`)
+	var input []BatchInput_t
+	for i, value := range []string{`"a"`, `"fail"`, `"c"`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "ECHO", batchctx, input, false)
+	assert.NoError(t, err)
+
+	var status batchsqlc.StatusEnum
+	var output []BatchOutput_t
+	var nsuccess, nfailed, naborted int
+	assert.Eventually(t, func() bool {
+		status, output, _, nsuccess, nfailed, naborted, err = jm.BatchDone(batchID)
+		return err != nil || isFinalStatus(status)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumFailed, status)
+	assert.Equal(t, 2, nsuccess)
+	assert.Equal(t, 1, nfailed)
+	assert.Equal(t, 0, naborted)
+	assert.Len(t, output, 3)
+	for _, row := range output {
+		if row.Line == 2 {
+			assert.Equal(t, BatchFailed, row.Status)
+		} else {
+			assert.Equal(t, BatchSuccess, row.Status)
+		}
+	}
+
+	details := <-p.markDoneCalled
+	assert.Equal(t, batchID, details.ID)
+	assert.Equal(t, "echo", details.Op)
+}
+
+type echoSlowQueryProcessor struct{}
+
+func (p echoSlowQueryProcessor) DoSlowQuery(ctx context.Context, initBlock InitBlock, queryctx JSONstr, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	return batchsqlc.StatusEnumSuccess, input, nil, map[string]string{}, nil
+}
+
+func TestMemJobStoreSlowQueryEndToEnd(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "echo", echoSlowQueryProcessor{}))
+	runJobManager(t, jm)
+
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"n":42}`)
+	reqID, err := jm.SlowQuerySubmit("app1", "echo", queryctx, input)
+	assert.NoError(t, err)
+
+	var status BatchStatus_t
+	var result JSONstr
+	assert.Eventually(t, func() bool {
+		status, result, _, _, err = jm.SlowQueryDone(reqID)
+		return err != nil || status != BatchTryLater
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchSuccess, status)
+	assert.JSONEq(t, `{"n":42}`, result.String())
+}
+
+func TestMemJobStoreRollback(t *testing.T) {
+	store := NewMemJobStore()
+	ctx := context.Background()
+	insert := func(q batchsqlc.Querier) uuid.UUID {
+		id := uuid.New()
+		_, err := q.InsertIntoBatches(ctx, batchsqlc.InsertIntoBatchesParams{
+			ID:     id,
+			App:    "app1",
+			Op:     "op1",
+			Status: batchsqlc.StatusEnumQueued,
+			Reqat:  pgtype.Timestamp{Time: time.Now(), Valid: true},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, q.InsertIntoBatchRows(ctx, batchsqlc.InsertIntoBatchRowsParams{Batch: id, Line: 1}))
+		return id
+	}
+
+	committed := insert(store.Queries())
+
+	tx, err := store.Begin(ctx)
+	assert.NoError(t, err)
+	rolledBack := insert(tx.Queries())
+	assert.NoError(t, tx.Queries().UpdateBatchStatus(ctx, batchsqlc.UpdateBatchStatusParams{ID: committed, Status: batchsqlc.StatusEnumWait}))
+	assert.NoError(t, tx.Rollback(ctx))
+
+	_, err = store.Queries().GetBatchByID(ctx, rolledBack)
+	assert.True(t, errors.Is(err, pgx.ErrNoRows))
+	count, err := store.Queries().GetBatchRowsCount(ctx, rolledBack)
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+	status, err := store.Queries().GetBatchStatus(ctx, committed)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumQueued, status)
+
+	// A rollback after a commit has no effect
+	tx, err = store.Begin(ctx)
+	assert.NoError(t, err)
+	kept := insert(tx.Queries())
+	assert.NoError(t, tx.Commit(ctx))
+	assert.Error(t, tx.Rollback(ctx))
+	_, err = store.Queries().GetBatchByID(ctx, kept)
+	assert.NoError(t, err)
+}
+
+func TestMemJobStoreNotifiesOnCommit(t *testing.T) {
+	store := NewMemJobStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	woken := make(chan struct{}, 4)
+	go store.Listen(ctx, func() { woken <- struct{}{} })
+	assert.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.listeners) == 1
+	}, time.Second, time.Millisecond)
+
+	tx, err := store.Begin(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Queries().NotifyBatchQueued(ctx, batchsqlc.NotifyBatchQueuedParams{Channel: ALYA_NOTIFY_CHANNEL}))
+	select {
+	case <-woken:
+		t.Fatal("listener woken before commit")
+	default:
+	}
+	assert.NoError(t, tx.Commit(ctx))
+	select {
+	case <-woken:
+	case <-time.After(time.Second):
+		t.Fatal("listener not woken after commit")
+	}
+
+	// Notifications of a rolled back transaction are dropped
+	tx, err = store.Begin(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Queries().NotifyBatchQueued(ctx, batchsqlc.NotifyBatchQueuedParams{Channel: ALYA_NOTIFY_CHANNEL}))
+	assert.NoError(t, tx.Rollback(ctx))
+	assert.Empty(t, woken)
+}
+
+func TestMemJobStoreFetchBlockOfRowsFairness(t *testing.T) {
+	store := NewMemJobStore()
+	q := store.Queries()
+	ctx := context.Background()
+	now := time.Now()
+	submit := func(app string, priority int32, nrows int, reqat time.Time) uuid.UUID {
+		id := uuid.New()
+		_, err := q.InsertIntoBatches(ctx, batchsqlc.InsertIntoBatchesParams{
+			ID:       id,
+			App:      app,
+			Op:       "op1",
+			Status:   batchsqlc.StatusEnumQueued,
+			Reqat:    pgtype.Timestamp{Time: reqat, Valid: true},
+			Priority: priority,
+		})
+		assert.NoError(t, err)
+		for line := 1; line <= nrows; line++ {
+			assert.NoError(t, q.InsertIntoBatchRows(ctx, batchsqlc.InsertIntoBatchRowsParams{Batch: id, Line: int32(line)}))
+		}
+		return id
+	}
+	large := submit("app1", 0, 5, now.Add(-time.Minute))
+	small := submit("app2", 0, 1, now)
+	urgent := submit("app1", 10, 1, now)
+
+	rows, err := q.FetchBlockOfRows(ctx, batchsqlc.FetchBlockOfRowsParams{
+		Status:   batchsqlc.StatusEnumQueued,
+		Nexttry:  pgtype.Timestamp{Time: now, Valid: true},
+		Perbatch: 10,
+		Maxrows:  4,
+	})
+	assert.NoError(t, err)
+	var batches []uuid.UUID
+	for _, row := range rows {
+		batches = append(batches, row.Batch)
+	}
+	assert.Equal(t, []uuid.UUID{urgent, large, small, large}, batches)
+}
+
+func TestMemJobStoreFetchBlockOfRowsPartitionKey(t *testing.T) {
+	store := NewMemJobStore()
+	q := store.Queries()
+	ctx := context.Background()
+	now := time.Now()
+	id := uuid.New()
+	_, err := q.InsertIntoBatches(ctx, batchsqlc.InsertIntoBatchesParams{
+		ID:     id,
+		App:    "app1",
+		Op:     "op1",
+		Status: batchsqlc.StatusEnumQueued,
+		Reqat:  pgtype.Timestamp{Time: now, Valid: true},
+	})
+	assert.NoError(t, err)
+	_, err = q.BulkInsertIntoBatchRows(ctx, batchsqlc.BulkInsertIntoBatchRowsParams{
+		Batch:   []uuid.UUID{id, id, id, id, id},
+		Line:    []int32{1, 2, 3, 4, 5},
+		Input:   make([][]byte, 5),
+		Reqat:   make([]pgtype.Timestamp, 5),
+		Partkey: []string{"A1", "A1", "A2", "", "A1"},
+	})
+	assert.NoError(t, err)
+
+	fetch := func() (lines []int32, rowids []int64) {
+		rows, err := q.FetchBlockOfRows(ctx, batchsqlc.FetchBlockOfRowsParams{
+			Status:   batchsqlc.StatusEnumQueued,
+			Nexttry:  pgtype.Timestamp{Time: now, Valid: true},
+			Perbatch: 10,
+			Maxrows:  10,
+		})
+		assert.NoError(t, err)
+		for _, row := range rows {
+			lines = append(lines, row.Line)
+			rowids = append(rowids, row.Rowid)
+		}
+		return lines, rowids
+	}
+	setStatus := func(rowid int64, status batchsqlc.StatusEnum) {
+		assert.NoError(t, q.UpdateBatchRowsStatus(ctx, batchsqlc.UpdateBatchRowsStatusParams{Status: status, Column2: []int64{rowid}}))
+	}
+
+	// Only the first row of each key is handed out, along with the rows without a key
+	lines, rowids := fetch()
+	assert.Equal(t, []int32{1, 3, 4}, lines)
+
+	// The next row of a key waits until the row before it is done, not just leased
+	setStatus(rowids[0], batchsqlc.StatusEnumInprog)
+	lines, _ = fetch()
+	assert.Equal(t, []int32{3, 4}, lines)
+	setStatus(rowids[0], batchsqlc.StatusEnumFailed)
+	lines, rowids = fetch()
+	assert.Equal(t, []int32{2, 3, 4}, lines)
+	setStatus(rowids[0], batchsqlc.StatusEnumSuccess)
+	lines, _ = fetch()
+	assert.Equal(t, []int32{3, 4, 5}, lines)
+}
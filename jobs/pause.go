@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ErrBatchNotRunning is returned by BatchPause for a batch which is neither queued nor in progress.
+var ErrBatchNotRunning = errors.New("batch is not queued or in progress")
+
+// ErrBatchNotPaused is returned by BatchResume for a batch which is not paused.
+var ErrBatchNotPaused = errors.New("batch is not paused")
+
+// BatchPause stops the rows of a queued or running batch from being fetched by any JobManager
+// instance, for instance during an incident, without aborting the batch. Rows which are already in
+// progress are finished, and their results recorded; if no row is left in the queue then the batch
+// is done, as it would have been without the pause. The batch is in the paused status until it is
+// resumed with BatchResume. Pausing a batch which is already paused has no effect.
+func (jm *JobManager) BatchPause(batchID string) error {
+	return jm.setPaused(batchID, true)
+}
+
+// BatchResume puts a batch paused by BatchPause back in the queue, so that its remaining rows are
+// processed. Resuming a batch which is queued or in progress has no effect.
+func (jm *JobManager) BatchResume(batchID string) error {
+	return jm.setPaused(batchID, false)
+}
+
+// setPaused moves a batch to the paused status, or from the paused status back to queued
+func (jm *JobManager) setPaused(batchID string, pause bool) error {
+	batchUUID, err := uuid.Parse(batchID)
+	if err != nil {
+		return fmt.Errorf("invalid batch ID: %v", err)
+	}
+
+	tx, err := jm.Store.Begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
+
+	// Lock the batch, so that workers marking it inprog wait for the new status
+	batch, err := txQueries.GetBatchByID(context.Background(), batchUUID)
+	if err != nil {
+		return fmt.Errorf("failed to get batch by ID: %v", err)
+	}
+
+	newStatus := batchsqlc.StatusEnumPaused
+	if pause {
+		if batch.Status == batchsqlc.StatusEnumPaused {
+			return nil
+		}
+		if !hasStatus(batch.Status, batchsqlc.StatusEnumQueued, batchsqlc.StatusEnumInprog) {
+			return fmt.Errorf("%w: batch %s is %s", ErrBatchNotRunning, batchID, batch.Status)
+		}
+	} else {
+		if hasStatus(batch.Status, batchsqlc.StatusEnumQueued, batchsqlc.StatusEnumInprog) {
+			return nil
+		}
+		if batch.Status != batchsqlc.StatusEnumPaused {
+			return fmt.Errorf("%w: batch %s is %s", ErrBatchNotPaused, batchID, batch.Status)
+		}
+		newStatus = batchsqlc.StatusEnumQueued
+	}
+
+	err = txQueries.SetBatchStatus(context.Background(), batchsqlc.SetBatchStatusParams{
+		ID:     batchUUID,
+		Status: newStatus,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update batch status: %v", err)
+	}
+	if !pause {
+		if err := notifyBatchQueued(context.Background(), txQueries, batchUUID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	jm.cacheStatus(batchUUID, CachedStatus{Status: newStatus})
+	if pause {
+		jm.publishEvent(BatchEvent{BatchID: batchID, Type: BatchEventPaused, Status: newStatus})
+		log.Printf("Paused batch %s", batchID)
+	} else {
+		jm.publishEvent(BatchEvent{BatchID: batchID, Type: BatchEventQueued, Status: newStatus})
+		log.Printf("Resumed batch %s", batchID)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchPauseResume(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	rowInput, _ := NewJSONstr(`"a"`)
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, []BatchInput_t{{Line: 1, Input: rowInput}}, false)
+	assert.NoError(t, err)
+
+	assert.NoError(t, jm.BatchPause(batchID))
+	assert.NoError(t, jm.BatchPause(batchID))
+	runJobManager(t, jm)
+
+	// The rows of the paused batch are not fetched
+	assert.Never(t, func() bool {
+		status, _, _, _, _, _, _ := jm.BatchDone(batchID)
+		return status != batchsqlc.StatusEnumPaused
+	}, 200*time.Millisecond, 20*time.Millisecond)
+	assert.Equal(t, BatchPaused, mapStatusEnum(batchsqlc.StatusEnumPaused))
+
+	assert.NoError(t, jm.BatchResume(batchID))
+	status, _, nsuccess, _ := waitForBatch(t, jm, batchID)
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, status)
+	assert.Equal(t, 1, nsuccess)
+	<-p.markDoneCalled
+
+	err = jm.BatchPause(batchID)
+	assert.True(t, errors.Is(err, ErrBatchNotRunning))
+	err = jm.BatchResume(batchID)
+	assert.True(t, errors.Is(err, ErrBatchNotPaused))
+}
+
+func TestBatchPauseResumeAfterRowsDone(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	jm.Config.BatchChunkNRows = 2
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for i, value := range []string{`1`, `2`, `3`, `4`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, input, false)
+	assert.NoError(t, err)
+
+	// Pausing and resuming keeps the counters of the rows done so far, so the batch still completes
+	nrows, err := jm.processBlock(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, nrows)
+	assert.NoError(t, jm.BatchPause(batchID))
+	assert.NoError(t, jm.BatchResume(batchID))
+	processQueuedRows(t, jm)
+
+	status, _, nsuccess, nfailed := waitForBatch(t, jm, batchID)
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, status)
+	assert.Equal(t, 4, nsuccess)
+	assert.Equal(t, 0, nfailed)
+	details := <-p.markDoneCalled
+	assert.Equal(t, 4, details.NSuccess)
+}
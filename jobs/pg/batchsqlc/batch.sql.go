@@ -12,30 +12,144 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const bulkInsertIntoBatchRows = `-- name: BulkInsertIntoBatchRows :execrows
-INSERT INTO batchrows (batch, line, input, status, reqat) 
-VALUES 
-    (unnest($1::uuid[]), unnest($2::int[]), unnest($3::jsonb[]), 'queued', unnest($4::timestamp[]))
+const bulkInsertIntoBatchRows = `-- name: BulkInsertIntoBatchRows :one
+WITH inserted AS (
+    INSERT INTO batchrows (batch, line, input, status, reqat, partkey) 
+    VALUES 
+        (unnest($1::uuid[]), unnest($2::int[]), unnest($3::jsonb[]), 'queued', unnest($4::timestamp[]),
+        NULLIF(unnest($5::text[]), ''))
+    RETURNING batch
+), counted AS (
+    UPDATE batches
+    SET nrows = nrows + c.nrows
+    FROM (SELECT batch, count(*)::int AS nrows FROM inserted GROUP BY batch) c
+    WHERE batches.id = c.batch
+)
+SELECT count(*) FROM inserted
 `
 
 type BulkInsertIntoBatchRowsParams struct {
-	Batch []uuid.UUID        `json:"batch"`
-	Line  []int32            `json:"line"`
-	Input [][]byte           `json:"input"`
-	Reqat []pgtype.Timestamp `json:"reqat"`
+	Batch   []uuid.UUID        `json:"batch"`
+	Line    []int32            `json:"line"`
+	Input   [][]byte           `json:"input"`
+	Reqat   []pgtype.Timestamp `json:"reqat"`
+	Partkey []string           `json:"partkey"`
 }
 
+// The number of rows of each batch is counted in batches.nrows. Rows without a partition key have
+// an empty one in @partkey.
 func (q *Queries) BulkInsertIntoBatchRows(ctx context.Context, arg BulkInsertIntoBatchRowsParams) (int64, error) {
-	result, err := q.db.Exec(ctx, bulkInsertIntoBatchRows,
+	row := q.db.QueryRow(ctx, bulkInsertIntoBatchRows,
 		arg.Batch,
 		arg.Line,
 		arg.Input,
 		arg.Reqat,
+		arg.Partkey,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const bulkUpdateBatchRowsBatchJob = `-- name: BulkUpdateBatchRowsBatchJob :many
+WITH updated AS (
+    UPDATE batchrows
+    SET status = u.status::status_enum, doneat = $1, res = u.res, blobrows = u.blobrows, messages = u.messages,
+        leaseexpiry = NULL
+    FROM (
+        SELECT unnest($2::bigint[]) AS rowid, unnest($3::text[]) AS status, unnest($4::jsonb[]) AS res,
+            unnest($5::jsonb[]) AS blobrows, unnest($6::jsonb[]) AS messages
+    ) u
+    WHERE batchrows.rowid = u.rowid AND batchrows.status = 'inprog' AND batchrows.doneby = $7
+    RETURNING batchrows.rowid, batchrows.batch, batchrows.status
+), counted AS (
+    UPDATE batches
+    SET nsuccess = COALESCE(nsuccess, 0) + c.nsuccess,
+        nfailed = COALESCE(nfailed, 0) + c.nfailed,
+        naborted = COALESCE(naborted, 0) + c.naborted
+    FROM (
+        SELECT batch,
+            count(*) FILTER (WHERE status = 'success')::int AS nsuccess,
+            count(*) FILTER (WHERE status IN ('failed', 'deadletter'))::int AS nfailed,
+            count(*) FILTER (WHERE status = 'aborted')::int AS naborted
+        FROM updated
+        GROUP BY batch
+    ) c
+    WHERE batches.id = c.batch
+)
+SELECT rowid FROM updated
+`
+
+type BulkUpdateBatchRowsBatchJobParams struct {
+	Doneat   pgtype.Timestamp `json:"doneat"`
+	Rowid    []int64          `json:"rowid"`
+	Status   []string         `json:"status"`
+	Res      [][]byte         `json:"res"`
+	Blobrows [][]byte         `json:"blobrows"`
+	Messages [][]byte         `json:"messages"`
+	Doneby   pgtype.Text      `json:"doneby"`
+}
+
+// Records the results of a block of rows in one statement, and adds them to the counters of their
+// batches; rows no longer leased to doneby are skipped. It returns the rowids of the rows updated.
+func (q *Queries) BulkUpdateBatchRowsBatchJob(ctx context.Context, arg BulkUpdateBatchRowsBatchJobParams) ([]int64, error) {
+	rows, err := q.db.Query(ctx, bulkUpdateBatchRowsBatchJob,
+		arg.Doneat,
+		arg.Rowid,
+		arg.Status,
+		arg.Res,
+		arg.Blobrows,
+		arg.Messages,
+		arg.Doneby,
 	)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return result.RowsAffected(), nil
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var rowid int64
+		if err := rows.Scan(&rowid); err != nil {
+			return nil, err
+		}
+		items = append(items, rowid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const closeBatchInput = `-- name: CloseBatchInput :exec
+UPDATE batches
+SET status = $1, nrows = nrows + $2::int, payloadhash = $3
+WHERE id = $4
+`
+
+type CloseBatchInputParams struct {
+	Status      StatusEnum `json:"status"`
+	Nrows       int32      `json:"nrows"`
+	Payloadhash []byte     `json:"payloadhash"`
+	ID          uuid.UUID  `json:"id"`
+}
+
+// The rows of a batch submitted as a stream have all been copied in, which does not count them
+func (q *Queries) CloseBatchInput(ctx context.Context, arg CloseBatchInputParams) error {
+	_, err := q.db.Exec(ctx, closeBatchInput,
+		arg.Status,
+		arg.Nrows,
+		arg.Payloadhash,
+		arg.ID,
+	)
+	return err
+}
+
+type CopyIntoBatchRowsParams struct {
+	Batch   uuid.UUID        `json:"batch"`
+	Line    int32            `json:"line"`
+	Input   []byte           `json:"input"`
+	Reqat   pgtype.Timestamp `json:"reqat"`
+	Partkey pgtype.Text      `json:"partkey"`
 }
 
 const countBatchRowsByBatchIDAndStatus = `-- name: CountBatchRowsByBatchIDAndStatus :one
@@ -57,10 +171,89 @@ func (q *Queries) CountBatchRowsByBatchIDAndStatus(ctx context.Context, arg Coun
 	return count, err
 }
 
+const deadLetterBatchRow = `-- name: DeadLetterBatchRow :execrows
+WITH dead AS (
+    UPDATE batchrows
+    SET status = 'deadletter', doneat = $2, messages = $3, lasterr = $4, errtrace = $5, leaseexpiry = NULL
+    WHERE rowid = $1 AND status = 'inprog' AND doneby = $6
+    RETURNING batch
+)
+UPDATE batches
+SET nfailed = COALESCE(nfailed, 0) + 1
+WHERE id IN (SELECT batch FROM dead)
+`
+
+type DeadLetterBatchRowParams struct {
+	Rowid    int64            `json:"rowid"`
+	Doneat   pgtype.Timestamp `json:"doneat"`
+	Messages []byte           `json:"messages"`
+	Lasterr  pgtype.Text      `json:"lasterr"`
+	Errtrace pgtype.Text      `json:"errtrace"`
+	Doneby   pgtype.Text      `json:"doneby"`
+}
+
+// The row is added to the nfailed counter of its batch. Nothing is updated if the row is no longer
+// leased to doneby, such as when it was aborted or its lease was reclaimed.
+func (q *Queries) DeadLetterBatchRow(ctx context.Context, arg DeadLetterBatchRowParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deadLetterBatchRow,
+		arg.Rowid,
+		arg.Doneat,
+		arg.Messages,
+		arg.Lasterr,
+		arg.Errtrace,
+		arg.Doneby,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteStaleWorkers = `-- name: DeleteStaleWorkers :execrows
+DELETE FROM workers WHERE heartbeat < $1
+`
+
+func (q *Queries) DeleteStaleWorkers(ctx context.Context, heartbeat pgtype.Timestamp) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStaleWorkers, heartbeat)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteWorker = `-- name: DeleteWorker :exec
+DELETE FROM workers WHERE id = $1
+`
+
+func (q *Queries) DeleteWorker(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, deleteWorker, id)
+	return err
+}
+
+const extendWorkerLeases = `-- name: ExtendWorkerLeases :execrows
+UPDATE batchrows
+SET leaseexpiry = $2
+WHERE doneby = $1 AND status = 'inprog'
+`
+
+type ExtendWorkerLeasesParams struct {
+	Doneby      pgtype.Text      `json:"doneby"`
+	Leaseexpiry pgtype.Timestamp `json:"leaseexpiry"`
+}
+
+func (q *Queries) ExtendWorkerLeases(ctx context.Context, arg ExtendWorkerLeasesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, extendWorkerLeases, arg.Doneby, arg.Leaseexpiry)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const fetchBatchRowsForBatchDone = `-- name: FetchBatchRowsForBatchDone :many
-SELECT line, status, res, messages
+SELECT line, status, res, messages, runno
 FROM batchrows
 WHERE batch = $1
+ORDER BY line, rowid
 `
 
 type FetchBatchRowsForBatchDoneRow struct {
@@ -68,6 +261,7 @@ type FetchBatchRowsForBatchDoneRow struct {
 	Status   StatusEnum `json:"status"`
 	Res      []byte     `json:"res"`
 	Messages []byte     `json:"messages"`
+	Runno    int32      `json:"runno"`
 }
 
 func (q *Queries) FetchBatchRowsForBatchDone(ctx context.Context, batch uuid.UUID) ([]FetchBatchRowsForBatchDoneRow, error) {
@@ -84,6 +278,7 @@ func (q *Queries) FetchBatchRowsForBatchDone(ctx context.Context, batch uuid.UUI
 			&i.Status,
 			&i.Res,
 			&i.Messages,
+			&i.Runno,
 		); err != nil {
 			return nil, err
 		}
@@ -96,32 +291,83 @@ func (q *Queries) FetchBatchRowsForBatchDone(ctx context.Context, batch uuid.UUI
 }
 
 const fetchBlockOfRows = `-- name: FetchBlockOfRows :many
-SELECT batches.app, batches.status, batches.op, batches.context, batchrows.batch, batchrows.rowid, batchrows.line, batchrows.input
-FROM batchrows
-INNER JOIN batches ON batchrows.batch = batches.id
-WHERE batchrows.status = $1 AND batches.status != 'wait'
-LIMIT $2
-FOR UPDATE OF batchrows SKIP LOCKED
+WITH ranked AS (
+    SELECT c.batch, c.rowid, c.priority, c.reqat,
+        row_number() OVER (PARTITION BY c.priority, c.app ORDER BY c.batchrank, c.reqat, c.batch)::int AS apprank
+    FROM (
+        SELECT batches.app, batches.priority, batches.reqat, r.batch, r.rowid,
+            row_number() OVER (PARTITION BY r.batch ORDER BY r.line, r.rowid)::int AS batchrank
+        FROM batches
+        CROSS JOIN LATERAL (
+            SELECT batchrows.batch, batchrows.rowid, batchrows.line
+            FROM batchrows
+            WHERE batchrows.batch = batches.id AND batchrows.status = $1
+            AND (batchrows.nexttry IS NULL OR batchrows.nexttry <= $2)
+            AND (batchrows.partkey IS NULL OR NOT EXISTS (
+                SELECT 1
+                FROM batchrows prev
+                WHERE prev.batch = batchrows.batch AND prev.partkey = batchrows.partkey
+                AND (prev.line, prev.rowid) < (batchrows.line, batchrows.rowid)
+                AND prev.status IN ('queued', 'inprog')
+            ))
+            ORDER BY batchrows.line, batchrows.rowid
+            LIMIT $3
+        ) r
+        WHERE batches.status IN ('queued', 'inprog')
+        AND (batches.notbefore IS NULL OR batches.notbefore <= $2)
+    ) c
+), chosen AS (
+    SELECT batch, rowid, priority, reqat, apprank
+    FROM ranked
+    ORDER BY priority DESC, apprank, reqat, batch
+    LIMIT $4
+), locked AS (
+    SELECT batchrows.batch, batchrows.rowid, batchrows.line, batchrows.input, batchrows.attempts
+    FROM batchrows
+    WHERE batchrows.rowid IN (SELECT rowid FROM chosen) AND batchrows.status = $1
+    FOR UPDATE SKIP LOCKED
+)
+SELECT batches.app, batches.status, batches.op, batches.context, locked.batch, locked.rowid, locked.line,
+    locked.input, locked.attempts
+FROM chosen
+JOIN locked ON locked.rowid = chosen.rowid
+JOIN batches ON batches.id = locked.batch
+ORDER BY chosen.priority DESC, chosen.apprank, chosen.reqat, chosen.batch
 `
 
 type FetchBlockOfRowsParams struct {
-	Status StatusEnum `json:"status"`
-	Limit  int32      `json:"limit"`
+	Status   StatusEnum       `json:"status"`
+	Nexttry  pgtype.Timestamp `json:"nexttry"`
+	Perbatch int32            `json:"perbatch"`
+	Maxrows  int32            `json:"maxrows"`
 }
 
 type FetchBlockOfRowsRow struct {
-	App     string     `json:"app"`
-	Status  StatusEnum `json:"status"`
-	Op      string     `json:"op"`
-	Context []byte     `json:"context"`
-	Batch   uuid.UUID  `json:"batch"`
-	Rowid   int64      `json:"rowid"`
-	Line    int32      `json:"line"`
-	Input   []byte     `json:"input"`
+	App      string     `json:"app"`
+	Status   StatusEnum `json:"status"`
+	Op       string     `json:"op"`
+	Context  []byte     `json:"context"`
+	Batch    uuid.UUID  `json:"batch"`
+	Rowid    int64      `json:"rowid"`
+	Line     int32      `json:"line"`
+	Input    []byte     `json:"input"`
+	Attempts int32      `json:"attempts"`
 }
 
+// Rows are taken from the batches with the highest priority first. Within a priority, the first
+// queued row of each app comes before the second of any app, and the apps take turns among their
+// batches in the same way, so that small batches and slow queries are not stuck behind a large batch.
+// A row with a partition key is only taken once the rows before it with the same key are done, so
+// that they are processed one at a time in the order of their lines.
+// The rows are ranked without locking them, and only the maxrows rows chosen are then locked. Rows
+// locked or taken by another worker in the meantime are skipped, so fewer rows may be returned.
 func (q *Queries) FetchBlockOfRows(ctx context.Context, arg FetchBlockOfRowsParams) ([]FetchBlockOfRowsRow, error) {
-	rows, err := q.db.Query(ctx, fetchBlockOfRows, arg.Status, arg.Limit)
+	rows, err := q.db.Query(ctx, fetchBlockOfRows,
+		arg.Status,
+		arg.Nexttry,
+		arg.Perbatch,
+		arg.Maxrows,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -138,6 +384,7 @@ func (q *Queries) FetchBlockOfRows(ctx context.Context, arg FetchBlockOfRowsPara
 			&i.Rowid,
 			&i.Line,
 			&i.Input,
+			&i.Attempts,
 		); err != nil {
 			return nil, err
 		}
@@ -149,8 +396,56 @@ func (q *Queries) FetchBlockOfRows(ctx context.Context, arg FetchBlockOfRowsPara
 	return items, nil
 }
 
+const forgetMemoizedQueries = `-- name: ForgetMemoizedQueries :execrows
+UPDATE batches
+SET memohash = NULL
+WHERE app = $1 AND op = $2 AND memohash IS NOT NULL
+  AND ($3::bytea IS NULL OR memohash = $3)
+`
+
+type ForgetMemoizedQueriesParams struct {
+	App      string `json:"app"`
+	Op       string `json:"op"`
+	Memohash []byte `json:"memohash"`
+}
+
+// All the memoized slow queries of the (app, op) are forgotten if memohash is NULL
+func (q *Queries) ForgetMemoizedQueries(ctx context.Context, arg ForgetMemoizedQueriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, forgetMemoizedQueries, arg.App, arg.Op, arg.Memohash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getAbortedBatches = `-- name: GetAbortedBatches :many
+SELECT id
+FROM batches
+WHERE id = ANY($1::uuid[]) AND status = 'aborted'
+`
+
+func (q *Queries) GetAbortedBatches(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.Query(ctx, getAbortedBatches, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getBatchByID = `-- name: GetBatchByID :one
-SELECT id, app, op, context, inputfile, status, reqat, doneat, outputfiles, nsuccess, nfailed, naborted, created_at
+SELECT id, app, op, context, inputfile, status, reqat, doneat, outputfiles, nsuccess, nfailed, naborted, created_at, priority, notbefore, nrows, idempotencykey, payloadhash, aggregate, startedat, progress, memohash, aggregateerr
 FROM batches
 WHERE id = $1 
 FOR UPDATE
@@ -173,12 +468,141 @@ func (q *Queries) GetBatchByID(ctx context.Context, id uuid.UUID) (Batch, error)
 		&i.Nfailed,
 		&i.Naborted,
 		&i.CreatedAt,
+		&i.Priority,
+		&i.Notbefore,
+		&i.Nrows,
+		&i.Idempotencykey,
+		&i.Payloadhash,
+		&i.Aggregate,
+		&i.Startedat,
+		&i.Progress,
+		&i.Memohash,
+		&i.Aggregateerr,
+	)
+	return i, err
+}
+
+const getBatchByIdempotencyKey = `-- name: GetBatchByIdempotencyKey :one
+SELECT id, payloadhash
+FROM batches
+WHERE app = $1 AND op = $2 AND idempotencykey = $3
+`
+
+type GetBatchByIdempotencyKeyParams struct {
+	App            string      `json:"app"`
+	Op             string      `json:"op"`
+	Idempotencykey pgtype.Text `json:"idempotencykey"`
+}
+
+type GetBatchByIdempotencyKeyRow struct {
+	ID          uuid.UUID `json:"id"`
+	Payloadhash []byte    `json:"payloadhash"`
+}
+
+func (q *Queries) GetBatchByIdempotencyKey(ctx context.Context, arg GetBatchByIdempotencyKeyParams) (GetBatchByIdempotencyKeyRow, error) {
+	row := q.db.QueryRow(ctx, getBatchByIdempotencyKey, arg.App, arg.Op, arg.Idempotencykey)
+	var i GetBatchByIdempotencyKeyRow
+	err := row.Scan(&i.ID, &i.Payloadhash)
+	return i, err
+}
+
+const getBatchCounts = `-- name: GetBatchCounts :one
+SELECT status, nsuccess, nfailed, naborted
+FROM batches
+WHERE id = $1
+`
+
+type GetBatchCountsRow struct {
+	Status   StatusEnum  `json:"status"`
+	Nsuccess pgtype.Int4 `json:"nsuccess"`
+	Nfailed  pgtype.Int4 `json:"nfailed"`
+	Naborted pgtype.Int4 `json:"naborted"`
+}
+
+func (q *Queries) GetBatchCounts(ctx context.Context, id uuid.UUID) (GetBatchCountsRow, error) {
+	row := q.db.QueryRow(ctx, getBatchCounts, id)
+	var i GetBatchCountsRow
+	err := row.Scan(
+		&i.Status,
+		&i.Nsuccess,
+		&i.Nfailed,
+		&i.Naborted,
+	)
+	return i, err
+}
+
+const getBatchProgress = `-- name: GetBatchProgress :one
+SELECT status, nrows, nsuccess, nfailed, naborted, startedat, progress
+FROM batches
+WHERE id = $1
+`
+
+type GetBatchProgressRow struct {
+	Status    StatusEnum       `json:"status"`
+	Nrows     int32            `json:"nrows"`
+	Nsuccess  pgtype.Int4      `json:"nsuccess"`
+	Nfailed   pgtype.Int4      `json:"nfailed"`
+	Naborted  pgtype.Int4      `json:"naborted"`
+	Startedat pgtype.Timestamp `json:"startedat"`
+	Progress  []byte           `json:"progress"`
+}
+
+// The counters and progress of a batch or slow query, read without locking it
+func (q *Queries) GetBatchProgress(ctx context.Context, id uuid.UUID) (GetBatchProgressRow, error) {
+	row := q.db.QueryRow(ctx, getBatchProgress, id)
+	var i GetBatchProgressRow
+	err := row.Scan(
+		&i.Status,
+		&i.Nrows,
+		&i.Nsuccess,
+		&i.Nfailed,
+		&i.Naborted,
+		&i.Startedat,
+		&i.Progress,
 	)
 	return i, err
 }
 
+const getBatchRowResults = `-- name: GetBatchRowResults :many
+SELECT rowid, runno, batch, line, status, doneat, res, blobrows, messages, doneby
+FROM batchrowresults
+WHERE batch = $1
+ORDER BY line, runno
+`
+
+func (q *Queries) GetBatchRowResults(ctx context.Context, batch uuid.UUID) ([]Batchrowresult, error) {
+	rows, err := q.db.Query(ctx, getBatchRowResults, batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Batchrowresult
+	for rows.Next() {
+		var i Batchrowresult
+		if err := rows.Scan(
+			&i.Rowid,
+			&i.Runno,
+			&i.Batch,
+			&i.Line,
+			&i.Status,
+			&i.Doneat,
+			&i.Res,
+			&i.Blobrows,
+			&i.Messages,
+			&i.Doneby,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getBatchRowsByBatchID = `-- name: GetBatchRowsByBatchID :many
-SELECT rowid, batch, line, input, status, reqat, doneat, res, blobrows, messages, doneby, created_at FROM batchrows WHERE batch = $1
+SELECT rowid, batch, line, input, status, reqat, doneat, res, blobrows, messages, doneby, created_at, leaseexpiry, attempts, nexttry, lasterr, errtrace, runno, partkey FROM batchrows WHERE batch = $1
 `
 
 func (q *Queries) GetBatchRowsByBatchID(ctx context.Context, batch uuid.UUID) ([]Batchrow, error) {
@@ -203,6 +627,13 @@ func (q *Queries) GetBatchRowsByBatchID(ctx context.Context, batch uuid.UUID) ([
 			&i.Messages,
 			&i.Doneby,
 			&i.CreatedAt,
+			&i.Leaseexpiry,
+			&i.Attempts,
+			&i.Nexttry,
+			&i.Lasterr,
+			&i.Errtrace,
+			&i.Runno,
+			&i.Partkey,
 		); err != nil {
 			return nil, err
 		}
@@ -338,50 +769,27 @@ func (q *Queries) GetCompletedBatches(ctx context.Context) ([]uuid.UUID, error)
 	return items, nil
 }
 
-const getPendingBatchRows = `-- name: GetPendingBatchRows :many
-SELECT rowid, line, input, status, reqat, doneat, res, blobrows, messages, doneby
+const getDeadLetterBatches = `-- name: GetDeadLetterBatches :many
+SELECT DISTINCT batch
 FROM batchrows
-WHERE batch = $1 AND status IN ('queued', 'inprog')
-FOR UPDATE
+WHERE rowid = ANY($1::bigint[]) AND status = 'deadletter'
+ORDER BY batch
 `
 
-type GetPendingBatchRowsRow struct {
-	Rowid    int64            `json:"rowid"`
-	Line     int32            `json:"line"`
-	Input    []byte           `json:"input"`
-	Status   StatusEnum       `json:"status"`
-	Reqat    pgtype.Timestamp `json:"reqat"`
-	Doneat   pgtype.Timestamp `json:"doneat"`
-	Res      []byte           `json:"res"`
-	Blobrows []byte           `json:"blobrows"`
-	Messages []byte           `json:"messages"`
-	Doneby   pgtype.Text      `json:"doneby"`
-}
-
-func (q *Queries) GetPendingBatchRows(ctx context.Context, batch uuid.UUID) ([]GetPendingBatchRowsRow, error) {
-	rows, err := q.db.Query(ctx, getPendingBatchRows, batch)
+// The batches of the dead-lettered rows, in the order in which they are locked
+func (q *Queries) GetDeadLetterBatches(ctx context.Context, rowids []int64) ([]uuid.UUID, error) {
+	rows, err := q.db.Query(ctx, getDeadLetterBatches, rowids)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetPendingBatchRowsRow
+	var items []uuid.UUID
 	for rows.Next() {
-		var i GetPendingBatchRowsRow
-		if err := rows.Scan(
-			&i.Rowid,
-			&i.Line,
-			&i.Input,
-			&i.Status,
-			&i.Reqat,
-			&i.Doneat,
-			&i.Res,
-			&i.Blobrows,
-			&i.Messages,
-			&i.Doneby,
-		); err != nil {
+		var batch uuid.UUID
+		if err := rows.Scan(&batch); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, batch)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -389,24 +797,276 @@ func (q *Queries) GetPendingBatchRows(ctx context.Context, batch uuid.UUID) ([]G
 	return items, nil
 }
 
-const getProcessedBatchRowsByBatchIDSorted = `-- name: GetProcessedBatchRowsByBatchIDSorted :many
-SELECT rowid, line, input, status, reqat, doneat, res, blobrows, messages, doneby
+const getDeadLetterRow = `-- name: GetDeadLetterRow :one
+SELECT batchrows.rowid, batchrows.batch, batches.app, batches.op, batchrows.line, batchrows.input,
+    batchrows.attempts, batchrows.lasterr, batchrows.errtrace, batchrows.doneat
 FROM batchrows
-WHERE batch = $1 AND status IN ('success', 'failed')
-ORDER BY line
-FOR UPDATE
+INNER JOIN batches ON batchrows.batch = batches.id
+WHERE batchrows.status = 'deadletter' AND batchrows.rowid = $1
 `
 
-type GetProcessedBatchRowsByBatchIDSortedRow struct {
+type GetDeadLetterRowRow struct {
 	Rowid    int64            `json:"rowid"`
+	Batch    uuid.UUID        `json:"batch"`
+	App      string           `json:"app"`
+	Op       string           `json:"op"`
 	Line     int32            `json:"line"`
 	Input    []byte           `json:"input"`
-	Status   StatusEnum       `json:"status"`
-	Reqat    pgtype.Timestamp `json:"reqat"`
+	Attempts int32            `json:"attempts"`
+	Lasterr  pgtype.Text      `json:"lasterr"`
+	Errtrace pgtype.Text      `json:"errtrace"`
 	Doneat   pgtype.Timestamp `json:"doneat"`
-	Res      []byte           `json:"res"`
-	Blobrows []byte           `json:"blobrows"`
-	Messages []byte           `json:"messages"`
+}
+
+func (q *Queries) GetDeadLetterRow(ctx context.Context, rowid int64) (GetDeadLetterRowRow, error) {
+	row := q.db.QueryRow(ctx, getDeadLetterRow, rowid)
+	var i GetDeadLetterRowRow
+	err := row.Scan(
+		&i.Rowid,
+		&i.Batch,
+		&i.App,
+		&i.Op,
+		&i.Line,
+		&i.Input,
+		&i.Attempts,
+		&i.Lasterr,
+		&i.Errtrace,
+		&i.Doneat,
+	)
+	return i, err
+}
+
+const getDueRecurringJob = `-- name: GetDueRecurringJob :one
+SELECT name, nextrun
+FROM recurringjobs
+WHERE name = $1 AND nextrun <= $2
+FOR UPDATE SKIP LOCKED
+`
+
+type GetDueRecurringJobParams struct {
+	Name    string           `json:"name"`
+	Nextrun pgtype.Timestamp `json:"nextrun"`
+}
+
+type GetDueRecurringJobRow struct {
+	Name    string           `json:"name"`
+	Nextrun pgtype.Timestamp `json:"nextrun"`
+}
+
+func (q *Queries) GetDueRecurringJob(ctx context.Context, arg GetDueRecurringJobParams) (GetDueRecurringJobRow, error) {
+	row := q.db.QueryRow(ctx, getDueRecurringJob, arg.Name, arg.Nextrun)
+	var i GetDueRecurringJobRow
+	err := row.Scan(&i.Name, &i.Nextrun)
+	return i, err
+}
+
+const getMemoizedQuery = `-- name: GetMemoizedQuery :one
+SELECT id
+FROM batches
+WHERE app = $1 AND op = $2 AND memohash = $3
+  AND (status IN ('queued', 'inprog', 'paused') OR (status = 'success' AND doneat > $4))
+ORDER BY reqat DESC
+LIMIT 1
+`
+
+type GetMemoizedQueryParams struct {
+	App       string           `json:"app"`
+	Op        string           `json:"op"`
+	Memohash  []byte           `json:"memohash"`
+	Doneafter pgtype.Timestamp `json:"doneafter"`
+}
+
+// The latest slow query of the (app, op) with the memo hash which is pending, or which succeeded after
+// doneafter
+func (q *Queries) GetMemoizedQuery(ctx context.Context, arg GetMemoizedQueryParams) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, getMemoizedQuery,
+		arg.App,
+		arg.Op,
+		arg.Memohash,
+		arg.Doneafter,
+	)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getPendingBatchRows = `-- name: GetPendingBatchRows :many
+SELECT rowid, line, input, status, reqat, doneat, res, blobrows, messages, doneby
+FROM batchrows
+WHERE batch = $1 AND status IN ('queued', 'inprog')
+FOR UPDATE
+`
+
+type GetPendingBatchRowsRow struct {
+	Rowid    int64            `json:"rowid"`
+	Line     int32            `json:"line"`
+	Input    []byte           `json:"input"`
+	Status   StatusEnum       `json:"status"`
+	Reqat    pgtype.Timestamp `json:"reqat"`
+	Doneat   pgtype.Timestamp `json:"doneat"`
+	Res      []byte           `json:"res"`
+	Blobrows []byte           `json:"blobrows"`
+	Messages []byte           `json:"messages"`
+	Doneby   pgtype.Text      `json:"doneby"`
+}
+
+func (q *Queries) GetPendingBatchRows(ctx context.Context, batch uuid.UUID) ([]GetPendingBatchRowsRow, error) {
+	rows, err := q.db.Query(ctx, getPendingBatchRows, batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPendingBatchRowsRow
+	for rows.Next() {
+		var i GetPendingBatchRowsRow
+		if err := rows.Scan(
+			&i.Rowid,
+			&i.Line,
+			&i.Input,
+			&i.Status,
+			&i.Reqat,
+			&i.Doneat,
+			&i.Res,
+			&i.Blobrows,
+			&i.Messages,
+			&i.Doneby,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPipelineByID = `-- name: GetPipelineByID :one
+SELECT id, name, context, priority, status, step, reqat, doneat
+FROM pipelines
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetPipelineByID(ctx context.Context, id uuid.UUID) (Pipeline, error) {
+	row := q.db.QueryRow(ctx, getPipelineByID, id)
+	var i Pipeline
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Context,
+		&i.Priority,
+		&i.Status,
+		&i.Step,
+		&i.Reqat,
+		&i.Doneat,
+	)
+	return i, err
+}
+
+const getPipelineSteps = `-- name: GetPipelineSteps :many
+SELECT s.step, s.batch, b.app, b.op, b.status
+FROM pipelinesteps s
+JOIN batches b ON b.id = s.batch
+WHERE s.pipeline = $1
+ORDER BY s.step
+`
+
+type GetPipelineStepsRow struct {
+	Step   int32      `json:"step"`
+	Batch  uuid.UUID  `json:"batch"`
+	App    string     `json:"app"`
+	Op     string     `json:"op"`
+	Status StatusEnum `json:"status"`
+}
+
+func (q *Queries) GetPipelineSteps(ctx context.Context, pipeline uuid.UUID) ([]GetPipelineStepsRow, error) {
+	rows, err := q.db.Query(ctx, getPipelineSteps, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPipelineStepsRow
+	for rows.Next() {
+		var i GetPipelineStepsRow
+		if err := rows.Scan(
+			&i.Step,
+			&i.Batch,
+			&i.App,
+			&i.Op,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPipelineToAdvance = `-- name: GetPipelineToAdvance :one
+SELECT p.id, p.name, p.context, p.priority, p.step, s.batch, b.status AS batchstatus
+FROM pipelines p
+JOIN pipelinesteps s ON s.pipeline = p.id AND s.step = p.step
+JOIN batches b ON b.id = s.batch
+WHERE p.status = 'inprog' AND b.status IN ('success', 'failed', 'aborted')
+AND p.name = ANY($1::text[]) AND NOT (p.id = ANY($2::uuid[]))
+ORDER BY b.doneat
+LIMIT 1
+FOR UPDATE OF p SKIP LOCKED
+`
+
+type GetPipelineToAdvanceParams struct {
+	Names   []string    `json:"names"`
+	Skipped []uuid.UUID `json:"skipped"`
+}
+
+type GetPipelineToAdvanceRow struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Context     []byte     `json:"context"`
+	Priority    int32      `json:"priority"`
+	Step        int32      `json:"step"`
+	Batch       uuid.UUID  `json:"batch"`
+	Batchstatus StatusEnum `json:"batchstatus"`
+}
+
+// Returns a pipeline whose current batch is done, skipping those being advanced by another instance
+func (q *Queries) GetPipelineToAdvance(ctx context.Context, arg GetPipelineToAdvanceParams) (GetPipelineToAdvanceRow, error) {
+	row := q.db.QueryRow(ctx, getPipelineToAdvance, arg.Names, arg.Skipped)
+	var i GetPipelineToAdvanceRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Context,
+		&i.Priority,
+		&i.Step,
+		&i.Batch,
+		&i.Batchstatus,
+	)
+	return i, err
+}
+
+const getProcessedBatchRowsByBatchIDSorted = `-- name: GetProcessedBatchRowsByBatchIDSorted :many
+SELECT rowid, line, input, status, reqat, doneat, res, blobrows, messages, doneby
+FROM batchrows
+WHERE batch = $1 AND status IN ('success', 'failed') AND blobrows IS NOT NULL
+ORDER BY line
+FOR UPDATE
+`
+
+type GetProcessedBatchRowsByBatchIDSortedRow struct {
+	Rowid    int64            `json:"rowid"`
+	Line     int32            `json:"line"`
+	Input    []byte           `json:"input"`
+	Status   StatusEnum       `json:"status"`
+	Reqat    pgtype.Timestamp `json:"reqat"`
+	Doneat   pgtype.Timestamp `json:"doneat"`
+	Res      []byte           `json:"res"`
+	Blobrows []byte           `json:"blobrows"`
+	Messages []byte           `json:"messages"`
 	Doneby   pgtype.Text      `json:"doneby"`
 }
 
@@ -416,20 +1076,483 @@ func (q *Queries) GetProcessedBatchRowsByBatchIDSorted(ctx context.Context, batc
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetProcessedBatchRowsByBatchIDSortedRow
+	var items []GetProcessedBatchRowsByBatchIDSortedRow
+	for rows.Next() {
+		var i GetProcessedBatchRowsByBatchIDSortedRow
+		if err := rows.Scan(
+			&i.Rowid,
+			&i.Line,
+			&i.Input,
+			&i.Status,
+			&i.Reqat,
+			&i.Doneat,
+			&i.Res,
+			&i.Blobrows,
+			&i.Messages,
+			&i.Doneby,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertBatchFile = `-- name: InsertBatchFile :exec
+INSERT INTO batch_files (
+    batch_id,
+    object_id,
+    filename,
+    size,
+    checksum,
+    content_type,
+    status,
+    received_at,
+    metadata
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+`
+
+type InsertBatchFileParams struct {
+	BatchID     uuid.UUID          `json:"batch_id"`
+	ObjectID    string             `json:"object_id"`
+	Filename    string             `json:"filename"`
+	Size        int64              `json:"size"`
+	Checksum    string             `json:"checksum"`
+	ContentType string             `json:"content_type"`
+	Status      bool               `json:"status"`
+	ReceivedAt  pgtype.Timestamptz `json:"received_at"`
+	Metadata    []byte             `json:"metadata"`
+}
+
+func (q *Queries) InsertBatchFile(ctx context.Context, arg InsertBatchFileParams) error {
+	_, err := q.db.Exec(ctx, insertBatchFile,
+		arg.BatchID,
+		arg.ObjectID,
+		arg.Filename,
+		arg.Size,
+		arg.Checksum,
+		arg.ContentType,
+		arg.Status,
+		arg.ReceivedAt,
+		arg.Metadata,
+	)
+	return err
+}
+
+const insertIntoBatchRows = `-- name: InsertIntoBatchRows :exec
+WITH inserted AS (
+    INSERT INTO batchrows (batch, line, input, status, reqat, partkey)
+    VALUES ($1, $2, $3, 'queued', $4, $5)
+    RETURNING batch
+)
+UPDATE batches
+SET nrows = nrows + 1
+WHERE id IN (SELECT batch FROM inserted)
+`
+
+type InsertIntoBatchRowsParams struct {
+	Batch   uuid.UUID        `json:"batch"`
+	Line    int32            `json:"line"`
+	Input   []byte           `json:"input"`
+	Reqat   pgtype.Timestamp `json:"reqat"`
+	Partkey pgtype.Text      `json:"partkey"`
+}
+
+// The number of rows of the batch is counted in batches.nrows
+func (q *Queries) InsertIntoBatchRows(ctx context.Context, arg InsertIntoBatchRowsParams) error {
+	_, err := q.db.Exec(ctx, insertIntoBatchRows,
+		arg.Batch,
+		arg.Line,
+		arg.Input,
+		arg.Reqat,
+		arg.Partkey,
+	)
+	return err
+}
+
+const insertIntoBatches = `-- name: InsertIntoBatches :one
+INSERT INTO batches (id, app, op, context, status, reqat, priority, notbefore, idempotencykey, payloadhash, memohash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (app, op, idempotencykey) WHERE idempotencykey IS NOT NULL DO NOTHING
+RETURNING id
+`
+
+type InsertIntoBatchesParams struct {
+	ID             uuid.UUID        `json:"id"`
+	App            string           `json:"app"`
+	Op             string           `json:"op"`
+	Context        []byte           `json:"context"`
+	Status         StatusEnum       `json:"status"`
+	Reqat          pgtype.Timestamp `json:"reqat"`
+	Priority       int32            `json:"priority"`
+	Notbefore      pgtype.Timestamp `json:"notbefore"`
+	Idempotencykey pgtype.Text      `json:"idempotencykey"`
+	Payloadhash    []byte           `json:"payloadhash"`
+	Memohash       []byte           `json:"memohash"`
+}
+
+// Nothing is inserted, and no row returned, if a batch of the (app, op) has the idempotency key
+func (q *Queries) InsertIntoBatches(ctx context.Context, arg InsertIntoBatchesParams) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, insertIntoBatches,
+		arg.ID,
+		arg.App,
+		arg.Op,
+		arg.Context,
+		arg.Status,
+		arg.Reqat,
+		arg.Priority,
+		arg.Notbefore,
+		arg.Idempotencykey,
+		arg.Payloadhash,
+		arg.Memohash,
+	)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const insertPipeline = `-- name: InsertPipeline :exec
+INSERT INTO pipelines (id, name, context, priority, status, reqat)
+VALUES ($1, $2, $3, $4, 'inprog', $5)
+`
+
+type InsertPipelineParams struct {
+	ID       uuid.UUID        `json:"id"`
+	Name     string           `json:"name"`
+	Context  []byte           `json:"context"`
+	Priority int32            `json:"priority"`
+	Reqat    pgtype.Timestamp `json:"reqat"`
+}
+
+func (q *Queries) InsertPipeline(ctx context.Context, arg InsertPipelineParams) error {
+	_, err := q.db.Exec(ctx, insertPipeline,
+		arg.ID,
+		arg.Name,
+		arg.Context,
+		arg.Priority,
+		arg.Reqat,
+	)
+	return err
+}
+
+const insertPipelineStep = `-- name: InsertPipelineStep :exec
+WITH inserted AS (
+    INSERT INTO pipelinesteps (pipeline, step, batch)
+    VALUES ($1, $2, $3)
+)
+UPDATE pipelines
+SET step = $2
+WHERE id = $1
+`
+
+type InsertPipelineStepParams struct {
+	Pipeline uuid.UUID `json:"pipeline"`
+	Step     int32     `json:"step"`
+	Batch    uuid.UUID `json:"batch"`
+}
+
+// The step becomes the current step of its pipeline
+func (q *Queries) InsertPipelineStep(ctx context.Context, arg InsertPipelineStepParams) error {
+	_, err := q.db.Exec(ctx, insertPipelineStep, arg.Pipeline, arg.Step, arg.Batch)
+	return err
+}
+
+const leaseBatchRows = `-- name: LeaseBatchRows :exec
+UPDATE batchrows
+SET status = 'inprog', doneby = $1, leaseexpiry = $2, attempts = attempts + 1
+WHERE rowid = ANY($3::bigint[])
+`
+
+type LeaseBatchRowsParams struct {
+	Doneby      pgtype.Text      `json:"doneby"`
+	Leaseexpiry pgtype.Timestamp `json:"leaseexpiry"`
+	Rowids      []int64          `json:"rowids"`
+}
+
+func (q *Queries) LeaseBatchRows(ctx context.Context, arg LeaseBatchRowsParams) error {
+	_, err := q.db.Exec(ctx, leaseBatchRows, arg.Doneby, arg.Leaseexpiry, arg.Rowids)
+	return err
+}
+
+const listBatchRowResults = `-- name: ListBatchRowResults :many
+SELECT rowid, line, status, res, messages, runno
+FROM batchrows
+WHERE batch = $1
+AND (cardinality($2::text[]) = 0 OR status::text = ANY($2::text[]))
+AND line >= $3::int AND line <= $4::int
+AND ($5::int IS NULL OR (line, rowid) > ($5::int, $6::bigint))
+ORDER BY line, rowid
+LIMIT $7
+`
+
+type ListBatchRowResultsParams struct {
+	Batch      uuid.UUID   `json:"batch"`
+	Statuses   []string    `json:"statuses"`
+	Fromline   int32       `json:"fromline"`
+	Toline     int32       `json:"toline"`
+	Afterline  pgtype.Int4 `json:"afterline"`
+	Afterrowid pgtype.Int8 `json:"afterrowid"`
+	Pagesize   int32       `json:"pagesize"`
+}
+
+type ListBatchRowResultsRow struct {
+	Rowid    int64      `json:"rowid"`
+	Line     int32      `json:"line"`
+	Status   StatusEnum `json:"status"`
+	Res      []byte     `json:"res"`
+	Messages []byte     `json:"messages"`
+	Runno    int32      `json:"runno"`
+}
+
+func (q *Queries) ListBatchRowResults(ctx context.Context, arg ListBatchRowResultsParams) ([]ListBatchRowResultsRow, error) {
+	rows, err := q.db.Query(ctx, listBatchRowResults,
+		arg.Batch,
+		arg.Statuses,
+		arg.Fromline,
+		arg.Toline,
+		arg.Afterline,
+		arg.Afterrowid,
+		arg.Pagesize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBatchRowResultsRow
+	for rows.Next() {
+		var i ListBatchRowResultsRow
+		if err := rows.Scan(
+			&i.Rowid,
+			&i.Line,
+			&i.Status,
+			&i.Res,
+			&i.Messages,
+			&i.Runno,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBatches = `-- name: ListBatches :many
+SELECT b.id, b.app, b.op, b.context, b.inputfile, b.status, b.reqat, b.doneat, b.outputfiles,
+    b.nsuccess, b.nfailed, b.naborted, b.aggregate, b.aggregateerr,
+    (SELECT count(*) FROM batchrows r WHERE r.batch = b.id) AS nrows
+FROM batches b
+WHERE b.app = $1
+AND ($2::text = '' OR b.op = $2::text)
+AND (cardinality($3::text[]) = 0 OR b.status::text = ANY($3::text[]))
+AND b.reqat >= $4::timestamp AND b.reqat < $5::timestamp
+AND ($6::timestamp IS NULL OR (b.reqat, b.id) < ($6::timestamp, $7::uuid))
+AND NOT EXISTS (SELECT 1 FROM batchrows r WHERE r.batch = b.id AND r.line = 0)
+ORDER BY b.reqat DESC, b.id DESC
+LIMIT $8
+`
+
+type ListBatchesParams struct {
+	App        string           `json:"app"`
+	Op         string           `json:"op"`
+	Statuses   []string         `json:"statuses"`
+	Reqfrom    pgtype.Timestamp `json:"reqfrom"`
+	Requntil   pgtype.Timestamp `json:"requntil"`
+	Afterreqat pgtype.Timestamp `json:"afterreqat"`
+	Afterid    pgtype.UUID      `json:"afterid"`
+	Pagesize   int32            `json:"pagesize"`
+}
+
+type ListBatchesRow struct {
+	ID           uuid.UUID        `json:"id"`
+	App          string           `json:"app"`
+	Op           string           `json:"op"`
+	Context      []byte           `json:"context"`
+	Inputfile    pgtype.Text      `json:"inputfile"`
+	Status       StatusEnum       `json:"status"`
+	Reqat        pgtype.Timestamp `json:"reqat"`
+	Doneat       pgtype.Timestamp `json:"doneat"`
+	Outputfiles  []byte           `json:"outputfiles"`
+	Nsuccess     pgtype.Int4      `json:"nsuccess"`
+	Nfailed      pgtype.Int4      `json:"nfailed"`
+	Naborted     pgtype.Int4      `json:"naborted"`
+	Aggregate    []byte           `json:"aggregate"`
+	Aggregateerr pgtype.Text      `json:"aggregateerr"`
+	Nrows        int64            `json:"nrows"`
+}
+
+func (q *Queries) ListBatches(ctx context.Context, arg ListBatchesParams) ([]ListBatchesRow, error) {
+	rows, err := q.db.Query(ctx, listBatches,
+		arg.App,
+		arg.Op,
+		arg.Statuses,
+		arg.Reqfrom,
+		arg.Requntil,
+		arg.Afterreqat,
+		arg.Afterid,
+		arg.Pagesize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBatchesRow
+	for rows.Next() {
+		var i ListBatchesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.App,
+			&i.Op,
+			&i.Context,
+			&i.Inputfile,
+			&i.Status,
+			&i.Reqat,
+			&i.Doneat,
+			&i.Outputfiles,
+			&i.Nsuccess,
+			&i.Nfailed,
+			&i.Naborted,
+			&i.Aggregate,
+			&i.Aggregateerr,
+			&i.Nrows,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeadLetterRows = `-- name: ListDeadLetterRows :many
+SELECT batchrows.rowid, batchrows.batch, batches.app, batches.op, batchrows.line, batchrows.input,
+    batchrows.attempts, batchrows.lasterr, batchrows.errtrace, batchrows.doneat
+FROM batchrows
+INNER JOIN batches ON batchrows.batch = batches.id
+WHERE batchrows.status = 'deadletter' AND batches.app = $1 AND batches.op = $2
+ORDER BY batchrows.rowid
+`
+
+type ListDeadLetterRowsParams struct {
+	App string `json:"app"`
+	Op  string `json:"op"`
+}
+
+type ListDeadLetterRowsRow struct {
+	Rowid    int64            `json:"rowid"`
+	Batch    uuid.UUID        `json:"batch"`
+	App      string           `json:"app"`
+	Op       string           `json:"op"`
+	Line     int32            `json:"line"`
+	Input    []byte           `json:"input"`
+	Attempts int32            `json:"attempts"`
+	Lasterr  pgtype.Text      `json:"lasterr"`
+	Errtrace pgtype.Text      `json:"errtrace"`
+	Doneat   pgtype.Timestamp `json:"doneat"`
+}
+
+func (q *Queries) ListDeadLetterRows(ctx context.Context, arg ListDeadLetterRowsParams) ([]ListDeadLetterRowsRow, error) {
+	rows, err := q.db.Query(ctx, listDeadLetterRows, arg.App, arg.Op)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDeadLetterRowsRow
+	for rows.Next() {
+		var i ListDeadLetterRowsRow
+		if err := rows.Scan(
+			&i.Rowid,
+			&i.Batch,
+			&i.App,
+			&i.Op,
+			&i.Line,
+			&i.Input,
+			&i.Attempts,
+			&i.Lasterr,
+			&i.Errtrace,
+			&i.Doneat,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSlowQueries = `-- name: ListSlowQueries :many
+SELECT b.id, b.app, b.op, b.context, b.status, b.reqat, b.doneat, b.outputfiles
+FROM batches b
+WHERE b.app = $1
+AND ($2::text = '' OR b.op = $2::text)
+AND (cardinality($3::text[]) = 0 OR b.status::text = ANY($3::text[]))
+AND b.reqat >= $4::timestamp AND b.reqat < $5::timestamp
+AND ($6::timestamp IS NULL OR (b.reqat, b.id) < ($6::timestamp, $7::uuid))
+AND EXISTS (SELECT 1 FROM batchrows r WHERE r.batch = b.id AND r.line = 0)
+ORDER BY b.reqat DESC, b.id DESC
+LIMIT $8
+`
+
+type ListSlowQueriesParams struct {
+	App        string           `json:"app"`
+	Op         string           `json:"op"`
+	Statuses   []string         `json:"statuses"`
+	Reqfrom    pgtype.Timestamp `json:"reqfrom"`
+	Requntil   pgtype.Timestamp `json:"requntil"`
+	Afterreqat pgtype.Timestamp `json:"afterreqat"`
+	Afterid    pgtype.UUID      `json:"afterid"`
+	Pagesize   int32            `json:"pagesize"`
+}
+
+type ListSlowQueriesRow struct {
+	ID          uuid.UUID        `json:"id"`
+	App         string           `json:"app"`
+	Op          string           `json:"op"`
+	Context     []byte           `json:"context"`
+	Status      StatusEnum       `json:"status"`
+	Reqat       pgtype.Timestamp `json:"reqat"`
+	Doneat      pgtype.Timestamp `json:"doneat"`
+	Outputfiles []byte           `json:"outputfiles"`
+}
+
+func (q *Queries) ListSlowQueries(ctx context.Context, arg ListSlowQueriesParams) ([]ListSlowQueriesRow, error) {
+	rows, err := q.db.Query(ctx, listSlowQueries,
+		arg.App,
+		arg.Op,
+		arg.Statuses,
+		arg.Reqfrom,
+		arg.Requntil,
+		arg.Afterreqat,
+		arg.Afterid,
+		arg.Pagesize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSlowQueriesRow
 	for rows.Next() {
-		var i GetProcessedBatchRowsByBatchIDSortedRow
+		var i ListSlowQueriesRow
 		if err := rows.Scan(
-			&i.Rowid,
-			&i.Line,
-			&i.Input,
+			&i.ID,
+			&i.App,
+			&i.Op,
+			&i.Context,
 			&i.Status,
 			&i.Reqat,
 			&i.Doneat,
-			&i.Res,
-			&i.Blobrows,
-			&i.Messages,
-			&i.Doneby,
+			&i.Outputfiles,
 		); err != nil {
 			return nil, err
 		}
@@ -441,98 +1564,324 @@ func (q *Queries) GetProcessedBatchRowsByBatchIDSorted(ctx context.Context, batc
 	return items, nil
 }
 
-const insertBatchFile = `-- name: InsertBatchFile :exec
-INSERT INTO batch_files (
-    batch_id,
-    object_id,
-    filename,
-    size,
-    checksum,
-    content_type,
-    status,
-    received_at,
-    metadata
-) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9
+const markBatchInprog = `-- name: MarkBatchInprog :exec
+UPDATE batches
+SET status = 'inprog', startedat = COALESCE(startedat, $2)
+WHERE id = $1 AND status = 'queued'
+`
+
+type MarkBatchInprogParams struct {
+	ID        uuid.UUID        `json:"id"`
+	Startedat pgtype.Timestamp `json:"startedat"`
+}
+
+// A batch paused since its rows were fetched stays paused. The time at which the rows of a batch
+// were first fetched is kept when it is queued again.
+func (q *Queries) MarkBatchInprog(ctx context.Context, arg MarkBatchInprogParams) error {
+	_, err := q.db.Exec(ctx, markBatchInprog, arg.ID, arg.Startedat)
+	return err
+}
+
+const notifyBatchQueued = `-- name: NotifyBatchQueued :exec
+SELECT pg_notify($1::text, $2::text)
+`
+
+type NotifyBatchQueuedParams struct {
+	Channel string `json:"channel"`
+	Batch   string `json:"batch"`
+}
+
+// The notification is delivered to the listening instances when the transaction commits
+func (q *Queries) NotifyBatchQueued(ctx context.Context, arg NotifyBatchQueuedParams) error {
+	_, err := q.db.Exec(ctx, notifyBatchQueued, arg.Channel, arg.Batch)
+	return err
+}
+
+const reclaimExpiredLeases = `-- name: ReclaimExpiredLeases :many
+WITH policies AS (
+    SELECT unnest($1::text[]) AS key, unnest($2::int[]) AS maxattempts
+), expired AS (
+    SELECT r.rowid, COALESCE(p.maxattempts, $3::int) AS maxattempts
+    FROM batchrows r
+    JOIN batches b ON b.id = r.batch
+    LEFT JOIN policies p ON p.key = b.app || b.op
+    WHERE r.status = 'inprog' AND r.leaseexpiry < $4::timestamp
+), reclaimed AS (
+    UPDATE batchrows
+    SET status = CASE WHEN batchrows.attempts >= e.maxattempts THEN 'deadletter'::status_enum ELSE 'queued'::status_enum END,
+        doneat = CASE WHEN batchrows.attempts >= e.maxattempts THEN $4::timestamp ELSE NULL END,
+        lasterr = 'lease expired before a result was recorded',
+        doneby = NULL, leaseexpiry = NULL
+    FROM expired e
+    WHERE batchrows.rowid = e.rowid AND batchrows.status = 'inprog' AND batchrows.leaseexpiry < $4::timestamp
+    RETURNING batchrows.rowid, batchrows.batch, batchrows.status
+), counted AS (
+    UPDATE batches
+    SET nfailed = COALESCE(nfailed, 0) + d.nfailed
+    FROM (SELECT batch, count(*)::int AS nfailed FROM reclaimed WHERE status = 'deadletter' GROUP BY batch) d
+    WHERE batches.id = d.batch
 )
+SELECT rowid, batch, status FROM reclaimed
 `
 
-type InsertBatchFileParams struct {
-	BatchID     uuid.UUID          `json:"batch_id"`
-	ObjectID    string             `json:"object_id"`
-	Filename    string             `json:"filename"`
-	Size        int64              `json:"size"`
-	Checksum    string             `json:"checksum"`
-	ContentType string             `json:"content_type"`
-	Status      bool               `json:"status"`
-	ReceivedAt  pgtype.Timestamptz `json:"received_at"`
-	Metadata    []byte             `json:"metadata"`
+type ReclaimExpiredLeasesParams struct {
+	Policykeys        []string         `json:"policykeys"`
+	Policymaxattempts []int32          `json:"policymaxattempts"`
+	Maxattempts       int32            `json:"maxattempts"`
+	Leaseexpiry       pgtype.Timestamp `json:"leaseexpiry"`
 }
 
-func (q *Queries) InsertBatchFile(ctx context.Context, arg InsertBatchFileParams) error {
-	_, err := q.db.Exec(ctx, insertBatchFile,
-		arg.BatchID,
-		arg.ObjectID,
-		arg.Filename,
-		arg.Size,
-		arg.Checksum,
-		arg.ContentType,
-		arg.Status,
-		arg.ReceivedAt,
-		arg.Metadata,
+type ReclaimExpiredLeasesRow struct {
+	Rowid  int64      `json:"rowid"`
+	Batch  uuid.UUID  `json:"batch"`
+	Status StatusEnum `json:"status"`
+}
+
+// Rows are dead-lettered once they have been attempted as many times as the retry policy of their
+// (app, op) allows, given by the app || op keys in policykeys and the matching policymaxattempts, or
+// maxattempts times if it has none. Dead-lettered rows are added to the nfailed counter of their batches.
+func (q *Queries) ReclaimExpiredLeases(ctx context.Context, arg ReclaimExpiredLeasesParams) ([]ReclaimExpiredLeasesRow, error) {
+	rows, err := q.db.Query(ctx, reclaimExpiredLeases,
+		arg.Policykeys,
+		arg.Policymaxattempts,
+		arg.Maxattempts,
+		arg.Leaseexpiry,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReclaimExpiredLeasesRow
+	for rows.Next() {
+		var i ReclaimExpiredLeasesRow
+		if err := rows.Scan(&i.Rowid, &i.Batch, &i.Status); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWorkerHeartbeat = `-- name: RecordWorkerHeartbeat :exec
+INSERT INTO workers (id, startedat, heartbeat)
+VALUES ($1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET heartbeat = EXCLUDED.heartbeat
+`
+
+type RecordWorkerHeartbeatParams struct {
+	ID        string           `json:"id"`
+	Startedat pgtype.Timestamp `json:"startedat"`
+	Heartbeat pgtype.Timestamp `json:"heartbeat"`
+}
+
+func (q *Queries) RecordWorkerHeartbeat(ctx context.Context, arg RecordWorkerHeartbeatParams) error {
+	_, err := q.db.Exec(ctx, recordWorkerHeartbeat, arg.ID, arg.Startedat, arg.Heartbeat)
 	return err
 }
 
-const insertIntoBatchRows = `-- name: InsertIntoBatchRows :exec
-INSERT INTO batchrows (batch, line, input, status, reqat)
-VALUES ($1, $2, $3, 'queued', $4)
+const releaseBatchRows = `-- name: ReleaseBatchRows :exec
+UPDATE batchrows
+SET status = 'queued', attempts = attempts - 1, lasterr = $1, doneby = NULL, leaseexpiry = NULL
+WHERE rowid = ANY($2::bigint[]) AND status = 'inprog' AND doneby = $3
 `
 
-type InsertIntoBatchRowsParams struct {
-	Batch uuid.UUID        `json:"batch"`
-	Line  int32            `json:"line"`
-	Input []byte           `json:"input"`
-	Reqat pgtype.Timestamp `json:"reqat"`
+type ReleaseBatchRowsParams struct {
+	Lasterr pgtype.Text `json:"lasterr"`
+	Rowids  []int64     `json:"rowids"`
+	Doneby  pgtype.Text `json:"doneby"`
 }
 
-func (q *Queries) InsertIntoBatchRows(ctx context.Context, arg InsertIntoBatchRowsParams) error {
-	_, err := q.db.Exec(ctx, insertIntoBatchRows,
-		arg.Batch,
-		arg.Line,
-		arg.Input,
-		arg.Reqat,
-	)
+// Rows leased by a worker which is shutting down are queued again, unless they were aborted or taken
+// over by another worker since. The attempt counted when they were leased is taken back, since the
+// rows were released rather than lost.
+func (q *Queries) ReleaseBatchRows(ctx context.Context, arg ReleaseBatchRowsParams) error {
+	_, err := q.db.Exec(ctx, releaseBatchRows, arg.Lasterr, arg.Rowids, arg.Doneby)
 	return err
 }
 
-const insertIntoBatches = `-- name: InsertIntoBatches :one
-INSERT INTO batches (id, app, op, context, status, reqat)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id
+const reopenBatch = `-- name: ReopenBatch :exec
+UPDATE batches
+SET status = CASE WHEN batches.status IN ('paused', 'wait') THEN batches.status ELSE 'queued'::status_enum END,
+    doneat = NULL, nsuccess = c.nsuccess, nfailed = c.nfailed, naborted = c.naborted
+FROM (
+    SELECT count(*) FILTER (WHERE status = 'success')::int AS nsuccess,
+        count(*) FILTER (WHERE status IN ('failed', 'deadletter'))::int AS nfailed,
+        count(*) FILTER (WHERE status = 'aborted')::int AS naborted
+    FROM batchrows
+    WHERE batch = $1
+) c
+WHERE id = $1
 `
 
-type InsertIntoBatchesParams struct {
-	ID      uuid.UUID        `json:"id"`
-	App     string           `json:"app"`
-	Op      string           `json:"op"`
-	Context []byte           `json:"context"`
-	Status  StatusEnum       `json:"status"`
-	Reqat   pgtype.Timestamp `json:"reqat"`
+// Rows of the batch have been queued again, so its counters are recounted from its rows. A batch
+// which is paused or waiting keeps its status.
+func (q *Queries) ReopenBatch(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, reopenBatch, id)
+	return err
 }
 
-func (q *Queries) InsertIntoBatches(ctx context.Context, arg InsertIntoBatchesParams) (uuid.UUID, error) {
-	row := q.db.QueryRow(ctx, insertIntoBatches,
-		arg.ID,
-		arg.App,
-		arg.Op,
-		arg.Context,
-		arg.Status,
-		arg.Reqat,
+const requeueDeadLetterRows = `-- name: RequeueDeadLetterRows :many
+UPDATE batchrows
+SET status = 'queued', attempts = 0, nexttry = NULL, doneat = NULL, res = NULL, messages = NULL,
+    lasterr = NULL, errtrace = NULL, doneby = NULL, leaseexpiry = NULL
+WHERE rowid = ANY($1::bigint[]) AND status = 'deadletter'
+AND batch IN (SELECT id FROM batches WHERE status != 'aborted')
+RETURNING rowid, batch
+`
+
+type RequeueDeadLetterRowsRow struct {
+	Rowid int64     `json:"rowid"`
+	Batch uuid.UUID `json:"batch"`
+}
+
+func (q *Queries) RequeueDeadLetterRows(ctx context.Context, rowids []int64) ([]RequeueDeadLetterRowsRow, error) {
+	rows, err := q.db.Query(ctx, requeueDeadLetterRows, rowids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RequeueDeadLetterRowsRow
+	for rows.Next() {
+		var i RequeueDeadLetterRowsRow
+		if err := rows.Scan(&i.Rowid, &i.Batch); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const retryBatchRow = `-- name: RetryBatchRow :exec
+UPDATE batchrows
+SET status = 'queued', nexttry = $2, lasterr = $3, doneby = NULL, leaseexpiry = NULL
+WHERE rowid = $1 AND status = 'inprog' AND doneby = $4
+`
+
+type RetryBatchRowParams struct {
+	Rowid   int64            `json:"rowid"`
+	Nexttry pgtype.Timestamp `json:"nexttry"`
+	Lasterr pgtype.Text      `json:"lasterr"`
+	Doneby  pgtype.Text      `json:"doneby"`
+}
+
+func (q *Queries) RetryBatchRow(ctx context.Context, arg RetryBatchRowParams) error {
+	_, err := q.db.Exec(ctx, retryBatchRow,
+		arg.Rowid,
+		arg.Nexttry,
+		arg.Lasterr,
+		arg.Doneby,
 	)
-	var id uuid.UUID
-	err := row.Scan(&id)
-	return id, err
+	return err
+}
+
+const retryBatchRows = `-- name: RetryBatchRows :many
+WITH retried AS (
+    SELECT rowid
+    FROM batchrows
+    WHERE batchrows.batch = $1 AND batchrows.status::text = ANY($2::text[])
+    AND (cardinality($3::int[]) = 0 OR batchrows.line = ANY($3::int[]))
+    FOR UPDATE
+), saved AS (
+    INSERT INTO batchrowresults (rowid, runno, batch, line, status, doneat, res, blobrows, messages, doneby)
+    SELECT r.rowid, r.runno, r.batch, r.line, r.status, r.doneat, r.res, r.blobrows, r.messages, r.doneby
+    FROM batchrows r
+    WHERE r.rowid IN (SELECT rowid FROM retried)
+)
+UPDATE batchrows
+SET status = 'queued', runno = batchrows.runno + 1, attempts = 0, nexttry = NULL, doneat = NULL, res = NULL,
+    blobrows = NULL, messages = NULL, lasterr = NULL, errtrace = NULL, doneby = NULL, leaseexpiry = NULL
+WHERE batchrows.rowid IN (SELECT rowid FROM retried)
+RETURNING rowid, line, runno
+`
+
+type RetryBatchRowsParams struct {
+	Batch    uuid.UUID `json:"batch"`
+	Statuses []string  `json:"statuses"`
+	Lines    []int32   `json:"lines"`
+}
+
+type RetryBatchRowsRow struct {
+	Rowid int64 `json:"rowid"`
+	Line  int32 `json:"line"`
+	Runno int32 `json:"runno"`
+}
+
+// The current result of each row is saved in batchrowresults before the row is queued for its next run
+func (q *Queries) RetryBatchRows(ctx context.Context, arg RetryBatchRowsParams) ([]RetryBatchRowsRow, error) {
+	rows, err := q.db.Query(ctx, retryBatchRows, arg.Batch, arg.Statuses, arg.Lines)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RetryBatchRowsRow
+	for rows.Next() {
+		var i RetryBatchRowsRow
+		if err := rows.Scan(&i.Rowid, &i.Line, &i.Runno); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setBatchStatus = `-- name: SetBatchStatus :exec
+UPDATE batches
+SET status = $2
+WHERE id = $1
+`
+
+type SetBatchStatusParams struct {
+	ID     uuid.UUID  `json:"id"`
+	Status StatusEnum `json:"status"`
+}
+
+// Only the status is changed, since the counters of a batch are kept up to date as its rows finish
+func (q *Queries) SetBatchStatus(ctx context.Context, arg SetBatchStatusParams) error {
+	_, err := q.db.Exec(ctx, setBatchStatus, arg.ID, arg.Status)
+	return err
+}
+
+const updateBatchAggregate = `-- name: UpdateBatchAggregate :exec
+UPDATE batches
+SET aggregate = $2
+WHERE id = $1
+`
+
+type UpdateBatchAggregateParams struct {
+	ID        uuid.UUID `json:"id"`
+	Aggregate []byte    `json:"aggregate"`
+}
+
+func (q *Queries) UpdateBatchAggregate(ctx context.Context, arg UpdateBatchAggregateParams) error {
+	_, err := q.db.Exec(ctx, updateBatchAggregate, arg.ID, arg.Aggregate)
+	return err
+}
+
+const updateBatchAggregateErr = `-- name: UpdateBatchAggregateErr :exec
+UPDATE batches
+SET aggregateerr = $2
+WHERE id = $1
+`
+
+type UpdateBatchAggregateErrParams struct {
+	ID           uuid.UUID   `json:"id"`
+	Aggregateerr pgtype.Text `json:"aggregateerr"`
+}
+
+// Records the error returned by the reducer of a batch, or clears it once the aggregate is computed
+// again from all the rows
+func (q *Queries) UpdateBatchAggregateErr(ctx context.Context, arg UpdateBatchAggregateErrParams) error {
+	_, err := q.db.Exec(ctx, updateBatchAggregateErr, arg.ID, arg.Aggregateerr)
+	return err
 }
 
 const updateBatchCounters = `-- name: UpdateBatchCounters :exec
@@ -576,6 +1925,23 @@ func (q *Queries) UpdateBatchOutputFiles(ctx context.Context, arg UpdateBatchOut
 	return err
 }
 
+const updateBatchProgress = `-- name: UpdateBatchProgress :exec
+UPDATE batches
+SET progress = $2
+WHERE id = $1 AND doneat IS NULL
+`
+
+type UpdateBatchProgressParams struct {
+	ID       uuid.UUID `json:"id"`
+	Progress []byte    `json:"progress"`
+}
+
+// The progress of a slow query is only recorded until it is done
+func (q *Queries) UpdateBatchProgress(ctx context.Context, arg UpdateBatchProgressParams) error {
+	_, err := q.db.Exec(ctx, updateBatchProgress, arg.ID, arg.Progress)
+	return err
+}
+
 const updateBatchResult = `-- name: UpdateBatchResult :exec
 UPDATE batches
 SET outputfiles = $1,
@@ -617,39 +1983,10 @@ func (q *Queries) UpdateBatchRowStatus(ctx context.Context, arg UpdateBatchRowSt
 	return err
 }
 
-const updateBatchRowsBatchJob = `-- name: UpdateBatchRowsBatchJob :exec
-UPDATE batchrows
-SET status = $2, doneat = $3, res = $4, blobrows = $5, messages = $6, doneby = $7
-WHERE rowid = $1
-`
-
-type UpdateBatchRowsBatchJobParams struct {
-	Rowid    int64            `json:"rowid"`
-	Status   StatusEnum       `json:"status"`
-	Doneat   pgtype.Timestamp `json:"doneat"`
-	Res      []byte           `json:"res"`
-	Blobrows []byte           `json:"blobrows"`
-	Messages []byte           `json:"messages"`
-	Doneby   pgtype.Text      `json:"doneby"`
-}
-
-func (q *Queries) UpdateBatchRowsBatchJob(ctx context.Context, arg UpdateBatchRowsBatchJobParams) error {
-	_, err := q.db.Exec(ctx, updateBatchRowsBatchJob,
-		arg.Rowid,
-		arg.Status,
-		arg.Doneat,
-		arg.Res,
-		arg.Blobrows,
-		arg.Messages,
-		arg.Doneby,
-	)
-	return err
-}
-
-const updateBatchRowsSlowQuery = `-- name: UpdateBatchRowsSlowQuery :exec
+const updateBatchRowsSlowQuery = `-- name: UpdateBatchRowsSlowQuery :execrows
 UPDATE batchrows
-SET status = $2, doneat = $3, res = $4, messages = $5, doneby = $6
-WHERE rowid = $1
+SET status = $2, doneat = $3, res = $4, messages = $5, leaseexpiry = NULL
+WHERE rowid = $1 AND status = 'inprog' AND doneby = $6
 `
 
 type UpdateBatchRowsSlowQueryParams struct {
@@ -661,8 +1998,8 @@ type UpdateBatchRowsSlowQueryParams struct {
 	Doneby   pgtype.Text      `json:"doneby"`
 }
 
-func (q *Queries) UpdateBatchRowsSlowQuery(ctx context.Context, arg UpdateBatchRowsSlowQueryParams) error {
-	_, err := q.db.Exec(ctx, updateBatchRowsSlowQuery,
+func (q *Queries) UpdateBatchRowsSlowQuery(ctx context.Context, arg UpdateBatchRowsSlowQueryParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateBatchRowsSlowQuery,
 		arg.Rowid,
 		arg.Status,
 		arg.Doneat,
@@ -670,7 +2007,10 @@ func (q *Queries) UpdateBatchRowsSlowQuery(ctx context.Context, arg UpdateBatchR
 		arg.Messages,
 		arg.Doneby,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const updateBatchRowsStatus = `-- name: UpdateBatchRowsStatus :exec
@@ -769,3 +2109,71 @@ func (q *Queries) UpdateBatchSummaryOnAbort(ctx context.Context, arg UpdateBatch
 	)
 	return err
 }
+
+const updatePipelineStatus = `-- name: UpdatePipelineStatus :exec
+UPDATE pipelines
+SET status = $2, doneat = $3
+WHERE id = $1
+`
+
+type UpdatePipelineStatusParams struct {
+	ID     uuid.UUID        `json:"id"`
+	Status StatusEnum       `json:"status"`
+	Doneat pgtype.Timestamp `json:"doneat"`
+}
+
+func (q *Queries) UpdatePipelineStatus(ctx context.Context, arg UpdatePipelineStatusParams) error {
+	_, err := q.db.Exec(ctx, updatePipelineStatus, arg.ID, arg.Status, arg.Doneat)
+	return err
+}
+
+const updateRecurringJobRun = `-- name: UpdateRecurringJobRun :exec
+UPDATE recurringjobs
+SET lastrun = $2, lastbatch = $3, nextrun = $4
+WHERE name = $1
+`
+
+type UpdateRecurringJobRunParams struct {
+	Name      string           `json:"name"`
+	Lastrun   pgtype.Timestamp `json:"lastrun"`
+	Lastbatch pgtype.UUID      `json:"lastbatch"`
+	Nextrun   pgtype.Timestamp `json:"nextrun"`
+}
+
+func (q *Queries) UpdateRecurringJobRun(ctx context.Context, arg UpdateRecurringJobRunParams) error {
+	_, err := q.db.Exec(ctx, updateRecurringJobRun,
+		arg.Name,
+		arg.Lastrun,
+		arg.Lastbatch,
+		arg.Nextrun,
+	)
+	return err
+}
+
+const upsertRecurringJob = `-- name: UpsertRecurringJob :exec
+INSERT INTO recurringjobs (name, app, op, cronspec, nextrun)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (name) DO UPDATE
+SET app = EXCLUDED.app, op = EXCLUDED.op, cronspec = EXCLUDED.cronspec,
+    nextrun = CASE WHEN recurringjobs.cronspec = EXCLUDED.cronspec THEN recurringjobs.nextrun ELSE EXCLUDED.nextrun END
+`
+
+type UpsertRecurringJobParams struct {
+	Name     string           `json:"name"`
+	App      string           `json:"app"`
+	Op       string           `json:"op"`
+	Cronspec string           `json:"cronspec"`
+	Nextrun  pgtype.Timestamp `json:"nextrun"`
+}
+
+// The next tick of an existing recurring batch is kept unless its cron expression has changed
+func (q *Queries) UpsertRecurringJob(ctx context.Context, arg UpsertRecurringJobParams) error {
+	_, err := q.db.Exec(ctx, upsertRecurringJob,
+		arg.Name,
+		arg.App,
+		arg.Op,
+		arg.Cronspec,
+		arg.Nextrun,
+	)
+	return err
+}
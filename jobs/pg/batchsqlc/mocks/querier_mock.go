@@ -6,6 +6,7 @@ package mocks
 import (
 	"context"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
 	"sync"
 )
@@ -23,18 +24,57 @@ var _ batchsqlc.Querier = &QuerierMock{}
 //			BulkInsertIntoBatchRowsFunc: func(ctx context.Context, arg batchsqlc.BulkInsertIntoBatchRowsParams) (int64, error) {
 //				panic("mock out the BulkInsertIntoBatchRows method")
 //			},
+//			BulkUpdateBatchRowsBatchJobFunc: func(ctx context.Context, arg batchsqlc.BulkUpdateBatchRowsBatchJobParams) ([]int64, error) {
+//				panic("mock out the BulkUpdateBatchRowsBatchJob method")
+//			},
+//			CloseBatchInputFunc: func(ctx context.Context, arg batchsqlc.CloseBatchInputParams) error {
+//				panic("mock out the CloseBatchInput method")
+//			},
+//			CopyIntoBatchRowsFunc: func(ctx context.Context, arg []batchsqlc.CopyIntoBatchRowsParams) (int64, error) {
+//				panic("mock out the CopyIntoBatchRows method")
+//			},
 //			CountBatchRowsByBatchIDAndStatusFunc: func(ctx context.Context, arg batchsqlc.CountBatchRowsByBatchIDAndStatusParams) (int64, error) {
 //				panic("mock out the CountBatchRowsByBatchIDAndStatus method")
 //			},
+//			DeadLetterBatchRowFunc: func(ctx context.Context, arg batchsqlc.DeadLetterBatchRowParams) (int64, error) {
+//				panic("mock out the DeadLetterBatchRow method")
+//			},
+//			DeleteStaleWorkersFunc: func(ctx context.Context, heartbeat pgtype.Timestamp) (int64, error) {
+//				panic("mock out the DeleteStaleWorkers method")
+//			},
+//			DeleteWorkerFunc: func(ctx context.Context, id string) error {
+//				panic("mock out the DeleteWorker method")
+//			},
+//			ExtendWorkerLeasesFunc: func(ctx context.Context, arg batchsqlc.ExtendWorkerLeasesParams) (int64, error) {
+//				panic("mock out the ExtendWorkerLeases method")
+//			},
 //			FetchBatchRowsForBatchDoneFunc: func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.FetchBatchRowsForBatchDoneRow, error) {
 //				panic("mock out the FetchBatchRowsForBatchDone method")
 //			},
 //			FetchBlockOfRowsFunc: func(ctx context.Context, arg batchsqlc.FetchBlockOfRowsParams) ([]batchsqlc.FetchBlockOfRowsRow, error) {
 //				panic("mock out the FetchBlockOfRows method")
 //			},
+//			ForgetMemoizedQueriesFunc: func(ctx context.Context, arg batchsqlc.ForgetMemoizedQueriesParams) (int64, error) {
+//				panic("mock out the ForgetMemoizedQueries method")
+//			},
+//			GetAbortedBatchesFunc: func(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+//				panic("mock out the GetAbortedBatches method")
+//			},
 //			GetBatchByIDFunc: func(ctx context.Context, id uuid.UUID) (batchsqlc.Batch, error) {
 //				panic("mock out the GetBatchByID method")
 //			},
+//			GetBatchByIdempotencyKeyFunc: func(ctx context.Context, arg batchsqlc.GetBatchByIdempotencyKeyParams) (batchsqlc.GetBatchByIdempotencyKeyRow, error) {
+//				panic("mock out the GetBatchByIdempotencyKey method")
+//			},
+//			GetBatchCountsFunc: func(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchCountsRow, error) {
+//				panic("mock out the GetBatchCounts method")
+//			},
+//			GetBatchProgressFunc: func(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchProgressRow, error) {
+//				panic("mock out the GetBatchProgress method")
+//			},
+//			GetBatchRowResultsFunc: func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.Batchrowresult, error) {
+//				panic("mock out the GetBatchRowResults method")
+//			},
 //			GetBatchRowsByBatchIDFunc: func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.Batchrow, error) {
 //				panic("mock out the GetBatchRowsByBatchID method")
 //			},
@@ -53,9 +93,30 @@ var _ batchsqlc.Querier = &QuerierMock{}
 //			GetCompletedBatchesFunc: func(ctx context.Context) ([]uuid.UUID, error) {
 //				panic("mock out the GetCompletedBatches method")
 //			},
+//			GetDeadLetterBatchesFunc: func(ctx context.Context, rowids []int64) ([]uuid.UUID, error) {
+//				panic("mock out the GetDeadLetterBatches method")
+//			},
+//			GetDeadLetterRowFunc: func(ctx context.Context, rowid int64) (batchsqlc.GetDeadLetterRowRow, error) {
+//				panic("mock out the GetDeadLetterRow method")
+//			},
+//			GetDueRecurringJobFunc: func(ctx context.Context, arg batchsqlc.GetDueRecurringJobParams) (batchsqlc.GetDueRecurringJobRow, error) {
+//				panic("mock out the GetDueRecurringJob method")
+//			},
+//			GetMemoizedQueryFunc: func(ctx context.Context, arg batchsqlc.GetMemoizedQueryParams) (uuid.UUID, error) {
+//				panic("mock out the GetMemoizedQuery method")
+//			},
 //			GetPendingBatchRowsFunc: func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetPendingBatchRowsRow, error) {
 //				panic("mock out the GetPendingBatchRows method")
 //			},
+//			GetPipelineByIDFunc: func(ctx context.Context, id uuid.UUID) (batchsqlc.Pipeline, error) {
+//				panic("mock out the GetPipelineByID method")
+//			},
+//			GetPipelineStepsFunc: func(ctx context.Context, pipeline uuid.UUID) ([]batchsqlc.GetPipelineStepsRow, error) {
+//				panic("mock out the GetPipelineSteps method")
+//			},
+//			GetPipelineToAdvanceFunc: func(ctx context.Context, arg batchsqlc.GetPipelineToAdvanceParams) (batchsqlc.GetPipelineToAdvanceRow, error) {
+//				panic("mock out the GetPipelineToAdvance method")
+//			},
 //			GetProcessedBatchRowsByBatchIDSortedFunc: func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow, error) {
 //				panic("mock out the GetProcessedBatchRowsByBatchIDSorted method")
 //			},
@@ -68,22 +129,79 @@ var _ batchsqlc.Querier = &QuerierMock{}
 //			InsertIntoBatchesFunc: func(ctx context.Context, arg batchsqlc.InsertIntoBatchesParams) (uuid.UUID, error) {
 //				panic("mock out the InsertIntoBatches method")
 //			},
+//			InsertPipelineFunc: func(ctx context.Context, arg batchsqlc.InsertPipelineParams) error {
+//				panic("mock out the InsertPipeline method")
+//			},
+//			InsertPipelineStepFunc: func(ctx context.Context, arg batchsqlc.InsertPipelineStepParams) error {
+//				panic("mock out the InsertPipelineStep method")
+//			},
+//			LeaseBatchRowsFunc: func(ctx context.Context, arg batchsqlc.LeaseBatchRowsParams) error {
+//				panic("mock out the LeaseBatchRows method")
+//			},
+//			ListBatchRowResultsFunc: func(ctx context.Context, arg batchsqlc.ListBatchRowResultsParams) ([]batchsqlc.ListBatchRowResultsRow, error) {
+//				panic("mock out the ListBatchRowResults method")
+//			},
+//			ListBatchesFunc: func(ctx context.Context, arg batchsqlc.ListBatchesParams) ([]batchsqlc.ListBatchesRow, error) {
+//				panic("mock out the ListBatches method")
+//			},
+//			ListDeadLetterRowsFunc: func(ctx context.Context, arg batchsqlc.ListDeadLetterRowsParams) ([]batchsqlc.ListDeadLetterRowsRow, error) {
+//				panic("mock out the ListDeadLetterRows method")
+//			},
+//			ListSlowQueriesFunc: func(ctx context.Context, arg batchsqlc.ListSlowQueriesParams) ([]batchsqlc.ListSlowQueriesRow, error) {
+//				panic("mock out the ListSlowQueries method")
+//			},
+//			MarkBatchInprogFunc: func(ctx context.Context, arg batchsqlc.MarkBatchInprogParams) error {
+//				panic("mock out the MarkBatchInprog method")
+//			},
+//			NotifyBatchQueuedFunc: func(ctx context.Context, arg batchsqlc.NotifyBatchQueuedParams) error {
+//				panic("mock out the NotifyBatchQueued method")
+//			},
+//			ReclaimExpiredLeasesFunc: func(ctx context.Context, arg batchsqlc.ReclaimExpiredLeasesParams) ([]batchsqlc.ReclaimExpiredLeasesRow, error) {
+//				panic("mock out the ReclaimExpiredLeases method")
+//			},
+//			RecordWorkerHeartbeatFunc: func(ctx context.Context, arg batchsqlc.RecordWorkerHeartbeatParams) error {
+//				panic("mock out the RecordWorkerHeartbeat method")
+//			},
+//			ReleaseBatchRowsFunc: func(ctx context.Context, arg batchsqlc.ReleaseBatchRowsParams) error {
+//				panic("mock out the ReleaseBatchRows method")
+//			},
+//			ReopenBatchFunc: func(ctx context.Context, id uuid.UUID) error {
+//				panic("mock out the ReopenBatch method")
+//			},
+//			RequeueDeadLetterRowsFunc: func(ctx context.Context, rowids []int64) ([]batchsqlc.RequeueDeadLetterRowsRow, error) {
+//				panic("mock out the RequeueDeadLetterRows method")
+//			},
+//			RetryBatchRowFunc: func(ctx context.Context, arg batchsqlc.RetryBatchRowParams) error {
+//				panic("mock out the RetryBatchRow method")
+//			},
+//			RetryBatchRowsFunc: func(ctx context.Context, arg batchsqlc.RetryBatchRowsParams) ([]batchsqlc.RetryBatchRowsRow, error) {
+//				panic("mock out the RetryBatchRows method")
+//			},
+//			SetBatchStatusFunc: func(ctx context.Context, arg batchsqlc.SetBatchStatusParams) error {
+//				panic("mock out the SetBatchStatus method")
+//			},
+//			UpdateBatchAggregateFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchAggregateParams) error {
+//				panic("mock out the UpdateBatchAggregate method")
+//			},
+//			UpdateBatchAggregateErrFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchAggregateErrParams) error {
+//				panic("mock out the UpdateBatchAggregateErr method")
+//			},
 //			UpdateBatchCountersFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchCountersParams) error {
 //				panic("mock out the UpdateBatchCounters method")
 //			},
 //			UpdateBatchOutputFilesFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchOutputFilesParams) error {
 //				panic("mock out the UpdateBatchOutputFiles method")
 //			},
+//			UpdateBatchProgressFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchProgressParams) error {
+//				panic("mock out the UpdateBatchProgress method")
+//			},
 //			UpdateBatchResultFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchResultParams) error {
 //				panic("mock out the UpdateBatchResult method")
 //			},
 //			UpdateBatchRowStatusFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchRowStatusParams) error {
 //				panic("mock out the UpdateBatchRowStatus method")
 //			},
-//			UpdateBatchRowsBatchJobFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchRowsBatchJobParams) error {
-//				panic("mock out the UpdateBatchRowsBatchJob method")
-//			},
-//			UpdateBatchRowsSlowQueryFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchRowsSlowQueryParams) error {
+//			UpdateBatchRowsSlowQueryFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchRowsSlowQueryParams) (int64, error) {
 //				panic("mock out the UpdateBatchRowsSlowQuery method")
 //			},
 //			UpdateBatchRowsStatusFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchRowsStatusParams) error {
@@ -98,6 +216,15 @@ var _ batchsqlc.Querier = &QuerierMock{}
 //			UpdateBatchSummaryOnAbortFunc: func(ctx context.Context, arg batchsqlc.UpdateBatchSummaryOnAbortParams) error {
 //				panic("mock out the UpdateBatchSummaryOnAbort method")
 //			},
+//			UpdatePipelineStatusFunc: func(ctx context.Context, arg batchsqlc.UpdatePipelineStatusParams) error {
+//				panic("mock out the UpdatePipelineStatus method")
+//			},
+//			UpdateRecurringJobRunFunc: func(ctx context.Context, arg batchsqlc.UpdateRecurringJobRunParams) error {
+//				panic("mock out the UpdateRecurringJobRun method")
+//			},
+//			UpsertRecurringJobFunc: func(ctx context.Context, arg batchsqlc.UpsertRecurringJobParams) error {
+//				panic("mock out the UpsertRecurringJob method")
+//			},
 //		}
 //
 //		// use mockedQuerier in code that requires batchsqlc.Querier
@@ -108,18 +235,57 @@ type QuerierMock struct {
 	// BulkInsertIntoBatchRowsFunc mocks the BulkInsertIntoBatchRows method.
 	BulkInsertIntoBatchRowsFunc func(ctx context.Context, arg batchsqlc.BulkInsertIntoBatchRowsParams) (int64, error)
 
+	// BulkUpdateBatchRowsBatchJobFunc mocks the BulkUpdateBatchRowsBatchJob method.
+	BulkUpdateBatchRowsBatchJobFunc func(ctx context.Context, arg batchsqlc.BulkUpdateBatchRowsBatchJobParams) ([]int64, error)
+
+	// CloseBatchInputFunc mocks the CloseBatchInput method.
+	CloseBatchInputFunc func(ctx context.Context, arg batchsqlc.CloseBatchInputParams) error
+
+	// CopyIntoBatchRowsFunc mocks the CopyIntoBatchRows method.
+	CopyIntoBatchRowsFunc func(ctx context.Context, arg []batchsqlc.CopyIntoBatchRowsParams) (int64, error)
+
 	// CountBatchRowsByBatchIDAndStatusFunc mocks the CountBatchRowsByBatchIDAndStatus method.
 	CountBatchRowsByBatchIDAndStatusFunc func(ctx context.Context, arg batchsqlc.CountBatchRowsByBatchIDAndStatusParams) (int64, error)
 
+	// DeadLetterBatchRowFunc mocks the DeadLetterBatchRow method.
+	DeadLetterBatchRowFunc func(ctx context.Context, arg batchsqlc.DeadLetterBatchRowParams) (int64, error)
+
+	// DeleteStaleWorkersFunc mocks the DeleteStaleWorkers method.
+	DeleteStaleWorkersFunc func(ctx context.Context, heartbeat pgtype.Timestamp) (int64, error)
+
+	// DeleteWorkerFunc mocks the DeleteWorker method.
+	DeleteWorkerFunc func(ctx context.Context, id string) error
+
+	// ExtendWorkerLeasesFunc mocks the ExtendWorkerLeases method.
+	ExtendWorkerLeasesFunc func(ctx context.Context, arg batchsqlc.ExtendWorkerLeasesParams) (int64, error)
+
 	// FetchBatchRowsForBatchDoneFunc mocks the FetchBatchRowsForBatchDone method.
 	FetchBatchRowsForBatchDoneFunc func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.FetchBatchRowsForBatchDoneRow, error)
 
 	// FetchBlockOfRowsFunc mocks the FetchBlockOfRows method.
 	FetchBlockOfRowsFunc func(ctx context.Context, arg batchsqlc.FetchBlockOfRowsParams) ([]batchsqlc.FetchBlockOfRowsRow, error)
 
+	// ForgetMemoizedQueriesFunc mocks the ForgetMemoizedQueries method.
+	ForgetMemoizedQueriesFunc func(ctx context.Context, arg batchsqlc.ForgetMemoizedQueriesParams) (int64, error)
+
+	// GetAbortedBatchesFunc mocks the GetAbortedBatches method.
+	GetAbortedBatchesFunc func(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
+
 	// GetBatchByIDFunc mocks the GetBatchByID method.
 	GetBatchByIDFunc func(ctx context.Context, id uuid.UUID) (batchsqlc.Batch, error)
 
+	// GetBatchByIdempotencyKeyFunc mocks the GetBatchByIdempotencyKey method.
+	GetBatchByIdempotencyKeyFunc func(ctx context.Context, arg batchsqlc.GetBatchByIdempotencyKeyParams) (batchsqlc.GetBatchByIdempotencyKeyRow, error)
+
+	// GetBatchCountsFunc mocks the GetBatchCounts method.
+	GetBatchCountsFunc func(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchCountsRow, error)
+
+	// GetBatchProgressFunc mocks the GetBatchProgress method.
+	GetBatchProgressFunc func(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchProgressRow, error)
+
+	// GetBatchRowResultsFunc mocks the GetBatchRowResults method.
+	GetBatchRowResultsFunc func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.Batchrowresult, error)
+
 	// GetBatchRowsByBatchIDFunc mocks the GetBatchRowsByBatchID method.
 	GetBatchRowsByBatchIDFunc func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.Batchrow, error)
 
@@ -138,9 +304,30 @@ type QuerierMock struct {
 	// GetCompletedBatchesFunc mocks the GetCompletedBatches method.
 	GetCompletedBatchesFunc func(ctx context.Context) ([]uuid.UUID, error)
 
+	// GetDeadLetterBatchesFunc mocks the GetDeadLetterBatches method.
+	GetDeadLetterBatchesFunc func(ctx context.Context, rowids []int64) ([]uuid.UUID, error)
+
+	// GetDeadLetterRowFunc mocks the GetDeadLetterRow method.
+	GetDeadLetterRowFunc func(ctx context.Context, rowid int64) (batchsqlc.GetDeadLetterRowRow, error)
+
+	// GetDueRecurringJobFunc mocks the GetDueRecurringJob method.
+	GetDueRecurringJobFunc func(ctx context.Context, arg batchsqlc.GetDueRecurringJobParams) (batchsqlc.GetDueRecurringJobRow, error)
+
+	// GetMemoizedQueryFunc mocks the GetMemoizedQuery method.
+	GetMemoizedQueryFunc func(ctx context.Context, arg batchsqlc.GetMemoizedQueryParams) (uuid.UUID, error)
+
 	// GetPendingBatchRowsFunc mocks the GetPendingBatchRows method.
 	GetPendingBatchRowsFunc func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetPendingBatchRowsRow, error)
 
+	// GetPipelineByIDFunc mocks the GetPipelineByID method.
+	GetPipelineByIDFunc func(ctx context.Context, id uuid.UUID) (batchsqlc.Pipeline, error)
+
+	// GetPipelineStepsFunc mocks the GetPipelineSteps method.
+	GetPipelineStepsFunc func(ctx context.Context, pipeline uuid.UUID) ([]batchsqlc.GetPipelineStepsRow, error)
+
+	// GetPipelineToAdvanceFunc mocks the GetPipelineToAdvance method.
+	GetPipelineToAdvanceFunc func(ctx context.Context, arg batchsqlc.GetPipelineToAdvanceParams) (batchsqlc.GetPipelineToAdvanceRow, error)
+
 	// GetProcessedBatchRowsByBatchIDSortedFunc mocks the GetProcessedBatchRowsByBatchIDSorted method.
 	GetProcessedBatchRowsByBatchIDSortedFunc func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow, error)
 
@@ -153,23 +340,80 @@ type QuerierMock struct {
 	// InsertIntoBatchesFunc mocks the InsertIntoBatches method.
 	InsertIntoBatchesFunc func(ctx context.Context, arg batchsqlc.InsertIntoBatchesParams) (uuid.UUID, error)
 
+	// InsertPipelineFunc mocks the InsertPipeline method.
+	InsertPipelineFunc func(ctx context.Context, arg batchsqlc.InsertPipelineParams) error
+
+	// InsertPipelineStepFunc mocks the InsertPipelineStep method.
+	InsertPipelineStepFunc func(ctx context.Context, arg batchsqlc.InsertPipelineStepParams) error
+
+	// LeaseBatchRowsFunc mocks the LeaseBatchRows method.
+	LeaseBatchRowsFunc func(ctx context.Context, arg batchsqlc.LeaseBatchRowsParams) error
+
+	// ListBatchRowResultsFunc mocks the ListBatchRowResults method.
+	ListBatchRowResultsFunc func(ctx context.Context, arg batchsqlc.ListBatchRowResultsParams) ([]batchsqlc.ListBatchRowResultsRow, error)
+
+	// ListBatchesFunc mocks the ListBatches method.
+	ListBatchesFunc func(ctx context.Context, arg batchsqlc.ListBatchesParams) ([]batchsqlc.ListBatchesRow, error)
+
+	// ListDeadLetterRowsFunc mocks the ListDeadLetterRows method.
+	ListDeadLetterRowsFunc func(ctx context.Context, arg batchsqlc.ListDeadLetterRowsParams) ([]batchsqlc.ListDeadLetterRowsRow, error)
+
+	// ListSlowQueriesFunc mocks the ListSlowQueries method.
+	ListSlowQueriesFunc func(ctx context.Context, arg batchsqlc.ListSlowQueriesParams) ([]batchsqlc.ListSlowQueriesRow, error)
+
+	// MarkBatchInprogFunc mocks the MarkBatchInprog method.
+	MarkBatchInprogFunc func(ctx context.Context, arg batchsqlc.MarkBatchInprogParams) error
+
+	// NotifyBatchQueuedFunc mocks the NotifyBatchQueued method.
+	NotifyBatchQueuedFunc func(ctx context.Context, arg batchsqlc.NotifyBatchQueuedParams) error
+
+	// ReclaimExpiredLeasesFunc mocks the ReclaimExpiredLeases method.
+	ReclaimExpiredLeasesFunc func(ctx context.Context, arg batchsqlc.ReclaimExpiredLeasesParams) ([]batchsqlc.ReclaimExpiredLeasesRow, error)
+
+	// RecordWorkerHeartbeatFunc mocks the RecordWorkerHeartbeat method.
+	RecordWorkerHeartbeatFunc func(ctx context.Context, arg batchsqlc.RecordWorkerHeartbeatParams) error
+
+	// ReleaseBatchRowsFunc mocks the ReleaseBatchRows method.
+	ReleaseBatchRowsFunc func(ctx context.Context, arg batchsqlc.ReleaseBatchRowsParams) error
+
+	// ReopenBatchFunc mocks the ReopenBatch method.
+	ReopenBatchFunc func(ctx context.Context, id uuid.UUID) error
+
+	// RequeueDeadLetterRowsFunc mocks the RequeueDeadLetterRows method.
+	RequeueDeadLetterRowsFunc func(ctx context.Context, rowids []int64) ([]batchsqlc.RequeueDeadLetterRowsRow, error)
+
+	// RetryBatchRowFunc mocks the RetryBatchRow method.
+	RetryBatchRowFunc func(ctx context.Context, arg batchsqlc.RetryBatchRowParams) error
+
+	// RetryBatchRowsFunc mocks the RetryBatchRows method.
+	RetryBatchRowsFunc func(ctx context.Context, arg batchsqlc.RetryBatchRowsParams) ([]batchsqlc.RetryBatchRowsRow, error)
+
+	// SetBatchStatusFunc mocks the SetBatchStatus method.
+	SetBatchStatusFunc func(ctx context.Context, arg batchsqlc.SetBatchStatusParams) error
+
+	// UpdateBatchAggregateFunc mocks the UpdateBatchAggregate method.
+	UpdateBatchAggregateFunc func(ctx context.Context, arg batchsqlc.UpdateBatchAggregateParams) error
+
+	// UpdateBatchAggregateErrFunc mocks the UpdateBatchAggregateErr method.
+	UpdateBatchAggregateErrFunc func(ctx context.Context, arg batchsqlc.UpdateBatchAggregateErrParams) error
+
 	// UpdateBatchCountersFunc mocks the UpdateBatchCounters method.
 	UpdateBatchCountersFunc func(ctx context.Context, arg batchsqlc.UpdateBatchCountersParams) error
 
 	// UpdateBatchOutputFilesFunc mocks the UpdateBatchOutputFiles method.
 	UpdateBatchOutputFilesFunc func(ctx context.Context, arg batchsqlc.UpdateBatchOutputFilesParams) error
 
+	// UpdateBatchProgressFunc mocks the UpdateBatchProgress method.
+	UpdateBatchProgressFunc func(ctx context.Context, arg batchsqlc.UpdateBatchProgressParams) error
+
 	// UpdateBatchResultFunc mocks the UpdateBatchResult method.
 	UpdateBatchResultFunc func(ctx context.Context, arg batchsqlc.UpdateBatchResultParams) error
 
 	// UpdateBatchRowStatusFunc mocks the UpdateBatchRowStatus method.
 	UpdateBatchRowStatusFunc func(ctx context.Context, arg batchsqlc.UpdateBatchRowStatusParams) error
 
-	// UpdateBatchRowsBatchJobFunc mocks the UpdateBatchRowsBatchJob method.
-	UpdateBatchRowsBatchJobFunc func(ctx context.Context, arg batchsqlc.UpdateBatchRowsBatchJobParams) error
-
 	// UpdateBatchRowsSlowQueryFunc mocks the UpdateBatchRowsSlowQuery method.
-	UpdateBatchRowsSlowQueryFunc func(ctx context.Context, arg batchsqlc.UpdateBatchRowsSlowQueryParams) error
+	UpdateBatchRowsSlowQueryFunc func(ctx context.Context, arg batchsqlc.UpdateBatchRowsSlowQueryParams) (int64, error)
 
 	// UpdateBatchRowsStatusFunc mocks the UpdateBatchRowsStatus method.
 	UpdateBatchRowsStatusFunc func(ctx context.Context, arg batchsqlc.UpdateBatchRowsStatusParams) error
@@ -183,6 +427,15 @@ type QuerierMock struct {
 	// UpdateBatchSummaryOnAbortFunc mocks the UpdateBatchSummaryOnAbort method.
 	UpdateBatchSummaryOnAbortFunc func(ctx context.Context, arg batchsqlc.UpdateBatchSummaryOnAbortParams) error
 
+	// UpdatePipelineStatusFunc mocks the UpdatePipelineStatus method.
+	UpdatePipelineStatusFunc func(ctx context.Context, arg batchsqlc.UpdatePipelineStatusParams) error
+
+	// UpdateRecurringJobRunFunc mocks the UpdateRecurringJobRun method.
+	UpdateRecurringJobRunFunc func(ctx context.Context, arg batchsqlc.UpdateRecurringJobRunParams) error
+
+	// UpsertRecurringJobFunc mocks the UpsertRecurringJob method.
+	UpsertRecurringJobFunc func(ctx context.Context, arg batchsqlc.UpsertRecurringJobParams) error
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// BulkInsertIntoBatchRows holds details about calls to the BulkInsertIntoBatchRows method.
@@ -192,6 +445,27 @@ type QuerierMock struct {
 			// Arg is the arg argument value.
 			Arg batchsqlc.BulkInsertIntoBatchRowsParams
 		}
+		// BulkUpdateBatchRowsBatchJob holds details about calls to the BulkUpdateBatchRowsBatchJob method.
+		BulkUpdateBatchRowsBatchJob []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.BulkUpdateBatchRowsBatchJobParams
+		}
+		// CloseBatchInput holds details about calls to the CloseBatchInput method.
+		CloseBatchInput []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.CloseBatchInputParams
+		}
+		// CopyIntoBatchRows holds details about calls to the CopyIntoBatchRows method.
+		CopyIntoBatchRows []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg []batchsqlc.CopyIntoBatchRowsParams
+		}
 		// CountBatchRowsByBatchIDAndStatus holds details about calls to the CountBatchRowsByBatchIDAndStatus method.
 		CountBatchRowsByBatchIDAndStatus []struct {
 			// Ctx is the ctx argument value.
@@ -199,6 +473,34 @@ type QuerierMock struct {
 			// Arg is the arg argument value.
 			Arg batchsqlc.CountBatchRowsByBatchIDAndStatusParams
 		}
+		// DeadLetterBatchRow holds details about calls to the DeadLetterBatchRow method.
+		DeadLetterBatchRow []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.DeadLetterBatchRowParams
+		}
+		// DeleteStaleWorkers holds details about calls to the DeleteStaleWorkers method.
+		DeleteStaleWorkers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Heartbeat is the heartbeat argument value.
+			Heartbeat pgtype.Timestamp
+		}
+		// DeleteWorker holds details about calls to the DeleteWorker method.
+		DeleteWorker []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// ExtendWorkerLeases holds details about calls to the ExtendWorkerLeases method.
+		ExtendWorkerLeases []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.ExtendWorkerLeasesParams
+		}
 		// FetchBatchRowsForBatchDone holds details about calls to the FetchBatchRowsForBatchDone method.
 		FetchBatchRowsForBatchDone []struct {
 			// Ctx is the ctx argument value.
@@ -213,6 +515,20 @@ type QuerierMock struct {
 			// Arg is the arg argument value.
 			Arg batchsqlc.FetchBlockOfRowsParams
 		}
+		// ForgetMemoizedQueries holds details about calls to the ForgetMemoizedQueries method.
+		ForgetMemoizedQueries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.ForgetMemoizedQueriesParams
+		}
+		// GetAbortedBatches holds details about calls to the GetAbortedBatches method.
+		GetAbortedBatches []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// IDs is the ids argument value.
+			IDs []uuid.UUID
+		}
 		// GetBatchByID holds details about calls to the GetBatchByID method.
 		GetBatchByID []struct {
 			// Ctx is the ctx argument value.
@@ -220,6 +536,34 @@ type QuerierMock struct {
 			// ID is the id argument value.
 			ID uuid.UUID
 		}
+		// GetBatchByIdempotencyKey holds details about calls to the GetBatchByIdempotencyKey method.
+		GetBatchByIdempotencyKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.GetBatchByIdempotencyKeyParams
+		}
+		// GetBatchCounts holds details about calls to the GetBatchCounts method.
+		GetBatchCounts []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID uuid.UUID
+		}
+		// GetBatchProgress holds details about calls to the GetBatchProgress method.
+		GetBatchProgress []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID uuid.UUID
+		}
+		// GetBatchRowResults holds details about calls to the GetBatchRowResults method.
+		GetBatchRowResults []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Batch is the batch argument value.
+			Batch uuid.UUID
+		}
 		// GetBatchRowsByBatchID holds details about calls to the GetBatchRowsByBatchID method.
 		GetBatchRowsByBatchID []struct {
 			// Ctx is the ctx argument value.
@@ -260,6 +604,34 @@ type QuerierMock struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// GetDeadLetterBatches holds details about calls to the GetDeadLetterBatches method.
+		GetDeadLetterBatches []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Rowids is the rowids argument value.
+			Rowids []int64
+		}
+		// GetDeadLetterRow holds details about calls to the GetDeadLetterRow method.
+		GetDeadLetterRow []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Rowid is the rowid argument value.
+			Rowid int64
+		}
+		// GetDueRecurringJob holds details about calls to the GetDueRecurringJob method.
+		GetDueRecurringJob []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.GetDueRecurringJobParams
+		}
+		// GetMemoizedQuery holds details about calls to the GetMemoizedQuery method.
+		GetMemoizedQuery []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.GetMemoizedQueryParams
+		}
 		// GetPendingBatchRows holds details about calls to the GetPendingBatchRows method.
 		GetPendingBatchRows []struct {
 			// Ctx is the ctx argument value.
@@ -267,6 +639,27 @@ type QuerierMock struct {
 			// Batch is the batch argument value.
 			Batch uuid.UUID
 		}
+		// GetPipelineByID holds details about calls to the GetPipelineByID method.
+		GetPipelineByID []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID uuid.UUID
+		}
+		// GetPipelineSteps holds details about calls to the GetPipelineSteps method.
+		GetPipelineSteps []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Pipeline is the pipeline argument value.
+			Pipeline uuid.UUID
+		}
+		// GetPipelineToAdvance holds details about calls to the GetPipelineToAdvance method.
+		GetPipelineToAdvance []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.GetPipelineToAdvanceParams
+		}
 		// GetProcessedBatchRowsByBatchIDSorted holds details about calls to the GetProcessedBatchRowsByBatchIDSorted method.
 		GetProcessedBatchRowsByBatchIDSorted []struct {
 			// Ctx is the ctx argument value.
@@ -295,6 +688,139 @@ type QuerierMock struct {
 			// Arg is the arg argument value.
 			Arg batchsqlc.InsertIntoBatchesParams
 		}
+		// InsertPipeline holds details about calls to the InsertPipeline method.
+		InsertPipeline []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.InsertPipelineParams
+		}
+		// InsertPipelineStep holds details about calls to the InsertPipelineStep method.
+		InsertPipelineStep []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.InsertPipelineStepParams
+		}
+		// LeaseBatchRows holds details about calls to the LeaseBatchRows method.
+		LeaseBatchRows []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.LeaseBatchRowsParams
+		}
+		// ListBatchRowResults holds details about calls to the ListBatchRowResults method.
+		ListBatchRowResults []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.ListBatchRowResultsParams
+		}
+		// ListBatches holds details about calls to the ListBatches method.
+		ListBatches []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.ListBatchesParams
+		}
+		// ListDeadLetterRows holds details about calls to the ListDeadLetterRows method.
+		ListDeadLetterRows []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.ListDeadLetterRowsParams
+		}
+		// ListSlowQueries holds details about calls to the ListSlowQueries method.
+		ListSlowQueries []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.ListSlowQueriesParams
+		}
+		// MarkBatchInprog holds details about calls to the MarkBatchInprog method.
+		MarkBatchInprog []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.MarkBatchInprogParams
+		}
+		// NotifyBatchQueued holds details about calls to the NotifyBatchQueued method.
+		NotifyBatchQueued []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.NotifyBatchQueuedParams
+		}
+		// ReclaimExpiredLeases holds details about calls to the ReclaimExpiredLeases method.
+		ReclaimExpiredLeases []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.ReclaimExpiredLeasesParams
+		}
+		// RecordWorkerHeartbeat holds details about calls to the RecordWorkerHeartbeat method.
+		RecordWorkerHeartbeat []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.RecordWorkerHeartbeatParams
+		}
+		// ReleaseBatchRows holds details about calls to the ReleaseBatchRows method.
+		ReleaseBatchRows []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.ReleaseBatchRowsParams
+		}
+		// ReopenBatch holds details about calls to the ReopenBatch method.
+		ReopenBatch []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID uuid.UUID
+		}
+		// RequeueDeadLetterRows holds details about calls to the RequeueDeadLetterRows method.
+		RequeueDeadLetterRows []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Rowids is the rowids argument value.
+			Rowids []int64
+		}
+		// RetryBatchRow holds details about calls to the RetryBatchRow method.
+		RetryBatchRow []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.RetryBatchRowParams
+		}
+		// RetryBatchRows holds details about calls to the RetryBatchRows method.
+		RetryBatchRows []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.RetryBatchRowsParams
+		}
+		// SetBatchStatus holds details about calls to the SetBatchStatus method.
+		SetBatchStatus []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.SetBatchStatusParams
+		}
+		// UpdateBatchAggregate holds details about calls to the UpdateBatchAggregate method.
+		UpdateBatchAggregate []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.UpdateBatchAggregateParams
+		}
+		// UpdateBatchAggregateErr holds details about calls to the UpdateBatchAggregateErr method.
+		UpdateBatchAggregateErr []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.UpdateBatchAggregateErrParams
+		}
 		// UpdateBatchCounters holds details about calls to the UpdateBatchCounters method.
 		UpdateBatchCounters []struct {
 			// Ctx is the ctx argument value.
@@ -309,6 +835,13 @@ type QuerierMock struct {
 			// Arg is the arg argument value.
 			Arg batchsqlc.UpdateBatchOutputFilesParams
 		}
+		// UpdateBatchProgress holds details about calls to the UpdateBatchProgress method.
+		UpdateBatchProgress []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.UpdateBatchProgressParams
+		}
 		// UpdateBatchResult holds details about calls to the UpdateBatchResult method.
 		UpdateBatchResult []struct {
 			// Ctx is the ctx argument value.
@@ -323,13 +856,6 @@ type QuerierMock struct {
 			// Arg is the arg argument value.
 			Arg batchsqlc.UpdateBatchRowStatusParams
 		}
-		// UpdateBatchRowsBatchJob holds details about calls to the UpdateBatchRowsBatchJob method.
-		UpdateBatchRowsBatchJob []struct {
-			// Ctx is the ctx argument value.
-			Ctx context.Context
-			// Arg is the arg argument value.
-			Arg batchsqlc.UpdateBatchRowsBatchJobParams
-		}
 		// UpdateBatchRowsSlowQuery holds details about calls to the UpdateBatchRowsSlowQuery method.
 		UpdateBatchRowsSlowQuery []struct {
 			// Ctx is the ctx argument value.
@@ -365,33 +891,96 @@ type QuerierMock struct {
 			// Arg is the arg argument value.
 			Arg batchsqlc.UpdateBatchSummaryOnAbortParams
 		}
-	}
-	lockBulkInsertIntoBatchRows              sync.RWMutex
-	lockCountBatchRowsByBatchIDAndStatus     sync.RWMutex
-	lockFetchBatchRowsForBatchDone           sync.RWMutex
-	lockFetchBlockOfRows                     sync.RWMutex
+		// UpdatePipelineStatus holds details about calls to the UpdatePipelineStatus method.
+		UpdatePipelineStatus []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.UpdatePipelineStatusParams
+		}
+		// UpdateRecurringJobRun holds details about calls to the UpdateRecurringJobRun method.
+		UpdateRecurringJobRun []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.UpdateRecurringJobRunParams
+		}
+		// UpsertRecurringJob holds details about calls to the UpsertRecurringJob method.
+		UpsertRecurringJob []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Arg is the arg argument value.
+			Arg batchsqlc.UpsertRecurringJobParams
+		}
+	}
+	lockBulkInsertIntoBatchRows              sync.RWMutex
+	lockBulkUpdateBatchRowsBatchJob          sync.RWMutex
+	lockCloseBatchInput                      sync.RWMutex
+	lockCopyIntoBatchRows                    sync.RWMutex
+	lockCountBatchRowsByBatchIDAndStatus     sync.RWMutex
+	lockDeadLetterBatchRow                   sync.RWMutex
+	lockDeleteStaleWorkers                   sync.RWMutex
+	lockDeleteWorker                         sync.RWMutex
+	lockExtendWorkerLeases                   sync.RWMutex
+	lockFetchBatchRowsForBatchDone           sync.RWMutex
+	lockFetchBlockOfRows                     sync.RWMutex
+	lockForgetMemoizedQueries                sync.RWMutex
+	lockGetAbortedBatches                    sync.RWMutex
 	lockGetBatchByID                         sync.RWMutex
+	lockGetBatchByIdempotencyKey             sync.RWMutex
+	lockGetBatchCounts                       sync.RWMutex
+	lockGetBatchProgress                     sync.RWMutex
+	lockGetBatchRowResults                   sync.RWMutex
 	lockGetBatchRowsByBatchID                sync.RWMutex
 	lockGetBatchRowsByBatchIDSorted          sync.RWMutex
 	lockGetBatchRowsCount                    sync.RWMutex
 	lockGetBatchStatus                       sync.RWMutex
 	lockGetBatchStatusAndOutputFiles         sync.RWMutex
 	lockGetCompletedBatches                  sync.RWMutex
+	lockGetDeadLetterBatches                 sync.RWMutex
+	lockGetDeadLetterRow                     sync.RWMutex
+	lockGetDueRecurringJob                   sync.RWMutex
+	lockGetMemoizedQuery                     sync.RWMutex
 	lockGetPendingBatchRows                  sync.RWMutex
+	lockGetPipelineByID                      sync.RWMutex
+	lockGetPipelineSteps                     sync.RWMutex
+	lockGetPipelineToAdvance                 sync.RWMutex
 	lockGetProcessedBatchRowsByBatchIDSorted sync.RWMutex
 	lockInsertBatchFile                      sync.RWMutex
 	lockInsertIntoBatchRows                  sync.RWMutex
 	lockInsertIntoBatches                    sync.RWMutex
+	lockInsertPipeline                       sync.RWMutex
+	lockInsertPipelineStep                   sync.RWMutex
+	lockLeaseBatchRows                       sync.RWMutex
+	lockListBatchRowResults                  sync.RWMutex
+	lockListBatches                          sync.RWMutex
+	lockListDeadLetterRows                   sync.RWMutex
+	lockListSlowQueries                      sync.RWMutex
+	lockMarkBatchInprog                      sync.RWMutex
+	lockNotifyBatchQueued                    sync.RWMutex
+	lockReclaimExpiredLeases                 sync.RWMutex
+	lockRecordWorkerHeartbeat                sync.RWMutex
+	lockReleaseBatchRows                     sync.RWMutex
+	lockReopenBatch                          sync.RWMutex
+	lockRequeueDeadLetterRows                sync.RWMutex
+	lockRetryBatchRow                        sync.RWMutex
+	lockRetryBatchRows                       sync.RWMutex
+	lockSetBatchStatus                       sync.RWMutex
+	lockUpdateBatchAggregate                 sync.RWMutex
+	lockUpdateBatchAggregateErr              sync.RWMutex
 	lockUpdateBatchCounters                  sync.RWMutex
 	lockUpdateBatchOutputFiles               sync.RWMutex
+	lockUpdateBatchProgress                  sync.RWMutex
 	lockUpdateBatchResult                    sync.RWMutex
 	lockUpdateBatchRowStatus                 sync.RWMutex
-	lockUpdateBatchRowsBatchJob              sync.RWMutex
 	lockUpdateBatchRowsSlowQuery             sync.RWMutex
 	lockUpdateBatchRowsStatus                sync.RWMutex
 	lockUpdateBatchStatus                    sync.RWMutex
 	lockUpdateBatchSummary                   sync.RWMutex
 	lockUpdateBatchSummaryOnAbort            sync.RWMutex
+	lockUpdatePipelineStatus                 sync.RWMutex
+	lockUpdateRecurringJobRun                sync.RWMutex
+	lockUpsertRecurringJob                   sync.RWMutex
 }
 
 // BulkInsertIntoBatchRows calls BulkInsertIntoBatchRowsFunc.
@@ -430,6 +1019,114 @@ func (mock *QuerierMock) BulkInsertIntoBatchRowsCalls() []struct {
 	return calls
 }
 
+// BulkUpdateBatchRowsBatchJob calls BulkUpdateBatchRowsBatchJobFunc.
+func (mock *QuerierMock) BulkUpdateBatchRowsBatchJob(ctx context.Context, arg batchsqlc.BulkUpdateBatchRowsBatchJobParams) ([]int64, error) {
+	if mock.BulkUpdateBatchRowsBatchJobFunc == nil {
+		panic("QuerierMock.BulkUpdateBatchRowsBatchJobFunc: method is nil but Querier.BulkUpdateBatchRowsBatchJob was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.BulkUpdateBatchRowsBatchJobParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockBulkUpdateBatchRowsBatchJob.Lock()
+	mock.calls.BulkUpdateBatchRowsBatchJob = append(mock.calls.BulkUpdateBatchRowsBatchJob, callInfo)
+	mock.lockBulkUpdateBatchRowsBatchJob.Unlock()
+	return mock.BulkUpdateBatchRowsBatchJobFunc(ctx, arg)
+}
+
+// BulkUpdateBatchRowsBatchJobCalls gets all the calls that were made to BulkUpdateBatchRowsBatchJob.
+// Check the length with:
+//
+//	len(mockedQuerier.BulkUpdateBatchRowsBatchJobCalls())
+func (mock *QuerierMock) BulkUpdateBatchRowsBatchJobCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.BulkUpdateBatchRowsBatchJobParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.BulkUpdateBatchRowsBatchJobParams
+	}
+	mock.lockBulkUpdateBatchRowsBatchJob.RLock()
+	calls = mock.calls.BulkUpdateBatchRowsBatchJob
+	mock.lockBulkUpdateBatchRowsBatchJob.RUnlock()
+	return calls
+}
+
+// CloseBatchInput calls CloseBatchInputFunc.
+func (mock *QuerierMock) CloseBatchInput(ctx context.Context, arg batchsqlc.CloseBatchInputParams) error {
+	if mock.CloseBatchInputFunc == nil {
+		panic("QuerierMock.CloseBatchInputFunc: method is nil but Querier.CloseBatchInput was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.CloseBatchInputParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCloseBatchInput.Lock()
+	mock.calls.CloseBatchInput = append(mock.calls.CloseBatchInput, callInfo)
+	mock.lockCloseBatchInput.Unlock()
+	return mock.CloseBatchInputFunc(ctx, arg)
+}
+
+// CloseBatchInputCalls gets all the calls that were made to CloseBatchInput.
+// Check the length with:
+//
+//	len(mockedQuerier.CloseBatchInputCalls())
+func (mock *QuerierMock) CloseBatchInputCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.CloseBatchInputParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.CloseBatchInputParams
+	}
+	mock.lockCloseBatchInput.RLock()
+	calls = mock.calls.CloseBatchInput
+	mock.lockCloseBatchInput.RUnlock()
+	return calls
+}
+
+// CopyIntoBatchRows calls CopyIntoBatchRowsFunc.
+func (mock *QuerierMock) CopyIntoBatchRows(ctx context.Context, arg []batchsqlc.CopyIntoBatchRowsParams) (int64, error) {
+	if mock.CopyIntoBatchRowsFunc == nil {
+		panic("QuerierMock.CopyIntoBatchRowsFunc: method is nil but Querier.CopyIntoBatchRows was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg []batchsqlc.CopyIntoBatchRowsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockCopyIntoBatchRows.Lock()
+	mock.calls.CopyIntoBatchRows = append(mock.calls.CopyIntoBatchRows, callInfo)
+	mock.lockCopyIntoBatchRows.Unlock()
+	return mock.CopyIntoBatchRowsFunc(ctx, arg)
+}
+
+// CopyIntoBatchRowsCalls gets all the calls that were made to CopyIntoBatchRows.
+// Check the length with:
+//
+//	len(mockedQuerier.CopyIntoBatchRowsCalls())
+func (mock *QuerierMock) CopyIntoBatchRowsCalls() []struct {
+	Ctx context.Context
+	Arg []batchsqlc.CopyIntoBatchRowsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg []batchsqlc.CopyIntoBatchRowsParams
+	}
+	mock.lockCopyIntoBatchRows.RLock()
+	calls = mock.calls.CopyIntoBatchRows
+	mock.lockCopyIntoBatchRows.RUnlock()
+	return calls
+}
+
 // CountBatchRowsByBatchIDAndStatus calls CountBatchRowsByBatchIDAndStatusFunc.
 func (mock *QuerierMock) CountBatchRowsByBatchIDAndStatus(ctx context.Context, arg batchsqlc.CountBatchRowsByBatchIDAndStatusParams) (int64, error) {
 	if mock.CountBatchRowsByBatchIDAndStatusFunc == nil {
@@ -466,6 +1163,150 @@ func (mock *QuerierMock) CountBatchRowsByBatchIDAndStatusCalls() []struct {
 	return calls
 }
 
+// DeadLetterBatchRow calls DeadLetterBatchRowFunc.
+func (mock *QuerierMock) DeadLetterBatchRow(ctx context.Context, arg batchsqlc.DeadLetterBatchRowParams) (int64, error) {
+	if mock.DeadLetterBatchRowFunc == nil {
+		panic("QuerierMock.DeadLetterBatchRowFunc: method is nil but Querier.DeadLetterBatchRow was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.DeadLetterBatchRowParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockDeadLetterBatchRow.Lock()
+	mock.calls.DeadLetterBatchRow = append(mock.calls.DeadLetterBatchRow, callInfo)
+	mock.lockDeadLetterBatchRow.Unlock()
+	return mock.DeadLetterBatchRowFunc(ctx, arg)
+}
+
+// DeadLetterBatchRowCalls gets all the calls that were made to DeadLetterBatchRow.
+// Check the length with:
+//
+//	len(mockedQuerier.DeadLetterBatchRowCalls())
+func (mock *QuerierMock) DeadLetterBatchRowCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.DeadLetterBatchRowParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.DeadLetterBatchRowParams
+	}
+	mock.lockDeadLetterBatchRow.RLock()
+	calls = mock.calls.DeadLetterBatchRow
+	mock.lockDeadLetterBatchRow.RUnlock()
+	return calls
+}
+
+// DeleteStaleWorkers calls DeleteStaleWorkersFunc.
+func (mock *QuerierMock) DeleteStaleWorkers(ctx context.Context, heartbeat pgtype.Timestamp) (int64, error) {
+	if mock.DeleteStaleWorkersFunc == nil {
+		panic("QuerierMock.DeleteStaleWorkersFunc: method is nil but Querier.DeleteStaleWorkers was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		Heartbeat pgtype.Timestamp
+	}{
+		Ctx:       ctx,
+		Heartbeat: heartbeat,
+	}
+	mock.lockDeleteStaleWorkers.Lock()
+	mock.calls.DeleteStaleWorkers = append(mock.calls.DeleteStaleWorkers, callInfo)
+	mock.lockDeleteStaleWorkers.Unlock()
+	return mock.DeleteStaleWorkersFunc(ctx, heartbeat)
+}
+
+// DeleteStaleWorkersCalls gets all the calls that were made to DeleteStaleWorkers.
+// Check the length with:
+//
+//	len(mockedQuerier.DeleteStaleWorkersCalls())
+func (mock *QuerierMock) DeleteStaleWorkersCalls() []struct {
+	Ctx       context.Context
+	Heartbeat pgtype.Timestamp
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Heartbeat pgtype.Timestamp
+	}
+	mock.lockDeleteStaleWorkers.RLock()
+	calls = mock.calls.DeleteStaleWorkers
+	mock.lockDeleteStaleWorkers.RUnlock()
+	return calls
+}
+
+// DeleteWorker calls DeleteWorkerFunc.
+func (mock *QuerierMock) DeleteWorker(ctx context.Context, id string) error {
+	if mock.DeleteWorkerFunc == nil {
+		panic("QuerierMock.DeleteWorkerFunc: method is nil but Querier.DeleteWorker was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockDeleteWorker.Lock()
+	mock.calls.DeleteWorker = append(mock.calls.DeleteWorker, callInfo)
+	mock.lockDeleteWorker.Unlock()
+	return mock.DeleteWorkerFunc(ctx, id)
+}
+
+// DeleteWorkerCalls gets all the calls that were made to DeleteWorker.
+// Check the length with:
+//
+//	len(mockedQuerier.DeleteWorkerCalls())
+func (mock *QuerierMock) DeleteWorkerCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockDeleteWorker.RLock()
+	calls = mock.calls.DeleteWorker
+	mock.lockDeleteWorker.RUnlock()
+	return calls
+}
+
+// ExtendWorkerLeases calls ExtendWorkerLeasesFunc.
+func (mock *QuerierMock) ExtendWorkerLeases(ctx context.Context, arg batchsqlc.ExtendWorkerLeasesParams) (int64, error) {
+	if mock.ExtendWorkerLeasesFunc == nil {
+		panic("QuerierMock.ExtendWorkerLeasesFunc: method is nil but Querier.ExtendWorkerLeases was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.ExtendWorkerLeasesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockExtendWorkerLeases.Lock()
+	mock.calls.ExtendWorkerLeases = append(mock.calls.ExtendWorkerLeases, callInfo)
+	mock.lockExtendWorkerLeases.Unlock()
+	return mock.ExtendWorkerLeasesFunc(ctx, arg)
+}
+
+// ExtendWorkerLeasesCalls gets all the calls that were made to ExtendWorkerLeases.
+// Check the length with:
+//
+//	len(mockedQuerier.ExtendWorkerLeasesCalls())
+func (mock *QuerierMock) ExtendWorkerLeasesCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.ExtendWorkerLeasesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.ExtendWorkerLeasesParams
+	}
+	mock.lockExtendWorkerLeases.RLock()
+	calls = mock.calls.ExtendWorkerLeases
+	mock.lockExtendWorkerLeases.RUnlock()
+	return calls
+}
+
 // FetchBatchRowsForBatchDone calls FetchBatchRowsForBatchDoneFunc.
 func (mock *QuerierMock) FetchBatchRowsForBatchDone(ctx context.Context, batch uuid.UUID) ([]batchsqlc.FetchBatchRowsForBatchDoneRow, error) {
 	if mock.FetchBatchRowsForBatchDoneFunc == nil {
@@ -538,6 +1379,78 @@ func (mock *QuerierMock) FetchBlockOfRowsCalls() []struct {
 	return calls
 }
 
+// ForgetMemoizedQueries calls ForgetMemoizedQueriesFunc.
+func (mock *QuerierMock) ForgetMemoizedQueries(ctx context.Context, arg batchsqlc.ForgetMemoizedQueriesParams) (int64, error) {
+	if mock.ForgetMemoizedQueriesFunc == nil {
+		panic("QuerierMock.ForgetMemoizedQueriesFunc: method is nil but Querier.ForgetMemoizedQueries was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.ForgetMemoizedQueriesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockForgetMemoizedQueries.Lock()
+	mock.calls.ForgetMemoizedQueries = append(mock.calls.ForgetMemoizedQueries, callInfo)
+	mock.lockForgetMemoizedQueries.Unlock()
+	return mock.ForgetMemoizedQueriesFunc(ctx, arg)
+}
+
+// ForgetMemoizedQueriesCalls gets all the calls that were made to ForgetMemoizedQueries.
+// Check the length with:
+//
+//	len(mockedQuerier.ForgetMemoizedQueriesCalls())
+func (mock *QuerierMock) ForgetMemoizedQueriesCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.ForgetMemoizedQueriesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.ForgetMemoizedQueriesParams
+	}
+	mock.lockForgetMemoizedQueries.RLock()
+	calls = mock.calls.ForgetMemoizedQueries
+	mock.lockForgetMemoizedQueries.RUnlock()
+	return calls
+}
+
+// GetAbortedBatches calls GetAbortedBatchesFunc.
+func (mock *QuerierMock) GetAbortedBatches(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	if mock.GetAbortedBatchesFunc == nil {
+		panic("QuerierMock.GetAbortedBatchesFunc: method is nil but Querier.GetAbortedBatches was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		IDs []uuid.UUID
+	}{
+		Ctx: ctx,
+		IDs: ids,
+	}
+	mock.lockGetAbortedBatches.Lock()
+	mock.calls.GetAbortedBatches = append(mock.calls.GetAbortedBatches, callInfo)
+	mock.lockGetAbortedBatches.Unlock()
+	return mock.GetAbortedBatchesFunc(ctx, ids)
+}
+
+// GetAbortedBatchesCalls gets all the calls that were made to GetAbortedBatches.
+// Check the length with:
+//
+//	len(mockedQuerier.GetAbortedBatchesCalls())
+func (mock *QuerierMock) GetAbortedBatchesCalls() []struct {
+	Ctx context.Context
+	IDs []uuid.UUID
+} {
+	var calls []struct {
+		Ctx context.Context
+		IDs []uuid.UUID
+	}
+	mock.lockGetAbortedBatches.RLock()
+	calls = mock.calls.GetAbortedBatches
+	mock.lockGetAbortedBatches.RUnlock()
+	return calls
+}
+
 // GetBatchByID calls GetBatchByIDFunc.
 func (mock *QuerierMock) GetBatchByID(ctx context.Context, id uuid.UUID) (batchsqlc.Batch, error) {
 	if mock.GetBatchByIDFunc == nil {
@@ -574,97 +1487,241 @@ func (mock *QuerierMock) GetBatchByIDCalls() []struct {
 	return calls
 }
 
-// GetBatchRowsByBatchID calls GetBatchRowsByBatchIDFunc.
-func (mock *QuerierMock) GetBatchRowsByBatchID(ctx context.Context, batch uuid.UUID) ([]batchsqlc.Batchrow, error) {
-	if mock.GetBatchRowsByBatchIDFunc == nil {
-		panic("QuerierMock.GetBatchRowsByBatchIDFunc: method is nil but Querier.GetBatchRowsByBatchID was just called")
+// GetBatchByIdempotencyKey calls GetBatchByIdempotencyKeyFunc.
+func (mock *QuerierMock) GetBatchByIdempotencyKey(ctx context.Context, arg batchsqlc.GetBatchByIdempotencyKeyParams) (batchsqlc.GetBatchByIdempotencyKeyRow, error) {
+	if mock.GetBatchByIdempotencyKeyFunc == nil {
+		panic("QuerierMock.GetBatchByIdempotencyKeyFunc: method is nil but Querier.GetBatchByIdempotencyKey was just called")
 	}
 	callInfo := struct {
-		Ctx   context.Context
-		Batch uuid.UUID
+		Ctx context.Context
+		Arg batchsqlc.GetBatchByIdempotencyKeyParams
 	}{
-		Ctx:   ctx,
-		Batch: batch,
+		Ctx: ctx,
+		Arg: arg,
 	}
-	mock.lockGetBatchRowsByBatchID.Lock()
-	mock.calls.GetBatchRowsByBatchID = append(mock.calls.GetBatchRowsByBatchID, callInfo)
-	mock.lockGetBatchRowsByBatchID.Unlock()
-	return mock.GetBatchRowsByBatchIDFunc(ctx, batch)
+	mock.lockGetBatchByIdempotencyKey.Lock()
+	mock.calls.GetBatchByIdempotencyKey = append(mock.calls.GetBatchByIdempotencyKey, callInfo)
+	mock.lockGetBatchByIdempotencyKey.Unlock()
+	return mock.GetBatchByIdempotencyKeyFunc(ctx, arg)
 }
 
-// GetBatchRowsByBatchIDCalls gets all the calls that were made to GetBatchRowsByBatchID.
+// GetBatchByIdempotencyKeyCalls gets all the calls that were made to GetBatchByIdempotencyKey.
 // Check the length with:
 //
-//	len(mockedQuerier.GetBatchRowsByBatchIDCalls())
-func (mock *QuerierMock) GetBatchRowsByBatchIDCalls() []struct {
-	Ctx   context.Context
-	Batch uuid.UUID
+//	len(mockedQuerier.GetBatchByIdempotencyKeyCalls())
+func (mock *QuerierMock) GetBatchByIdempotencyKeyCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.GetBatchByIdempotencyKeyParams
 } {
 	var calls []struct {
-		Ctx   context.Context
-		Batch uuid.UUID
+		Ctx context.Context
+		Arg batchsqlc.GetBatchByIdempotencyKeyParams
 	}
-	mock.lockGetBatchRowsByBatchID.RLock()
-	calls = mock.calls.GetBatchRowsByBatchID
-	mock.lockGetBatchRowsByBatchID.RUnlock()
+	mock.lockGetBatchByIdempotencyKey.RLock()
+	calls = mock.calls.GetBatchByIdempotencyKey
+	mock.lockGetBatchByIdempotencyKey.RUnlock()
 	return calls
 }
 
-// GetBatchRowsByBatchIDSorted calls GetBatchRowsByBatchIDSortedFunc.
-func (mock *QuerierMock) GetBatchRowsByBatchIDSorted(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetBatchRowsByBatchIDSortedRow, error) {
-	if mock.GetBatchRowsByBatchIDSortedFunc == nil {
-		panic("QuerierMock.GetBatchRowsByBatchIDSortedFunc: method is nil but Querier.GetBatchRowsByBatchIDSorted was just called")
+// GetBatchCounts calls GetBatchCountsFunc.
+func (mock *QuerierMock) GetBatchCounts(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchCountsRow, error) {
+	if mock.GetBatchCountsFunc == nil {
+		panic("QuerierMock.GetBatchCountsFunc: method is nil but Querier.GetBatchCounts was just called")
 	}
 	callInfo := struct {
-		Ctx   context.Context
-		Batch uuid.UUID
+		Ctx context.Context
+		ID  uuid.UUID
 	}{
-		Ctx:   ctx,
-		Batch: batch,
+		Ctx: ctx,
+		ID:  id,
 	}
-	mock.lockGetBatchRowsByBatchIDSorted.Lock()
-	mock.calls.GetBatchRowsByBatchIDSorted = append(mock.calls.GetBatchRowsByBatchIDSorted, callInfo)
-	mock.lockGetBatchRowsByBatchIDSorted.Unlock()
-	return mock.GetBatchRowsByBatchIDSortedFunc(ctx, batch)
+	mock.lockGetBatchCounts.Lock()
+	mock.calls.GetBatchCounts = append(mock.calls.GetBatchCounts, callInfo)
+	mock.lockGetBatchCounts.Unlock()
+	return mock.GetBatchCountsFunc(ctx, id)
 }
 
-// GetBatchRowsByBatchIDSortedCalls gets all the calls that were made to GetBatchRowsByBatchIDSorted.
+// GetBatchCountsCalls gets all the calls that were made to GetBatchCounts.
 // Check the length with:
 //
-//	len(mockedQuerier.GetBatchRowsByBatchIDSortedCalls())
-func (mock *QuerierMock) GetBatchRowsByBatchIDSortedCalls() []struct {
-	Ctx   context.Context
-	Batch uuid.UUID
+//	len(mockedQuerier.GetBatchCountsCalls())
+func (mock *QuerierMock) GetBatchCountsCalls() []struct {
+	Ctx context.Context
+	ID  uuid.UUID
 } {
 	var calls []struct {
-		Ctx   context.Context
-		Batch uuid.UUID
+		Ctx context.Context
+		ID  uuid.UUID
 	}
-	mock.lockGetBatchRowsByBatchIDSorted.RLock()
-	calls = mock.calls.GetBatchRowsByBatchIDSorted
-	mock.lockGetBatchRowsByBatchIDSorted.RUnlock()
+	mock.lockGetBatchCounts.RLock()
+	calls = mock.calls.GetBatchCounts
+	mock.lockGetBatchCounts.RUnlock()
 	return calls
 }
 
-// GetBatchRowsCount calls GetBatchRowsCountFunc.
-func (mock *QuerierMock) GetBatchRowsCount(ctx context.Context, batch uuid.UUID) (int64, error) {
-	if mock.GetBatchRowsCountFunc == nil {
-		panic("QuerierMock.GetBatchRowsCountFunc: method is nil but Querier.GetBatchRowsCount was just called")
+// GetBatchProgress calls GetBatchProgressFunc.
+func (mock *QuerierMock) GetBatchProgress(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchProgressRow, error) {
+	if mock.GetBatchProgressFunc == nil {
+		panic("QuerierMock.GetBatchProgressFunc: method is nil but Querier.GetBatchProgress was just called")
 	}
 	callInfo := struct {
-		Ctx   context.Context
-		Batch uuid.UUID
+		Ctx context.Context
+		ID  uuid.UUID
 	}{
-		Ctx:   ctx,
-		Batch: batch,
+		Ctx: ctx,
+		ID:  id,
 	}
-	mock.lockGetBatchRowsCount.Lock()
-	mock.calls.GetBatchRowsCount = append(mock.calls.GetBatchRowsCount, callInfo)
-	mock.lockGetBatchRowsCount.Unlock()
-	return mock.GetBatchRowsCountFunc(ctx, batch)
+	mock.lockGetBatchProgress.Lock()
+	mock.calls.GetBatchProgress = append(mock.calls.GetBatchProgress, callInfo)
+	mock.lockGetBatchProgress.Unlock()
+	return mock.GetBatchProgressFunc(ctx, id)
 }
 
-// GetBatchRowsCountCalls gets all the calls that were made to GetBatchRowsCount.
+// GetBatchProgressCalls gets all the calls that were made to GetBatchProgress.
+// Check the length with:
+//
+//	len(mockedQuerier.GetBatchProgressCalls())
+func (mock *QuerierMock) GetBatchProgressCalls() []struct {
+	Ctx context.Context
+	ID  uuid.UUID
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  uuid.UUID
+	}
+	mock.lockGetBatchProgress.RLock()
+	calls = mock.calls.GetBatchProgress
+	mock.lockGetBatchProgress.RUnlock()
+	return calls
+}
+
+// GetBatchRowResults calls GetBatchRowResultsFunc.
+func (mock *QuerierMock) GetBatchRowResults(ctx context.Context, batch uuid.UUID) ([]batchsqlc.Batchrowresult, error) {
+	if mock.GetBatchRowResultsFunc == nil {
+		panic("QuerierMock.GetBatchRowResultsFunc: method is nil but Querier.GetBatchRowResults was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}{
+		Ctx:   ctx,
+		Batch: batch,
+	}
+	mock.lockGetBatchRowResults.Lock()
+	mock.calls.GetBatchRowResults = append(mock.calls.GetBatchRowResults, callInfo)
+	mock.lockGetBatchRowResults.Unlock()
+	return mock.GetBatchRowResultsFunc(ctx, batch)
+}
+
+// GetBatchRowResultsCalls gets all the calls that were made to GetBatchRowResults.
+// Check the length with:
+//
+//	len(mockedQuerier.GetBatchRowResultsCalls())
+func (mock *QuerierMock) GetBatchRowResultsCalls() []struct {
+	Ctx   context.Context
+	Batch uuid.UUID
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}
+	mock.lockGetBatchRowResults.RLock()
+	calls = mock.calls.GetBatchRowResults
+	mock.lockGetBatchRowResults.RUnlock()
+	return calls
+}
+
+// GetBatchRowsByBatchID calls GetBatchRowsByBatchIDFunc.
+func (mock *QuerierMock) GetBatchRowsByBatchID(ctx context.Context, batch uuid.UUID) ([]batchsqlc.Batchrow, error) {
+	if mock.GetBatchRowsByBatchIDFunc == nil {
+		panic("QuerierMock.GetBatchRowsByBatchIDFunc: method is nil but Querier.GetBatchRowsByBatchID was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}{
+		Ctx:   ctx,
+		Batch: batch,
+	}
+	mock.lockGetBatchRowsByBatchID.Lock()
+	mock.calls.GetBatchRowsByBatchID = append(mock.calls.GetBatchRowsByBatchID, callInfo)
+	mock.lockGetBatchRowsByBatchID.Unlock()
+	return mock.GetBatchRowsByBatchIDFunc(ctx, batch)
+}
+
+// GetBatchRowsByBatchIDCalls gets all the calls that were made to GetBatchRowsByBatchID.
+// Check the length with:
+//
+//	len(mockedQuerier.GetBatchRowsByBatchIDCalls())
+func (mock *QuerierMock) GetBatchRowsByBatchIDCalls() []struct {
+	Ctx   context.Context
+	Batch uuid.UUID
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}
+	mock.lockGetBatchRowsByBatchID.RLock()
+	calls = mock.calls.GetBatchRowsByBatchID
+	mock.lockGetBatchRowsByBatchID.RUnlock()
+	return calls
+}
+
+// GetBatchRowsByBatchIDSorted calls GetBatchRowsByBatchIDSortedFunc.
+func (mock *QuerierMock) GetBatchRowsByBatchIDSorted(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetBatchRowsByBatchIDSortedRow, error) {
+	if mock.GetBatchRowsByBatchIDSortedFunc == nil {
+		panic("QuerierMock.GetBatchRowsByBatchIDSortedFunc: method is nil but Querier.GetBatchRowsByBatchIDSorted was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}{
+		Ctx:   ctx,
+		Batch: batch,
+	}
+	mock.lockGetBatchRowsByBatchIDSorted.Lock()
+	mock.calls.GetBatchRowsByBatchIDSorted = append(mock.calls.GetBatchRowsByBatchIDSorted, callInfo)
+	mock.lockGetBatchRowsByBatchIDSorted.Unlock()
+	return mock.GetBatchRowsByBatchIDSortedFunc(ctx, batch)
+}
+
+// GetBatchRowsByBatchIDSortedCalls gets all the calls that were made to GetBatchRowsByBatchIDSorted.
+// Check the length with:
+//
+//	len(mockedQuerier.GetBatchRowsByBatchIDSortedCalls())
+func (mock *QuerierMock) GetBatchRowsByBatchIDSortedCalls() []struct {
+	Ctx   context.Context
+	Batch uuid.UUID
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}
+	mock.lockGetBatchRowsByBatchIDSorted.RLock()
+	calls = mock.calls.GetBatchRowsByBatchIDSorted
+	mock.lockGetBatchRowsByBatchIDSorted.RUnlock()
+	return calls
+}
+
+// GetBatchRowsCount calls GetBatchRowsCountFunc.
+func (mock *QuerierMock) GetBatchRowsCount(ctx context.Context, batch uuid.UUID) (int64, error) {
+	if mock.GetBatchRowsCountFunc == nil {
+		panic("QuerierMock.GetBatchRowsCountFunc: method is nil but Querier.GetBatchRowsCount was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}{
+		Ctx:   ctx,
+		Batch: batch,
+	}
+	mock.lockGetBatchRowsCount.Lock()
+	mock.calls.GetBatchRowsCount = append(mock.calls.GetBatchRowsCount, callInfo)
+	mock.lockGetBatchRowsCount.Unlock()
+	return mock.GetBatchRowsCountFunc(ctx, batch)
+}
+
+// GetBatchRowsCountCalls gets all the calls that were made to GetBatchRowsCount.
 // Check the length with:
 //
 //	len(mockedQuerier.GetBatchRowsCountCalls())
@@ -676,52 +1733,984 @@ func (mock *QuerierMock) GetBatchRowsCountCalls() []struct {
 		Ctx   context.Context
 		Batch uuid.UUID
 	}
-	mock.lockGetBatchRowsCount.RLock()
-	calls = mock.calls.GetBatchRowsCount
-	mock.lockGetBatchRowsCount.RUnlock()
+	mock.lockGetBatchRowsCount.RLock()
+	calls = mock.calls.GetBatchRowsCount
+	mock.lockGetBatchRowsCount.RUnlock()
+	return calls
+}
+
+// GetBatchStatus calls GetBatchStatusFunc.
+func (mock *QuerierMock) GetBatchStatus(ctx context.Context, id uuid.UUID) (batchsqlc.StatusEnum, error) {
+	if mock.GetBatchStatusFunc == nil {
+		panic("QuerierMock.GetBatchStatusFunc: method is nil but Querier.GetBatchStatus was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  uuid.UUID
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetBatchStatus.Lock()
+	mock.calls.GetBatchStatus = append(mock.calls.GetBatchStatus, callInfo)
+	mock.lockGetBatchStatus.Unlock()
+	return mock.GetBatchStatusFunc(ctx, id)
+}
+
+// GetBatchStatusCalls gets all the calls that were made to GetBatchStatus.
+// Check the length with:
+//
+//	len(mockedQuerier.GetBatchStatusCalls())
+func (mock *QuerierMock) GetBatchStatusCalls() []struct {
+	Ctx context.Context
+	ID  uuid.UUID
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  uuid.UUID
+	}
+	mock.lockGetBatchStatus.RLock()
+	calls = mock.calls.GetBatchStatus
+	mock.lockGetBatchStatus.RUnlock()
+	return calls
+}
+
+// GetBatchStatusAndOutputFiles calls GetBatchStatusAndOutputFilesFunc.
+func (mock *QuerierMock) GetBatchStatusAndOutputFiles(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchStatusAndOutputFilesRow, error) {
+	if mock.GetBatchStatusAndOutputFilesFunc == nil {
+		panic("QuerierMock.GetBatchStatusAndOutputFilesFunc: method is nil but Querier.GetBatchStatusAndOutputFiles was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  uuid.UUID
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetBatchStatusAndOutputFiles.Lock()
+	mock.calls.GetBatchStatusAndOutputFiles = append(mock.calls.GetBatchStatusAndOutputFiles, callInfo)
+	mock.lockGetBatchStatusAndOutputFiles.Unlock()
+	return mock.GetBatchStatusAndOutputFilesFunc(ctx, id)
+}
+
+// GetBatchStatusAndOutputFilesCalls gets all the calls that were made to GetBatchStatusAndOutputFiles.
+// Check the length with:
+//
+//	len(mockedQuerier.GetBatchStatusAndOutputFilesCalls())
+func (mock *QuerierMock) GetBatchStatusAndOutputFilesCalls() []struct {
+	Ctx context.Context
+	ID  uuid.UUID
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  uuid.UUID
+	}
+	mock.lockGetBatchStatusAndOutputFiles.RLock()
+	calls = mock.calls.GetBatchStatusAndOutputFiles
+	mock.lockGetBatchStatusAndOutputFiles.RUnlock()
+	return calls
+}
+
+// GetCompletedBatches calls GetCompletedBatchesFunc.
+func (mock *QuerierMock) GetCompletedBatches(ctx context.Context) ([]uuid.UUID, error) {
+	if mock.GetCompletedBatchesFunc == nil {
+		panic("QuerierMock.GetCompletedBatchesFunc: method is nil but Querier.GetCompletedBatches was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockGetCompletedBatches.Lock()
+	mock.calls.GetCompletedBatches = append(mock.calls.GetCompletedBatches, callInfo)
+	mock.lockGetCompletedBatches.Unlock()
+	return mock.GetCompletedBatchesFunc(ctx)
+}
+
+// GetCompletedBatchesCalls gets all the calls that were made to GetCompletedBatches.
+// Check the length with:
+//
+//	len(mockedQuerier.GetCompletedBatchesCalls())
+func (mock *QuerierMock) GetCompletedBatchesCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockGetCompletedBatches.RLock()
+	calls = mock.calls.GetCompletedBatches
+	mock.lockGetCompletedBatches.RUnlock()
+	return calls
+}
+
+// GetDeadLetterBatches calls GetDeadLetterBatchesFunc.
+func (mock *QuerierMock) GetDeadLetterBatches(ctx context.Context, rowids []int64) ([]uuid.UUID, error) {
+	if mock.GetDeadLetterBatchesFunc == nil {
+		panic("QuerierMock.GetDeadLetterBatchesFunc: method is nil but Querier.GetDeadLetterBatches was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Rowids []int64
+	}{
+		Ctx:    ctx,
+		Rowids: rowids,
+	}
+	mock.lockGetDeadLetterBatches.Lock()
+	mock.calls.GetDeadLetterBatches = append(mock.calls.GetDeadLetterBatches, callInfo)
+	mock.lockGetDeadLetterBatches.Unlock()
+	return mock.GetDeadLetterBatchesFunc(ctx, rowids)
+}
+
+// GetDeadLetterBatchesCalls gets all the calls that were made to GetDeadLetterBatches.
+// Check the length with:
+//
+//	len(mockedQuerier.GetDeadLetterBatchesCalls())
+func (mock *QuerierMock) GetDeadLetterBatchesCalls() []struct {
+	Ctx    context.Context
+	Rowids []int64
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Rowids []int64
+	}
+	mock.lockGetDeadLetterBatches.RLock()
+	calls = mock.calls.GetDeadLetterBatches
+	mock.lockGetDeadLetterBatches.RUnlock()
+	return calls
+}
+
+// GetDeadLetterRow calls GetDeadLetterRowFunc.
+func (mock *QuerierMock) GetDeadLetterRow(ctx context.Context, rowid int64) (batchsqlc.GetDeadLetterRowRow, error) {
+	if mock.GetDeadLetterRowFunc == nil {
+		panic("QuerierMock.GetDeadLetterRowFunc: method is nil but Querier.GetDeadLetterRow was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Rowid int64
+	}{
+		Ctx:   ctx,
+		Rowid: rowid,
+	}
+	mock.lockGetDeadLetterRow.Lock()
+	mock.calls.GetDeadLetterRow = append(mock.calls.GetDeadLetterRow, callInfo)
+	mock.lockGetDeadLetterRow.Unlock()
+	return mock.GetDeadLetterRowFunc(ctx, rowid)
+}
+
+// GetDeadLetterRowCalls gets all the calls that were made to GetDeadLetterRow.
+// Check the length with:
+//
+//	len(mockedQuerier.GetDeadLetterRowCalls())
+func (mock *QuerierMock) GetDeadLetterRowCalls() []struct {
+	Ctx   context.Context
+	Rowid int64
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Rowid int64
+	}
+	mock.lockGetDeadLetterRow.RLock()
+	calls = mock.calls.GetDeadLetterRow
+	mock.lockGetDeadLetterRow.RUnlock()
+	return calls
+}
+
+// GetDueRecurringJob calls GetDueRecurringJobFunc.
+func (mock *QuerierMock) GetDueRecurringJob(ctx context.Context, arg batchsqlc.GetDueRecurringJobParams) (batchsqlc.GetDueRecurringJobRow, error) {
+	if mock.GetDueRecurringJobFunc == nil {
+		panic("QuerierMock.GetDueRecurringJobFunc: method is nil but Querier.GetDueRecurringJob was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.GetDueRecurringJobParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockGetDueRecurringJob.Lock()
+	mock.calls.GetDueRecurringJob = append(mock.calls.GetDueRecurringJob, callInfo)
+	mock.lockGetDueRecurringJob.Unlock()
+	return mock.GetDueRecurringJobFunc(ctx, arg)
+}
+
+// GetDueRecurringJobCalls gets all the calls that were made to GetDueRecurringJob.
+// Check the length with:
+//
+//	len(mockedQuerier.GetDueRecurringJobCalls())
+func (mock *QuerierMock) GetDueRecurringJobCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.GetDueRecurringJobParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.GetDueRecurringJobParams
+	}
+	mock.lockGetDueRecurringJob.RLock()
+	calls = mock.calls.GetDueRecurringJob
+	mock.lockGetDueRecurringJob.RUnlock()
+	return calls
+}
+
+// GetMemoizedQuery calls GetMemoizedQueryFunc.
+func (mock *QuerierMock) GetMemoizedQuery(ctx context.Context, arg batchsqlc.GetMemoizedQueryParams) (uuid.UUID, error) {
+	if mock.GetMemoizedQueryFunc == nil {
+		panic("QuerierMock.GetMemoizedQueryFunc: method is nil but Querier.GetMemoizedQuery was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.GetMemoizedQueryParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockGetMemoizedQuery.Lock()
+	mock.calls.GetMemoizedQuery = append(mock.calls.GetMemoizedQuery, callInfo)
+	mock.lockGetMemoizedQuery.Unlock()
+	return mock.GetMemoizedQueryFunc(ctx, arg)
+}
+
+// GetMemoizedQueryCalls gets all the calls that were made to GetMemoizedQuery.
+// Check the length with:
+//
+//	len(mockedQuerier.GetMemoizedQueryCalls())
+func (mock *QuerierMock) GetMemoizedQueryCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.GetMemoizedQueryParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.GetMemoizedQueryParams
+	}
+	mock.lockGetMemoizedQuery.RLock()
+	calls = mock.calls.GetMemoizedQuery
+	mock.lockGetMemoizedQuery.RUnlock()
+	return calls
+}
+
+// GetPendingBatchRows calls GetPendingBatchRowsFunc.
+func (mock *QuerierMock) GetPendingBatchRows(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetPendingBatchRowsRow, error) {
+	if mock.GetPendingBatchRowsFunc == nil {
+		panic("QuerierMock.GetPendingBatchRowsFunc: method is nil but Querier.GetPendingBatchRows was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}{
+		Ctx:   ctx,
+		Batch: batch,
+	}
+	mock.lockGetPendingBatchRows.Lock()
+	mock.calls.GetPendingBatchRows = append(mock.calls.GetPendingBatchRows, callInfo)
+	mock.lockGetPendingBatchRows.Unlock()
+	return mock.GetPendingBatchRowsFunc(ctx, batch)
+}
+
+// GetPendingBatchRowsCalls gets all the calls that were made to GetPendingBatchRows.
+// Check the length with:
+//
+//	len(mockedQuerier.GetPendingBatchRowsCalls())
+func (mock *QuerierMock) GetPendingBatchRowsCalls() []struct {
+	Ctx   context.Context
+	Batch uuid.UUID
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}
+	mock.lockGetPendingBatchRows.RLock()
+	calls = mock.calls.GetPendingBatchRows
+	mock.lockGetPendingBatchRows.RUnlock()
+	return calls
+}
+
+// GetPipelineByID calls GetPipelineByIDFunc.
+func (mock *QuerierMock) GetPipelineByID(ctx context.Context, id uuid.UUID) (batchsqlc.Pipeline, error) {
+	if mock.GetPipelineByIDFunc == nil {
+		panic("QuerierMock.GetPipelineByIDFunc: method is nil but Querier.GetPipelineByID was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  uuid.UUID
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetPipelineByID.Lock()
+	mock.calls.GetPipelineByID = append(mock.calls.GetPipelineByID, callInfo)
+	mock.lockGetPipelineByID.Unlock()
+	return mock.GetPipelineByIDFunc(ctx, id)
+}
+
+// GetPipelineByIDCalls gets all the calls that were made to GetPipelineByID.
+// Check the length with:
+//
+//	len(mockedQuerier.GetPipelineByIDCalls())
+func (mock *QuerierMock) GetPipelineByIDCalls() []struct {
+	Ctx context.Context
+	ID  uuid.UUID
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  uuid.UUID
+	}
+	mock.lockGetPipelineByID.RLock()
+	calls = mock.calls.GetPipelineByID
+	mock.lockGetPipelineByID.RUnlock()
+	return calls
+}
+
+// GetPipelineSteps calls GetPipelineStepsFunc.
+func (mock *QuerierMock) GetPipelineSteps(ctx context.Context, pipeline uuid.UUID) ([]batchsqlc.GetPipelineStepsRow, error) {
+	if mock.GetPipelineStepsFunc == nil {
+		panic("QuerierMock.GetPipelineStepsFunc: method is nil but Querier.GetPipelineSteps was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Pipeline uuid.UUID
+	}{
+		Ctx:      ctx,
+		Pipeline: pipeline,
+	}
+	mock.lockGetPipelineSteps.Lock()
+	mock.calls.GetPipelineSteps = append(mock.calls.GetPipelineSteps, callInfo)
+	mock.lockGetPipelineSteps.Unlock()
+	return mock.GetPipelineStepsFunc(ctx, pipeline)
+}
+
+// GetPipelineStepsCalls gets all the calls that were made to GetPipelineSteps.
+// Check the length with:
+//
+//	len(mockedQuerier.GetPipelineStepsCalls())
+func (mock *QuerierMock) GetPipelineStepsCalls() []struct {
+	Ctx      context.Context
+	Pipeline uuid.UUID
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Pipeline uuid.UUID
+	}
+	mock.lockGetPipelineSteps.RLock()
+	calls = mock.calls.GetPipelineSteps
+	mock.lockGetPipelineSteps.RUnlock()
+	return calls
+}
+
+// GetPipelineToAdvance calls GetPipelineToAdvanceFunc.
+func (mock *QuerierMock) GetPipelineToAdvance(ctx context.Context, arg batchsqlc.GetPipelineToAdvanceParams) (batchsqlc.GetPipelineToAdvanceRow, error) {
+	if mock.GetPipelineToAdvanceFunc == nil {
+		panic("QuerierMock.GetPipelineToAdvanceFunc: method is nil but Querier.GetPipelineToAdvance was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.GetPipelineToAdvanceParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockGetPipelineToAdvance.Lock()
+	mock.calls.GetPipelineToAdvance = append(mock.calls.GetPipelineToAdvance, callInfo)
+	mock.lockGetPipelineToAdvance.Unlock()
+	return mock.GetPipelineToAdvanceFunc(ctx, arg)
+}
+
+// GetPipelineToAdvanceCalls gets all the calls that were made to GetPipelineToAdvance.
+// Check the length with:
+//
+//	len(mockedQuerier.GetPipelineToAdvanceCalls())
+func (mock *QuerierMock) GetPipelineToAdvanceCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.GetPipelineToAdvanceParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.GetPipelineToAdvanceParams
+	}
+	mock.lockGetPipelineToAdvance.RLock()
+	calls = mock.calls.GetPipelineToAdvance
+	mock.lockGetPipelineToAdvance.RUnlock()
+	return calls
+}
+
+// GetProcessedBatchRowsByBatchIDSorted calls GetProcessedBatchRowsByBatchIDSortedFunc.
+func (mock *QuerierMock) GetProcessedBatchRowsByBatchIDSorted(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow, error) {
+	if mock.GetProcessedBatchRowsByBatchIDSortedFunc == nil {
+		panic("QuerierMock.GetProcessedBatchRowsByBatchIDSortedFunc: method is nil but Querier.GetProcessedBatchRowsByBatchIDSorted was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}{
+		Ctx:   ctx,
+		Batch: batch,
+	}
+	mock.lockGetProcessedBatchRowsByBatchIDSorted.Lock()
+	mock.calls.GetProcessedBatchRowsByBatchIDSorted = append(mock.calls.GetProcessedBatchRowsByBatchIDSorted, callInfo)
+	mock.lockGetProcessedBatchRowsByBatchIDSorted.Unlock()
+	return mock.GetProcessedBatchRowsByBatchIDSortedFunc(ctx, batch)
+}
+
+// GetProcessedBatchRowsByBatchIDSortedCalls gets all the calls that were made to GetProcessedBatchRowsByBatchIDSorted.
+// Check the length with:
+//
+//	len(mockedQuerier.GetProcessedBatchRowsByBatchIDSortedCalls())
+func (mock *QuerierMock) GetProcessedBatchRowsByBatchIDSortedCalls() []struct {
+	Ctx   context.Context
+	Batch uuid.UUID
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Batch uuid.UUID
+	}
+	mock.lockGetProcessedBatchRowsByBatchIDSorted.RLock()
+	calls = mock.calls.GetProcessedBatchRowsByBatchIDSorted
+	mock.lockGetProcessedBatchRowsByBatchIDSorted.RUnlock()
+	return calls
+}
+
+// InsertBatchFile calls InsertBatchFileFunc.
+func (mock *QuerierMock) InsertBatchFile(ctx context.Context, arg batchsqlc.InsertBatchFileParams) error {
+	if mock.InsertBatchFileFunc == nil {
+		panic("QuerierMock.InsertBatchFileFunc: method is nil but Querier.InsertBatchFile was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertBatchFileParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockInsertBatchFile.Lock()
+	mock.calls.InsertBatchFile = append(mock.calls.InsertBatchFile, callInfo)
+	mock.lockInsertBatchFile.Unlock()
+	return mock.InsertBatchFileFunc(ctx, arg)
+}
+
+// InsertBatchFileCalls gets all the calls that were made to InsertBatchFile.
+// Check the length with:
+//
+//	len(mockedQuerier.InsertBatchFileCalls())
+func (mock *QuerierMock) InsertBatchFileCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.InsertBatchFileParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertBatchFileParams
+	}
+	mock.lockInsertBatchFile.RLock()
+	calls = mock.calls.InsertBatchFile
+	mock.lockInsertBatchFile.RUnlock()
+	return calls
+}
+
+// InsertIntoBatchRows calls InsertIntoBatchRowsFunc.
+func (mock *QuerierMock) InsertIntoBatchRows(ctx context.Context, arg batchsqlc.InsertIntoBatchRowsParams) error {
+	if mock.InsertIntoBatchRowsFunc == nil {
+		panic("QuerierMock.InsertIntoBatchRowsFunc: method is nil but Querier.InsertIntoBatchRows was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertIntoBatchRowsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockInsertIntoBatchRows.Lock()
+	mock.calls.InsertIntoBatchRows = append(mock.calls.InsertIntoBatchRows, callInfo)
+	mock.lockInsertIntoBatchRows.Unlock()
+	return mock.InsertIntoBatchRowsFunc(ctx, arg)
+}
+
+// InsertIntoBatchRowsCalls gets all the calls that were made to InsertIntoBatchRows.
+// Check the length with:
+//
+//	len(mockedQuerier.InsertIntoBatchRowsCalls())
+func (mock *QuerierMock) InsertIntoBatchRowsCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.InsertIntoBatchRowsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertIntoBatchRowsParams
+	}
+	mock.lockInsertIntoBatchRows.RLock()
+	calls = mock.calls.InsertIntoBatchRows
+	mock.lockInsertIntoBatchRows.RUnlock()
+	return calls
+}
+
+// InsertIntoBatches calls InsertIntoBatchesFunc.
+func (mock *QuerierMock) InsertIntoBatches(ctx context.Context, arg batchsqlc.InsertIntoBatchesParams) (uuid.UUID, error) {
+	if mock.InsertIntoBatchesFunc == nil {
+		panic("QuerierMock.InsertIntoBatchesFunc: method is nil but Querier.InsertIntoBatches was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertIntoBatchesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockInsertIntoBatches.Lock()
+	mock.calls.InsertIntoBatches = append(mock.calls.InsertIntoBatches, callInfo)
+	mock.lockInsertIntoBatches.Unlock()
+	return mock.InsertIntoBatchesFunc(ctx, arg)
+}
+
+// InsertIntoBatchesCalls gets all the calls that were made to InsertIntoBatches.
+// Check the length with:
+//
+//	len(mockedQuerier.InsertIntoBatchesCalls())
+func (mock *QuerierMock) InsertIntoBatchesCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.InsertIntoBatchesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertIntoBatchesParams
+	}
+	mock.lockInsertIntoBatches.RLock()
+	calls = mock.calls.InsertIntoBatches
+	mock.lockInsertIntoBatches.RUnlock()
+	return calls
+}
+
+// InsertPipeline calls InsertPipelineFunc.
+func (mock *QuerierMock) InsertPipeline(ctx context.Context, arg batchsqlc.InsertPipelineParams) error {
+	if mock.InsertPipelineFunc == nil {
+		panic("QuerierMock.InsertPipelineFunc: method is nil but Querier.InsertPipeline was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertPipelineParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockInsertPipeline.Lock()
+	mock.calls.InsertPipeline = append(mock.calls.InsertPipeline, callInfo)
+	mock.lockInsertPipeline.Unlock()
+	return mock.InsertPipelineFunc(ctx, arg)
+}
+
+// InsertPipelineCalls gets all the calls that were made to InsertPipeline.
+// Check the length with:
+//
+//	len(mockedQuerier.InsertPipelineCalls())
+func (mock *QuerierMock) InsertPipelineCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.InsertPipelineParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertPipelineParams
+	}
+	mock.lockInsertPipeline.RLock()
+	calls = mock.calls.InsertPipeline
+	mock.lockInsertPipeline.RUnlock()
+	return calls
+}
+
+// InsertPipelineStep calls InsertPipelineStepFunc.
+func (mock *QuerierMock) InsertPipelineStep(ctx context.Context, arg batchsqlc.InsertPipelineStepParams) error {
+	if mock.InsertPipelineStepFunc == nil {
+		panic("QuerierMock.InsertPipelineStepFunc: method is nil but Querier.InsertPipelineStep was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertPipelineStepParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockInsertPipelineStep.Lock()
+	mock.calls.InsertPipelineStep = append(mock.calls.InsertPipelineStep, callInfo)
+	mock.lockInsertPipelineStep.Unlock()
+	return mock.InsertPipelineStepFunc(ctx, arg)
+}
+
+// InsertPipelineStepCalls gets all the calls that were made to InsertPipelineStep.
+// Check the length with:
+//
+//	len(mockedQuerier.InsertPipelineStepCalls())
+func (mock *QuerierMock) InsertPipelineStepCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.InsertPipelineStepParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.InsertPipelineStepParams
+	}
+	mock.lockInsertPipelineStep.RLock()
+	calls = mock.calls.InsertPipelineStep
+	mock.lockInsertPipelineStep.RUnlock()
+	return calls
+}
+
+// LeaseBatchRows calls LeaseBatchRowsFunc.
+func (mock *QuerierMock) LeaseBatchRows(ctx context.Context, arg batchsqlc.LeaseBatchRowsParams) error {
+	if mock.LeaseBatchRowsFunc == nil {
+		panic("QuerierMock.LeaseBatchRowsFunc: method is nil but Querier.LeaseBatchRows was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.LeaseBatchRowsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockLeaseBatchRows.Lock()
+	mock.calls.LeaseBatchRows = append(mock.calls.LeaseBatchRows, callInfo)
+	mock.lockLeaseBatchRows.Unlock()
+	return mock.LeaseBatchRowsFunc(ctx, arg)
+}
+
+// LeaseBatchRowsCalls gets all the calls that were made to LeaseBatchRows.
+// Check the length with:
+//
+//	len(mockedQuerier.LeaseBatchRowsCalls())
+func (mock *QuerierMock) LeaseBatchRowsCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.LeaseBatchRowsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.LeaseBatchRowsParams
+	}
+	mock.lockLeaseBatchRows.RLock()
+	calls = mock.calls.LeaseBatchRows
+	mock.lockLeaseBatchRows.RUnlock()
+	return calls
+}
+
+// ListBatchRowResults calls ListBatchRowResultsFunc.
+func (mock *QuerierMock) ListBatchRowResults(ctx context.Context, arg batchsqlc.ListBatchRowResultsParams) ([]batchsqlc.ListBatchRowResultsRow, error) {
+	if mock.ListBatchRowResultsFunc == nil {
+		panic("QuerierMock.ListBatchRowResultsFunc: method is nil but Querier.ListBatchRowResults was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.ListBatchRowResultsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListBatchRowResults.Lock()
+	mock.calls.ListBatchRowResults = append(mock.calls.ListBatchRowResults, callInfo)
+	mock.lockListBatchRowResults.Unlock()
+	return mock.ListBatchRowResultsFunc(ctx, arg)
+}
+
+// ListBatchRowResultsCalls gets all the calls that were made to ListBatchRowResults.
+// Check the length with:
+//
+//	len(mockedQuerier.ListBatchRowResultsCalls())
+func (mock *QuerierMock) ListBatchRowResultsCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.ListBatchRowResultsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.ListBatchRowResultsParams
+	}
+	mock.lockListBatchRowResults.RLock()
+	calls = mock.calls.ListBatchRowResults
+	mock.lockListBatchRowResults.RUnlock()
+	return calls
+}
+
+// ListBatches calls ListBatchesFunc.
+func (mock *QuerierMock) ListBatches(ctx context.Context, arg batchsqlc.ListBatchesParams) ([]batchsqlc.ListBatchesRow, error) {
+	if mock.ListBatchesFunc == nil {
+		panic("QuerierMock.ListBatchesFunc: method is nil but Querier.ListBatches was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.ListBatchesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListBatches.Lock()
+	mock.calls.ListBatches = append(mock.calls.ListBatches, callInfo)
+	mock.lockListBatches.Unlock()
+	return mock.ListBatchesFunc(ctx, arg)
+}
+
+// ListBatchesCalls gets all the calls that were made to ListBatches.
+// Check the length with:
+//
+//	len(mockedQuerier.ListBatchesCalls())
+func (mock *QuerierMock) ListBatchesCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.ListBatchesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.ListBatchesParams
+	}
+	mock.lockListBatches.RLock()
+	calls = mock.calls.ListBatches
+	mock.lockListBatches.RUnlock()
+	return calls
+}
+
+// ListDeadLetterRows calls ListDeadLetterRowsFunc.
+func (mock *QuerierMock) ListDeadLetterRows(ctx context.Context, arg batchsqlc.ListDeadLetterRowsParams) ([]batchsqlc.ListDeadLetterRowsRow, error) {
+	if mock.ListDeadLetterRowsFunc == nil {
+		panic("QuerierMock.ListDeadLetterRowsFunc: method is nil but Querier.ListDeadLetterRows was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.ListDeadLetterRowsParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListDeadLetterRows.Lock()
+	mock.calls.ListDeadLetterRows = append(mock.calls.ListDeadLetterRows, callInfo)
+	mock.lockListDeadLetterRows.Unlock()
+	return mock.ListDeadLetterRowsFunc(ctx, arg)
+}
+
+// ListDeadLetterRowsCalls gets all the calls that were made to ListDeadLetterRows.
+// Check the length with:
+//
+//	len(mockedQuerier.ListDeadLetterRowsCalls())
+func (mock *QuerierMock) ListDeadLetterRowsCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.ListDeadLetterRowsParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.ListDeadLetterRowsParams
+	}
+	mock.lockListDeadLetterRows.RLock()
+	calls = mock.calls.ListDeadLetterRows
+	mock.lockListDeadLetterRows.RUnlock()
+	return calls
+}
+
+// ListSlowQueries calls ListSlowQueriesFunc.
+func (mock *QuerierMock) ListSlowQueries(ctx context.Context, arg batchsqlc.ListSlowQueriesParams) ([]batchsqlc.ListSlowQueriesRow, error) {
+	if mock.ListSlowQueriesFunc == nil {
+		panic("QuerierMock.ListSlowQueriesFunc: method is nil but Querier.ListSlowQueries was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.ListSlowQueriesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockListSlowQueries.Lock()
+	mock.calls.ListSlowQueries = append(mock.calls.ListSlowQueries, callInfo)
+	mock.lockListSlowQueries.Unlock()
+	return mock.ListSlowQueriesFunc(ctx, arg)
+}
+
+// ListSlowQueriesCalls gets all the calls that were made to ListSlowQueries.
+// Check the length with:
+//
+//	len(mockedQuerier.ListSlowQueriesCalls())
+func (mock *QuerierMock) ListSlowQueriesCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.ListSlowQueriesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.ListSlowQueriesParams
+	}
+	mock.lockListSlowQueries.RLock()
+	calls = mock.calls.ListSlowQueries
+	mock.lockListSlowQueries.RUnlock()
+	return calls
+}
+
+// MarkBatchInprog calls MarkBatchInprogFunc.
+func (mock *QuerierMock) MarkBatchInprog(ctx context.Context, arg batchsqlc.MarkBatchInprogParams) error {
+	if mock.MarkBatchInprogFunc == nil {
+		panic("QuerierMock.MarkBatchInprogFunc: method is nil but Querier.MarkBatchInprog was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.MarkBatchInprogParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockMarkBatchInprog.Lock()
+	mock.calls.MarkBatchInprog = append(mock.calls.MarkBatchInprog, callInfo)
+	mock.lockMarkBatchInprog.Unlock()
+	return mock.MarkBatchInprogFunc(ctx, arg)
+}
+
+// MarkBatchInprogCalls gets all the calls that were made to MarkBatchInprog.
+// Check the length with:
+//
+//	len(mockedQuerier.MarkBatchInprogCalls())
+func (mock *QuerierMock) MarkBatchInprogCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.MarkBatchInprogParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.MarkBatchInprogParams
+	}
+	mock.lockMarkBatchInprog.RLock()
+	calls = mock.calls.MarkBatchInprog
+	mock.lockMarkBatchInprog.RUnlock()
+	return calls
+}
+
+// NotifyBatchQueued calls NotifyBatchQueuedFunc.
+func (mock *QuerierMock) NotifyBatchQueued(ctx context.Context, arg batchsqlc.NotifyBatchQueuedParams) error {
+	if mock.NotifyBatchQueuedFunc == nil {
+		panic("QuerierMock.NotifyBatchQueuedFunc: method is nil but Querier.NotifyBatchQueued was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.NotifyBatchQueuedParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockNotifyBatchQueued.Lock()
+	mock.calls.NotifyBatchQueued = append(mock.calls.NotifyBatchQueued, callInfo)
+	mock.lockNotifyBatchQueued.Unlock()
+	return mock.NotifyBatchQueuedFunc(ctx, arg)
+}
+
+// NotifyBatchQueuedCalls gets all the calls that were made to NotifyBatchQueued.
+// Check the length with:
+//
+//	len(mockedQuerier.NotifyBatchQueuedCalls())
+func (mock *QuerierMock) NotifyBatchQueuedCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.NotifyBatchQueuedParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.NotifyBatchQueuedParams
+	}
+	mock.lockNotifyBatchQueued.RLock()
+	calls = mock.calls.NotifyBatchQueued
+	mock.lockNotifyBatchQueued.RUnlock()
+	return calls
+}
+
+// ReclaimExpiredLeases calls ReclaimExpiredLeasesFunc.
+func (mock *QuerierMock) ReclaimExpiredLeases(ctx context.Context, arg batchsqlc.ReclaimExpiredLeasesParams) ([]batchsqlc.ReclaimExpiredLeasesRow, error) {
+	if mock.ReclaimExpiredLeasesFunc == nil {
+		panic("QuerierMock.ReclaimExpiredLeasesFunc: method is nil but Querier.ReclaimExpiredLeases was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.ReclaimExpiredLeasesParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockReclaimExpiredLeases.Lock()
+	mock.calls.ReclaimExpiredLeases = append(mock.calls.ReclaimExpiredLeases, callInfo)
+	mock.lockReclaimExpiredLeases.Unlock()
+	return mock.ReclaimExpiredLeasesFunc(ctx, arg)
+}
+
+// ReclaimExpiredLeasesCalls gets all the calls that were made to ReclaimExpiredLeases.
+// Check the length with:
+//
+//	len(mockedQuerier.ReclaimExpiredLeasesCalls())
+func (mock *QuerierMock) ReclaimExpiredLeasesCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.ReclaimExpiredLeasesParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.ReclaimExpiredLeasesParams
+	}
+	mock.lockReclaimExpiredLeases.RLock()
+	calls = mock.calls.ReclaimExpiredLeases
+	mock.lockReclaimExpiredLeases.RUnlock()
+	return calls
+}
+
+// RecordWorkerHeartbeat calls RecordWorkerHeartbeatFunc.
+func (mock *QuerierMock) RecordWorkerHeartbeat(ctx context.Context, arg batchsqlc.RecordWorkerHeartbeatParams) error {
+	if mock.RecordWorkerHeartbeatFunc == nil {
+		panic("QuerierMock.RecordWorkerHeartbeatFunc: method is nil but Querier.RecordWorkerHeartbeat was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.RecordWorkerHeartbeatParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockRecordWorkerHeartbeat.Lock()
+	mock.calls.RecordWorkerHeartbeat = append(mock.calls.RecordWorkerHeartbeat, callInfo)
+	mock.lockRecordWorkerHeartbeat.Unlock()
+	return mock.RecordWorkerHeartbeatFunc(ctx, arg)
+}
+
+// RecordWorkerHeartbeatCalls gets all the calls that were made to RecordWorkerHeartbeat.
+// Check the length with:
+//
+//	len(mockedQuerier.RecordWorkerHeartbeatCalls())
+func (mock *QuerierMock) RecordWorkerHeartbeatCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.RecordWorkerHeartbeatParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.RecordWorkerHeartbeatParams
+	}
+	mock.lockRecordWorkerHeartbeat.RLock()
+	calls = mock.calls.RecordWorkerHeartbeat
+	mock.lockRecordWorkerHeartbeat.RUnlock()
 	return calls
 }
 
-// GetBatchStatus calls GetBatchStatusFunc.
-func (mock *QuerierMock) GetBatchStatus(ctx context.Context, id uuid.UUID) (batchsqlc.StatusEnum, error) {
-	if mock.GetBatchStatusFunc == nil {
-		panic("QuerierMock.GetBatchStatusFunc: method is nil but Querier.GetBatchStatus was just called")
+// ReleaseBatchRows calls ReleaseBatchRowsFunc.
+func (mock *QuerierMock) ReleaseBatchRows(ctx context.Context, arg batchsqlc.ReleaseBatchRowsParams) error {
+	if mock.ReleaseBatchRowsFunc == nil {
+		panic("QuerierMock.ReleaseBatchRowsFunc: method is nil but Querier.ReleaseBatchRows was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
-		ID  uuid.UUID
+		Arg batchsqlc.ReleaseBatchRowsParams
 	}{
 		Ctx: ctx,
-		ID:  id,
+		Arg: arg,
 	}
-	mock.lockGetBatchStatus.Lock()
-	mock.calls.GetBatchStatus = append(mock.calls.GetBatchStatus, callInfo)
-	mock.lockGetBatchStatus.Unlock()
-	return mock.GetBatchStatusFunc(ctx, id)
+	mock.lockReleaseBatchRows.Lock()
+	mock.calls.ReleaseBatchRows = append(mock.calls.ReleaseBatchRows, callInfo)
+	mock.lockReleaseBatchRows.Unlock()
+	return mock.ReleaseBatchRowsFunc(ctx, arg)
 }
 
-// GetBatchStatusCalls gets all the calls that were made to GetBatchStatus.
+// ReleaseBatchRowsCalls gets all the calls that were made to ReleaseBatchRows.
 // Check the length with:
 //
-//	len(mockedQuerier.GetBatchStatusCalls())
-func (mock *QuerierMock) GetBatchStatusCalls() []struct {
+//	len(mockedQuerier.ReleaseBatchRowsCalls())
+func (mock *QuerierMock) ReleaseBatchRowsCalls() []struct {
 	Ctx context.Context
-	ID  uuid.UUID
+	Arg batchsqlc.ReleaseBatchRowsParams
 } {
 	var calls []struct {
 		Ctx context.Context
-		ID  uuid.UUID
+		Arg batchsqlc.ReleaseBatchRowsParams
 	}
-	mock.lockGetBatchStatus.RLock()
-	calls = mock.calls.GetBatchStatus
-	mock.lockGetBatchStatus.RUnlock()
+	mock.lockReleaseBatchRows.RLock()
+	calls = mock.calls.ReleaseBatchRows
+	mock.lockReleaseBatchRows.RUnlock()
 	return calls
 }
 
-// GetBatchStatusAndOutputFiles calls GetBatchStatusAndOutputFilesFunc.
-func (mock *QuerierMock) GetBatchStatusAndOutputFiles(ctx context.Context, id uuid.UUID) (batchsqlc.GetBatchStatusAndOutputFilesRow, error) {
-	if mock.GetBatchStatusAndOutputFilesFunc == nil {
-		panic("QuerierMock.GetBatchStatusAndOutputFilesFunc: method is nil but Querier.GetBatchStatusAndOutputFiles was just called")
+// ReopenBatch calls ReopenBatchFunc.
+func (mock *QuerierMock) ReopenBatch(ctx context.Context, id uuid.UUID) error {
+	if mock.ReopenBatchFunc == nil {
+		panic("QuerierMock.ReopenBatchFunc: method is nil but Querier.ReopenBatch was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
@@ -730,17 +2719,17 @@ func (mock *QuerierMock) GetBatchStatusAndOutputFiles(ctx context.Context, id uu
 		Ctx: ctx,
 		ID:  id,
 	}
-	mock.lockGetBatchStatusAndOutputFiles.Lock()
-	mock.calls.GetBatchStatusAndOutputFiles = append(mock.calls.GetBatchStatusAndOutputFiles, callInfo)
-	mock.lockGetBatchStatusAndOutputFiles.Unlock()
-	return mock.GetBatchStatusAndOutputFilesFunc(ctx, id)
+	mock.lockReopenBatch.Lock()
+	mock.calls.ReopenBatch = append(mock.calls.ReopenBatch, callInfo)
+	mock.lockReopenBatch.Unlock()
+	return mock.ReopenBatchFunc(ctx, id)
 }
 
-// GetBatchStatusAndOutputFilesCalls gets all the calls that were made to GetBatchStatusAndOutputFiles.
+// ReopenBatchCalls gets all the calls that were made to ReopenBatch.
 // Check the length with:
 //
-//	len(mockedQuerier.GetBatchStatusAndOutputFilesCalls())
-func (mock *QuerierMock) GetBatchStatusAndOutputFilesCalls() []struct {
+//	len(mockedQuerier.ReopenBatchCalls())
+func (mock *QuerierMock) ReopenBatchCalls() []struct {
 	Ctx context.Context
 	ID  uuid.UUID
 } {
@@ -748,221 +2737,225 @@ func (mock *QuerierMock) GetBatchStatusAndOutputFilesCalls() []struct {
 		Ctx context.Context
 		ID  uuid.UUID
 	}
-	mock.lockGetBatchStatusAndOutputFiles.RLock()
-	calls = mock.calls.GetBatchStatusAndOutputFiles
-	mock.lockGetBatchStatusAndOutputFiles.RUnlock()
+	mock.lockReopenBatch.RLock()
+	calls = mock.calls.ReopenBatch
+	mock.lockReopenBatch.RUnlock()
 	return calls
 }
 
-// GetCompletedBatches calls GetCompletedBatchesFunc.
-func (mock *QuerierMock) GetCompletedBatches(ctx context.Context) ([]uuid.UUID, error) {
-	if mock.GetCompletedBatchesFunc == nil {
-		panic("QuerierMock.GetCompletedBatchesFunc: method is nil but Querier.GetCompletedBatches was just called")
+// RequeueDeadLetterRows calls RequeueDeadLetterRowsFunc.
+func (mock *QuerierMock) RequeueDeadLetterRows(ctx context.Context, rowids []int64) ([]batchsqlc.RequeueDeadLetterRowsRow, error) {
+	if mock.RequeueDeadLetterRowsFunc == nil {
+		panic("QuerierMock.RequeueDeadLetterRowsFunc: method is nil but Querier.RequeueDeadLetterRows was just called")
 	}
 	callInfo := struct {
-		Ctx context.Context
+		Ctx    context.Context
+		Rowids []int64
 	}{
-		Ctx: ctx,
+		Ctx:    ctx,
+		Rowids: rowids,
 	}
-	mock.lockGetCompletedBatches.Lock()
-	mock.calls.GetCompletedBatches = append(mock.calls.GetCompletedBatches, callInfo)
-	mock.lockGetCompletedBatches.Unlock()
-	return mock.GetCompletedBatchesFunc(ctx)
+	mock.lockRequeueDeadLetterRows.Lock()
+	mock.calls.RequeueDeadLetterRows = append(mock.calls.RequeueDeadLetterRows, callInfo)
+	mock.lockRequeueDeadLetterRows.Unlock()
+	return mock.RequeueDeadLetterRowsFunc(ctx, rowids)
 }
 
-// GetCompletedBatchesCalls gets all the calls that were made to GetCompletedBatches.
+// RequeueDeadLetterRowsCalls gets all the calls that were made to RequeueDeadLetterRows.
 // Check the length with:
 //
-//	len(mockedQuerier.GetCompletedBatchesCalls())
-func (mock *QuerierMock) GetCompletedBatchesCalls() []struct {
-	Ctx context.Context
+//	len(mockedQuerier.RequeueDeadLetterRowsCalls())
+func (mock *QuerierMock) RequeueDeadLetterRowsCalls() []struct {
+	Ctx    context.Context
+	Rowids []int64
 } {
 	var calls []struct {
-		Ctx context.Context
+		Ctx    context.Context
+		Rowids []int64
 	}
-	mock.lockGetCompletedBatches.RLock()
-	calls = mock.calls.GetCompletedBatches
-	mock.lockGetCompletedBatches.RUnlock()
+	mock.lockRequeueDeadLetterRows.RLock()
+	calls = mock.calls.RequeueDeadLetterRows
+	mock.lockRequeueDeadLetterRows.RUnlock()
 	return calls
 }
 
-// GetPendingBatchRows calls GetPendingBatchRowsFunc.
-func (mock *QuerierMock) GetPendingBatchRows(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetPendingBatchRowsRow, error) {
-	if mock.GetPendingBatchRowsFunc == nil {
-		panic("QuerierMock.GetPendingBatchRowsFunc: method is nil but Querier.GetPendingBatchRows was just called")
+// RetryBatchRow calls RetryBatchRowFunc.
+func (mock *QuerierMock) RetryBatchRow(ctx context.Context, arg batchsqlc.RetryBatchRowParams) error {
+	if mock.RetryBatchRowFunc == nil {
+		panic("QuerierMock.RetryBatchRowFunc: method is nil but Querier.RetryBatchRow was just called")
 	}
 	callInfo := struct {
-		Ctx   context.Context
-		Batch uuid.UUID
+		Ctx context.Context
+		Arg batchsqlc.RetryBatchRowParams
 	}{
-		Ctx:   ctx,
-		Batch: batch,
+		Ctx: ctx,
+		Arg: arg,
 	}
-	mock.lockGetPendingBatchRows.Lock()
-	mock.calls.GetPendingBatchRows = append(mock.calls.GetPendingBatchRows, callInfo)
-	mock.lockGetPendingBatchRows.Unlock()
-	return mock.GetPendingBatchRowsFunc(ctx, batch)
+	mock.lockRetryBatchRow.Lock()
+	mock.calls.RetryBatchRow = append(mock.calls.RetryBatchRow, callInfo)
+	mock.lockRetryBatchRow.Unlock()
+	return mock.RetryBatchRowFunc(ctx, arg)
 }
 
-// GetPendingBatchRowsCalls gets all the calls that were made to GetPendingBatchRows.
+// RetryBatchRowCalls gets all the calls that were made to RetryBatchRow.
 // Check the length with:
 //
-//	len(mockedQuerier.GetPendingBatchRowsCalls())
-func (mock *QuerierMock) GetPendingBatchRowsCalls() []struct {
-	Ctx   context.Context
-	Batch uuid.UUID
+//	len(mockedQuerier.RetryBatchRowCalls())
+func (mock *QuerierMock) RetryBatchRowCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.RetryBatchRowParams
 } {
 	var calls []struct {
-		Ctx   context.Context
-		Batch uuid.UUID
+		Ctx context.Context
+		Arg batchsqlc.RetryBatchRowParams
 	}
-	mock.lockGetPendingBatchRows.RLock()
-	calls = mock.calls.GetPendingBatchRows
-	mock.lockGetPendingBatchRows.RUnlock()
+	mock.lockRetryBatchRow.RLock()
+	calls = mock.calls.RetryBatchRow
+	mock.lockRetryBatchRow.RUnlock()
 	return calls
 }
 
-// GetProcessedBatchRowsByBatchIDSorted calls GetProcessedBatchRowsByBatchIDSortedFunc.
-func (mock *QuerierMock) GetProcessedBatchRowsByBatchIDSorted(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow, error) {
-	if mock.GetProcessedBatchRowsByBatchIDSortedFunc == nil {
-		panic("QuerierMock.GetProcessedBatchRowsByBatchIDSortedFunc: method is nil but Querier.GetProcessedBatchRowsByBatchIDSorted was just called")
+// RetryBatchRows calls RetryBatchRowsFunc.
+func (mock *QuerierMock) RetryBatchRows(ctx context.Context, arg batchsqlc.RetryBatchRowsParams) ([]batchsqlc.RetryBatchRowsRow, error) {
+	if mock.RetryBatchRowsFunc == nil {
+		panic("QuerierMock.RetryBatchRowsFunc: method is nil but Querier.RetryBatchRows was just called")
 	}
 	callInfo := struct {
-		Ctx   context.Context
-		Batch uuid.UUID
+		Ctx context.Context
+		Arg batchsqlc.RetryBatchRowsParams
 	}{
-		Ctx:   ctx,
-		Batch: batch,
+		Ctx: ctx,
+		Arg: arg,
 	}
-	mock.lockGetProcessedBatchRowsByBatchIDSorted.Lock()
-	mock.calls.GetProcessedBatchRowsByBatchIDSorted = append(mock.calls.GetProcessedBatchRowsByBatchIDSorted, callInfo)
-	mock.lockGetProcessedBatchRowsByBatchIDSorted.Unlock()
-	return mock.GetProcessedBatchRowsByBatchIDSortedFunc(ctx, batch)
+	mock.lockRetryBatchRows.Lock()
+	mock.calls.RetryBatchRows = append(mock.calls.RetryBatchRows, callInfo)
+	mock.lockRetryBatchRows.Unlock()
+	return mock.RetryBatchRowsFunc(ctx, arg)
 }
 
-// GetProcessedBatchRowsByBatchIDSortedCalls gets all the calls that were made to GetProcessedBatchRowsByBatchIDSorted.
+// RetryBatchRowsCalls gets all the calls that were made to RetryBatchRows.
 // Check the length with:
 //
-//	len(mockedQuerier.GetProcessedBatchRowsByBatchIDSortedCalls())
-func (mock *QuerierMock) GetProcessedBatchRowsByBatchIDSortedCalls() []struct {
-	Ctx   context.Context
-	Batch uuid.UUID
+//	len(mockedQuerier.RetryBatchRowsCalls())
+func (mock *QuerierMock) RetryBatchRowsCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.RetryBatchRowsParams
 } {
 	var calls []struct {
-		Ctx   context.Context
-		Batch uuid.UUID
+		Ctx context.Context
+		Arg batchsqlc.RetryBatchRowsParams
 	}
-	mock.lockGetProcessedBatchRowsByBatchIDSorted.RLock()
-	calls = mock.calls.GetProcessedBatchRowsByBatchIDSorted
-	mock.lockGetProcessedBatchRowsByBatchIDSorted.RUnlock()
+	mock.lockRetryBatchRows.RLock()
+	calls = mock.calls.RetryBatchRows
+	mock.lockRetryBatchRows.RUnlock()
 	return calls
 }
 
-// InsertBatchFile calls InsertBatchFileFunc.
-func (mock *QuerierMock) InsertBatchFile(ctx context.Context, arg batchsqlc.InsertBatchFileParams) error {
-	if mock.InsertBatchFileFunc == nil {
-		panic("QuerierMock.InsertBatchFileFunc: method is nil but Querier.InsertBatchFile was just called")
+// SetBatchStatus calls SetBatchStatusFunc.
+func (mock *QuerierMock) SetBatchStatus(ctx context.Context, arg batchsqlc.SetBatchStatusParams) error {
+	if mock.SetBatchStatusFunc == nil {
+		panic("QuerierMock.SetBatchStatusFunc: method is nil but Querier.SetBatchStatus was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
-		Arg batchsqlc.InsertBatchFileParams
+		Arg batchsqlc.SetBatchStatusParams
 	}{
 		Ctx: ctx,
 		Arg: arg,
 	}
-	mock.lockInsertBatchFile.Lock()
-	mock.calls.InsertBatchFile = append(mock.calls.InsertBatchFile, callInfo)
-	mock.lockInsertBatchFile.Unlock()
-	return mock.InsertBatchFileFunc(ctx, arg)
+	mock.lockSetBatchStatus.Lock()
+	mock.calls.SetBatchStatus = append(mock.calls.SetBatchStatus, callInfo)
+	mock.lockSetBatchStatus.Unlock()
+	return mock.SetBatchStatusFunc(ctx, arg)
 }
 
-// InsertBatchFileCalls gets all the calls that were made to InsertBatchFile.
+// SetBatchStatusCalls gets all the calls that were made to SetBatchStatus.
 // Check the length with:
 //
-//	len(mockedQuerier.InsertBatchFileCalls())
-func (mock *QuerierMock) InsertBatchFileCalls() []struct {
+//	len(mockedQuerier.SetBatchStatusCalls())
+func (mock *QuerierMock) SetBatchStatusCalls() []struct {
 	Ctx context.Context
-	Arg batchsqlc.InsertBatchFileParams
+	Arg batchsqlc.SetBatchStatusParams
 } {
 	var calls []struct {
 		Ctx context.Context
-		Arg batchsqlc.InsertBatchFileParams
+		Arg batchsqlc.SetBatchStatusParams
 	}
-	mock.lockInsertBatchFile.RLock()
-	calls = mock.calls.InsertBatchFile
-	mock.lockInsertBatchFile.RUnlock()
+	mock.lockSetBatchStatus.RLock()
+	calls = mock.calls.SetBatchStatus
+	mock.lockSetBatchStatus.RUnlock()
 	return calls
 }
 
-// InsertIntoBatchRows calls InsertIntoBatchRowsFunc.
-func (mock *QuerierMock) InsertIntoBatchRows(ctx context.Context, arg batchsqlc.InsertIntoBatchRowsParams) error {
-	if mock.InsertIntoBatchRowsFunc == nil {
-		panic("QuerierMock.InsertIntoBatchRowsFunc: method is nil but Querier.InsertIntoBatchRows was just called")
+// UpdateBatchAggregate calls UpdateBatchAggregateFunc.
+func (mock *QuerierMock) UpdateBatchAggregate(ctx context.Context, arg batchsqlc.UpdateBatchAggregateParams) error {
+	if mock.UpdateBatchAggregateFunc == nil {
+		panic("QuerierMock.UpdateBatchAggregateFunc: method is nil but Querier.UpdateBatchAggregate was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
-		Arg batchsqlc.InsertIntoBatchRowsParams
+		Arg batchsqlc.UpdateBatchAggregateParams
 	}{
 		Ctx: ctx,
 		Arg: arg,
 	}
-	mock.lockInsertIntoBatchRows.Lock()
-	mock.calls.InsertIntoBatchRows = append(mock.calls.InsertIntoBatchRows, callInfo)
-	mock.lockInsertIntoBatchRows.Unlock()
-	return mock.InsertIntoBatchRowsFunc(ctx, arg)
+	mock.lockUpdateBatchAggregate.Lock()
+	mock.calls.UpdateBatchAggregate = append(mock.calls.UpdateBatchAggregate, callInfo)
+	mock.lockUpdateBatchAggregate.Unlock()
+	return mock.UpdateBatchAggregateFunc(ctx, arg)
 }
 
-// InsertIntoBatchRowsCalls gets all the calls that were made to InsertIntoBatchRows.
+// UpdateBatchAggregateCalls gets all the calls that were made to UpdateBatchAggregate.
 // Check the length with:
 //
-//	len(mockedQuerier.InsertIntoBatchRowsCalls())
-func (mock *QuerierMock) InsertIntoBatchRowsCalls() []struct {
+//	len(mockedQuerier.UpdateBatchAggregateCalls())
+func (mock *QuerierMock) UpdateBatchAggregateCalls() []struct {
 	Ctx context.Context
-	Arg batchsqlc.InsertIntoBatchRowsParams
+	Arg batchsqlc.UpdateBatchAggregateParams
 } {
 	var calls []struct {
 		Ctx context.Context
-		Arg batchsqlc.InsertIntoBatchRowsParams
+		Arg batchsqlc.UpdateBatchAggregateParams
 	}
-	mock.lockInsertIntoBatchRows.RLock()
-	calls = mock.calls.InsertIntoBatchRows
-	mock.lockInsertIntoBatchRows.RUnlock()
+	mock.lockUpdateBatchAggregate.RLock()
+	calls = mock.calls.UpdateBatchAggregate
+	mock.lockUpdateBatchAggregate.RUnlock()
 	return calls
 }
 
-// InsertIntoBatches calls InsertIntoBatchesFunc.
-func (mock *QuerierMock) InsertIntoBatches(ctx context.Context, arg batchsqlc.InsertIntoBatchesParams) (uuid.UUID, error) {
-	if mock.InsertIntoBatchesFunc == nil {
-		panic("QuerierMock.InsertIntoBatchesFunc: method is nil but Querier.InsertIntoBatches was just called")
+// UpdateBatchAggregateErr calls UpdateBatchAggregateErrFunc.
+func (mock *QuerierMock) UpdateBatchAggregateErr(ctx context.Context, arg batchsqlc.UpdateBatchAggregateErrParams) error {
+	if mock.UpdateBatchAggregateErrFunc == nil {
+		panic("QuerierMock.UpdateBatchAggregateErrFunc: method is nil but Querier.UpdateBatchAggregateErr was just called")
 	}
 	callInfo := struct {
 		Ctx context.Context
-		Arg batchsqlc.InsertIntoBatchesParams
+		Arg batchsqlc.UpdateBatchAggregateErrParams
 	}{
 		Ctx: ctx,
 		Arg: arg,
 	}
-	mock.lockInsertIntoBatches.Lock()
-	mock.calls.InsertIntoBatches = append(mock.calls.InsertIntoBatches, callInfo)
-	mock.lockInsertIntoBatches.Unlock()
-	return mock.InsertIntoBatchesFunc(ctx, arg)
+	mock.lockUpdateBatchAggregateErr.Lock()
+	mock.calls.UpdateBatchAggregateErr = append(mock.calls.UpdateBatchAggregateErr, callInfo)
+	mock.lockUpdateBatchAggregateErr.Unlock()
+	return mock.UpdateBatchAggregateErrFunc(ctx, arg)
 }
 
-// InsertIntoBatchesCalls gets all the calls that were made to InsertIntoBatches.
+// UpdateBatchAggregateErrCalls gets all the calls that were made to UpdateBatchAggregateErr.
 // Check the length with:
 //
-//	len(mockedQuerier.InsertIntoBatchesCalls())
-func (mock *QuerierMock) InsertIntoBatchesCalls() []struct {
+//	len(mockedQuerier.UpdateBatchAggregateErrCalls())
+func (mock *QuerierMock) UpdateBatchAggregateErrCalls() []struct {
 	Ctx context.Context
-	Arg batchsqlc.InsertIntoBatchesParams
+	Arg batchsqlc.UpdateBatchAggregateErrParams
 } {
 	var calls []struct {
 		Ctx context.Context
-		Arg batchsqlc.InsertIntoBatchesParams
+		Arg batchsqlc.UpdateBatchAggregateErrParams
 	}
-	mock.lockInsertIntoBatches.RLock()
-	calls = mock.calls.InsertIntoBatches
-	mock.lockInsertIntoBatches.RUnlock()
+	mock.lockUpdateBatchAggregateErr.RLock()
+	calls = mock.calls.UpdateBatchAggregateErr
+	mock.lockUpdateBatchAggregateErr.RUnlock()
 	return calls
 }
 
@@ -1038,6 +3031,42 @@ func (mock *QuerierMock) UpdateBatchOutputFilesCalls() []struct {
 	return calls
 }
 
+// UpdateBatchProgress calls UpdateBatchProgressFunc.
+func (mock *QuerierMock) UpdateBatchProgress(ctx context.Context, arg batchsqlc.UpdateBatchProgressParams) error {
+	if mock.UpdateBatchProgressFunc == nil {
+		panic("QuerierMock.UpdateBatchProgressFunc: method is nil but Querier.UpdateBatchProgress was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.UpdateBatchProgressParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockUpdateBatchProgress.Lock()
+	mock.calls.UpdateBatchProgress = append(mock.calls.UpdateBatchProgress, callInfo)
+	mock.lockUpdateBatchProgress.Unlock()
+	return mock.UpdateBatchProgressFunc(ctx, arg)
+}
+
+// UpdateBatchProgressCalls gets all the calls that were made to UpdateBatchProgress.
+// Check the length with:
+//
+//	len(mockedQuerier.UpdateBatchProgressCalls())
+func (mock *QuerierMock) UpdateBatchProgressCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.UpdateBatchProgressParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.UpdateBatchProgressParams
+	}
+	mock.lockUpdateBatchProgress.RLock()
+	calls = mock.calls.UpdateBatchProgress
+	mock.lockUpdateBatchProgress.RUnlock()
+	return calls
+}
+
 // UpdateBatchResult calls UpdateBatchResultFunc.
 func (mock *QuerierMock) UpdateBatchResult(ctx context.Context, arg batchsqlc.UpdateBatchResultParams) error {
 	if mock.UpdateBatchResultFunc == nil {
@@ -1110,44 +3139,8 @@ func (mock *QuerierMock) UpdateBatchRowStatusCalls() []struct {
 	return calls
 }
 
-// UpdateBatchRowsBatchJob calls UpdateBatchRowsBatchJobFunc.
-func (mock *QuerierMock) UpdateBatchRowsBatchJob(ctx context.Context, arg batchsqlc.UpdateBatchRowsBatchJobParams) error {
-	if mock.UpdateBatchRowsBatchJobFunc == nil {
-		panic("QuerierMock.UpdateBatchRowsBatchJobFunc: method is nil but Querier.UpdateBatchRowsBatchJob was just called")
-	}
-	callInfo := struct {
-		Ctx context.Context
-		Arg batchsqlc.UpdateBatchRowsBatchJobParams
-	}{
-		Ctx: ctx,
-		Arg: arg,
-	}
-	mock.lockUpdateBatchRowsBatchJob.Lock()
-	mock.calls.UpdateBatchRowsBatchJob = append(mock.calls.UpdateBatchRowsBatchJob, callInfo)
-	mock.lockUpdateBatchRowsBatchJob.Unlock()
-	return mock.UpdateBatchRowsBatchJobFunc(ctx, arg)
-}
-
-// UpdateBatchRowsBatchJobCalls gets all the calls that were made to UpdateBatchRowsBatchJob.
-// Check the length with:
-//
-//	len(mockedQuerier.UpdateBatchRowsBatchJobCalls())
-func (mock *QuerierMock) UpdateBatchRowsBatchJobCalls() []struct {
-	Ctx context.Context
-	Arg batchsqlc.UpdateBatchRowsBatchJobParams
-} {
-	var calls []struct {
-		Ctx context.Context
-		Arg batchsqlc.UpdateBatchRowsBatchJobParams
-	}
-	mock.lockUpdateBatchRowsBatchJob.RLock()
-	calls = mock.calls.UpdateBatchRowsBatchJob
-	mock.lockUpdateBatchRowsBatchJob.RUnlock()
-	return calls
-}
-
 // UpdateBatchRowsSlowQuery calls UpdateBatchRowsSlowQueryFunc.
-func (mock *QuerierMock) UpdateBatchRowsSlowQuery(ctx context.Context, arg batchsqlc.UpdateBatchRowsSlowQueryParams) error {
+func (mock *QuerierMock) UpdateBatchRowsSlowQuery(ctx context.Context, arg batchsqlc.UpdateBatchRowsSlowQueryParams) (int64, error) {
 	if mock.UpdateBatchRowsSlowQueryFunc == nil {
 		panic("QuerierMock.UpdateBatchRowsSlowQueryFunc: method is nil but Querier.UpdateBatchRowsSlowQuery was just called")
 	}
@@ -1325,3 +3318,111 @@ func (mock *QuerierMock) UpdateBatchSummaryOnAbortCalls() []struct {
 	mock.lockUpdateBatchSummaryOnAbort.RUnlock()
 	return calls
 }
+
+// UpdatePipelineStatus calls UpdatePipelineStatusFunc.
+func (mock *QuerierMock) UpdatePipelineStatus(ctx context.Context, arg batchsqlc.UpdatePipelineStatusParams) error {
+	if mock.UpdatePipelineStatusFunc == nil {
+		panic("QuerierMock.UpdatePipelineStatusFunc: method is nil but Querier.UpdatePipelineStatus was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.UpdatePipelineStatusParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockUpdatePipelineStatus.Lock()
+	mock.calls.UpdatePipelineStatus = append(mock.calls.UpdatePipelineStatus, callInfo)
+	mock.lockUpdatePipelineStatus.Unlock()
+	return mock.UpdatePipelineStatusFunc(ctx, arg)
+}
+
+// UpdatePipelineStatusCalls gets all the calls that were made to UpdatePipelineStatus.
+// Check the length with:
+//
+//	len(mockedQuerier.UpdatePipelineStatusCalls())
+func (mock *QuerierMock) UpdatePipelineStatusCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.UpdatePipelineStatusParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.UpdatePipelineStatusParams
+	}
+	mock.lockUpdatePipelineStatus.RLock()
+	calls = mock.calls.UpdatePipelineStatus
+	mock.lockUpdatePipelineStatus.RUnlock()
+	return calls
+}
+
+// UpdateRecurringJobRun calls UpdateRecurringJobRunFunc.
+func (mock *QuerierMock) UpdateRecurringJobRun(ctx context.Context, arg batchsqlc.UpdateRecurringJobRunParams) error {
+	if mock.UpdateRecurringJobRunFunc == nil {
+		panic("QuerierMock.UpdateRecurringJobRunFunc: method is nil but Querier.UpdateRecurringJobRun was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.UpdateRecurringJobRunParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockUpdateRecurringJobRun.Lock()
+	mock.calls.UpdateRecurringJobRun = append(mock.calls.UpdateRecurringJobRun, callInfo)
+	mock.lockUpdateRecurringJobRun.Unlock()
+	return mock.UpdateRecurringJobRunFunc(ctx, arg)
+}
+
+// UpdateRecurringJobRunCalls gets all the calls that were made to UpdateRecurringJobRun.
+// Check the length with:
+//
+//	len(mockedQuerier.UpdateRecurringJobRunCalls())
+func (mock *QuerierMock) UpdateRecurringJobRunCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.UpdateRecurringJobRunParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.UpdateRecurringJobRunParams
+	}
+	mock.lockUpdateRecurringJobRun.RLock()
+	calls = mock.calls.UpdateRecurringJobRun
+	mock.lockUpdateRecurringJobRun.RUnlock()
+	return calls
+}
+
+// UpsertRecurringJob calls UpsertRecurringJobFunc.
+func (mock *QuerierMock) UpsertRecurringJob(ctx context.Context, arg batchsqlc.UpsertRecurringJobParams) error {
+	if mock.UpsertRecurringJobFunc == nil {
+		panic("QuerierMock.UpsertRecurringJobFunc: method is nil but Querier.UpsertRecurringJob was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Arg batchsqlc.UpsertRecurringJobParams
+	}{
+		Ctx: ctx,
+		Arg: arg,
+	}
+	mock.lockUpsertRecurringJob.Lock()
+	mock.calls.UpsertRecurringJob = append(mock.calls.UpsertRecurringJob, callInfo)
+	mock.lockUpsertRecurringJob.Unlock()
+	return mock.UpsertRecurringJobFunc(ctx, arg)
+}
+
+// UpsertRecurringJobCalls gets all the calls that were made to UpsertRecurringJob.
+// Check the length with:
+//
+//	len(mockedQuerier.UpsertRecurringJobCalls())
+func (mock *QuerierMock) UpsertRecurringJobCalls() []struct {
+	Ctx context.Context
+	Arg batchsqlc.UpsertRecurringJobParams
+} {
+	var calls []struct {
+		Ctx context.Context
+		Arg batchsqlc.UpsertRecurringJobParams
+	}
+	mock.lockUpsertRecurringJob.RLock()
+	calls = mock.calls.UpsertRecurringJob
+	mock.lockUpsertRecurringJob.RUnlock()
+	return calls
+}
@@ -15,12 +15,14 @@ import (
 type StatusEnum string
 
 const (
-	StatusEnumQueued  StatusEnum = "queued"
-	StatusEnumInprog  StatusEnum = "inprog"
-	StatusEnumSuccess StatusEnum = "success"
-	StatusEnumFailed  StatusEnum = "failed"
-	StatusEnumAborted StatusEnum = "aborted"
-	StatusEnumWait    StatusEnum = "wait"
+	StatusEnumQueued     StatusEnum = "queued"
+	StatusEnumInprog     StatusEnum = "inprog"
+	StatusEnumSuccess    StatusEnum = "success"
+	StatusEnumFailed     StatusEnum = "failed"
+	StatusEnumAborted    StatusEnum = "aborted"
+	StatusEnumWait       StatusEnum = "wait"
+	StatusEnumDeadletter StatusEnum = "deadletter"
+	StatusEnumPaused     StatusEnum = "paused"
 )
 
 func (e *StatusEnum) Scan(src interface{}) error {
@@ -59,19 +61,36 @@ func (ns NullStatusEnum) Value() (driver.Value, error) {
 }
 
 type Batch struct {
-	ID          uuid.UUID        `json:"id"`
-	App         string           `json:"app"`
-	Op          string           `json:"op"`
-	Context     []byte           `json:"context"`
-	Inputfile   pgtype.Text      `json:"inputfile"`
-	Status      StatusEnum       `json:"status"`
-	Reqat       pgtype.Timestamp `json:"reqat"`
-	Doneat      pgtype.Timestamp `json:"doneat"`
-	Outputfiles []byte           `json:"outputfiles"`
-	Nsuccess    pgtype.Int4      `json:"nsuccess"`
-	Nfailed     pgtype.Int4      `json:"nfailed"`
-	Naborted    pgtype.Int4      `json:"naborted"`
-	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	ID             uuid.UUID        `json:"id"`
+	App            string           `json:"app"`
+	Op             string           `json:"op"`
+	Context        []byte           `json:"context"`
+	Inputfile      pgtype.Text      `json:"inputfile"`
+	Status         StatusEnum       `json:"status"`
+	Reqat          pgtype.Timestamp `json:"reqat"`
+	Doneat         pgtype.Timestamp `json:"doneat"`
+	Outputfiles    []byte           `json:"outputfiles"`
+	Nsuccess       pgtype.Int4      `json:"nsuccess"`
+	Nfailed        pgtype.Int4      `json:"nfailed"`
+	Naborted       pgtype.Int4      `json:"naborted"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	Priority       int32            `json:"priority"`
+	Notbefore      pgtype.Timestamp `json:"notbefore"`
+	Nrows          int32            `json:"nrows"`
+	Idempotencykey pgtype.Text      `json:"idempotencykey"`
+	Payloadhash    []byte           `json:"payloadhash"`
+	// The aggregate of the results of the rows of a batch, kept up to date as the rows finish by the
+	// BatchReducer registered for the (app, op) of the batch, if any
+	Aggregate []byte `json:"aggregate"`
+	// Time at which the first rows of the batch were fetched for processing
+	Startedat pgtype.Timestamp `json:"startedat"`
+	// Progress last reported with ReportProgress by the processor of a slow query which is running
+	Progress []byte `json:"progress"`
+	// Hash of the context and input of a memoized slow query, cleared when it is invalidated
+	Memohash []byte `json:"memohash"`
+	// The error returned by the BatchReducer of a batch when it last failed to reduce the results of its
+	// rows, in which case the aggregate does not cover all of them
+	Aggregateerr pgtype.Text `json:"aggregateerr"`
 }
 
 // Stores metadata for files associated with batch jobs
@@ -104,16 +123,87 @@ type BatchFile struct {
 }
 
 type Batchrow struct {
-	Rowid     int64            `json:"rowid"`
-	Batch     uuid.UUID        `json:"batch"`
-	Line      int32            `json:"line"`
-	Input     []byte           `json:"input"`
-	Status    StatusEnum       `json:"status"`
-	Reqat     pgtype.Timestamp `json:"reqat"`
-	Doneat    pgtype.Timestamp `json:"doneat"`
-	Res       []byte           `json:"res"`
-	Blobrows  []byte           `json:"blobrows"`
-	Messages  []byte           `json:"messages"`
-	Doneby    pgtype.Text      `json:"doneby"`
-	CreatedAt pgtype.Timestamp `json:"created_at"`
+	Rowid       int64            `json:"rowid"`
+	Batch       uuid.UUID        `json:"batch"`
+	Line        int32            `json:"line"`
+	Input       []byte           `json:"input"`
+	Status      StatusEnum       `json:"status"`
+	Reqat       pgtype.Timestamp `json:"reqat"`
+	Doneat      pgtype.Timestamp `json:"doneat"`
+	Res         []byte           `json:"res"`
+	Blobrows    []byte           `json:"blobrows"`
+	Messages    []byte           `json:"messages"`
+	Doneby      pgtype.Text      `json:"doneby"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	Leaseexpiry pgtype.Timestamp `json:"leaseexpiry"`
+	Attempts    int32            `json:"attempts"`
+	Nexttry     pgtype.Timestamp `json:"nexttry"`
+	Lasterr     pgtype.Text      `json:"lasterr"`
+	Errtrace    pgtype.Text      `json:"errtrace"`
+	Runno       int32            `json:"runno"`
+	// Partition key of the row; the rows of a batch with the same key are processed one at a time in line order
+	Partkey pgtype.Text `json:"partkey"`
+}
+
+// Results of batch rows replaced by BatchRetry
+type Batchrowresult struct {
+	Rowid int64 `json:"rowid"`
+	// Run of the row which produced the result
+	Runno    int32            `json:"runno"`
+	Batch    uuid.UUID        `json:"batch"`
+	Line     int32            `json:"line"`
+	Status   StatusEnum       `json:"status"`
+	Doneat   pgtype.Timestamp `json:"doneat"`
+	Res      []byte           `json:"res"`
+	Blobrows []byte           `json:"blobrows"`
+	Messages []byte           `json:"messages"`
+	Doneby   pgtype.Text      `json:"doneby"`
+}
+
+// Pipelines of batches submitted with PipelineSubmit
+type Pipeline struct {
+	ID uuid.UUID `json:"id"`
+	// Name with which the steps of the pipeline were registered with RegisterPipeline
+	Name string `json:"name"`
+	// Context passed to PipelineSubmit, which is passed to the mapping of each step
+	Context []byte `json:"context"`
+	// Priority of the batch of each step
+	Priority int32      `json:"priority"`
+	Status   StatusEnum `json:"status"`
+	// Index of the current step of the pipeline, from 0
+	Step   int32            `json:"step"`
+	Reqat  pgtype.Timestamp `json:"reqat"`
+	Doneat pgtype.Timestamp `json:"doneat"`
+}
+
+// Batches submitted for the steps of pipelines
+type Pipelinestep struct {
+	Pipeline uuid.UUID `json:"pipeline"`
+	Step     int32     `json:"step"`
+	Batch    uuid.UUID `json:"batch"`
+}
+
+// Recurring batches registered with RegisterRecurringBatch
+type Recurringjob struct {
+	Name string `json:"name"`
+	App  string `json:"app"`
+	Op   string `json:"op"`
+	// Cron expression giving the ticks at which a batch is submitted
+	Cronspec string `json:"cronspec"`
+	// Next tick for which a batch is to be submitted
+	Nextrun pgtype.Timestamp `json:"nextrun"`
+	// Last tick for which a batch was submitted
+	Lastrun pgtype.Timestamp `json:"lastrun"`
+	// Batch submitted for the last tick, NULL if there was no input for it
+	Lastbatch pgtype.UUID `json:"lastbatch"`
+}
+
+// JobManager instances and the time of their last heartbeat
+type Worker struct {
+	// Worker ID, also recorded in batchrows.doneby for the rows leased by this worker
+	ID string `json:"id"`
+	// Timestamp when the worker started
+	Startedat pgtype.Timestamp `json:"startedat"`
+	// Timestamp of the last heartbeat of the worker
+	Heartbeat pgtype.Timestamp `json:"heartbeat"`
 }
@@ -8,35 +8,119 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	// The number of rows of each batch is counted in batches.nrows. Rows without a partition key have
+	// an empty one in @partkey.
 	BulkInsertIntoBatchRows(ctx context.Context, arg BulkInsertIntoBatchRowsParams) (int64, error)
+	// Records the results of a block of rows in one statement, and adds them to the counters of their
+	// batches; rows no longer leased to doneby are skipped. It returns the rowids of the rows updated.
+	BulkUpdateBatchRowsBatchJob(ctx context.Context, arg BulkUpdateBatchRowsBatchJobParams) ([]int64, error)
+	// The rows of a batch submitted as a stream have all been copied in, which does not count them
+	CloseBatchInput(ctx context.Context, arg CloseBatchInputParams) error
+	// Rows copied in are queued, but not counted in batches.nrows; see CloseBatchInput
+	CopyIntoBatchRows(ctx context.Context, arg []CopyIntoBatchRowsParams) (int64, error)
 	CountBatchRowsByBatchIDAndStatus(ctx context.Context, arg CountBatchRowsByBatchIDAndStatusParams) (int64, error)
+	// The row is added to the nfailed counter of its batch. Nothing is updated if the row is no longer
+	// leased to doneby, such as when it was aborted or its lease was reclaimed.
+	DeadLetterBatchRow(ctx context.Context, arg DeadLetterBatchRowParams) (int64, error)
+	DeleteStaleWorkers(ctx context.Context, heartbeat pgtype.Timestamp) (int64, error)
+	DeleteWorker(ctx context.Context, id string) error
+	ExtendWorkerLeases(ctx context.Context, arg ExtendWorkerLeasesParams) (int64, error)
 	FetchBatchRowsForBatchDone(ctx context.Context, batch uuid.UUID) ([]FetchBatchRowsForBatchDoneRow, error)
+	// Rows are taken from the batches with the highest priority first. Within a priority, the first
+	// queued row of each app comes before the second of any app, and the apps take turns among their
+	// batches in the same way, so that small batches and slow queries are not stuck behind a large batch.
+	// A row with a partition key is only taken once the rows before it with the same key are done, so
+	// that they are processed one at a time in the order of their lines.
+	// The rows are ranked without locking them, and only the maxrows rows chosen are then locked. Rows
+	// locked or taken by another worker in the meantime are skipped, so fewer rows may be returned.
 	FetchBlockOfRows(ctx context.Context, arg FetchBlockOfRowsParams) ([]FetchBlockOfRowsRow, error)
+	// All the memoized slow queries of the (app, op) are forgotten if memohash is NULL
+	ForgetMemoizedQueries(ctx context.Context, arg ForgetMemoizedQueriesParams) (int64, error)
+	GetAbortedBatches(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
 	GetBatchByID(ctx context.Context, id uuid.UUID) (Batch, error)
+	GetBatchByIdempotencyKey(ctx context.Context, arg GetBatchByIdempotencyKeyParams) (GetBatchByIdempotencyKeyRow, error)
+	GetBatchCounts(ctx context.Context, id uuid.UUID) (GetBatchCountsRow, error)
+	// The counters and progress of a batch or slow query, read without locking it
+	GetBatchProgress(ctx context.Context, id uuid.UUID) (GetBatchProgressRow, error)
+	GetBatchRowResults(ctx context.Context, batch uuid.UUID) ([]Batchrowresult, error)
 	GetBatchRowsByBatchID(ctx context.Context, batch uuid.UUID) ([]Batchrow, error)
 	GetBatchRowsByBatchIDSorted(ctx context.Context, batch uuid.UUID) ([]GetBatchRowsByBatchIDSortedRow, error)
 	GetBatchRowsCount(ctx context.Context, batch uuid.UUID) (int64, error)
 	GetBatchStatus(ctx context.Context, id uuid.UUID) (StatusEnum, error)
 	GetBatchStatusAndOutputFiles(ctx context.Context, id uuid.UUID) (GetBatchStatusAndOutputFilesRow, error)
 	GetCompletedBatches(ctx context.Context) ([]uuid.UUID, error)
+	// The batches of the dead-lettered rows, in the order in which they are locked
+	GetDeadLetterBatches(ctx context.Context, rowids []int64) ([]uuid.UUID, error)
+	GetDeadLetterRow(ctx context.Context, rowid int64) (GetDeadLetterRowRow, error)
+	GetDueRecurringJob(ctx context.Context, arg GetDueRecurringJobParams) (GetDueRecurringJobRow, error)
+	// The latest slow query of the (app, op) with the memo hash which is pending, or which succeeded after
+	// doneafter
+	GetMemoizedQuery(ctx context.Context, arg GetMemoizedQueryParams) (uuid.UUID, error)
 	GetPendingBatchRows(ctx context.Context, batch uuid.UUID) ([]GetPendingBatchRowsRow, error)
+	GetPipelineByID(ctx context.Context, id uuid.UUID) (Pipeline, error)
+	GetPipelineSteps(ctx context.Context, pipeline uuid.UUID) ([]GetPipelineStepsRow, error)
+	// Returns a pipeline whose current batch is done, skipping those being advanced by another instance
+	GetPipelineToAdvance(ctx context.Context, arg GetPipelineToAdvanceParams) (GetPipelineToAdvanceRow, error)
 	GetProcessedBatchRowsByBatchIDSorted(ctx context.Context, batch uuid.UUID) ([]GetProcessedBatchRowsByBatchIDSortedRow, error)
 	InsertBatchFile(ctx context.Context, arg InsertBatchFileParams) error
+	// The number of rows of the batch is counted in batches.nrows
 	InsertIntoBatchRows(ctx context.Context, arg InsertIntoBatchRowsParams) error
+	// Nothing is inserted, and no row returned, if a batch of the (app, op) has the idempotency key
 	InsertIntoBatches(ctx context.Context, arg InsertIntoBatchesParams) (uuid.UUID, error)
+	InsertPipeline(ctx context.Context, arg InsertPipelineParams) error
+	// The step becomes the current step of its pipeline
+	InsertPipelineStep(ctx context.Context, arg InsertPipelineStepParams) error
+	LeaseBatchRows(ctx context.Context, arg LeaseBatchRowsParams) error
+	ListBatchRowResults(ctx context.Context, arg ListBatchRowResultsParams) ([]ListBatchRowResultsRow, error)
+	ListBatches(ctx context.Context, arg ListBatchesParams) ([]ListBatchesRow, error)
+	ListDeadLetterRows(ctx context.Context, arg ListDeadLetterRowsParams) ([]ListDeadLetterRowsRow, error)
+	ListSlowQueries(ctx context.Context, arg ListSlowQueriesParams) ([]ListSlowQueriesRow, error)
+	// A batch paused since its rows were fetched stays paused. The time at which the rows of a batch
+	// were first fetched is kept when it is queued again.
+	MarkBatchInprog(ctx context.Context, arg MarkBatchInprogParams) error
+	// The notification is delivered to the listening instances when the transaction commits
+	NotifyBatchQueued(ctx context.Context, arg NotifyBatchQueuedParams) error
+	// Rows are dead-lettered once they have been attempted as many times as the retry policy of their
+	// (app, op) allows, given by the app || op keys in policykeys and the matching policymaxattempts, or
+	// maxattempts times if it has none. Dead-lettered rows are added to the nfailed counter of their batches.
+	ReclaimExpiredLeases(ctx context.Context, arg ReclaimExpiredLeasesParams) ([]ReclaimExpiredLeasesRow, error)
+	RecordWorkerHeartbeat(ctx context.Context, arg RecordWorkerHeartbeatParams) error
+	// Rows leased by a worker which is shutting down are queued again, unless they were aborted or taken
+	// over by another worker since. The attempt counted when they were leased is taken back, since the
+	// rows were released rather than lost.
+	ReleaseBatchRows(ctx context.Context, arg ReleaseBatchRowsParams) error
+	// Rows of the batch have been queued again, so its counters are recounted from its rows. A batch
+	// which is paused or waiting keeps its status.
+	ReopenBatch(ctx context.Context, id uuid.UUID) error
+	RequeueDeadLetterRows(ctx context.Context, rowids []int64) ([]RequeueDeadLetterRowsRow, error)
+	RetryBatchRow(ctx context.Context, arg RetryBatchRowParams) error
+	// The current result of each row is saved in batchrowresults before the row is queued for its next run
+	RetryBatchRows(ctx context.Context, arg RetryBatchRowsParams) ([]RetryBatchRowsRow, error)
+	// Only the status is changed, since the counters of a batch are kept up to date as its rows finish
+	SetBatchStatus(ctx context.Context, arg SetBatchStatusParams) error
+	UpdateBatchAggregate(ctx context.Context, arg UpdateBatchAggregateParams) error
+	// Records the error returned by the reducer of a batch, or clears it once the aggregate is computed
+	// again from all the rows
+	UpdateBatchAggregateErr(ctx context.Context, arg UpdateBatchAggregateErrParams) error
 	UpdateBatchCounters(ctx context.Context, arg UpdateBatchCountersParams) error
 	UpdateBatchOutputFiles(ctx context.Context, arg UpdateBatchOutputFilesParams) error
+	// The progress of a slow query is only recorded until it is done
+	UpdateBatchProgress(ctx context.Context, arg UpdateBatchProgressParams) error
 	UpdateBatchResult(ctx context.Context, arg UpdateBatchResultParams) error
 	UpdateBatchRowStatus(ctx context.Context, arg UpdateBatchRowStatusParams) error
-	UpdateBatchRowsBatchJob(ctx context.Context, arg UpdateBatchRowsBatchJobParams) error
-	UpdateBatchRowsSlowQuery(ctx context.Context, arg UpdateBatchRowsSlowQueryParams) error
+	UpdateBatchRowsSlowQuery(ctx context.Context, arg UpdateBatchRowsSlowQueryParams) (int64, error)
 	UpdateBatchRowsStatus(ctx context.Context, arg UpdateBatchRowsStatusParams) error
 	UpdateBatchStatus(ctx context.Context, arg UpdateBatchStatusParams) error
 	UpdateBatchSummary(ctx context.Context, arg UpdateBatchSummaryParams) error
 	UpdateBatchSummaryOnAbort(ctx context.Context, arg UpdateBatchSummaryOnAbortParams) error
+	UpdatePipelineStatus(ctx context.Context, arg UpdatePipelineStatusParams) error
+	UpdateRecurringJobRun(ctx context.Context, arg UpdateRecurringJobRunParams) error
+	// The next tick of an existing recurring batch is kept unless its cron expression has changed
+	UpsertRecurringJob(ctx context.Context, arg UpsertRecurringJobParams) error
 }
 
 var _ Querier = (*Queries)(nil)
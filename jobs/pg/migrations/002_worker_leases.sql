@@ -0,0 +1,24 @@
+-- Lease held by a worker on a batchrows record while it is inprog. The worker which took the row
+-- is recorded in batchrows.doneby; rows whose lease has expired are requeued by the reaper.
+ALTER TABLE batchrows ADD COLUMN leaseexpiry TIMESTAMP WITHOUT TIME ZONE;
+
+-- Table to record the JobManager instances which are alive
+CREATE TABLE workers (
+    id VARCHAR(255) NOT NULL PRIMARY KEY,
+    startedat TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    heartbeat TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+COMMENT ON TABLE workers IS 'JobManager instances and the time of their last heartbeat';
+COMMENT ON COLUMN workers.id IS 'Worker ID, also recorded in batchrows.doneby for the rows leased by this worker';
+COMMENT ON COLUMN workers.startedat IS 'Timestamp when the worker started';
+COMMENT ON COLUMN workers.heartbeat IS 'Timestamp of the last heartbeat of the worker';
+
+-- Index for the reaper, which looks for inprog rows whose lease has expired
+CREATE INDEX idx_batchrows_inprog_leaseexpiry ON batchrows(leaseexpiry) WHERE status = 'inprog';
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS idx_batchrows_inprog_leaseexpiry;
+DROP TABLE IF EXISTS workers;
+ALTER TABLE batchrows DROP COLUMN IF EXISTS leaseexpiry;
@@ -0,0 +1,9 @@
+-- Number of times a batchrows record has been taken up for processing, and the earliest time at
+-- which it may be taken up again after a failed attempt (NULL means immediately)
+ALTER TABLE batchrows ADD COLUMN attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE batchrows ADD COLUMN nexttry TIMESTAMP WITHOUT TIME ZONE;
+
+---- create above / drop below ----
+
+ALTER TABLE batchrows DROP COLUMN IF EXISTS nexttry;
+ALTER TABLE batchrows DROP COLUMN IF EXISTS attempts;
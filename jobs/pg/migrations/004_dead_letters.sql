@@ -0,0 +1,20 @@
+-- Rows which panic in the processor, exhaust their retries or repeatedly lose their lease are moved
+-- to the deadletter status, with the error and (for panics) the stack trace captured for inspection
+ALTER TYPE status_enum ADD VALUE IF NOT EXISTS 'deadletter';
+
+ALTER TABLE batchrows ADD COLUMN lasterr TEXT;
+ALTER TABLE batchrows ADD COLUMN errtrace TEXT;
+
+---- create above / drop below ----
+
+-- Postgres cannot drop a value from an enum, so the type is recreated without it
+UPDATE batchrows SET status = 'failed' WHERE status = 'deadletter';
+ALTER TABLE batchrows DROP COLUMN IF EXISTS errtrace;
+ALTER TABLE batchrows DROP COLUMN IF EXISTS lasterr;
+DROP INDEX IF EXISTS idx_batchrows_inprog_leaseexpiry;
+ALTER TYPE status_enum RENAME TO status_enum_old;
+CREATE TYPE status_enum AS ENUM ('queued', 'inprog', 'success', 'failed', 'aborted', 'wait');
+ALTER TABLE batches ALTER COLUMN status TYPE status_enum USING status::text::status_enum;
+ALTER TABLE batchrows ALTER COLUMN status TYPE status_enum USING status::text::status_enum;
+DROP TYPE status_enum_old;
+CREATE INDEX idx_batchrows_inprog_leaseexpiry ON batchrows(leaseexpiry) WHERE status = 'inprog';
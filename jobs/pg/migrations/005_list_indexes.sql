@@ -0,0 +1,9 @@
+-- Indexes backing BatchList and SlowQueryList, which page through the batches of an app
+-- newest first, and count the rows of each batch
+CREATE INDEX idx_batches_app_reqat ON batches(app, reqat DESC, id DESC);
+CREATE INDEX idx_batchrows_batch_line ON batchrows(batch, line);
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS idx_batchrows_batch_line;
+DROP INDEX IF EXISTS idx_batches_app_reqat;
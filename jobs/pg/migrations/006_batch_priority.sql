@@ -0,0 +1,11 @@
+-- Batches with a higher priority are fetched first. Within a priority, FetchBlockOfRows takes rows
+-- round-robin across apps and the batches of each app, so that one large batch cannot starve the
+-- batches and slow queries submitted after it
+ALTER TABLE batches ADD COLUMN priority INT NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_batches_active_priority ON batches(priority DESC, reqat) WHERE status IN ('queued', 'inprog');
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS idx_batches_active_priority;
+ALTER TABLE batches DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,25 @@
+-- Batches and slow queries submitted with a "not before" time stay queued until then
+ALTER TABLE batches ADD COLUMN notbefore TIMESTAMP WITHOUT TIME ZONE;
+
+-- Table to record the schedule of recurring batches. The row of a recurring batch is locked by the
+-- instance submitting the batch for a tick, so that each tick is submitted once across the cluster
+CREATE TABLE recurringjobs (
+    name VARCHAR(255) NOT NULL PRIMARY KEY,
+    app VARCHAR(255) NOT NULL,
+    op VARCHAR(255) NOT NULL CHECK (op = LOWER(op)),
+    cronspec VARCHAR(255) NOT NULL,
+    nextrun TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    lastrun TIMESTAMP WITHOUT TIME ZONE,
+    lastbatch UUID
+);
+
+COMMENT ON TABLE recurringjobs IS 'Recurring batches registered with RegisterRecurringBatch';
+COMMENT ON COLUMN recurringjobs.cronspec IS 'Cron expression giving the ticks at which a batch is submitted';
+COMMENT ON COLUMN recurringjobs.nextrun IS 'Next tick for which a batch is to be submitted';
+COMMENT ON COLUMN recurringjobs.lastrun IS 'Last tick for which a batch was submitted';
+COMMENT ON COLUMN recurringjobs.lastbatch IS 'Batch submitted for the last tick, NULL if there was no input for it';
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS recurringjobs;
+ALTER TABLE batches DROP COLUMN IF EXISTS notbefore;
@@ -0,0 +1,28 @@
+-- BatchRetry queues the failed or aborted rows of a batch which is done again. The run of a row
+-- counts the times it has been queued this way, starting at 1 for the run in which it was submitted,
+-- and the results it had before each retry are kept in batchrowresults
+ALTER TABLE batchrows ADD COLUMN runno INT NOT NULL DEFAULT 1;
+
+CREATE TABLE batchrowresults (
+    rowid BIGINT NOT NULL REFERENCES batchrows(rowid) ON DELETE CASCADE,
+    runno INT NOT NULL,
+    batch UUID NOT NULL REFERENCES batches(id) ON DELETE CASCADE,
+    line INT NOT NULL,
+    status status_enum NOT NULL,
+    doneat TIMESTAMP WITHOUT TIME ZONE,
+    res JSONB,
+    blobrows JSONB,
+    messages JSONB,
+    doneby VARCHAR(255),
+    PRIMARY KEY (rowid, runno)
+);
+
+COMMENT ON TABLE batchrowresults IS 'Results of batch rows replaced by BatchRetry';
+COMMENT ON COLUMN batchrowresults.runno IS 'Run of the row which produced the result';
+
+CREATE INDEX idx_batchrowresults_batch_line ON batchrowresults(batch, line, runno);
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS batchrowresults;
+ALTER TABLE batchrows DROP COLUMN IF EXISTS runno;
@@ -0,0 +1,18 @@
+-- BatchPause moves a queued or inprog batch to the paused status, in which FetchBlockOfRows skips
+-- its rows until BatchResume puts it back in the queue
+ALTER TYPE status_enum ADD VALUE IF NOT EXISTS 'paused';
+
+---- create above / drop below ----
+
+-- Postgres cannot drop a value from an enum, so the type is recreated without it
+UPDATE batches SET status = 'queued' WHERE status = 'paused';
+DROP INDEX IF EXISTS idx_batches_active_priority;
+DROP INDEX IF EXISTS idx_batchrows_inprog_leaseexpiry;
+ALTER TYPE status_enum RENAME TO status_enum_old;
+CREATE TYPE status_enum AS ENUM ('queued', 'inprog', 'success', 'failed', 'aborted', 'wait', 'deadletter');
+ALTER TABLE batches ALTER COLUMN status TYPE status_enum USING status::text::status_enum;
+ALTER TABLE batchrows ALTER COLUMN status TYPE status_enum USING status::text::status_enum;
+ALTER TABLE batchrowresults ALTER COLUMN status TYPE status_enum USING status::text::status_enum;
+DROP TYPE status_enum_old;
+CREATE INDEX idx_batchrows_inprog_leaseexpiry ON batchrows(leaseexpiry) WHERE status = 'inprog';
+CREATE INDEX idx_batches_active_priority ON batches(priority DESC, reqat) WHERE status IN ('queued', 'inprog');
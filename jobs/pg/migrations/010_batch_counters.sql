@@ -0,0 +1,22 @@
+-- The counters of a batch are maintained as its rows finish, and nrows as rows are added to it, so
+-- that the JobManager can tell when a batch is done without counting its rows
+ALTER TABLE batches ADD COLUMN nrows INT NOT NULL DEFAULT 0;
+
+UPDATE batches
+SET nrows = (SELECT count(*) FROM batchrows WHERE batchrows.batch = batches.id);
+
+UPDATE batches
+SET nsuccess = c.nsuccess, nfailed = c.nfailed, naborted = c.naborted
+FROM (
+    SELECT batch,
+        count(*) FILTER (WHERE status = 'success')::int AS nsuccess,
+        count(*) FILTER (WHERE status IN ('failed', 'deadletter'))::int AS nfailed,
+        count(*) FILTER (WHERE status = 'aborted')::int AS naborted
+    FROM batchrows
+    GROUP BY batch
+) c
+WHERE batches.id = c.batch AND batches.doneat IS NULL;
+
+---- create above / drop below ----
+
+ALTER TABLE batches DROP COLUMN IF EXISTS nrows;
@@ -0,0 +1,7 @@
+-- Rows of a batch submitted as a stream are copied into batchrows without a status, so that COPY
+-- does not have to encode status_enum
+ALTER TABLE batchrows ALTER COLUMN status SET DEFAULT 'queued';
+
+---- create above / drop below ----
+
+ALTER TABLE batchrows ALTER COLUMN status DROP DEFAULT;
@@ -0,0 +1,12 @@
+-- A batch or slow query submitted with an idempotency key is submitted only once per (app, op); the
+-- hash of its payload tells a client retrying the same submission from one reusing the key for
+-- another
+ALTER TABLE batches ADD COLUMN idempotencykey TEXT;
+ALTER TABLE batches ADD COLUMN payloadhash BYTEA;
+CREATE UNIQUE INDEX idx_batches_idempotencykey ON batches(app, op, idempotencykey) WHERE idempotencykey IS NOT NULL;
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS idx_batches_idempotencykey;
+ALTER TABLE batches DROP COLUMN IF EXISTS payloadhash;
+ALTER TABLE batches DROP COLUMN IF EXISTS idempotencykey;
@@ -0,0 +1,36 @@
+-- Table to record the pipelines submitted with PipelineSubmit. The row of a pipeline is locked by the
+-- instance submitting the batch of its next step, so that each step is submitted once across the
+-- cluster
+CREATE TABLE pipelines (
+    id UUID NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    context JSONB NOT NULL,
+    priority INT NOT NULL DEFAULT 0,
+    status status_enum NOT NULL,
+    step INT NOT NULL DEFAULT 0,
+    reqat TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    doneat TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX idx_pipelines_inprog ON pipelines(name) WHERE status = 'inprog';
+
+COMMENT ON TABLE pipelines IS 'Pipelines of batches submitted with PipelineSubmit';
+COMMENT ON COLUMN pipelines.name IS 'Name with which the steps of the pipeline were registered with RegisterPipeline';
+COMMENT ON COLUMN pipelines.context IS 'Context passed to PipelineSubmit, which is passed to the mapping of each step';
+COMMENT ON COLUMN pipelines.priority IS 'Priority of the batch of each step';
+COMMENT ON COLUMN pipelines.step IS 'Index of the current step of the pipeline, from 0';
+
+-- Table to record the batch submitted for each step of a pipeline
+CREATE TABLE pipelinesteps (
+    pipeline UUID NOT NULL REFERENCES pipelines(id) ON DELETE CASCADE,
+    step INT NOT NULL,
+    batch UUID NOT NULL REFERENCES batches(id),
+    PRIMARY KEY (pipeline, step)
+);
+
+COMMENT ON TABLE pipelinesteps IS 'Batches submitted for the steps of pipelines';
+
+---- create above / drop below ----
+
+DROP TABLE IF EXISTS pipelinesteps;
+DROP TABLE IF EXISTS pipelines;
@@ -0,0 +1,7 @@
+-- The aggregate of the results of the rows of a batch, kept up to date as the rows finish by the
+-- BatchReducer registered for the (app, op) of the batch, if any
+ALTER TABLE batches ADD COLUMN aggregate JSONB;
+
+---- create above / drop below ----
+
+ALTER TABLE batches DROP COLUMN IF EXISTS aggregate;
@@ -0,0 +1,11 @@
+-- The rows of a batch which share a partition key are processed one at a time, in the order of their
+-- lines, so FetchBlockOfRows looks up the earlier rows with the key of each row
+ALTER TABLE batchrows ADD COLUMN partkey TEXT;
+CREATE INDEX idx_batchrows_partkey ON batchrows(batch, partkey, line) WHERE partkey IS NOT NULL;
+
+COMMENT ON COLUMN batchrows.partkey IS 'Partition key of the row; the rows of a batch with the same key are processed one at a time in line order';
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS idx_batchrows_partkey;
+ALTER TABLE batchrows DROP COLUMN IF EXISTS partkey;
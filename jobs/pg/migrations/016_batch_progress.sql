@@ -0,0 +1,12 @@
+-- When the first rows of a batch were fetched, to estimate when it will be done from the rate at which
+-- its rows are processed, and the last progress reported by the processor of a slow query
+ALTER TABLE batches ADD COLUMN startedat TIMESTAMP WITHOUT TIME ZONE;
+ALTER TABLE batches ADD COLUMN progress JSONB;
+
+COMMENT ON COLUMN batches.startedat IS 'Time at which the first rows of the batch were fetched for processing';
+COMMENT ON COLUMN batches.progress IS 'Progress last reported with ReportProgress by the processor of a slow query which is running';
+
+---- create above / drop below ----
+
+ALTER TABLE batches DROP COLUMN IF EXISTS progress;
+ALTER TABLE batches DROP COLUMN IF EXISTS startedat;
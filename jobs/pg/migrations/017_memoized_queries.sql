@@ -0,0 +1,11 @@
+-- Slow queries of an (app, op) with a memoization TTL are looked up by the hash of their context and
+-- input when they are submitted, so that an equivalent query which is running or done is reused
+ALTER TABLE batches ADD COLUMN memohash BYTEA;
+CREATE INDEX idx_batches_memohash ON batches(app, op, memohash) WHERE memohash IS NOT NULL;
+
+COMMENT ON COLUMN batches.memohash IS 'Hash of the context and input of a memoized slow query, cleared when it is invalidated';
+
+---- create above / drop below ----
+
+DROP INDEX IF EXISTS idx_batches_memohash;
+ALTER TABLE batches DROP COLUMN IF EXISTS memohash;
@@ -0,0 +1,7 @@
+-- The error returned by the BatchReducer of a batch when it last failed to reduce the results of its
+-- rows, in which case the aggregate does not cover all of them
+ALTER TABLE batches ADD COLUMN aggregateerr TEXT;
+
+---- create above / drop below ----
+
+ALTER TABLE batches DROP COLUMN IF EXISTS aggregateerr;
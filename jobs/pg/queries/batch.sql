@@ -1,22 +1,88 @@
 -- name: InsertIntoBatches :one
-INSERT INTO batches (id, app, op, context, status, reqat)
-VALUES ($1, $2, $3, $4, $5, $6)
+-- Nothing is inserted, and no row returned, if a batch of the (app, op) has the idempotency key
+INSERT INTO batches (id, app, op, context, status, reqat, priority, notbefore, idempotencykey, payloadhash, memohash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (app, op, idempotencykey) WHERE idempotencykey IS NOT NULL DO NOTHING
 RETURNING id;
 
+-- name: GetBatchByIdempotencyKey :one
+SELECT id, payloadhash
+FROM batches
+WHERE app = $1 AND op = $2 AND idempotencykey = $3;
+
+-- name: GetMemoizedQuery :one
+-- The latest slow query of the (app, op) with the memo hash which is pending, or which succeeded after
+-- doneafter
+SELECT id
+FROM batches
+WHERE app = @app AND op = @op AND memohash = @memohash
+  AND (status IN ('queued', 'inprog', 'paused') OR (status = 'success' AND doneat > @doneafter))
+ORDER BY reqat DESC
+LIMIT 1;
+
+-- name: ForgetMemoizedQueries :execrows
+-- All the memoized slow queries of the (app, op) are forgotten if memohash is NULL
+UPDATE batches
+SET memohash = NULL
+WHERE app = @app AND op = @op AND memohash IS NOT NULL
+  AND (sqlc.narg('memohash')::bytea IS NULL OR memohash = sqlc.narg('memohash'));
+
 -- name: InsertIntoBatchRows :exec
-INSERT INTO batchrows (batch, line, input, status, reqat)
-VALUES ($1, $2, $3, 'queued', $4);
+-- The number of rows of the batch is counted in batches.nrows
+WITH inserted AS (
+    INSERT INTO batchrows (batch, line, input, status, reqat, partkey)
+    VALUES ($1, $2, $3, 'queued', $4, $5)
+    RETURNING batch
+)
+UPDATE batches
+SET nrows = nrows + 1
+WHERE id IN (SELECT batch FROM inserted);
+
+-- name: BulkInsertIntoBatchRows :one
+-- The number of rows of each batch is counted in batches.nrows. Rows without a partition key have
+-- an empty one in @partkey.
+WITH inserted AS (
+    INSERT INTO batchrows (batch, line, input, status, reqat, partkey) 
+    VALUES 
+        (unnest(@batch::uuid[]), unnest(@line::int[]), unnest(@input::jsonb[]), 'queued', unnest(@reqat::timestamp[]),
+        NULLIF(unnest(@partkey::text[]), ''))
+    RETURNING batch
+), counted AS (
+    UPDATE batches
+    SET nrows = nrows + c.nrows
+    FROM (SELECT batch, count(*)::int AS nrows FROM inserted GROUP BY batch) c
+    WHERE batches.id = c.batch
+)
+SELECT count(*) FROM inserted;
 
--- name: BulkInsertIntoBatchRows :execrows
-INSERT INTO batchrows (batch, line, input, status, reqat) 
-VALUES 
-    (unnest(@batch::uuid[]), unnest(@line::int[]), unnest(@input::jsonb[]), 'queued', unnest(@reqat::timestamp[]));
+-- name: CopyIntoBatchRows :copyfrom
+-- Rows copied in are queued, but not counted in batches.nrows; see CloseBatchInput
+INSERT INTO batchrows (batch, line, input, reqat, partkey)
+VALUES (@batch, @line, @input, @reqat, @partkey);
+
+-- name: CloseBatchInput :exec
+-- The rows of a batch submitted as a stream have all been copied in, which does not count them
+UPDATE batches
+SET status = @status, nrows = nrows + @nrows::int, payloadhash = @payloadhash
+WHERE id = @id;
 
 -- name: GetBatchStatus :one
 SELECT status
 FROM batches
 WHERE id = $1;
 
+-- name: GetBatchProgress :one
+-- The counters and progress of a batch or slow query, read without locking it
+SELECT status, nrows, nsuccess, nfailed, naborted, startedat, progress
+FROM batches
+WHERE id = $1;
+
+-- name: UpdateBatchProgress :exec
+-- The progress of a slow query is only recorded until it is done
+UPDATE batches
+SET progress = $2
+WHERE id = $1 AND doneat IS NULL;
+
 -- name: GetBatchStatusAndOutputFiles :one
 SELECT a.status, a.outputfiles, b.res
 FROM batches a
@@ -26,33 +92,102 @@ WHERE a.id = $1;
 
 
 -- name: FetchBatchRowsForBatchDone :many
-SELECT line, status, res, messages
+SELECT line, status, res, messages, runno
 FROM batchrows
-WHERE batch = $1;
+WHERE batch = $1
+ORDER BY line, rowid;
 
--- name: UpdateBatchRowsSlowQuery :exec
+-- name: UpdateBatchRowsSlowQuery :execrows
 UPDATE batchrows
-SET status = $2, doneat = $3, res = $4, messages = $5, doneby = $6
-WHERE rowid = $1;
+SET status = $2, doneat = $3, res = $4, messages = $5, leaseexpiry = NULL
+WHERE rowid = $1 AND status = 'inprog' AND doneby = $6;
 
 -- name: UpdateBatchOutputFiles :exec
 UPDATE batches
 SET outputfiles = $2
 WHERE id = $1;
 
--- name: UpdateBatchRowsBatchJob :exec
-UPDATE batchrows
-SET status = $2, doneat = $3, res = $4, blobrows = $5, messages = $6, doneby = $7
-WHERE rowid = $1;
+-- name: BulkUpdateBatchRowsBatchJob :many
+-- Records the results of a block of rows in one statement, and adds them to the counters of their
+-- batches; rows no longer leased to doneby are skipped. It returns the rowids of the rows updated.
+WITH updated AS (
+    UPDATE batchrows
+    SET status = u.status::status_enum, doneat = @doneat, res = u.res, blobrows = u.blobrows, messages = u.messages,
+        leaseexpiry = NULL
+    FROM (
+        SELECT unnest(@rowid::bigint[]) AS rowid, unnest(@status::text[]) AS status, unnest(@res::jsonb[]) AS res,
+            unnest(@blobrows::jsonb[]) AS blobrows, unnest(@messages::jsonb[]) AS messages
+    ) u
+    WHERE batchrows.rowid = u.rowid AND batchrows.status = 'inprog' AND batchrows.doneby = @doneby
+    RETURNING batchrows.rowid, batchrows.batch, batchrows.status
+), counted AS (
+    UPDATE batches
+    SET nsuccess = COALESCE(nsuccess, 0) + c.nsuccess,
+        nfailed = COALESCE(nfailed, 0) + c.nfailed,
+        naborted = COALESCE(naborted, 0) + c.naborted
+    FROM (
+        SELECT batch,
+            count(*) FILTER (WHERE status = 'success')::int AS nsuccess,
+            count(*) FILTER (WHERE status IN ('failed', 'deadletter'))::int AS nfailed,
+            count(*) FILTER (WHERE status = 'aborted')::int AS naborted
+        FROM updated
+        GROUP BY batch
+    ) c
+    WHERE batches.id = c.batch
+)
+SELECT rowid FROM updated;
 
 
 -- name: FetchBlockOfRows :many
-SELECT batches.app, batches.status, batches.op, batches.context, batchrows.batch, batchrows.rowid, batchrows.line, batchrows.input
-FROM batchrows
-INNER JOIN batches ON batchrows.batch = batches.id
-WHERE batchrows.status = $1 AND batches.status != 'wait'
-LIMIT $2
-FOR UPDATE OF batchrows SKIP LOCKED;
+-- Rows are taken from the batches with the highest priority first. Within a priority, the first
+-- queued row of each app comes before the second of any app, and the apps take turns among their
+-- batches in the same way, so that small batches and slow queries are not stuck behind a large batch.
+-- A row with a partition key is only taken once the rows before it with the same key are done, so
+-- that they are processed one at a time in the order of their lines.
+-- The rows are ranked without locking them, and only the maxrows rows chosen are then locked. Rows
+-- locked or taken by another worker in the meantime are skipped, so fewer rows may be returned.
+WITH ranked AS (
+    SELECT c.batch, c.rowid, c.priority, c.reqat,
+        row_number() OVER (PARTITION BY c.priority, c.app ORDER BY c.batchrank, c.reqat, c.batch)::int AS apprank
+    FROM (
+        SELECT batches.app, batches.priority, batches.reqat, r.batch, r.rowid,
+            row_number() OVER (PARTITION BY r.batch ORDER BY r.line, r.rowid)::int AS batchrank
+        FROM batches
+        CROSS JOIN LATERAL (
+            SELECT batchrows.batch, batchrows.rowid, batchrows.line
+            FROM batchrows
+            WHERE batchrows.batch = batches.id AND batchrows.status = @status
+            AND (batchrows.nexttry IS NULL OR batchrows.nexttry <= @nexttry)
+            AND (batchrows.partkey IS NULL OR NOT EXISTS (
+                SELECT 1
+                FROM batchrows prev
+                WHERE prev.batch = batchrows.batch AND prev.partkey = batchrows.partkey
+                AND (prev.line, prev.rowid) < (batchrows.line, batchrows.rowid)
+                AND prev.status IN ('queued', 'inprog')
+            ))
+            ORDER BY batchrows.line, batchrows.rowid
+            LIMIT @perbatch
+        ) r
+        WHERE batches.status IN ('queued', 'inprog')
+        AND (batches.notbefore IS NULL OR batches.notbefore <= @nexttry)
+    ) c
+), chosen AS (
+    SELECT batch, rowid, priority, reqat, apprank
+    FROM ranked
+    ORDER BY priority DESC, apprank, reqat, batch
+    LIMIT @maxrows
+), locked AS (
+    SELECT batchrows.batch, batchrows.rowid, batchrows.line, batchrows.input, batchrows.attempts
+    FROM batchrows
+    WHERE batchrows.rowid IN (SELECT rowid FROM chosen) AND batchrows.status = @status
+    FOR UPDATE SKIP LOCKED
+)
+SELECT batches.app, batches.status, batches.op, batches.context, locked.batch, locked.rowid, locked.line,
+    locked.input, locked.attempts
+FROM chosen
+JOIN locked ON locked.rowid = chosen.rowid
+JOIN batches ON batches.id = locked.batch
+ORDER BY chosen.priority DESC, chosen.apprank, chosen.reqat, chosen.batch;
 
 
 -- name: UpdateBatchRowsStatus :exec
@@ -88,7 +223,7 @@ FOR UPDATE;
 -- name: GetProcessedBatchRowsByBatchIDSorted :many
 SELECT rowid, line, input, status, reqat, doneat, res, blobrows, messages, doneby
 FROM batchrows
-WHERE batch = $1 AND status IN ('success', 'failed')
+WHERE batch = $1 AND status IN ('success', 'failed') AND blobrows IS NOT NULL
 ORDER BY line
 FOR UPDATE;
 
@@ -114,6 +249,13 @@ SET nsuccess = COALESCE(nsuccess, 0) + $2,
     naborted = COALESCE(naborted, 0) + $4
 WHERE id = $1;
 
+-- name: MarkBatchInprog :exec
+-- A batch paused since its rows were fetched stays paused. The time at which the rows of a batch
+-- were first fetched is kept when it is queued again.
+UPDATE batches
+SET status = 'inprog', startedat = COALESCE(startedat, @startedat)
+WHERE id = @id AND status = 'queued';
+
 -- name: GetBatchRowsByBatchID :many
 SELECT * FROM batchrows WHERE batch = $1;
 
@@ -122,6 +264,12 @@ UPDATE batches
 SET status = $2, doneat = $3, outputfiles = $4, nsuccess = $5, nfailed = $6, naborted = $7
 WHERE id = $1;
 
+-- name: SetBatchStatus :exec
+-- Only the status is changed, since the counters of a batch are kept up to date as its rows finish
+UPDATE batches
+SET status = $2
+WHERE id = $1;
+
 -- name: GetBatchRowsCount :one
 SELECT COUNT(*) FROM batchrows WHERE batch = $1;
 
@@ -150,4 +298,276 @@ UPDATE batches
 SET outputfiles = $1,
    status = $2,
    doneat = $3
- WHERE id = $4;
\ No newline at end of file
+ WHERE id = $4;
+
+-- name: LeaseBatchRows :exec
+UPDATE batchrows
+SET status = 'inprog', doneby = @doneby, leaseexpiry = @leaseexpiry, attempts = attempts + 1
+WHERE rowid = ANY(@rowids::bigint[]);
+
+-- name: ExtendWorkerLeases :execrows
+UPDATE batchrows
+SET leaseexpiry = $2
+WHERE doneby = $1 AND status = 'inprog';
+
+-- name: ReclaimExpiredLeases :many
+-- Rows are dead-lettered once they have been attempted as many times as the retry policy of their
+-- (app, op) allows, given by the app || op keys in policykeys and the matching policymaxattempts, or
+-- maxattempts times if it has none. Dead-lettered rows are added to the nfailed counter of their batches.
+WITH policies AS (
+    SELECT unnest(@policykeys::text[]) AS key, unnest(@policymaxattempts::int[]) AS maxattempts
+), expired AS (
+    SELECT r.rowid, COALESCE(p.maxattempts, @maxattempts::int) AS maxattempts
+    FROM batchrows r
+    JOIN batches b ON b.id = r.batch
+    LEFT JOIN policies p ON p.key = b.app || b.op
+    WHERE r.status = 'inprog' AND r.leaseexpiry < @leaseexpiry::timestamp
+), reclaimed AS (
+    UPDATE batchrows
+    SET status = CASE WHEN batchrows.attempts >= e.maxattempts THEN 'deadletter'::status_enum ELSE 'queued'::status_enum END,
+        doneat = CASE WHEN batchrows.attempts >= e.maxattempts THEN @leaseexpiry::timestamp ELSE NULL END,
+        lasterr = 'lease expired before a result was recorded',
+        doneby = NULL, leaseexpiry = NULL
+    FROM expired e
+    WHERE batchrows.rowid = e.rowid AND batchrows.status = 'inprog' AND batchrows.leaseexpiry < @leaseexpiry::timestamp
+    RETURNING batchrows.rowid, batchrows.batch, batchrows.status
+), counted AS (
+    UPDATE batches
+    SET nfailed = COALESCE(nfailed, 0) + d.nfailed
+    FROM (SELECT batch, count(*)::int AS nfailed FROM reclaimed WHERE status = 'deadletter' GROUP BY batch) d
+    WHERE batches.id = d.batch
+)
+SELECT rowid, batch, status FROM reclaimed;
+
+-- name: RecordWorkerHeartbeat :exec
+INSERT INTO workers (id, startedat, heartbeat)
+VALUES ($1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET heartbeat = EXCLUDED.heartbeat;
+
+-- name: DeleteWorker :exec
+DELETE FROM workers WHERE id = $1;
+
+-- name: DeleteStaleWorkers :execrows
+DELETE FROM workers WHERE heartbeat < $1;
+
+-- name: ReleaseBatchRows :exec
+-- Rows leased by a worker which is shutting down are queued again, unless they were aborted or taken
+-- over by another worker since. The attempt counted when they were leased is taken back, since the
+-- rows were released rather than lost.
+UPDATE batchrows
+SET status = 'queued', attempts = attempts - 1, lasterr = @lasterr, doneby = NULL, leaseexpiry = NULL
+WHERE rowid = ANY(@rowids::bigint[]) AND status = 'inprog' AND doneby = @doneby;
+
+-- name: RetryBatchRow :exec
+UPDATE batchrows
+SET status = 'queued', nexttry = $2, lasterr = $3, doneby = NULL, leaseexpiry = NULL
+WHERE rowid = $1 AND status = 'inprog' AND doneby = $4;
+
+-- name: DeadLetterBatchRow :execrows
+-- The row is added to the nfailed counter of its batch. Nothing is updated if the row is no longer
+-- leased to doneby, such as when it was aborted or its lease was reclaimed.
+WITH dead AS (
+    UPDATE batchrows
+    SET status = 'deadletter', doneat = $2, messages = $3, lasterr = $4, errtrace = $5, leaseexpiry = NULL
+    WHERE rowid = $1 AND status = 'inprog' AND doneby = $6
+    RETURNING batch
+)
+UPDATE batches
+SET nfailed = COALESCE(nfailed, 0) + 1
+WHERE id IN (SELECT batch FROM dead);
+
+-- name: ListDeadLetterRows :many
+SELECT batchrows.rowid, batchrows.batch, batches.app, batches.op, batchrows.line, batchrows.input,
+    batchrows.attempts, batchrows.lasterr, batchrows.errtrace, batchrows.doneat
+FROM batchrows
+INNER JOIN batches ON batchrows.batch = batches.id
+WHERE batchrows.status = 'deadletter' AND batches.app = $1 AND batches.op = $2
+ORDER BY batchrows.rowid;
+
+-- name: GetDeadLetterRow :one
+SELECT batchrows.rowid, batchrows.batch, batches.app, batches.op, batchrows.line, batchrows.input,
+    batchrows.attempts, batchrows.lasterr, batchrows.errtrace, batchrows.doneat
+FROM batchrows
+INNER JOIN batches ON batchrows.batch = batches.id
+WHERE batchrows.status = 'deadletter' AND batchrows.rowid = $1;
+
+-- name: GetDeadLetterBatches :many
+-- The batches of the dead-lettered rows, in the order in which they are locked
+SELECT DISTINCT batch
+FROM batchrows
+WHERE rowid = ANY(@rowids::bigint[]) AND status = 'deadletter'
+ORDER BY batch;
+
+-- name: RequeueDeadLetterRows :many
+UPDATE batchrows
+SET status = 'queued', attempts = 0, nexttry = NULL, doneat = NULL, res = NULL, messages = NULL,
+    lasterr = NULL, errtrace = NULL, doneby = NULL, leaseexpiry = NULL
+WHERE rowid = ANY(@rowids::bigint[]) AND status = 'deadletter'
+AND batch IN (SELECT id FROM batches WHERE status != 'aborted')
+RETURNING rowid, batch;
+
+-- name: ReopenBatch :exec
+-- Rows of the batch have been queued again, so its counters are recounted from its rows. A batch
+-- which is paused or waiting keeps its status.
+UPDATE batches
+SET status = CASE WHEN batches.status IN ('paused', 'wait') THEN batches.status ELSE 'queued'::status_enum END,
+    doneat = NULL, nsuccess = c.nsuccess, nfailed = c.nfailed, naborted = c.naborted
+FROM (
+    SELECT count(*) FILTER (WHERE status = 'success')::int AS nsuccess,
+        count(*) FILTER (WHERE status IN ('failed', 'deadletter'))::int AS nfailed,
+        count(*) FILTER (WHERE status = 'aborted')::int AS naborted
+    FROM batchrows
+    WHERE batch = $1
+) c
+WHERE id = $1;
+
+-- name: ListBatches :many
+SELECT b.id, b.app, b.op, b.context, b.inputfile, b.status, b.reqat, b.doneat, b.outputfiles,
+    b.nsuccess, b.nfailed, b.naborted, b.aggregate, b.aggregateerr,
+    (SELECT count(*) FROM batchrows r WHERE r.batch = b.id) AS nrows
+FROM batches b
+WHERE b.app = @app
+AND (@op::text = '' OR b.op = @op::text)
+AND (cardinality(@statuses::text[]) = 0 OR b.status::text = ANY(@statuses::text[]))
+AND b.reqat >= @reqfrom::timestamp AND b.reqat < @requntil::timestamp
+AND (sqlc.narg(afterreqat)::timestamp IS NULL OR (b.reqat, b.id) < (sqlc.narg(afterreqat)::timestamp, sqlc.narg(afterid)::uuid))
+AND NOT EXISTS (SELECT 1 FROM batchrows r WHERE r.batch = b.id AND r.line = 0)
+ORDER BY b.reqat DESC, b.id DESC
+LIMIT @pagesize;
+
+-- name: ListBatchRowResults :many
+SELECT rowid, line, status, res, messages, runno
+FROM batchrows
+WHERE batch = @batch
+AND (cardinality(@statuses::text[]) = 0 OR status::text = ANY(@statuses::text[]))
+AND line >= @fromline::int AND line <= @toline::int
+AND (sqlc.narg(afterline)::int IS NULL OR (line, rowid) > (sqlc.narg(afterline)::int, sqlc.narg(afterrowid)::bigint))
+ORDER BY line, rowid
+LIMIT @pagesize;
+
+-- name: ListSlowQueries :many
+SELECT b.id, b.app, b.op, b.context, b.status, b.reqat, b.doneat, b.outputfiles
+FROM batches b
+WHERE b.app = @app
+AND (@op::text = '' OR b.op = @op::text)
+AND (cardinality(@statuses::text[]) = 0 OR b.status::text = ANY(@statuses::text[]))
+AND b.reqat >= @reqfrom::timestamp AND b.reqat < @requntil::timestamp
+AND (sqlc.narg(afterreqat)::timestamp IS NULL OR (b.reqat, b.id) < (sqlc.narg(afterreqat)::timestamp, sqlc.narg(afterid)::uuid))
+AND EXISTS (SELECT 1 FROM batchrows r WHERE r.batch = b.id AND r.line = 0)
+ORDER BY b.reqat DESC, b.id DESC
+LIMIT @pagesize;
+
+-- name: GetAbortedBatches :many
+SELECT id
+FROM batches
+WHERE id = ANY(@ids::uuid[]) AND status = 'aborted';
+
+-- name: UpsertRecurringJob :exec
+-- The next tick of an existing recurring batch is kept unless its cron expression has changed
+INSERT INTO recurringjobs (name, app, op, cronspec, nextrun)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (name) DO UPDATE
+SET app = EXCLUDED.app, op = EXCLUDED.op, cronspec = EXCLUDED.cronspec,
+    nextrun = CASE WHEN recurringjobs.cronspec = EXCLUDED.cronspec THEN recurringjobs.nextrun ELSE EXCLUDED.nextrun END;
+
+-- name: GetDueRecurringJob :one
+SELECT name, nextrun
+FROM recurringjobs
+WHERE name = $1 AND nextrun <= $2
+FOR UPDATE SKIP LOCKED;
+
+-- name: UpdateRecurringJobRun :exec
+UPDATE recurringjobs
+SET lastrun = $2, lastbatch = $3, nextrun = $4
+WHERE name = $1;
+
+-- name: NotifyBatchQueued :exec
+-- The notification is delivered to the listening instances when the transaction commits
+SELECT pg_notify(@channel::text, @batch::text);
+
+-- name: GetBatchCounts :one
+SELECT status, nsuccess, nfailed, naborted
+FROM batches
+WHERE id = $1;
+
+-- name: RetryBatchRows :many
+-- The current result of each row is saved in batchrowresults before the row is queued for its next run
+WITH retried AS (
+    SELECT rowid
+    FROM batchrows
+    WHERE batchrows.batch = @batch AND batchrows.status::text = ANY(@statuses::text[])
+    AND (cardinality(@lines::int[]) = 0 OR batchrows.line = ANY(@lines::int[]))
+    FOR UPDATE
+), saved AS (
+    INSERT INTO batchrowresults (rowid, runno, batch, line, status, doneat, res, blobrows, messages, doneby)
+    SELECT r.rowid, r.runno, r.batch, r.line, r.status, r.doneat, r.res, r.blobrows, r.messages, r.doneby
+    FROM batchrows r
+    WHERE r.rowid IN (SELECT rowid FROM retried)
+)
+UPDATE batchrows
+SET status = 'queued', runno = batchrows.runno + 1, attempts = 0, nexttry = NULL, doneat = NULL, res = NULL,
+    blobrows = NULL, messages = NULL, lasterr = NULL, errtrace = NULL, doneby = NULL, leaseexpiry = NULL
+WHERE batchrows.rowid IN (SELECT rowid FROM retried)
+RETURNING rowid, line, runno;
+
+-- name: GetBatchRowResults :many
+SELECT rowid, runno, batch, line, status, doneat, res, blobrows, messages, doneby
+FROM batchrowresults
+WHERE batch = $1
+ORDER BY line, runno;
+
+-- name: InsertPipeline :exec
+INSERT INTO pipelines (id, name, context, priority, status, reqat)
+VALUES ($1, $2, $3, $4, 'inprog', $5);
+
+-- name: InsertPipelineStep :exec
+-- The step becomes the current step of its pipeline
+WITH inserted AS (
+    INSERT INTO pipelinesteps (pipeline, step, batch)
+    VALUES (@pipeline, @step, @batch)
+)
+UPDATE pipelines
+SET step = @step
+WHERE id = @pipeline;
+
+-- name: GetPipelineByID :one
+SELECT id, name, context, priority, status, step, reqat, doneat
+FROM pipelines
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetPipelineSteps :many
+SELECT s.step, s.batch, b.app, b.op, b.status
+FROM pipelinesteps s
+JOIN batches b ON b.id = s.batch
+WHERE s.pipeline = $1
+ORDER BY s.step;
+
+-- name: GetPipelineToAdvance :one
+-- Returns a pipeline whose current batch is done, skipping those being advanced by another instance
+SELECT p.id, p.name, p.context, p.priority, p.step, s.batch, b.status AS batchstatus
+FROM pipelines p
+JOIN pipelinesteps s ON s.pipeline = p.id AND s.step = p.step
+JOIN batches b ON b.id = s.batch
+WHERE p.status = 'inprog' AND b.status IN ('success', 'failed', 'aborted')
+AND p.name = ANY(@names::text[]) AND NOT (p.id = ANY(@skipped::uuid[]))
+ORDER BY b.doneat
+LIMIT 1
+FOR UPDATE OF p SKIP LOCKED;
+
+-- name: UpdatePipelineStatus :exec
+UPDATE pipelines
+SET status = $2, doneat = $3
+WHERE id = $1;
+
+-- name: UpdateBatchAggregate :exec
+UPDATE batches
+SET aggregate = $2
+WHERE id = $1;
+
+-- name: UpdateBatchAggregateErr :exec
+-- Records the error returned by the reducer of a batch, or clears it once the aggregate is computed
+-- again from all the rows
+UPDATE batches
+SET aggregateerr = $2
+WHERE id = $1;
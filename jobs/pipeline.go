@@ -0,0 +1,389 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ErrPipelineAlreadyRegistered is returned when attempting to register a second pipeline with the
+// same name.
+var ErrPipelineAlreadyRegistered = errors.New("pipeline already registered with this name")
+
+// ErrPipelineNotRegistered is returned by PipelineSubmit for a pipeline which was not registered.
+var ErrPipelineNotRegistered = errors.New("pipeline not registered")
+
+// PipelineMapper derives the context and input of the batch of a step of a pipeline from the context
+// passed to PipelineSubmit and the results of the batch of the previous step, in order of line. If it
+// returns no input, the pipeline ends without running the remaining steps. If it returns an error,
+// it is called again at the next check.
+type PipelineMapper func(pipelinectx JSONstr, prev []BatchOutput_t) (batchctx JSONstr, batchInput []BatchInput_t, err error)
+
+// PipelineStep is a step of a pipeline registered with RegisterPipeline. The batch of the step is
+// submitted for (App, Op) once the batch of the previous step is done.
+type PipelineStep struct {
+	App string
+	Op  string
+	// OnSuccessOnly runs the step only if the batch of the previous step succeeded. If it failed,
+	// the pipeline ends as failed.
+	OnSuccessOnly bool
+	// Map derives the input of the step from the results of the previous one. It is not used for
+	// the first step, whose input is passed to PipelineSubmit.
+	Map PipelineMapper
+}
+
+// PipelineDetails_t describes a pipeline, as returned by PipelineStatus
+type PipelineDetails_t struct {
+	ID     string
+	Name   string
+	Status batchsqlc.StatusEnum // inprog until the pipeline is done
+	Step   int                  // index of the current step, from 0
+	ReqAt  time.Time
+	DoneAt time.Time // zero until the pipeline is done
+	Steps  []PipelineStepDetails_t
+}
+
+// PipelineStepDetails_t describes a step of a pipeline whose batch has been submitted
+type PipelineStepDetails_t struct {
+	Step    int
+	App     string
+	Op      string
+	BatchID string
+	Status  batchsqlc.StatusEnum
+}
+
+// RegisterPipeline registers the steps of a pipeline of batches under name. Each step after the first
+// is submitted by the JobManager once the batch of the previous step is done, with the input its Map
+// derives from the results of that batch. A pipeline ends when its last batch is done, with the status
+// of that batch, or earlier if a batch is aborted, if a batch fails before a step with OnSuccessOnly
+// set, or if a Map returns no input. Every instance which may advance the pipelines submitted under
+// name must register the same steps. Pipelines must be registered before Run is called.
+func (jm *JobManager) RegisterPipeline(name string, steps ...PipelineStep) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("pipeline %s has no steps", name)
+	}
+	if _, exists := jm.pipelines[name]; exists {
+		return fmt.Errorf("%w: name=%s", ErrPipelineAlreadyRegistered, name)
+	}
+	registered := make([]PipelineStep, len(steps))
+	for i, step := range steps {
+		if i > 0 && step.Map == nil {
+			return fmt.Errorf("step %d of pipeline %s has no Map", i, name)
+		}
+		// Convert op to lowercase, as it is stored in the database
+		step.Op = strings.ToLower(step.Op)
+		registered[i] = step
+	}
+	jm.pipelines[name] = registered
+	return nil
+}
+
+// PipelineSubmit submits a pipeline registered with RegisterPipeline, with the batch of its first step
+// for batchctx and batchInput. batchctx is also passed to the Map of each later step. opts apply to the
+// batch of every step, except WithNotBefore, which applies to the first step only, and
+// WithIdempotencyKey, which is ignored.
+func (jm *JobManager) PipelineSubmit(name string, batchctx JSONstr, batchInput []BatchInput_t, opts ...SubmitOption) (pipelineID string, err error) {
+	steps, exists := jm.pipelines[name]
+	if !exists {
+		return "", fmt.Errorf("%w: name=%s", ErrPipelineNotRegistered, name)
+	}
+	submitOpts := newSubmitOptions(ALYA_BATCH_PRIORITY, opts)
+	submitOpts.idempotencyKey = ""
+
+	ctx := context.Background()
+	tx, err := jm.Store.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
+
+	pipelineUUID, err := uuid.NewUUID()
+	if err != nil {
+		return "", err
+	}
+	err = txQueries.InsertPipeline(ctx, batchsqlc.InsertPipelineParams{
+		ID:       pipelineUUID,
+		Name:     name,
+		Context:  []byte(batchctx.String()),
+		Priority: int32(submitOpts.priority),
+		Reqat:    pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to insert pipeline: %v", err)
+	}
+
+	batchUUID, _, err := insertBatch(ctx, txQueries, steps[0].App, steps[0].Op, batchctx, batchInput, batchsqlc.StatusEnumQueued, submitOpts)
+	if err != nil {
+		return "", err
+	}
+	err = txQueries.InsertPipelineStep(ctx, batchsqlc.InsertPipelineStepParams{
+		Pipeline: pipelineUUID,
+		Step:     0,
+		Batch:    batchUUID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to insert step 0 of pipeline: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	jm.publishEvent(BatchEvent{BatchID: batchUUID.String(), Type: BatchEventQueued, Status: batchsqlc.StatusEnumQueued})
+	log.Printf("Submitted pipeline %s of %s with batch %s for step 0", pipelineUUID, name, batchUUID)
+	return pipelineUUID.String(), nil
+}
+
+// PipelineStatus returns the status of a pipeline and of the batches submitted for its steps so far.
+func (jm *JobManager) PipelineStatus(pipelineID string) (PipelineDetails_t, error) {
+	pipelineUUID, err := uuid.Parse(pipelineID)
+	if err != nil {
+		return PipelineDetails_t{}, fmt.Errorf("invalid pipeline ID: %v", err)
+	}
+
+	pipeline, err := jm.Queries.GetPipelineByID(context.Background(), pipelineUUID)
+	if err != nil {
+		return PipelineDetails_t{}, fmt.Errorf("failed to get pipeline by ID: %v", err)
+	}
+	steps, err := jm.Queries.GetPipelineSteps(context.Background(), pipelineUUID)
+	if err != nil {
+		return PipelineDetails_t{}, fmt.Errorf("failed to get steps of pipeline: %v", err)
+	}
+
+	details := PipelineDetails_t{
+		ID:     pipelineID,
+		Name:   pipeline.Name,
+		Status: pipeline.Status,
+		Step:   int(pipeline.Step),
+		ReqAt:  pipeline.Reqat.Time,
+		DoneAt: pipeline.Doneat.Time,
+		Steps:  make([]PipelineStepDetails_t, len(steps)),
+	}
+	for i, step := range steps {
+		details.Steps[i] = PipelineStepDetails_t{
+			Step:    int(step.Step),
+			App:     step.App,
+			Op:      step.Op,
+			BatchID: step.Batch.String(),
+			Status:  step.Status,
+		}
+	}
+	return details, nil
+}
+
+// PipelineAbort aborts a pipeline, along with the batch of its current step if that batch is not done,
+// so that no further step is submitted. It returns the status of the pipeline, which is left as it is
+// if the pipeline is already done.
+func (jm *JobManager) PipelineAbort(pipelineID string) (status batchsqlc.StatusEnum, err error) {
+	pipelineUUID, err := uuid.Parse(pipelineID)
+	if err != nil {
+		return "", fmt.Errorf("invalid pipeline ID: %v", err)
+	}
+
+	ctx := context.Background()
+	tx, err := jm.Store.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
+
+	// Lock the pipeline, so that it cannot be advanced while it is aborted
+	pipeline, err := txQueries.GetPipelineByID(ctx, pipelineUUID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get pipeline by ID: %v", err)
+	}
+	if pipeline.Status != batchsqlc.StatusEnumInprog {
+		return pipeline.Status, nil
+	}
+	steps, err := txQueries.GetPipelineSteps(ctx, pipelineUUID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get steps of pipeline: %v", err)
+	}
+
+	err = txQueries.UpdatePipelineStatus(ctx, batchsqlc.UpdatePipelineStatusParams{
+		ID:     pipelineUUID,
+		Status: batchsqlc.StatusEnumAborted,
+		Doneat: pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to update pipeline status: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	// The batch is aborted once the pipeline is, so that the pipeline is not advanced when the batch ends
+	if len(steps) > 0 {
+		current := steps[len(steps)-1]
+		if !isFinalStatus(current.Status) {
+			if _, _, _, _, err := jm.BatchAbort(current.Batch.String()); err != nil {
+				return batchsqlc.StatusEnumAborted, fmt.Errorf("failed to abort batch %s of pipeline %s: %v", current.Batch, pipelineID, err)
+			}
+		}
+	}
+	log.Printf("Aborted pipeline %s", pipelineID)
+	return batchsqlc.StatusEnumAborted, nil
+}
+
+// runPipelines advances the pipelines registered with this instance whose current batch is done,
+// checking every Config.PipelineIntervalSec seconds. It returns when ctx is cancelled.
+func (jm *JobManager) runPipelines(ctx context.Context) {
+	if len(jm.pipelines) == 0 {
+		return
+	}
+	interval := time.Duration(jm.Config.PipelineIntervalSec) * time.Second
+	for ctx.Err() == nil {
+		jm.advancePipelines(ctx)
+		sleepWithContext(ctx, interval)
+	}
+}
+
+// advancePipelines advances, one at a time, the pipelines registered with this instance whose current
+// batch is done. A pipeline which cannot be advanced is left for the next check.
+func (jm *JobManager) advancePipelines(ctx context.Context) {
+	names := make([]string, 0, len(jm.pipelines))
+	for name := range jm.pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Not nil, since no pipeline would be found if it were passed as NULL
+	skipped := []uuid.UUID{}
+	for ctx.Err() == nil {
+		pipelineUUID, err := jm.advancePipeline(ctx, names, skipped)
+		if err != nil {
+			log.Printf("Error advancing pipeline: %v", err)
+			if pipelineUUID == uuid.Nil {
+				return
+			}
+			skipped = append(skipped, pipelineUUID)
+			continue
+		}
+		if pipelineUUID == uuid.Nil {
+			return
+		}
+	}
+}
+
+// advancePipeline submits the batch of the next step of a pipeline whose current batch is done, or
+// ends the pipeline if it has no step left to run. It returns the ID of the pipeline, or uuid.Nil if
+// no pipeline was due. The batch is inserted in the same transaction which moves the pipeline to its
+// next step, so a step cannot be submitted twice.
+func (jm *JobManager) advancePipeline(ctx context.Context, names []string, skipped []uuid.UUID) (uuid.UUID, error) {
+	tx, err := jm.Store.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
+
+	due, err := txQueries.GetPipelineToAdvance(ctx, batchsqlc.GetPipelineToAdvanceParams{
+		Names:   names,
+		Skipped: skipped,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// No pipeline is due, or they are being advanced by other instances
+		return uuid.Nil, nil
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get pipeline to advance: %v", err)
+	}
+
+	steps := jm.pipelines[due.Name]
+	next := int(due.Step) + 1
+	status := batchsqlc.StatusEnumInprog
+	var nextBatch uuid.UUID
+	switch {
+	case due.Batchstatus == batchsqlc.StatusEnumAborted:
+		status = batchsqlc.StatusEnumAborted
+	case next >= len(steps):
+		status = due.Batchstatus
+	case due.Batchstatus == batchsqlc.StatusEnumFailed && steps[next].OnSuccessOnly:
+		status = batchsqlc.StatusEnumFailed
+	default:
+		nextBatch, err = jm.submitPipelineStep(ctx, txQueries, due, next, steps[next])
+		if err != nil {
+			return due.ID, fmt.Errorf("failed to submit step %d of pipeline %s: %v", next, due.ID, err)
+		}
+		if nextBatch == uuid.Nil {
+			// No input for the step
+			status = due.Batchstatus
+		}
+	}
+
+	if status != batchsqlc.StatusEnumInprog {
+		err = txQueries.UpdatePipelineStatus(ctx, batchsqlc.UpdatePipelineStatusParams{
+			ID:     due.ID,
+			Status: status,
+			Doneat: pgtype.Timestamp{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			return due.ID, fmt.Errorf("failed to update status of pipeline %s: %v", due.ID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return due.ID, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	if nextBatch != uuid.Nil {
+		jm.publishEvent(BatchEvent{BatchID: nextBatch.String(), Type: BatchEventQueued, Status: batchsqlc.StatusEnumQueued})
+		log.Printf("Submitted batch %s for step %d of pipeline %s", nextBatch, next, due.ID)
+	} else {
+		log.Printf("Pipeline %s of %s is done with status %s", due.ID, due.Name, status)
+	}
+	return due.ID, nil
+}
+
+// submitPipelineStep submits the batch of a step of a pipeline, with the input mapped from the results
+// of the batch of the previous step. It returns uuid.Nil if the step has no input.
+func (jm *JobManager) submitPipelineStep(ctx context.Context, txQueries batchsqlc.Querier, due batchsqlc.GetPipelineToAdvanceRow, next int, step PipelineStep) (uuid.UUID, error) {
+	var prev []BatchOutput_t
+	it := jm.BatchResultIter(due.Batch.String(), nil)
+	for it.Next() {
+		prev = append(prev, it.Result())
+	}
+	if err := it.Err(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get results of batch %s: %v", due.Batch, err)
+	}
+
+	pipelinectx, err := NewJSONstr(string(due.Context))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to parse pipeline context: %v", err)
+	}
+	batchctx, batchInput, err := step.Map(pipelinectx, prev)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to map results of batch %s: %v", due.Batch, err)
+	}
+	if len(batchInput) == 0 {
+		return uuid.Nil, nil
+	}
+
+	batchUUID, _, err := insertBatch(ctx, txQueries, step.App, step.Op, batchctx, batchInput, batchsqlc.StatusEnumQueued, submitOptions{priority: int(due.Priority)})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	err = txQueries.InsertPipelineStep(ctx, batchsqlc.InsertPipelineStepParams{
+		Pipeline: due.ID,
+		Step:     int32(next),
+		Batch:    batchUUID,
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert step: %v", err)
+	}
+	return batchUUID, nil
+}
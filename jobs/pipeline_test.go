@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/stretchr/testify/assert"
+)
+
+// successfulResults maps the results of the successful rows of the previous step to the input of the next
+func successfulResults(pipelinectx JSONstr, prev []BatchOutput_t) (JSONstr, []BatchInput_t, error) {
+	var input []BatchInput_t
+	for _, result := range prev {
+		if result.Status == BatchSuccess {
+			input = append(input, BatchInput_t{Line: len(input) + 1, Input: result.Res})
+		}
+	}
+	return pipelinectx, input, nil
+}
+
+func newPipelineTestJobManager(t *testing.T) *JobManager {
+	jm := newMemTestJobManager(t)
+	for _, op := range []string{"validate", "post", "notify"} {
+		p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 10)}
+		assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", op, p))
+	}
+	return jm
+}
+
+// processQueuedRows processes the queued rows of jm until none is left, without running it
+func processQueuedRows(t *testing.T, jm *JobManager) {
+	for {
+		nrows, err := jm.processBlock(context.Background())
+		assert.NoError(t, err)
+		if err != nil || nrows == 0 {
+			return
+		}
+	}
+}
+
+// runPipelineStep processes the batch of the current step of a pipeline and advances the pipeline
+func runPipelineStep(t *testing.T, jm *JobManager, pipelineID string) PipelineDetails_t {
+	processQueuedRows(t, jm)
+	jm.advancePipelines(context.Background())
+	details, err := jm.PipelineStatus(pipelineID)
+	assert.NoError(t, err)
+	return details
+}
+
+func TestPipeline(t *testing.T) {
+	jm := newPipelineTestJobManager(t)
+	assert.NoError(t, jm.RegisterPipeline("daily",
+		PipelineStep{App: "app1", Op: "validate"},
+		PipelineStep{App: "app1", Op: "POST", Map: successfulResults},
+		PipelineStep{App: "app1", Op: "notify", OnSuccessOnly: true, Map: successfulResults},
+	))
+	err := jm.RegisterPipeline("daily", PipelineStep{App: "app1", Op: "validate"})
+	assert.True(t, errors.Is(err, ErrPipelineAlreadyRegistered))
+	assert.Error(t, jm.RegisterPipeline("nomap", PipelineStep{App: "app1", Op: "validate"}, PipelineStep{App: "app1", Op: "post"}))
+	_, err = jm.PipelineSubmit("unknown", JSONstr{}, nil)
+	assert.True(t, errors.Is(err, ErrPipelineNotRegistered))
+
+	batchctx, _ := NewJSONstr(`{"day": 1}`)
+	var input []BatchInput_t
+	for i, value := range []string{`"a"`, `"fail"`, `"b"`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	pipelineID, err := jm.PipelineSubmit("daily", batchctx, input)
+	assert.NoError(t, err)
+
+	// The failed row is dropped by the mapping, so that the second step succeeds
+	details := runPipelineStep(t, jm, pipelineID)
+	assert.Equal(t, batchsqlc.StatusEnumInprog, details.Status)
+	assert.Equal(t, 1, details.Step)
+	assert.Equal(t, batchsqlc.StatusEnumFailed, details.Steps[0].Status)
+	assert.Equal(t, "post", details.Steps[1].Op)
+
+	details = runPipelineStep(t, jm, pipelineID)
+	assert.Equal(t, 2, details.Step)
+
+	// The results of the first step are carried through to the last
+	details = runPipelineStep(t, jm, pipelineID)
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, details.Status)
+	assert.False(t, details.DoneAt.IsZero())
+	if assert.Len(t, details.Steps, 3) {
+		results, _, err := jm.BatchResults(details.Steps[2].BatchID, nil)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, `"b"`, results[1].Res.String())
+	}
+
+	// Advancing again has no effect
+	jm.advancePipelines(context.Background())
+	details, err = jm.PipelineStatus(pipelineID)
+	assert.NoError(t, err)
+	assert.Len(t, details.Steps, 3)
+}
+
+func TestPipelineOnSuccessOnly(t *testing.T) {
+	jm := newPipelineTestJobManager(t)
+	assert.NoError(t, jm.RegisterPipeline("strict",
+		PipelineStep{App: "app1", Op: "validate"},
+		PipelineStep{App: "app1", Op: "post", OnSuccessOnly: true, Map: successfulResults},
+	))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	rowInput, _ := NewJSONstr(`"fail"`)
+	pipelineID, err := jm.PipelineSubmit("strict", batchctx, []BatchInput_t{{Line: 1, Input: rowInput}})
+	assert.NoError(t, err)
+
+	details := runPipelineStep(t, jm, pipelineID)
+	assert.Equal(t, batchsqlc.StatusEnumFailed, details.Status)
+	assert.Len(t, details.Steps, 1)
+}
+
+func TestPipelineAbort(t *testing.T) {
+	jm := newPipelineTestJobManager(t)
+	assert.NoError(t, jm.RegisterPipeline("daily",
+		PipelineStep{App: "app1", Op: "validate"},
+		PipelineStep{App: "app1", Op: "post", Map: successfulResults},
+	))
+
+	// The first batch is aborted before it is processed
+	batchctx, _ := NewJSONstr(`{}`)
+	rowInput, _ := NewJSONstr(`"a"`)
+	pipelineID, err := jm.PipelineSubmit("daily", batchctx, []BatchInput_t{{Line: 1, Input: rowInput}})
+	assert.NoError(t, err)
+
+	status, err := jm.PipelineAbort(pipelineID)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumAborted, status)
+
+	jm.advancePipelines(context.Background())
+	details, err := jm.PipelineStatus(pipelineID)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumAborted, details.Status)
+	assert.Len(t, details.Steps, 1)
+	assert.Equal(t, batchsqlc.StatusEnumAborted, details.Steps[0].Status)
+
+	// Aborting a pipeline which is done has no effect
+	status, err = jm.PipelineAbort(pipelineID)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumAborted, status)
+}
@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ErrNoProgressReporter is returned by ReportProgress when it is not passed the context given to a
+// SlowQueryProcessorCtx.
+var ErrNoProgressReporter = errors.New("progress can only be reported with the context passed to a slow query processor")
+
+// BatchProgress_t is the progress of a batch or slow query, as returned by BatchProgress.
+type BatchProgress_t struct {
+	Status   batchsqlc.StatusEnum `json:"status"`
+	NRows    int                  `json:"nrows"` // rows in the batch, 1 for a slow query
+	NDone    int                  `json:"ndone"` // rows which are done, whatever their status
+	NSuccess int                  `json:"nsuccess"`
+	NFailed  int                  `json:"nfailed"`
+	NAborted int                  `json:"naborted"`
+	// ETA is when the batch is expected to be done, at the rate at which its rows have been processed
+	// (or a slow query has reported progress) since the first of them was fetched. It is zero until
+	// there is some progress, and once the batch is done.
+	ETA time.Time `json:"eta"`
+	// Percent is the share of the rows which are done, or the percentage last reported with
+	// ReportProgress by the processor of a slow query which is running
+	Percent int `json:"percent"`
+	// Message is the message last reported with ReportProgress by the processor of a slow query
+	Message string `json:"message,omitempty"`
+}
+
+// SlowQueryProgress_t is the progress reported with ReportProgress by the processor of a slow query.
+// While the query is running, SlowQueryDone returns it, marshalled to JSON, as its result.
+type SlowQueryProgress_t struct {
+	Percent int    `json:"percent"`
+	Message string `json:"message"`
+}
+
+// BatchProgress returns the counters of a batch which is being processed, for progress bars, along
+// with an estimate of when it will be done. For a slow query, it returns the progress last reported
+// by its processor. The progress is served from the StatusCache when it is there, so it may be up to
+// Config.BatchStatusCacheDurSec seconds old.
+func (jm *JobManager) BatchProgress(batchID string) (progress BatchProgress_t, err error) {
+	batchUUID, err := uuid.Parse(batchID)
+	if err != nil {
+		return BatchProgress_t{}, fmt.Errorf("invalid batch ID: %v", err)
+	}
+	return jm.batchProgress(batchUUID)
+}
+
+// batchProgress returns the progress of a batch from the StatusCache, or computes it from the counters
+// of the batch and caches it
+func (jm *JobManager) batchProgress(batchID uuid.UUID) (BatchProgress_t, error) {
+	var progress BatchProgress_t
+	cached, found := jm.getCachedStatus(batchID)
+	if found && cached.Progress != "" {
+		if err := json.Unmarshal([]byte(cached.Progress), &progress); err == nil {
+			return progress, nil
+		}
+	}
+
+	row, err := jm.Queries.GetBatchProgress(context.Background(), batchID)
+	if err != nil {
+		return BatchProgress_t{}, fmt.Errorf("failed to get progress of batch %s: %v", batchID, err)
+	}
+	progress, err = newBatchProgress(row, time.Now())
+	if err != nil {
+		return BatchProgress_t{}, fmt.Errorf("failed to get progress of batch %s: %v", batchID, err)
+	}
+
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		return BatchProgress_t{}, fmt.Errorf("failed to marshal progress: %v", err)
+	}
+	if found && cached.Status == row.Status {
+		// Keep the result of a slow query which is done
+		cached.Progress = string(progressJSON)
+		jm.cacheStatus(batchID, cached)
+	} else {
+		jm.cacheStatus(batchID, CachedStatus{Status: row.Status, Progress: string(progressJSON)})
+	}
+	return progress, nil
+}
+
+// newBatchProgress computes the progress of a batch at now from its counters
+func newBatchProgress(row batchsqlc.GetBatchProgressRow, now time.Time) (BatchProgress_t, error) {
+	progress := BatchProgress_t{
+		Status:   row.Status,
+		NRows:    int(row.Nrows),
+		NSuccess: int(row.Nsuccess.Int32),
+		NFailed:  int(row.Nfailed.Int32),
+		NAborted: int(row.Naborted.Int32),
+	}
+	progress.NDone = progress.NSuccess + progress.NFailed + progress.NAborted
+	if progress.NRows > 0 {
+		progress.Percent = progress.NDone * 100 / progress.NRows
+	}
+
+	if row.Progress != nil && !isFinalStatus(row.Status) {
+		var reported SlowQueryProgress_t
+		if err := json.Unmarshal(row.Progress, &reported); err != nil {
+			return BatchProgress_t{}, fmt.Errorf("failed to unmarshal reported progress: %v", err)
+		}
+		progress.Percent = reported.Percent
+		progress.Message = reported.Message
+	}
+
+	if isFinalStatus(row.Status) || !row.Startedat.Valid {
+		return progress, nil
+	}
+	elapsed := float64(now.Sub(localTime(row.Startedat)))
+	if row.Progress != nil && progress.Percent > 0 && progress.Percent < 100 {
+		progress.ETA = now.Add(time.Duration(elapsed * float64(100-progress.Percent) / float64(progress.Percent)))
+	} else if progress.NDone > 0 && progress.NDone < progress.NRows {
+		progress.ETA = now.Add(time.Duration(elapsed * float64(progress.NRows-progress.NDone) / float64(progress.NDone)))
+	}
+	return progress, nil
+}
+
+// slowQueryProgress returns the progress last reported by the processor of a slow query which is
+// running, as SlowQueryDone returns it. The result is not valid if no progress has been reported.
+func (jm *JobManager) slowQueryProgress(reqID uuid.UUID) JSONstr {
+	progress, err := jm.batchProgress(reqID)
+	if err != nil {
+		log.Printf("Error getting progress of slow query %s: %v", reqID, err)
+		return JSONstr{}
+	}
+	if isFinalStatus(progress.Status) || (progress.Percent == 0 && progress.Message == "") {
+		return JSONstr{}
+	}
+	progressJSON, err := json.Marshal(SlowQueryProgress_t{Percent: progress.Percent, Message: progress.Message})
+	if err != nil {
+		log.Printf("Error marshalling progress of slow query %s: %v", reqID, err)
+		return JSONstr{}
+	}
+	return JSONstr{value: string(progressJSON), valid: true}
+}
+
+// localTime returns a timestamp stored without a time zone as a time in the local time zone
+func localTime(ts pgtype.Timestamp) time.Time {
+	t := ts.Time
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+}
+
+// progressReporterKey is the key of the progressReporter in the context passed to slow query processors
+type progressReporterKey struct{}
+
+// progressReporter records the progress reported by the processor of a slow query
+type progressReporter struct {
+	jm      *JobManager
+	batchID uuid.UUID
+}
+
+// withProgressReporter returns the context passed to the processor of a slow query, through which it
+// reports its progress with ReportProgress
+func (jm *JobManager) withProgressReporter(ctx context.Context, batchID uuid.UUID) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, &progressReporter{jm: jm, batchID: batchID})
+}
+
+// ReportProgress records the progress of the slow query being processed by a SlowQueryProcessorCtx,
+// which passes the context it was given. percent is capped to the range 0 to 100. Until the query is
+// done, the progress is returned by SlowQueryDone as the result and by BatchProgress. Each report is
+// written to the database and drops the status cached for the query, so long queries should report
+// every few seconds rather than at every step.
+func ReportProgress(ctx context.Context, percent int, message string) error {
+	reporter, ok := ctx.Value(progressReporterKey{}).(*progressReporter)
+	if !ok {
+		return ErrNoProgressReporter
+	}
+	percent = min(max(percent, 0), 100)
+
+	progressJSON, err := json.Marshal(SlowQueryProgress_t{Percent: percent, Message: message})
+	if err != nil {
+		return fmt.Errorf("failed to marshal progress: %v", err)
+	}
+	err = reporter.jm.Queries.UpdateBatchProgress(context.Background(), batchsqlc.UpdateBatchProgressParams{
+		ID:       reporter.batchID,
+		Progress: progressJSON,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record progress of slow query %s: %v", reporter.batchID, err)
+	}
+	// The cached progress is out of date, so the next poll reads it again from the database
+	reporter.jm.uncacheStatus(reporter.batchID)
+	return nil
+}
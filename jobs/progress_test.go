@@ -0,0 +1,211 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/wscutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBatchProgress(t *testing.T) {
+	now := time.Now()
+	started := now.Add(-time.Minute)
+	startedat := pgtype.Timestamp{
+		Time:  time.Date(started.Year(), started.Month(), started.Day(), started.Hour(), started.Minute(), started.Second(), started.Nanosecond(), time.UTC),
+		Valid: true,
+	}
+
+	// A quarter of the rows took a minute, so the rest should take three more
+	progress, err := newBatchProgress(batchsqlc.GetBatchProgressRow{
+		Status:    batchsqlc.StatusEnumInprog,
+		Nrows:     100,
+		Nsuccess:  pgtype.Int4{Int32: 20, Valid: true},
+		Nfailed:   pgtype.Int4{Int32: 5, Valid: true},
+		Startedat: startedat,
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 25, progress.NDone)
+	assert.Equal(t, 25, progress.Percent)
+	assert.WithinDuration(t, now.Add(3*time.Minute), progress.ETA, time.Second)
+
+	// The progress reported by a slow query is used instead of its single row
+	progress, err = newBatchProgress(batchsqlc.GetBatchProgressRow{
+		Status:    batchsqlc.StatusEnumInprog,
+		Nrows:     1,
+		Startedat: startedat,
+		Progress:  []byte(`{"percent":50,"message":"halfway"}`),
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, progress.Percent)
+	assert.Equal(t, "halfway", progress.Message)
+	assert.WithinDuration(t, now.Add(time.Minute), progress.ETA, time.Second)
+
+	// There is no ETA before any progress or once the batch is done
+	progress, err = newBatchProgress(batchsqlc.GetBatchProgressRow{Status: batchsqlc.StatusEnumQueued, Nrows: 10}, now)
+	assert.NoError(t, err)
+	assert.True(t, progress.ETA.IsZero())
+	progress, err = newBatchProgress(batchsqlc.GetBatchProgressRow{
+		Status:    batchsqlc.StatusEnumSuccess,
+		Nrows:     10,
+		Nsuccess:  pgtype.Int4{Int32: 10, Valid: true},
+		Startedat: startedat,
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, progress.Percent)
+	assert.True(t, progress.ETA.IsZero())
+}
+
+func TestBatchProgress(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	jm.StatusCache = NewLRUStatusCache(10)
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for i, value := range []string{`1`, `"fail"`, `3`, `4`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, input, false)
+	assert.NoError(t, err)
+
+	progress, err := jm.BatchProgress(batchID)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchProgress_t{Status: batchsqlc.StatusEnumQueued, NRows: 4}, progress)
+
+	// The cached progress is dropped once the batch is done
+	processQueuedRows(t, jm)
+	<-p.markDoneCalled
+	progress, err = jm.BatchProgress(batchID)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchProgress_t{Status: batchsqlc.StatusEnumFailed, NRows: 4, NDone: 4, NSuccess: 3, NFailed: 1, Percent: 100}, progress)
+
+	_, err = jm.BatchProgress("not-a-uuid")
+	assert.Error(t, err)
+}
+
+// progressSlowQueryProcessor reports its progress and waits to be released
+type progressSlowQueryProcessor struct {
+	reported chan struct{}
+	release  chan struct{}
+}
+
+func (p progressSlowQueryProcessor) DoSlowQuery(ctx context.Context, initBlock InitBlock, queryctx JSONstr, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	if err := ReportProgress(ctx, 40, "halfway"); err != nil {
+		return batchsqlc.StatusEnumFailed, JSONstr{}, nil, nil, err
+	}
+	p.reported <- struct{}{}
+	<-p.release
+	return batchsqlc.StatusEnumSuccess, input, nil, map[string]string{}, nil
+}
+
+func TestReportProgress(t *testing.T) {
+	err := ReportProgress(context.Background(), 10, "no reporter")
+	assert.True(t, errors.Is(err, ErrNoProgressReporter))
+
+	jm := newMemTestJobManager(t)
+	p := progressSlowQueryProcessor{reported: make(chan struct{}), release: make(chan struct{})}
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "report", p))
+
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"n":42}`)
+	reqID, err := jm.SlowQuerySubmit("app1", "report", queryctx, input)
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		processQueuedRows(t, jm)
+	}()
+	<-p.reported
+
+	// While the query is running, its progress is returned as the result
+	status, result, _, _, err := jm.SlowQueryDone(reqID)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchTryLater, status)
+	assert.JSONEq(t, `{"percent":40,"message":"halfway"}`, result.String())
+
+	progress, err := jm.BatchProgress(reqID)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumInprog, progress.Status)
+	assert.Equal(t, 40, progress.Percent)
+	assert.Equal(t, "halfway", progress.Message)
+	assert.False(t, progress.ETA.IsZero())
+
+	close(p.release)
+	<-done
+	status, result, _, _, err = jm.SlowQueryDone(reqID)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchSuccess, status)
+	assert.JSONEq(t, `{"n":42}`, result.String())
+}
+
+// steppedProgressSlowQueryProcessor reports its progress twice, and waits to be told to go on after
+// each report
+type steppedProgressSlowQueryProcessor struct {
+	reported chan struct{}
+	next     chan struct{}
+}
+
+func (p steppedProgressSlowQueryProcessor) DoSlowQuery(ctx context.Context, initBlock InitBlock, queryctx JSONstr, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	for _, percent := range []int{40, 80} {
+		if err := ReportProgress(ctx, percent, "working"); err != nil {
+			return batchsqlc.StatusEnumFailed, JSONstr{}, nil, nil, err
+		}
+		p.reported <- struct{}{}
+		<-p.next
+	}
+	return batchsqlc.StatusEnumSuccess, input, nil, map[string]string{}, nil
+}
+
+func TestReportProgressCached(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	jm.StatusCache = NewLRUStatusCache(10)
+	p := steppedProgressSlowQueryProcessor{reported: make(chan struct{}), next: make(chan struct{})}
+	assert.NoError(t, jm.RegisterProcessorSlowQueryCtx("app1", "report", p))
+
+	queryctx, _ := NewJSONstr(`{}`)
+	input, _ := NewJSONstr(`{"n":42}`)
+	reqID, err := jm.SlowQuerySubmit("app1", "report", queryctx, input)
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		processQueuedRows(t, jm)
+	}()
+	<-p.reported
+
+	// The first report is cached by polling it, and the second one replaces it
+	status, result, _, _, err := jm.SlowQueryDone(reqID)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchTryLater, status)
+	assert.JSONEq(t, `{"percent":40,"message":"working"}`, result.String())
+
+	p.next <- struct{}{}
+	<-p.reported
+	status, result, _, _, err = jm.SlowQueryDone(reqID)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchTryLater, status)
+	assert.JSONEq(t, `{"percent":80,"message":"working"}`, result.String())
+	progress, err := jm.BatchProgress(reqID)
+	assert.NoError(t, err)
+	assert.Equal(t, 80, progress.Percent)
+
+	// Once the query is done, its result replaces the cached progress
+	close(p.next)
+	<-done
+	status, result, _, _, err = jm.SlowQueryDone(reqID)
+	assert.NoError(t, err)
+	assert.Equal(t, BatchSuccess, status)
+	assert.JSONEq(t, `{"n":42}`, result.String())
+	progress, err = jm.BatchProgress(reqID)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, progress.Status)
+}
@@ -0,0 +1,267 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ErrReducerAlreadyRegistered is returned when attempting to register a second reducer
+// for the same (app, op) combination.
+var ErrReducerAlreadyRegistered = errors.New("reducer already registered for this app and operation")
+
+// BatchReducer folds the results of the rows of the batches of an (app, op) into an aggregate, such
+// as totals per branch or reconciliation sums, so that MarkDone does not have to read every row again.
+// The aggregate is stored in the batches record and returned in BatchDetails_t.Aggregate and by
+// BatchAggregate; BatchDone does not return it, so that its results are unchanged for callers which do
+// not aggregate.
+type BatchReducer interface {
+	// Reduce is called with the results of the rows of a batch which have just succeeded or failed,
+	// in the transaction which records them, and returns the new aggregate. acc is the aggregate
+	// returned by the previous call, and is not valid on the first call for a batch. The rows of a
+	// batch are passed in the order in which they finish, which is not the order of their lines, and
+	// their Run is not set. If Reduce returns an error, the aggregate is left as it was, and the error
+	// is recorded on the batch, since the aggregate no longer covers those rows.
+	Reduce(batchctx JSONstr, acc JSONstr, results []BatchOutput_t) (JSONstr, error)
+	// Final is called once all the rows of the batch are done, before MarkDone, and returns the final
+	// aggregate, for instance with averages computed from sums and counts. If it returns an error,
+	// acc is kept as the aggregate and the error is recorded on the batch.
+	Final(batchctx JSONstr, acc JSONstr) (JSONstr, error)
+}
+
+// RegisterReducer registers the reducer which aggregates the results of the rows of the batches
+// of an (app, op). Rows which are dead-lettered or aborted are not passed to it.
+// Each (app, op) combination can only have one registered reducer.
+// The 'op' parameter is case-insensitive and will be converted to lowercase before registration.
+func (jm *JobManager) RegisterReducer(app string, op string, reducer BatchReducer) error {
+	op = strings.ToLower(op)
+
+	key := app + op
+	if _, exists := jm.reducers[key]; exists {
+		return fmt.Errorf("%w: app=%s, op=%s", ErrReducerAlreadyRegistered, app, op)
+	}
+	jm.reducers[key] = reducer
+	return nil
+}
+
+// BatchAggregate returns the status of a batch and the aggregate of its rows computed by the reducer
+// registered for its (app, op). While the batch is being processed, the aggregate covers the rows done
+// so far; once it is done, it is the one returned by Final. The aggregate is not valid if no row has
+// been reduced yet. aggregateErr is the error returned by the last call to Reduce or Final which
+// failed, if any, in which case the aggregate does not cover all the rows.
+func (jm *JobManager) BatchAggregate(batchID string) (status batchsqlc.StatusEnum, aggregate JSONstr, aggregateErr string, err error) {
+	batchUUID, err := uuid.Parse(batchID)
+	if err != nil {
+		return "", JSONstr{}, "", fmt.Errorf("invalid batch ID: %v", err)
+	}
+
+	batch, err := jm.Queries.GetBatchByID(context.Background(), batchUUID)
+	if err != nil {
+		return "", JSONstr{}, "", fmt.Errorf("failed to get batch by ID: %v", err)
+	}
+	aggregate, err = batchAggregate(batch)
+	if err != nil {
+		return "", JSONstr{}, "", err
+	}
+	return batch.Status, aggregate, batch.Aggregateerr.String, nil
+}
+
+// batchAggregate returns the aggregate stored in a batches record, which is not valid if there is none
+func batchAggregate(batch batchsqlc.Batch) (JSONstr, error) {
+	if batch.Aggregate == nil {
+		return JSONstr{}, nil
+	}
+	aggregate, err := NewJSONstr(string(batch.Aggregate))
+	if err != nil {
+		return JSONstr{}, fmt.Errorf("failed to parse aggregate of batch %s: %v", batch.ID, err)
+	}
+	return aggregate, nil
+}
+
+// reduceRowResults passes the results of the rows recorded by recordRowResults to the reducers of
+// their batches. The batches records are already locked by the statement which recorded the rows.
+func (jm *JobManager) reduceRowResults(txQueries batchsqlc.Querier, results *rowResults, params batchsqlc.BulkUpdateBatchRowsBatchJobParams, recorded []int64) {
+	if len(jm.reducers) == 0 || len(recorded) == 0 {
+		return
+	}
+	isRecorded := make(map[int64]bool, len(recorded))
+	for _, rowid := range recorded {
+		isRecorded[rowid] = true
+	}
+
+	var batches []uuid.UUID
+	outputs := make(map[uuid.UUID][]BatchOutput_t)
+	for i, row := range results.rows {
+		if !isRecorded[row.Rowid] {
+			continue
+		}
+		if _, exists := jm.reducers[row.App+row.Op]; !exists {
+			continue
+		}
+		status := batchsqlc.StatusEnum(params.Status[i])
+		if !hasStatus(status, batchsqlc.StatusEnumSuccess, batchsqlc.StatusEnumFailed) {
+			continue
+		}
+		output, err := newBatchOutput(row.Line, status, params.Res[i], params.Messages[i], 0)
+		if err != nil {
+			log.Printf("Error reducing row %d of batch %s: %v", row.Rowid, row.Batch, err)
+			continue
+		}
+		if _, exists := outputs[row.Batch]; !exists {
+			batches = append(batches, row.Batch)
+		}
+		outputs[row.Batch] = append(outputs[row.Batch], output)
+	}
+
+	for _, batchID := range batches {
+		if err := jm.reduceBatch(txQueries, batchID, outputs[batchID]); err != nil {
+			log.Printf("Error reducing results of batch %s: %v", batchID, err)
+			if err := updateBatchAggregateErr(txQueries, batchID, err.Error()); err != nil {
+				log.Printf("Error recording reducer failure of batch %s: %v", batchID, err)
+			}
+		}
+	}
+}
+
+// reduceBatch adds the results of some rows of a batch to its aggregate
+func (jm *JobManager) reduceBatch(txQueries batchsqlc.Querier, batchID uuid.UUID, outputs []BatchOutput_t) error {
+	batch, err := txQueries.GetBatchByID(context.Background(), batchID)
+	if err != nil {
+		return fmt.Errorf("failed to get batch by ID: %v", err)
+	}
+	batchctx, err := NewJSONstr(string(batch.Context))
+	if err != nil {
+		return fmt.Errorf("failed to parse context: %v", err)
+	}
+	acc, err := batchAggregate(batch)
+	if err != nil {
+		return err
+	}
+
+	acc, err = jm.reducers[batch.App+batch.Op].Reduce(batchctx, acc, outputs)
+	if err != nil {
+		return fmt.Errorf("reducer failed: %v", err)
+	}
+	return updateBatchAggregate(txQueries, batchID, acc)
+}
+
+// finalizeAggregate calls the Final method of the reducer of a batch which is done, stores the final
+// aggregate and returns it, along with the error recorded on the batch if the reducer failed. It
+// returns an invalid aggregate for the batches of an (app, op) without a reducer.
+func (jm *JobManager) finalizeAggregate(q batchsqlc.Querier, batch batchsqlc.Batch) (JSONstr, string, error) {
+	reducer, exists := jm.reducers[batch.App+batch.Op]
+	if !exists {
+		return JSONstr{}, "", nil
+	}
+	batchctx, err := NewJSONstr(string(batch.Context))
+	if err != nil {
+		return JSONstr{}, "", fmt.Errorf("failed to parse context: %v", err)
+	}
+	acc, err := batchAggregate(batch)
+	if err != nil {
+		return JSONstr{}, "", err
+	}
+
+	aggregate, err := reducer.Final(batchctx, acc)
+	if err != nil {
+		log.Printf("Final aggregation failed for batch %s: %v", batch.ID, err)
+		aggregateErr := fmt.Sprintf("final aggregation failed: %v", err)
+		if err := updateBatchAggregateErr(q, batch.ID, aggregateErr); err != nil {
+			return JSONstr{}, "", err
+		}
+		return acc, aggregateErr, nil
+	}
+	if err := updateBatchAggregate(q, batch.ID, aggregate); err != nil {
+		return JSONstr{}, "", err
+	}
+	return aggregate, batch.Aggregateerr.String, nil
+}
+
+// reduceBatchAgain computes the aggregate of a batch again from the rows which are still done, for a
+// batch whose rows are being retried by BatchRetry. The rows are reduced a page at a time, in the
+// order of their lines; the retried rows are added to the aggregate once they are done again.
+func (jm *JobManager) reduceBatchAgain(txQueries batchsqlc.Querier, batch batchsqlc.Batch) error {
+	reducer, exists := jm.reducers[batch.App+batch.Op]
+	if !exists {
+		return nil
+	}
+	batchctx, err := NewJSONstr(string(batch.Context))
+	if err != nil {
+		return fmt.Errorf("failed to parse context: %v", err)
+	}
+
+	params := batchsqlc.ListBatchRowResultsParams{
+		Batch:    batch.ID,
+		Statuses: []string{string(batchsqlc.StatusEnumSuccess), string(batchsqlc.StatusEnumFailed)},
+		Toline:   math.MaxInt32,
+		Pagesize: ALYA_LIST_PAGESIZE,
+	}
+	var acc JSONstr
+	for {
+		rows, err := txQueries.ListBatchRowResults(context.Background(), params)
+		if err != nil {
+			return fmt.Errorf("failed to list results: %v", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		outputs := make([]BatchOutput_t, len(rows))
+		for i, row := range rows {
+			outputs[i], err = newBatchOutput(row.Line, row.Status, row.Res, row.Messages, row.Runno)
+			if err != nil {
+				return err
+			}
+		}
+		acc, err = reducer.Reduce(batchctx, acc, outputs)
+		if err != nil {
+			return fmt.Errorf("reducer failed: %v", err)
+		}
+		if len(rows) < int(params.Pagesize) {
+			break
+		}
+		last := rows[len(rows)-1]
+		params.Afterline = pgtype.Int4{Int32: last.Line, Valid: true}
+		params.Afterrowid = pgtype.Int8{Int64: last.Rowid, Valid: true}
+	}
+	// The aggregate now covers all the rows which are done, so an earlier failure no longer applies
+	if err := updateBatchAggregateErr(txQueries, batch.ID, ""); err != nil {
+		return err
+	}
+	return updateBatchAggregate(txQueries, batch.ID, acc)
+}
+
+// updateBatchAggregate stores the aggregate of a batch, or removes it if aggregate is not valid
+func updateBatchAggregate(q batchsqlc.Querier, batchID uuid.UUID, aggregate JSONstr) error {
+	var aggregateJSON []byte
+	if aggregate.IsValid() {
+		aggregateJSON = []byte(aggregate.String())
+	}
+	err := q.UpdateBatchAggregate(context.Background(), batchsqlc.UpdateBatchAggregateParams{
+		ID:        batchID,
+		Aggregate: aggregateJSON,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update aggregate of batch %s: %v", batchID, err)
+	}
+	return nil
+}
+
+// updateBatchAggregateErr records the error returned by the reducer of a batch, or clears it if
+// aggregateErr is empty
+func updateBatchAggregateErr(q batchsqlc.Querier, batchID uuid.UUID, aggregateErr string) error {
+	err := q.UpdateBatchAggregateErr(context.Background(), batchsqlc.UpdateBatchAggregateErrParams{
+		ID:           batchID,
+		Aggregateerr: pgtype.Text{String: aggregateErr, Valid: aggregateErr != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update aggregate error of batch %s: %v", batchID, err)
+	}
+	return nil
+}
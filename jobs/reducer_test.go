@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/stretchr/testify/assert"
+)
+
+// sumReducer adds up the results of the successful rows and counts the failed ones
+type sumReducer struct{}
+
+type sumAggregate struct {
+	Sum    int  `json:"sum"`
+	Failed int  `json:"failed"`
+	Final  bool `json:"final"`
+}
+
+func (sumReducer) Reduce(batchctx JSONstr, acc JSONstr, results []BatchOutput_t) (JSONstr, error) {
+	var agg sumAggregate
+	if acc.IsValid() {
+		if err := json.Unmarshal([]byte(acc.String()), &agg); err != nil {
+			return JSONstr{}, err
+		}
+	}
+	for _, result := range results {
+		if result.Status != BatchSuccess {
+			agg.Failed++
+			continue
+		}
+		var n int
+		if err := json.Unmarshal([]byte(result.Res.String()), &n); err != nil {
+			return JSONstr{}, err
+		}
+		agg.Sum += n
+	}
+	out, _ := json.Marshal(agg)
+	return NewJSONstr(string(out))
+}
+
+func (sumReducer) Final(batchctx JSONstr, acc JSONstr) (JSONstr, error) {
+	var agg sumAggregate
+	if acc.IsValid() {
+		if err := json.Unmarshal([]byte(acc.String()), &agg); err != nil {
+			return JSONstr{}, err
+		}
+	}
+	agg.Final = true
+	out, _ := json.Marshal(agg)
+	return NewJSONstr(string(out))
+}
+
+func aggregateOf(t *testing.T, aggregate JSONstr) sumAggregate {
+	var agg sumAggregate
+	if assert.True(t, aggregate.IsValid()) {
+		assert.NoError(t, json.Unmarshal([]byte(aggregate.String()), &agg))
+	}
+	return agg
+}
+
+func TestBatchReducer(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 2)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "sum", p))
+	assert.NoError(t, jm.RegisterReducer("app1", "SUM", sumReducer{}))
+	err := jm.RegisterReducer("app1", "sum", sumReducer{})
+	assert.True(t, errors.Is(err, ErrReducerAlreadyRegistered))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for i, value := range []string{`1`, `2`, `"fail"`, `4`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "sum", batchctx, input, false)
+	assert.NoError(t, err)
+
+	// Nothing is reduced before the rows are processed
+	status, aggregate, _, err := jm.BatchAggregate(batchID)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumQueued, status)
+	assert.False(t, aggregate.IsValid())
+
+	processQueuedRows(t, jm)
+	details := <-p.markDoneCalled
+	assert.Equal(t, sumAggregate{Sum: 7, Failed: 1, Final: true}, aggregateOf(t, details.Aggregate))
+	assert.Empty(t, details.AggregateErr)
+
+	status, aggregate, aggregateErr, err := jm.BatchAggregate(batchID)
+	assert.NoError(t, err)
+	assert.Empty(t, aggregateErr)
+	assert.Equal(t, batchsqlc.StatusEnumFailed, status)
+	assert.Equal(t, sumAggregate{Sum: 7, Failed: 1, Final: true}, aggregateOf(t, aggregate))
+
+	batchlist, _, err := jm.BatchList("app1", "sum", 1, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, batchlist, 1) {
+		assert.Equal(t, aggregate.String(), batchlist[0].Aggregate.String())
+	}
+
+	// The retried row is taken out of the aggregate until it is done again
+	nrows, err := jm.BatchRetry(batchID, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, nrows)
+	_, aggregate, _, err = jm.BatchAggregate(batchID)
+	assert.NoError(t, err)
+	assert.Equal(t, sumAggregate{Sum: 7}, aggregateOf(t, aggregate))
+
+	processQueuedRows(t, jm)
+	details = <-p.markDoneCalled
+	assert.Equal(t, sumAggregate{Sum: 7, Failed: 1, Final: true}, aggregateOf(t, details.Aggregate))
+}
+
+func TestBatchWithoutReducer(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "echo", p))
+
+	batchctx, _ := NewJSONstr(`{}`)
+	rowInput, _ := NewJSONstr(`1`)
+	batchID, err := jm.BatchSubmit("app1", "echo", batchctx, []BatchInput_t{{Line: 1, Input: rowInput}}, false)
+	assert.NoError(t, err)
+
+	processQueuedRows(t, jm)
+	details := <-p.markDoneCalled
+	assert.False(t, details.Aggregate.IsValid())
+	_, aggregate, _, err := jm.BatchAggregate(batchID)
+	assert.NoError(t, err)
+	assert.False(t, aggregate.IsValid())
+}
+
+func TestBatchReducerFailure(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	p := &echoBatchProcessor{markDoneCalled: make(chan BatchDetails_t, 1)}
+	assert.NoError(t, jm.RegisterProcessorBatchCtx("app1", "sum", p))
+	assert.NoError(t, jm.RegisterReducer("app1", "sum", sumReducer{}))
+
+	// The result of the second row is not a number, so sumReducer fails to reduce it
+	batchctx, _ := NewJSONstr(`{}`)
+	var input []BatchInput_t
+	for i, value := range []string{`1`, `"two"`} {
+		rowInput, _ := NewJSONstr(value)
+		input = append(input, BatchInput_t{Line: i + 1, Input: rowInput})
+	}
+	batchID, err := jm.BatchSubmit("app1", "sum", batchctx, input, false)
+	assert.NoError(t, err)
+
+	processQueuedRows(t, jm)
+	details := <-p.markDoneCalled
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, details.Status)
+	assert.Contains(t, details.AggregateErr, "reducer failed")
+
+	_, _, aggregateErr, err := jm.BatchAggregate(batchID)
+	assert.NoError(t, err)
+	assert.Equal(t, details.AggregateErr, aggregateErr)
+
+	batchlist, _, err := jm.BatchList("app1", "sum", 1, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, batchlist, 1) {
+		assert.Equal(t, aggregateErr, batchlist[0].AggregateErr)
+	}
+}
@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/wscutils"
+)
+
+// ErrRetryPolicyAlreadyRegistered is returned when attempting to register a second retry policy
+// for the same (app, op) combination.
+var ErrRetryPolicyAlreadyRegistered = errors.New("retry policy already registered for this app and operation")
+
+// ErrInvalidRetryPolicy is returned when registering a retry policy which cannot be applied.
+var ErrInvalidRetryPolicy = errors.New("invalid retry policy")
+
+// RetryPolicy specifies how a row is retried when the processor returns an error for it.
+// A row is retried until it has been attempted MaxAttempts times, after which it is moved to the
+// dead-letter state (see DeadLetterList()) with the error in its messages. Rows failing with an error
+// which is not retryable are recorded as failed.
+//
+// The delay before each retry is taken from BackoffSchedule if it is set: the n-th retry waits
+// BackoffSchedule[n-1], and retries beyond the end of the schedule wait for its last entry.
+// Otherwise the delay grows exponentially, starting at InitialBackoff and doubling on every
+// retry, capped at MaxBackoff (if set).
+type RetryPolicy struct {
+	MaxAttempts     int                  // total number of attempts, including the first one
+	InitialBackoff  time.Duration        // delay before the first retry when BackoffSchedule is not set
+	MaxBackoff      time.Duration        // upper bound on the exponential delay, 0 means no upper bound
+	BackoffSchedule []time.Duration      // explicit delay before each retry
+	IsRetryable     func(err error) bool // reports whether an error is transient; nil means all errors are
+}
+
+// validate checks whether the policy can be applied.
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("%w: MaxAttempts must be at least 1", ErrInvalidRetryPolicy)
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("%w: backoff durations must not be negative", ErrInvalidRetryPolicy)
+	}
+	for _, d := range p.BackoffSchedule {
+		if d < 0 {
+			return fmt.Errorf("%w: backoff durations must not be negative", ErrInvalidRetryPolicy)
+		}
+	}
+	return nil
+}
+
+// shouldRetry reports whether a row which failed with err on the given attempt (counting from 1)
+// must be attempted again.
+func (p RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.IsRetryable == nil || p.IsRetryable(err)
+}
+
+// backoff returns the delay before the retry which follows the given attempt (counting from 1).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if len(p.BackoffSchedule) > 0 {
+		if attempt > len(p.BackoffSchedule) {
+			attempt = len(p.BackoffSchedule)
+		}
+		return p.BackoffSchedule[attempt-1]
+	}
+	delay := p.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
+
+// RegisterRetryPolicy registers the retry policy to be applied to rows of a specific (app, op)
+// combination, for both batch jobs and slow queries. Rows of an (app, op) combination without
+// a retry policy are attempted only once.
+// Each (app, op) combination can only have one registered retry policy.
+// The 'op' parameter is case-insensitive and will be converted to lowercase before registration.
+func (jm *JobManager) RegisterRetryPolicy(app string, op string, policy RetryPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+
+	// Convert op to lowercase, as it is stored in the database
+	op = strings.ToLower(op)
+
+	key := app + op
+	_, exists := jm.retrypolicies[key]
+	if exists {
+		return fmt.Errorf("%w: app=%s, op=%s", ErrRetryPolicyAlreadyRegistered, app, op)
+	}
+	jm.retrypolicies[key] = policy
+	return nil
+}
+
+// retryRow puts a row for which the processor returned err back in the queue if the retry policy for
+// its (app, op) allows another attempt. The row becomes eligible for fetching again once the backoff
+// has elapsed. It returns true if the row was requeued.
+func (jm *JobManager) retryRow(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow, err error) (bool, error) {
+	policy, exists := jm.retrypolicies[row.App+row.Op]
+	if !exists {
+		return false, nil
+	}
+
+	// row.Attempts is the count before this row was leased, so this was attempt row.Attempts+1
+	attempt := int(row.Attempts) + 1
+	if !policy.shouldRetry(attempt, err) {
+		return false, nil
+	}
+
+	nextTry := time.Now().Add(policy.backoff(attempt))
+	updateErr := txQueries.RetryBatchRow(context.Background(), batchsqlc.RetryBatchRowParams{
+		Rowid:   row.Rowid,
+		Nexttry: pgtype.Timestamp{Time: nextTry, Valid: true},
+		Lasterr: pgtype.Text{String: err.Error(), Valid: true},
+		Doneby:  jm.doneBy(),
+	})
+	if updateErr != nil {
+		return false, fmt.Errorf("failed to requeue row %d for retry: %v", row.Rowid, updateErr)
+	}
+	log.Printf("Row %d of batch %s failed on attempt %d, retrying at %v: %v", row.Rowid, row.Batch, attempt, nextTry, err)
+	return true, nil
+}
+
+// handleRowError applies the retry policy for its (app, op) to a row for which the processor returned
+// err: the row is requeued if it is to be retried, or moved to the dead-letter state if the policy has
+// been exhausted. It reports whether either was done; if not, the row is to be recorded as failed with
+// the error in its messages.
+func (jm *JobManager) handleRowError(txQueries batchsqlc.Querier, row batchsqlc.FetchBlockOfRowsRow, err error) (status batchsqlc.StatusEnum, handled bool, updateErr error) {
+	retried, retryErr := jm.retryRow(txQueries, row, err)
+	if retryErr != nil {
+		return batchsqlc.StatusEnumFailed, true, retryErr
+	}
+	if retried {
+		return batchsqlc.StatusEnumQueued, true, nil
+	}
+	if jm.retriesExhausted(row, err) {
+		status, err := jm.deadLetterRow(txQueries, row, err, "")
+		return status, true, err
+	}
+	return batchsqlc.StatusEnumFailed, false, nil
+}
+
+// rowErrorMessage converts an error returned by a processor into the message recorded with the row.
+func rowErrorMessage(err error) wscutils.ErrorMessage {
+	if errors.Is(err, ErrRowTimedOut) {
+		return wscutils.BuildErrorMessage(MsgIDRowTimedOut, ErrcodeRowTimedOut, "", err.Error())
+	}
+	return wscutils.BuildErrorMessage(MsgIDRowProcessingFailed, ErrcodeRowProcessingFailed, "", err.Error())
+}
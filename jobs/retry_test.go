@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	exponential := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	assert.Equal(t, time.Second, exponential.backoff(1))
+	assert.Equal(t, 2*time.Second, exponential.backoff(2))
+	assert.Equal(t, 4*time.Second, exponential.backoff(3))
+	assert.Equal(t, 8*time.Second, exponential.backoff(4))
+	assert.Equal(t, 10*time.Second, exponential.backoff(5))
+	assert.Equal(t, 10*time.Second, exponential.backoff(9))
+
+	schedule := RetryPolicy{MaxAttempts: 10, BackoffSchedule: []time.Duration{time.Second, time.Minute}}
+	assert.Equal(t, time.Second, schedule.backoff(1))
+	assert.Equal(t, time.Minute, schedule.backoff(2))
+	assert.Equal(t, time.Minute, schedule.backoff(5))
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	errInvalid := errors.New("invalid account")
+
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		IsRetryable: func(err error) bool { return errors.Is(err, errTimeout) },
+	}
+	assert.True(t, policy.shouldRetry(1, errTimeout))
+	assert.True(t, policy.shouldRetry(2, errTimeout))
+	assert.False(t, policy.shouldRetry(3, errTimeout))
+	assert.False(t, policy.shouldRetry(1, errInvalid))
+
+	// Without IsRetryable every error is retried
+	assert.True(t, RetryPolicy{MaxAttempts: 2}.shouldRetry(1, errInvalid))
+}
+
+func TestRegisterRetryPolicy(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+
+	err := jm.RegisterRetryPolicy("app1", "OP1", RetryPolicy{MaxAttempts: 3})
+	assert.NoError(t, err)
+	_, exists := jm.retrypolicies["app1op1"]
+	assert.True(t, exists)
+
+	// Test registering a duplicate policy
+	err = jm.RegisterRetryPolicy("app1", "op1", RetryPolicy{MaxAttempts: 5})
+	assert.True(t, errors.Is(err, ErrRetryPolicyAlreadyRegistered))
+
+	// Test registering invalid policies
+	err = jm.RegisterRetryPolicy("app1", "op2", RetryPolicy{})
+	assert.True(t, errors.Is(err, ErrInvalidRetryPolicy))
+	err = jm.RegisterRetryPolicy("app1", "op2", RetryPolicy{MaxAttempts: 2, BackoffSchedule: []time.Duration{-time.Second}})
+	assert.True(t, errors.Is(err, ErrInvalidRetryPolicy))
+}
+
+func TestRetryRow(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+	err := jm.RegisterRetryPolicy("app1", "op1", RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Minute})
+	assert.NoError(t, err)
+
+	mockQuerier := &mocks.QuerierMock{
+		RetryBatchRowFunc: func(ctx context.Context, arg batchsqlc.RetryBatchRowParams) error {
+			return nil
+		},
+	}
+	row := batchsqlc.FetchBlockOfRowsRow{App: "app1", Op: "op1", Batch: uuid.New(), Rowid: 42}
+
+	// First attempt: the row is requeued with the backoff
+	before := time.Now()
+	retried, err := jm.retryRow(mockQuerier, row, errors.New("smtp unavailable"))
+	assert.NoError(t, err)
+	assert.True(t, retried)
+	assert.Len(t, mockQuerier.RetryBatchRowCalls(), 1)
+	call := mockQuerier.RetryBatchRowCalls()[0].Arg
+	assert.Equal(t, int64(42), call.Rowid)
+	assert.Equal(t, jm.WorkerID, call.Doneby.String)
+	assert.Equal(t, "smtp unavailable", call.Lasterr.String)
+	assert.WithinDuration(t, before.Add(time.Minute), call.Nexttry.Time, 5*time.Second)
+
+	// Second and last attempt: the row is not requeued
+	row.Attempts = 1
+	retried, err = jm.retryRow(mockQuerier, row, errors.New("smtp unavailable"))
+	assert.NoError(t, err)
+	assert.False(t, retried)
+	assert.Len(t, mockQuerier.RetryBatchRowCalls(), 1)
+
+	// No policy registered for the op
+	row.Op = "op2"
+	row.Attempts = 0
+	retried, err = jm.retryRow(mockQuerier, row, errors.New("smtp unavailable"))
+	assert.NoError(t, err)
+	assert.False(t, retried)
+}
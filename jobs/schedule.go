@@ -0,0 +1,190 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ErrRecurringBatchAlreadyRegistered is returned when attempting to register a second recurring
+// batch with the same name.
+var ErrRecurringBatchAlreadyRegistered = errors.New("recurring batch already registered with this name")
+
+// BatchProducer produces the context and input of the batch to be submitted for a tick of a recurring
+// batch. It is passed the time of the tick, which may be a little in the past. If it returns no input,
+// no batch is submitted for the tick. If it returns an error, the tick is tried again at the next check.
+type BatchProducer func(tick time.Time) (batchctx JSONstr, batchInput []BatchInput_t, err error)
+
+// recurringBatch is a recurring batch registered with RegisterRecurringBatch
+type recurringBatch struct {
+	app      string
+	op       string
+	cronSpec string
+	schedule cronSchedule
+	produce  BatchProducer
+	opts     submitOptions
+}
+
+// RegisterRecurringBatch registers a batch to be submitted for (app, op) at each tick of a cron
+// expression, with the context and input returned by produce. The schedule is recorded in the
+// database under name, and the instance submitting the batch for a tick locks it, so that each tick
+// is submitted exactly once however many JobManager instances run with the same registration.
+// If no instance was running at some ticks, a single batch is submitted for the latest of them.
+// The cron expression has five fields (minute, hour, day of month, month, day of week) and is
+// evaluated in the local time zone. opts apply to every batch submitted, except WithNotBefore and
+// WithIdempotencyKey, since each tick is already submitted once.
+// Recurring batches must be registered before Run is called.
+func (jm *JobManager) RegisterRecurringBatch(name, app, op, cronSpec string, produce BatchProducer, opts ...SubmitOption) error {
+	schedule, err := parseCronSpec(cronSpec)
+	if err != nil {
+		return err
+	}
+	if schedule.next(time.Now()).IsZero() {
+		return fmt.Errorf("cron expression %q never matches", cronSpec)
+	}
+
+	if _, exists := jm.recurringbatches[name]; exists {
+		return fmt.Errorf("%w: name=%s", ErrRecurringBatchAlreadyRegistered, name)
+	}
+	jm.recurringbatches[name] = recurringBatch{
+		app:      app,
+		op:       strings.ToLower(op),
+		cronSpec: cronSpec,
+		schedule: schedule,
+		produce:  produce,
+		opts:     newSubmitOptions(ALYA_BATCH_PRIORITY, opts),
+	}
+	return nil
+}
+
+// runScheduler submits the recurring batches registered with this instance when they are due,
+// checking every Config.SchedulerIntervalSec seconds. It returns when ctx is cancelled.
+func (jm *JobManager) runScheduler(ctx context.Context) {
+	if len(jm.recurringbatches) == 0 {
+		return
+	}
+	names := make([]string, 0, len(jm.recurringbatches))
+	for name := range jm.recurringbatches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	interval := time.Duration(jm.Config.SchedulerIntervalSec) * time.Second
+	synced := false
+	for ctx.Err() == nil {
+		if !synced {
+			if err := jm.syncRecurringBatches(ctx); err != nil {
+				log.Printf("Error recording recurring batches: %v", err)
+			} else {
+				synced = true
+			}
+		}
+		if synced {
+			for _, name := range names {
+				if err := jm.submitRecurringBatch(ctx, name); err != nil {
+					log.Printf("Error submitting recurring batch %s: %v", name, err)
+				}
+			}
+		}
+		sleepWithContext(ctx, interval)
+	}
+}
+
+// syncRecurringBatches records the recurring batches registered with this instance in the database.
+// The next tick of a recurring batch already recorded is kept, unless its cron expression has changed.
+func (jm *JobManager) syncRecurringBatches(ctx context.Context) error {
+	now := time.Now()
+	for name, rb := range jm.recurringbatches {
+		err := jm.Queries.UpsertRecurringJob(ctx, batchsqlc.UpsertRecurringJobParams{
+			Name:     name,
+			App:      rb.app,
+			Op:       rb.op,
+			Cronspec: rb.cronSpec,
+			Nextrun:  pgtype.Timestamp{Time: rb.schedule.next(now), Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record recurring batch %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// submitRecurringBatch submits the batch for the current tick of a recurring batch, if it is due and
+// not being submitted by another instance. The batch is inserted in the same transaction which moves
+// the recurring batch to its next tick, so a tick cannot be submitted twice.
+func (jm *JobManager) submitRecurringBatch(ctx context.Context, name string) error {
+	rb := jm.recurringbatches[name]
+
+	tx, err := jm.Store.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	txQueries := tx.Queries()
+
+	now := time.Now()
+	due, err := txQueries.GetDueRecurringJob(ctx, batchsqlc.GetDueRecurringJobParams{
+		Name:    name,
+		Nextrun: pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Not due yet, or being submitted by another instance
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get recurring batch: %v", err)
+	}
+
+	// Timestamps are stored without a time zone
+	tick := time.Date(due.Nextrun.Time.Year(), due.Nextrun.Time.Month(), due.Nextrun.Time.Day(),
+		due.Nextrun.Time.Hour(), due.Nextrun.Time.Minute(), 0, 0, time.Local)
+	batchctx, batchInput, err := rb.produce(tick)
+	if err != nil {
+		return fmt.Errorf("failed to produce input for tick %v: %v", tick, err)
+	}
+
+	var lastBatch pgtype.UUID
+	if len(batchInput) > 0 {
+		opts := rb.opts
+		opts.notBefore = time.Time{}
+		opts.idempotencyKey = ""
+		batchID, _, err := insertBatch(ctx, txQueries, rb.app, rb.op, batchctx, batchInput, batchsqlc.StatusEnumQueued, opts)
+		if err != nil {
+			return fmt.Errorf("failed to submit batch for tick %v: %v", tick, err)
+		}
+		lastBatch = pgtype.UUID{Bytes: batchID, Valid: true}
+	}
+
+	// Ticks missed while no instance was running are skipped
+	err = txQueries.UpdateRecurringJobRun(ctx, batchsqlc.UpdateRecurringJobRunParams{
+		Name:      name,
+		Lastrun:   pgtype.Timestamp{Time: tick, Valid: true},
+		Lastbatch: lastBatch,
+		Nextrun:   pgtype.Timestamp{Time: rb.schedule.next(now), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record run of tick %v: %v", tick, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	if lastBatch.Valid {
+		jm.publishEvent(BatchEvent{BatchID: uuid.UUID(lastBatch.Bytes).String(), Type: BatchEventQueued, Status: batchsqlc.StatusEnumQueued})
+		log.Printf("Submitted batch %s with %d rows for tick %v of recurring batch %s", uuid.UUID(lastBatch.Bytes), len(batchInput), tick, name)
+	} else {
+		log.Printf("No input for tick %v of recurring batch %s", tick, name)
+	}
+	return nil
+}
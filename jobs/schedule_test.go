@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterRecurringBatch(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+	produce := func(tick time.Time) (JSONstr, []BatchInput_t, error) {
+		return JSONstr{}, nil, nil
+	}
+
+	assert.NoError(t, jm.RegisterRecurringBatch("nightly-interest", "banking", "Calc_Interest", "0 2 * * *", produce, WithPriority(-10)))
+	rb := jm.recurringbatches["nightly-interest"]
+	assert.Equal(t, "calc_interest", rb.op)
+	assert.Equal(t, -10, rb.opts.priority)
+
+	err := jm.RegisterRecurringBatch("nightly-interest", "banking", "calc_interest", "0 3 * * *", produce)
+	assert.True(t, errors.Is(err, ErrRecurringBatchAlreadyRegistered))
+
+	assert.Error(t, jm.RegisterRecurringBatch("bad-spec", "banking", "calc_interest", "0 2 * *", produce))
+	assert.Error(t, jm.RegisterRecurringBatch("never", "banking", "calc_interest", "0 0 31 4 *", produce))
+}
+
+func TestSubmitOptionsNotBefore(t *testing.T) {
+	assert.True(t, newSubmitOptions(ALYA_BATCH_PRIORITY, nil).notBefore.IsZero())
+
+	at := time.Date(2024, 3, 31, 23, 0, 0, 0, time.Local)
+	opts := newSubmitOptions(ALYA_BATCH_PRIORITY, []SubmitOption{WithNotBefore(at)})
+	assert.Equal(t, at, opts.notBefore)
+}
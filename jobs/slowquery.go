@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
@@ -26,6 +25,15 @@ var ErrProcessorAlreadyRegistered = errors.New("processor already registered for
 // Attempting to register a second processor for the same combination will result in an error.
 // The 'op' parameter is case-insensitive and will be converted to lowercase before registration.
 func (jm *JobManager) RegisterProcessorSlowQuery(app string, op string, p SlowQueryProcessor) error {
+	return jm.RegisterProcessorSlowQueryCtx(app, op, slowQueryProcessorAdapter{p})
+}
+
+// RegisterProcessorSlowQueryCtx is like RegisterProcessorSlowQuery, for processors which implement the
+// SlowQueryProcessorCtx interface and can therefore be cancelled while processing a slow query.
+func (jm *JobManager) RegisterProcessorSlowQueryCtx(app string, op string, p SlowQueryProcessorCtx) error {
+	// Convert op to lowercase, as it is stored in the database
+	op = strings.ToLower(op)
+
 	key := app + op
 	_, exists := jm.slowqueryprocessorfuncs[key]
 	if exists {
@@ -35,41 +43,76 @@ func (jm *JobManager) RegisterProcessorSlowQuery(app string, op string, p SlowQu
 	return nil
 }
 
-func (jm *JobManager) SlowQuerySubmit(app, op string, inputContext, input JSONstr) (reqID string, err error) {
+// SlowQuerySubmit submits a new slow query for processing, as a batch with a single row with line 0.
+// The slow query has priority ALYA_SLOWQUERY_PRIORITY unless WithPriority is passed in opts, and it is
+// not processed before the time passed with WithNotBefore, if any. If a slow query of the (app, op)
+// was already submitted with the key passed with WithIdempotencyKey, its ID is returned instead, or
+// ErrIdempotencyConflict if its context or input were different.
+// If the (app, op) is memoized with RegisterSlowQueryMemo, the ID of an equivalent slow query is
+// returned instead, unless WithoutMemo or a key which was not used before is passed.
+func (jm *JobManager) SlowQuerySubmit(app, op string, inputContext, input JSONstr, opts ...SubmitOption) (reqID string, err error) {
+	submitOpts := newSubmitOptions(ALYA_SLOWQUERY_PRIORITY, opts)
+
 	// Start a database transaction
-	tx, err := jm.Db.Begin(context.Background())
+	tx, err := jm.Store.Begin(context.Background())
 	if err != nil {
 		return "", err
 	}
 	defer tx.Rollback(context.Background())
 
 	ctx := context.Background()
+	txQueries := tx.Queries()
 
-	batchId, err := uuid.NewUUID()
-	if err != nil {
-		log.Printf("SlowQuery.Submit uuid.NewUUID failed: %v", err)
-		return "", err
+	// A query submitted again with its idempotency key is the one first submitted with the key
+	var payloadHash, memoHash []byte
+	if submitOpts.idempotencyKey != "" {
+		payloadHash = hashSlowQuery(inputContext, input)
+		batchId, found, err := findSubmittedBatch(ctx, txQueries, app, op, submitOpts.idempotencyKey, payloadHash)
+		if err != nil {
+			log.Printf("SlowQuery.Submit %v", err)
+			return "", err
+		}
+		if found {
+			log.Printf("SlowQuery.Submit slow query %s was already submitted with idempotency key %s", batchId, submitOpts.idempotencyKey)
+			return batchId.String(), nil
+		}
 	}
 
-	// Convert op to lowercase before inserting into the database
-	op = strings.ToLower(op)
+	// Reuse an equivalent slow query if the (app, op) is memoized. A query submitted with a new
+	// idempotency key is queued, so that the key is recorded and later submissions with it find the query.
+	memoTTL, memoized := jm.memottls[app+strings.ToLower(op)]
+	if memoized {
+		memoHash = hashSlowQuery(inputContext, input)
+		if !submitOpts.bypassMemo && submitOpts.idempotencyKey == "" {
+			reqID, found, err := memoizedQuery(ctx, txQueries, app, op, memoHash, memoTTL)
+			if err != nil {
+				log.Printf("SlowQuery.Submit %v", err)
+				return "", err
+			}
+			if found {
+				log.Printf("SlowQuery.Submit reusing memoized slow query %s", reqID)
+				return reqID.String(), nil
+			}
+		}
+	}
 
 	// Use sqlc generated function to insert into batches table
-	_, err = jm.Queries.InsertIntoBatches(ctx, batchsqlc.InsertIntoBatchesParams{
-		ID:      batchId,
-		App:     app,
-		Op:      op,
-		Context: []byte(inputContext.String()),
-		Status:  batchsqlc.StatusEnumQueued,
-		Reqat:   pgtype.Timestamp{Time: time.Now(), Valid: true},
-	})
+	batchId, created, err := insertBatchRecord(ctx, txQueries, app, op, inputContext, batchsqlc.StatusEnumQueued, submitOpts, payloadHash, memoHash)
 	if err != nil {
 		log.Printf("SlowQuery.Submit InsertIntoBatchesFailed: %v", err)
 		return "", err
 	}
+	if !created {
+		batchId, err = submittedBatch(ctx, txQueries, app, op, submitOpts.idempotencyKey, payloadHash)
+		if err != nil {
+			return "", err
+		}
+		log.Printf("SlowQuery.Submit slow query %s was already submitted with idempotency key %s", batchId, submitOpts.idempotencyKey)
+		return batchId.String(), nil
+	}
 
 	// Use sqlc generated function to insert into batchrows table
-	err = jm.Queries.InsertIntoBatchRows(ctx, batchsqlc.InsertIntoBatchRowsParams{
+	err = txQueries.InsertIntoBatchRows(ctx, batchsqlc.InsertIntoBatchRowsParams{
 		Batch: batchId,
 		Line:  0,
 		Input: []byte(input.String()),
@@ -80,12 +123,20 @@ func (jm *JobManager) SlowQuerySubmit(app, op string, inputContext, input JSONst
 		return "", err
 	}
 
+	// Wake up idle workers once the slow query is committed
+	if err := notifyBatchQueued(ctx, txQueries, batchId); err != nil {
+		log.Printf("SlowQuery.Submit %v", err)
+		return "", err
+	}
+
 	// Commit the transaction
 	if err := tx.Commit(ctx); err != nil {
 		log.Printf("SlowQuery.Submit Txn CommitFailed: %v", err)
 		return "SlowQuery.Submit Txn CommitFailed", err
 	}
 
+	jm.publishEvent(BatchEvent{BatchID: batchId.String(), Type: BatchEventQueued, Status: batchsqlc.StatusEnumQueued})
+
 	// Return the UUID as reqID and nil for err
 	return batchId.String(), nil
 }
@@ -100,6 +151,8 @@ const (
 	BatchWait
 	BatchQueued
 	BatchInProgress
+	BatchDeadLetter
+	BatchPaused
 )
 
 // determineBatchStatus converts a batch status from the database or Redis
@@ -113,97 +166,63 @@ func getBatchStatus(status batchsqlc.StatusEnum) BatchStatus_t {
 	case batchsqlc.StatusEnumAborted:
 		return BatchAborted
 	default:
-		// This includes StatusEnumQueued, StatusEnumInprog, StatusEnumWait, or any other unexpected value.
+		// This includes StatusEnumQueued, StatusEnumInprog, StatusEnumWait, StatusEnumPaused, or any other unexpected value.
 		return BatchTryLater
 	}
 }
 
 func (jm *JobManager) SlowQueryDone(reqID string) (status BatchStatus_t, result JSONstr, messages []wscutils.ErrorMessage, outputfiles map[string]string, err error) {
-
-	// Check REDIS for the status
-	redisKey := fmt.Sprintf("ALYA_BATCHSTATUS_%s", reqID)
-	redisResultKey := fmt.Sprintf("ALYA_BATCHRESULT_%s", reqID)
-	redisOutputFilesKey := fmt.Sprintf("ALYA_BATCHOUTFILES_%s", reqID)
-
 	reqIDUUID, err := uuid.Parse(reqID)
 	if err != nil {
 		log.Printf("SlowQuery.Done invalid request ID: %v", err)
 		result, _ := NewJSONstr("")
 		return BatchTryLater, result, nil, outputfiles, fmt.Errorf("invalid request ID: %v", err)
 	}
-	statusVal, err := jm.RedisClient.Get(context.Background(), redisKey).Result()
-	if err == redis.Nil {
-		// Key does not exist in REDIS, check the database
-		batchStatus, resultData, outputfiles, err := getBatchDetails(jm, reqIDUUID)
-		if err != nil {
-			log.Printf("SlowQuery.Done GetBatchDetails failed for request %v: %v", reqID, err)
-			result, _ := NewJSONstr("")
-			return BatchTryLater, result, nil, outputfiles, err // Assuming GetBatchDetails returns an error if not found
-		}
-		if batchStatus == batchsqlc.StatusEnumSuccess || batchStatus == batchsqlc.StatusEnumFailed {
-			result = resultData
-		}
-
-		// Convert string to StatusEnum if necessary
-		var enumStatus batchsqlc.StatusEnum
-		enumStatus.Scan(batchStatus) // Assuming batchStatus is a string, adjust if it's already StatusEnum
-
-		// Determine the BatchStatus_t based on batchStatus
-		status := getBatchStatus(enumStatus)
-
-		// Insert/update REDIS with 100x expiry if not found earlier
-		expirySec := jm.Config.BatchStatusCacheDurSec
-		if status == BatchSuccess || status == BatchFailed || status == BatchAborted {
-			expirySec = 100 * jm.Config.BatchStatusCacheDurSec
-		}
-
-		updateStatusAndOutputFilesDataInRedis(jm.RedisClient, reqIDUUID, batchStatus, outputfiles, resultData.String(), expirySec)
-
-		// Return the formatted result, messages, and nil for error
-		return status, result, messages, outputfiles, nil
-
-	} else if err != nil {
-		result, _ := NewJSONstr("")
-		return BatchTryLater, result, nil, outputfiles, err
-	} else {
-
-		// Key exists in REDIS, determine the action based on its value
-
-		var enumStatus batchsqlc.StatusEnum
-		enumStatus.Scan(statusVal) // Convert Redis result to StatusEnum
-		status = getBatchStatus(enumStatus)
 
-		if enumStatus == batchsqlc.StatusEnumSuccess || enumStatus == batchsqlc.StatusEnumFailed {
-
-			// Fetch the result and outputFiles from Redis
-			resultVal, err := jm.RedisClient.Get(context.Background(), redisResultKey).Result()
-			if err != nil {
-				result, _ := NewJSONstr("")
-				return BatchTryLater, result, nil, outputfiles, err
-			}
-
-			outputFilesVal, err := jm.RedisClient.Get(context.Background(), redisOutputFilesKey).Result()
-			if err != nil {
-				result, _ := NewJSONstr("")
-				return BatchTryLater, result, nil, outputfiles, err
-			}
-
-			// Convert the values to the appropriate types
-			result, err = NewJSONstr(resultVal)
+	// Check the cache for the status, along with the result once the slow query is done
+	cached, found := jm.getCachedStatus(reqIDUUID)
+	done := cached.Status == batchsqlc.StatusEnumSuccess || cached.Status == batchsqlc.StatusEnumFailed
+	if found && done && cached.Result == "" {
+		// The status was cached without the result, by BatchDone
+		found = false
+	}
+	if found {
+		status = getBatchStatus(cached.Status)
+		if done {
+			result, err = NewJSONstr(cached.Result)
 			if err != nil {
 				return BatchTryLater, result, nil, outputfiles, err
 			}
+			outputfiles = cached.OutputFiles
+		} else if !isFinalStatus(cached.Status) {
+			result = jm.slowQueryProgress(reqIDUUID)
+		}
+		return status, result, messages, outputfiles, nil
+	}
 
-			err = json.Unmarshal([]byte(outputFilesVal), &outputfiles)
-			if err != nil {
-				return BatchTryLater, result, nil, outputfiles, fmt.Errorf("failed to unmarshal output files: %v", err)
-			}
+	// Not cached, check the database
+	batchStatus, resultData, outputfiles, err := getBatchDetails(jm, reqIDUUID)
+	if err != nil {
+		log.Printf("SlowQuery.Done GetBatchDetails failed for request %v: %v", reqID, err)
+		result, _ := NewJSONstr("")
+		return BatchTryLater, result, nil, outputfiles, err // Assuming GetBatchDetails returns an error if not found
+	}
+	if batchStatus == batchsqlc.StatusEnumSuccess || batchStatus == batchsqlc.StatusEnumFailed {
+		result = resultData
+	}
 
-		}
+	// Determine the BatchStatus_t based on batchStatus
+	status = getBatchStatus(batchStatus)
 
+	if isFinalStatus(batchStatus) {
+		jm.cacheStatus(reqIDUUID, CachedStatus{Status: batchStatus, Result: resultData.String(), OutputFiles: outputfiles})
+	} else {
+		// While the query is running, the progress reported by its processor is returned as the
+		// result, and cached along with the status
+		result = jm.slowQueryProgress(reqIDUUID)
 	}
 
-	// Format the response based on the status
+	// Return the formatted result, messages, and nil for error
 	return status, result, messages, outputfiles, nil
 }
 
@@ -215,7 +234,7 @@ func (jm *JobManager) SlowQueryAbort(reqID string) (err error) {
 	}
 
 	// Start a transaction
-	tx, err := jm.Db.Begin(context.Background())
+	tx, err := jm.Store.Begin(context.Background())
 	if err != nil {
 		return fmt.Errorf("failed to start transaction: %v", err)
 	}
@@ -271,13 +290,13 @@ func (jm *JobManager) SlowQueryAbort(reqID string) (err error) {
 		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	// Set the Redis batch status record to aborted with an expiry time
-	redisKey := fmt.Sprintf("ALYA_BATCHSTATUS_%s", reqID)
-	expiry := time.Duration(jm.Config.BatchStatusCacheDurSec*100) * time.Second
-	err = jm.RedisClient.Set(context.Background(), redisKey, string(batchsqlc.StatusEnumAborted), expiry).Err()
-	if err != nil {
-		log.Printf("failed to set Redis batch status: %v", err)
-	}
+	// Stop the slow query if it is being processed by this instance; other instances notice
+	// the abort through their abort watcher
+	jm.cancelBatch(reqIDUUID)
+	jm.publishEvent(doneEvent(reqIDUUID, batchsqlc.StatusEnumAborted, 0, 0, len(rowids)))
+
+	// Cache the aborted status
+	jm.cacheStatus(reqIDUUID, CachedStatus{Status: batchsqlc.StatusEnumAborted})
 
 	return nil
 }
@@ -323,3 +342,59 @@ func getBatchDetails(jm *JobManager, reqIDUUID uuid.UUID) (status batchsqlc.Stat
 
 	return batchStatus, result, outputfiles, nil
 }
+
+// SlowQueryList returns the slow queries of an app which were requested in the last age days, newest
+// first. If op is not empty only the slow queries for that op are returned. opts may be nil; it can be
+// used to return only the slow queries in certain statuses or in a narrower time window, and to page
+// through a long history. A page token is returned if there may be more slow queries than were returned;
+// it is passed in opts.PageToken to fetch the next page, and is empty on the last page.
+func (jm *JobManager) SlowQueryList(app, op string, age int, opts *ListOptions) (sqlist []SlowQueryDetails_t, nextPageToken string, err error) {
+	params, err := newListParams(op, age, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := jm.Queries.ListSlowQueries(context.Background(), batchsqlc.ListSlowQueriesParams{
+		App:        app,
+		Op:         params.op,
+		Statuses:   params.statuses,
+		Reqfrom:    params.reqFrom,
+		Requntil:   params.reqUntil,
+		Afterreqat: params.afterReqAt,
+		Afterid:    params.afterID,
+		Pagesize:   params.pageSize,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list slow queries: %v", err)
+	}
+
+	sqlist = make([]SlowQueryDetails_t, len(rows))
+	for i, row := range rows {
+		queryContext, err := NewJSONstr(string(row.Context))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse context of slow query %s: %v", row.ID, err)
+		}
+		outputFiles := make(map[string]string)
+		if row.Outputfiles != nil {
+			if err := json.Unmarshal(row.Outputfiles, &outputFiles); err != nil {
+				return nil, "", fmt.Errorf("failed to unmarshal output files of slow query %s: %v", row.ID, err)
+			}
+		}
+		sqlist[i] = SlowQueryDetails_t{
+			ID:          row.ID.String(),
+			App:         row.App,
+			Op:          row.Op,
+			Context:     queryContext,
+			Status:      row.Status,
+			ReqAt:       row.Reqat.Time,
+			DoneAt:      row.Doneat.Time,
+			OutputFiles: outputFiles,
+		}
+	}
+
+	if len(rows) == int(params.pageSize) {
+		last := rows[len(rows)-1]
+		nextPageToken = encodePageToken(last.Reqat.Time, last.ID)
+	}
+	return sqlist, nextPageToken, nil
+}
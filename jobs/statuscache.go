@@ -0,0 +1,246 @@
+package jobs
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+)
+
+// ALYA_CACHE_NAMESPACE is the default prefix of the names of the keys and channels used by a
+// JobManager in Redis
+const ALYA_CACHE_NAMESPACE = "alya"
+
+// CachedStatus is the status of a batch or slow query as kept in a StatusCache. Result and
+// OutputFiles are only set for slow queries which are done. Progress is the BatchProgress_t last
+// computed by BatchProgress, as JSON.
+type CachedStatus struct {
+	Status      batchsqlc.StatusEnum
+	Result      string
+	OutputFiles map[string]string
+	Progress    string
+}
+
+// StatusCache caches the status of batches and slow queries, so that BatchDone and SlowQueryDone
+// need not go to the database every time a client polls them. RedisStatusCache shares the cache
+// between all JobManager instances; LRUStatusCache keeps it within the process. A JobManager with
+// no StatusCache reads every status from its JobStore.
+type StatusCache interface {
+	// Get returns the cached status of a batch; found is false if it is not cached
+	Get(ctx context.Context, batchID uuid.UUID) (status CachedStatus, found bool, err error)
+	// Set caches the status of a batch for the given duration
+	Set(ctx context.Context, batchID uuid.UUID, status CachedStatus, expiry time.Duration) error
+	// Delete removes the cached status of a batch
+	Delete(ctx context.Context, batchID uuid.UUID) error
+}
+
+// cacheKey returns the name of a key or channel of a batch in the namespace. All the names used by a
+// JobManager for a batch have the form <namespace>:batch:<batch ID>:<field>.
+func cacheKey(namespace string, batchID string, field string) string {
+	return fmt.Sprintf("%s:batch:%s:%s", namespace, batchID, field)
+}
+
+// GetBatchStatusRedisKey returns the Redis key under which the status of a batch is cached in the
+// default namespace
+func GetBatchStatusRedisKey(batchID string) string {
+	return cacheKey(ALYA_CACHE_NAMESPACE, batchID, "status")
+}
+
+// RedisStatusCache is an implementation of StatusCache using Redis
+type RedisStatusCache struct {
+	client    *redis.Client
+	namespace string
+}
+
+// NewRedisStatusCache creates a new instance of RedisStatusCache with the provided Redis client. Its
+// keys are prefixed with namespace, or with ALYA_CACHE_NAMESPACE if namespace is empty.
+func NewRedisStatusCache(client *redis.Client, namespace string) *RedisStatusCache {
+	if namespace == "" {
+		namespace = ALYA_CACHE_NAMESPACE
+	}
+	return &RedisStatusCache{client: client, namespace: namespace}
+}
+
+func (c *RedisStatusCache) keys(batchID uuid.UUID) (statusKey, resultKey, outputFilesKey, progressKey string) {
+	id := batchID.String()
+	return cacheKey(c.namespace, id, "status"), cacheKey(c.namespace, id, "result"), cacheKey(c.namespace, id, "outputfiles"), cacheKey(c.namespace, id, "progress")
+}
+
+// Get reads the status, result, output files and progress of a batch in a single round trip
+func (c *RedisStatusCache) Get(ctx context.Context, batchID uuid.UUID) (CachedStatus, bool, error) {
+	statusKey, resultKey, outputFilesKey, progressKey := c.keys(batchID)
+	values, err := c.client.MGet(ctx, statusKey, resultKey, outputFilesKey, progressKey).Result()
+	if err != nil {
+		return CachedStatus{}, false, fmt.Errorf("failed to get cached status of batch %s: %v", batchID, err)
+	}
+	status, ok := values[0].(string)
+	if !ok {
+		return CachedStatus{}, false, nil
+	}
+	cached := CachedStatus{Status: batchsqlc.StatusEnum(status)}
+	if result, ok := values[1].(string); ok {
+		cached.Result = result
+	}
+	if outputFiles, ok := values[2].(string); ok && outputFiles != "" {
+		if err := json.Unmarshal([]byte(outputFiles), &cached.OutputFiles); err != nil {
+			return CachedStatus{}, false, fmt.Errorf("failed to unmarshal cached output files of batch %s: %v", batchID, err)
+		}
+	}
+	if progress, ok := values[3].(string); ok {
+		cached.Progress = progress
+	}
+	return cached, true, nil
+}
+
+// Set writes the status, result, output files and progress of a batch in a single transaction, so
+// that other instances never see a status along with the result of another one.
+func (c *RedisStatusCache) Set(ctx context.Context, batchID uuid.UUID, status CachedStatus, expiry time.Duration) error {
+	statusKey, resultKey, outputFilesKey, progressKey := c.keys(batchID)
+	var outputFiles []byte
+	if status.OutputFiles != nil {
+		var err error
+		if outputFiles, err = json.Marshal(status.OutputFiles); err != nil {
+			return fmt.Errorf("failed to marshal output files: %v", err)
+		}
+	}
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, statusKey, string(status.Status), expiry)
+		pipe.Set(ctx, resultKey, status.Result, expiry)
+		pipe.Set(ctx, outputFilesKey, string(outputFiles), expiry)
+		pipe.Set(ctx, progressKey, status.Progress, expiry)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache status of batch %s: %v", batchID, err)
+	}
+	return nil
+}
+
+func (c *RedisStatusCache) Delete(ctx context.Context, batchID uuid.UUID) error {
+	statusKey, resultKey, outputFilesKey, progressKey := c.keys(batchID)
+	if err := c.client.Del(ctx, statusKey, resultKey, outputFilesKey, progressKey).Err(); err != nil {
+		return fmt.Errorf("failed to remove cached status of batch %s: %v", batchID, err)
+	}
+	return nil
+}
+
+// LRUStatusCache is an implementation of StatusCache which keeps the most recently used statuses in
+// memory. It is not shared between JobManager instances, so a status changed by another instance is
+// seen only once the entry cached here expires.
+type LRUStatusCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // most recently used first
+	entries map[uuid.UUID]*list.Element
+}
+
+type lruEntry struct {
+	batchID   uuid.UUID
+	status    CachedStatus
+	expiresAt time.Time
+}
+
+// NewLRUStatusCache creates a new instance of LRUStatusCache holding up to size statuses
+func NewLRUStatusCache(size int) *LRUStatusCache {
+	if size < 1 {
+		size = 1
+	}
+	return &LRUStatusCache{size: size, order: list.New(), entries: make(map[uuid.UUID]*list.Element)}
+}
+
+func (c *LRUStatusCache) Get(ctx context.Context, batchID uuid.UUID) (CachedStatus, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, exists := c.entries[batchID]
+	if !exists {
+		return CachedStatus{}, false, nil
+	}
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, batchID)
+		return CachedStatus{}, false, nil
+	}
+	c.order.MoveToFront(elem)
+	return entry.status, true, nil
+}
+
+func (c *LRUStatusCache) Set(ctx context.Context, batchID uuid.UUID, status CachedStatus, expiry time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &lruEntry{batchID: batchID, status: status, expiresAt: time.Now().Add(expiry)}
+	if elem, exists := c.entries[batchID]; exists {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return nil
+	}
+	c.entries[batchID] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).batchID)
+	}
+	return nil
+}
+
+func (c *LRUStatusCache) Delete(ctx context.Context, batchID uuid.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, exists := c.entries[batchID]; exists {
+		c.order.Remove(elem)
+		delete(c.entries, batchID)
+	}
+	return nil
+}
+
+// cacheExpiry returns how long a status is cached: final statuses do not change, so they are cached
+// for 100 times Config.BatchStatusCacheDurSec
+func (jm *JobManager) cacheExpiry(status batchsqlc.StatusEnum) time.Duration {
+	expiry := time.Duration(jm.Config.BatchStatusCacheDurSec) * time.Second
+	if isFinalStatus(status) {
+		expiry *= 100
+	}
+	return expiry
+}
+
+// getCachedStatus returns the cached status of a batch. Errors are logged and reported as a cache
+// miss, so that the status is read from the JobStore instead.
+func (jm *JobManager) getCachedStatus(batchID uuid.UUID) (CachedStatus, bool) {
+	if jm.StatusCache == nil {
+		return CachedStatus{}, false
+	}
+	cached, found, err := jm.StatusCache.Get(context.Background(), batchID)
+	if err != nil {
+		log.Printf("Error reading status cache: %v", err)
+		return CachedStatus{}, false
+	}
+	return cached, found
+}
+
+// cacheStatus caches the status of a batch. Errors are logged, since the status is recorded in the
+// JobStore already.
+func (jm *JobManager) cacheStatus(batchID uuid.UUID, status CachedStatus) {
+	if jm.StatusCache == nil {
+		return
+	}
+	if err := jm.StatusCache.Set(context.Background(), batchID, status, jm.cacheExpiry(status.Status)); err != nil {
+		log.Printf("Error updating status cache: %v", err)
+	}
+}
+
+// uncacheStatus removes the cached status of a batch whose status is going back to queued
+func (jm *JobManager) uncacheStatus(batchID uuid.UUID) {
+	if jm.StatusCache == nil {
+		return
+	}
+	if err := jm.StatusCache.Delete(context.Background(), batchID); err != nil {
+		log.Printf("Error updating status cache: %v", err)
+	}
+}
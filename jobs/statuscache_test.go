@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v8"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUStatusCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUStatusCache(2)
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+
+	assert.NoError(t, cache.Set(ctx, first, CachedStatus{Status: batchsqlc.StatusEnumQueued}, time.Minute))
+	assert.NoError(t, cache.Set(ctx, second, CachedStatus{Status: batchsqlc.StatusEnumInprog}, time.Minute))
+
+	// Reading first makes second the least recently used, so it is evicted by third
+	cached, found, err := cache.Get(ctx, first)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, batchsqlc.StatusEnumQueued, cached.Status)
+	assert.NoError(t, cache.Set(ctx, third, CachedStatus{Status: batchsqlc.StatusEnumSuccess, Result: `{}`}, time.Minute))
+
+	_, found, _ = cache.Get(ctx, second)
+	assert.False(t, found)
+	cached, found, _ = cache.Get(ctx, third)
+	assert.True(t, found)
+	assert.Equal(t, `{}`, cached.Result)
+
+	assert.NoError(t, cache.Delete(ctx, third))
+	_, found, _ = cache.Get(ctx, third)
+	assert.False(t, found)
+
+	// Expired entries are not returned
+	assert.NoError(t, cache.Set(ctx, first, CachedStatus{Status: batchsqlc.StatusEnumQueued}, -time.Second))
+	_, found, _ = cache.Get(ctx, first)
+	assert.False(t, found)
+}
+
+func TestRedisStatusCache(t *testing.T) {
+	ctx := context.Background()
+	client, redisMock := redismock.NewClientMock()
+	cache := NewRedisStatusCache(client, "tenant1")
+	batchID := uuid.New()
+	statusKey := fmt.Sprintf("tenant1:batch:%s:status", batchID)
+	resultKey := fmt.Sprintf("tenant1:batch:%s:result", batchID)
+	outputFilesKey := fmt.Sprintf("tenant1:batch:%s:outputfiles", batchID)
+	progressKey := fmt.Sprintf("tenant1:batch:%s:progress", batchID)
+
+	redisMock.ExpectTxPipeline()
+	redisMock.ExpectSet(statusKey, "success", time.Minute).SetVal("OK")
+	redisMock.ExpectSet(resultKey, `{"n":1}`, time.Minute).SetVal("OK")
+	redisMock.ExpectSet(outputFilesKey, `{"report":"obj1"}`, time.Minute).SetVal("OK")
+	redisMock.ExpectSet(progressKey, "", time.Minute).SetVal("OK")
+	redisMock.ExpectTxPipelineExec()
+	err := cache.Set(ctx, batchID, CachedStatus{
+		Status:      batchsqlc.StatusEnumSuccess,
+		Result:      `{"n":1}`,
+		OutputFiles: map[string]string{"report": "obj1"},
+	}, time.Minute)
+	assert.NoError(t, err)
+
+	redisMock.ExpectMGet(statusKey, resultKey, outputFilesKey, progressKey).SetVal([]interface{}{"success", `{"n":1}`, `{"report":"obj1"}`, ""})
+	cached, found, err := cache.Get(ctx, batchID)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, cached.Status)
+	assert.Equal(t, `{"n":1}`, cached.Result)
+	assert.Equal(t, map[string]string{"report": "obj1"}, cached.OutputFiles)
+
+	redisMock.ExpectMGet(statusKey, resultKey, outputFilesKey, progressKey).SetVal([]interface{}{nil, nil, nil, nil})
+	_, found, err = cache.Get(ctx, batchID)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	redisMock.ExpectDel(statusKey, resultKey, outputFilesKey, progressKey).SetVal(4)
+	assert.NoError(t, cache.Delete(ctx, batchID))
+
+	assert.NoError(t, redisMock.ExpectationsWereMet())
+}
+
+func TestGetBatchStatusRedisKey(t *testing.T) {
+	assert.Equal(t, "alya:batch:b1:status", GetBatchStatusRedisKey("b1"))
+}
+
+func TestBatchDoneUsesStatusCache(t *testing.T) {
+	store := NewMemJobStore()
+	jm := NewJobManagerWithStore(store, nil, nil, nil, nil)
+	jm.StatusCache = NewLRUStatusCache(10)
+
+	batchID := uuid.New()
+	_, err := store.Queries().InsertIntoBatches(context.Background(), batchsqlc.InsertIntoBatchesParams{
+		ID:     batchID,
+		App:    "app1",
+		Op:     "op1",
+		Status: batchsqlc.StatusEnumQueued,
+		Reqat:  pgtype.Timestamp{Time: time.Now(), Valid: true},
+	})
+	assert.NoError(t, err)
+
+	// The status is cached on the first call
+	status, _, _, _, _, _, err := jm.BatchDone(batchID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumQueued, status)
+	cached, found, _ := jm.StatusCache.Get(context.Background(), batchID)
+	assert.True(t, found)
+	assert.Equal(t, batchsqlc.StatusEnumQueued, cached.Status)
+
+	// and used until it expires, even if the database has moved on
+	assert.NoError(t, store.Queries().UpdateBatchStatus(context.Background(), batchsqlc.UpdateBatchStatusParams{ID: batchID, Status: batchsqlc.StatusEnumInprog}))
+	status, _, _, _, _, _, err = jm.BatchDone(batchID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumQueued, status)
+
+	// Without a cache the database is read every time
+	jm.StatusCache = nil
+	status, _, _, _, _, _, err = jm.BatchDone(batchID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumInprog, status)
+}
@@ -0,0 +1,66 @@
+package jobs
+
+import "time"
+
+// ALYA_BATCH_PRIORITY is the priority of batches submitted without WithPriority.
+const ALYA_BATCH_PRIORITY = 0
+
+// ALYA_SLOWQUERY_PRIORITY is the priority of slow queries submitted without WithPriority. It is
+// above that of batches, since a user is usually waiting for the result of a slow query.
+const ALYA_SLOWQUERY_PRIORITY = 10
+
+// SubmitOption sets an optional property of a batch job or slow query when it is submitted
+// with BatchSubmit or SlowQuerySubmit.
+type SubmitOption func(*submitOptions)
+
+type submitOptions struct {
+	priority       int
+	notBefore      time.Time
+	idempotencyKey string
+	bypassMemo     bool
+}
+
+// WithPriority sets the priority of a batch job or slow query. The rows of batches with a higher
+// priority are processed before those of batches with a lower one; batches of the same priority
+// take turns, across apps first and then across the batches of each app.
+func WithPriority(priority int) SubmitOption {
+	return func(o *submitOptions) {
+		o.priority = priority
+	}
+}
+
+// WithNotBefore holds back a batch job or slow query until t. Its status stays queued in the
+// meantime. A zero t, or one in the past, makes it available for processing straight away.
+func WithNotBefore(t time.Time) SubmitOption {
+	return func(o *submitOptions) {
+		o.notBefore = t
+	}
+}
+
+// WithIdempotencyKey makes the submission of a batch job or slow query idempotent, so that a client
+// can retry it safely. If a batch job or slow query of the same (app, op) was already submitted with
+// key, nothing is submitted and the ID of the earlier one is returned, provided that it had the same
+// context and input; if it did not, ErrIdempotencyConflict is returned.
+func WithIdempotencyKey(key string) SubmitOption {
+	return func(o *submitOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+// WithoutMemo makes SlowQuerySubmit queue a slow query even if its (app, op) is memoized with
+// RegisterSlowQueryMemo and an equivalent query could be reused. The new query is then the one reused
+// by later submissions.
+func WithoutMemo() SubmitOption {
+	return func(o *submitOptions) {
+		o.bypassMemo = true
+	}
+}
+
+// newSubmitOptions applies opts over the defaults for a batch job or slow query
+func newSubmitOptions(defaultPriority int, opts []SubmitOption) submitOptions {
+	o := submitOptions{priority: defaultPriority}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
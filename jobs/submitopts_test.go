@@ -0,0 +1,20 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitOptionsPriority(t *testing.T) {
+	assert.Equal(t, ALYA_BATCH_PRIORITY, newSubmitOptions(ALYA_BATCH_PRIORITY, nil).priority)
+	assert.Equal(t, ALYA_SLOWQUERY_PRIORITY, newSubmitOptions(ALYA_SLOWQUERY_PRIORITY, nil).priority)
+	assert.Greater(t, ALYA_SLOWQUERY_PRIORITY, ALYA_BATCH_PRIORITY)
+
+	opts := newSubmitOptions(ALYA_BATCH_PRIORITY, []SubmitOption{WithPriority(50)})
+	assert.Equal(t, 50, opts.priority)
+
+	// A later option overrides an earlier one
+	opts = newSubmitOptions(ALYA_SLOWQUERY_PRIORITY, []SubmitOption{WithPriority(50), WithPriority(-5)})
+	assert.Equal(t, -5, opts.priority)
+}
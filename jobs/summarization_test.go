@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-redis/redismock/v8"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/remiges-tech/alya/jobs/objstore"
 	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
 	"github.com/remiges-tech/alya/jobs/pg/batchsqlc/mocks"
@@ -19,7 +20,6 @@ import (
 var summarizeBatchTests = []struct {
 	name           string
 	batchID        uuid.UUID
-	batchRows      []batchsqlc.GetBatchRowsByBatchIDSortedRow
 	processedRows  []batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow
 	expectedStatus batchsqlc.StatusEnum
 	expectedCounts struct {
@@ -31,11 +31,6 @@ var summarizeBatchTests = []struct {
 	{
 		name:    "Successful Summary",
 		batchID: uuid.New(),
-		batchRows: []batchsqlc.GetBatchRowsByBatchIDSortedRow{
-			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX001,DEPOSIT,1000.00,5000.00", "error_log.txt": ""}`)},
-			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX002,WITHDRAWAL,500.00,4500.00", "error_log.txt": ""}`)},
-			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX003,TRANSFER,2000.00,2500.00", "error_log.txt": ""}`)},
-		},
 		processedRows: []batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow{
 			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX001,DEPOSIT,1000.00,5000.00", "error_log.txt": ""}`)},
 			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX002,WITHDRAWAL,500.00,4500.00", "error_log.txt": ""}`)},
@@ -55,12 +50,6 @@ var summarizeBatchTests = []struct {
 	{
 		name:    "Failed Summary",
 		batchID: uuid.New(),
-		batchRows: []batchsqlc.GetBatchRowsByBatchIDSortedRow{
-			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX001,DEPOSIT,1000.00,5000.00", "error_log.txt": ""}`)},
-			{Status: batchsqlc.StatusEnumFailed, Blobrows: []byte(`{"transaction_summary.txt": "", "error_log.txt": "ERROR: Insufficient funds for TX002"}`)},
-			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX003,TRANSFER,2000.00,2500.00", "error_log.txt": ""}`)},
-			{Status: batchsqlc.StatusEnumFailed, Blobrows: []byte(`{"transaction_summary.txt": "", "error_log.txt": "ERROR: Invalid account number for TX004"}`)},
-		},
 		processedRows: []batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow{
 			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX001,DEPOSIT,1000.00,5000.00", "error_log.txt": ""}`)},
 			{Status: batchsqlc.StatusEnumFailed, Blobrows: []byte(`{"transaction_summary.txt": "", "error_log.txt": "ERROR: Insufficient funds for TX002"}`)},
@@ -81,12 +70,6 @@ var summarizeBatchTests = []struct {
 	{
 		name:    "Aborted Summary",
 		batchID: uuid.New(),
-		batchRows: []batchsqlc.GetBatchRowsByBatchIDSortedRow{
-			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX001,DEPOSIT,1000.00,5000.00", "error_log.txt": ""}`)},
-			{Status: batchsqlc.StatusEnumAborted, Blobrows: []byte(`{"transaction_summary.txt": "", "error_log.txt": "ABORT: User canceled transaction TX002"}`)},
-			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX003,TRANSFER,2000.00,2500.00", "error_log.txt": ""}`)},
-			{Status: batchsqlc.StatusEnumAborted, Blobrows: []byte(`{"transaction_summary.txt": "", "error_log.txt": "ABORT: System maintenance, transaction TX004 aborted"}`)},
-		},
 		processedRows: []batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow{
 			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX001,DEPOSIT,1000.00,5000.00", "error_log.txt": ""}`)},
 			{Status: batchsqlc.StatusEnumSuccess, Blobrows: []byte(`{"transaction_summary.txt": "TX003,TRANSFER,2000.00,2500.00", "error_log.txt": ""}`)},
@@ -112,17 +95,17 @@ func TestSummarizeBatch(t *testing.T) {
 			// $ moq -out mocks/querier_mock.go -pkg mocks . Querier
 			mockQuerier := &mocks.QuerierMock{}
 			mockQuerier.GetBatchByIDFunc = func(ctx context.Context, id uuid.UUID) (batchsqlc.Batch, error) {
+				// every row has finished and been counted
+				counts := tt.expectedCounts
 				return batchsqlc.Batch{
-					ID:     tt.batchID,
-					Status: batchsqlc.StatusEnumQueued,
+					ID:       tt.batchID,
+					Status:   batchsqlc.StatusEnumInprog,
+					Nsuccess: pgtype.Int4{Int32: int32(counts.success), Valid: true},
+					Nfailed:  pgtype.Int4{Int32: int32(counts.failed), Valid: true},
+					Naborted: pgtype.Int4{Int32: int32(counts.aborted), Valid: true},
+					Nrows:    int32(counts.success + counts.failed + counts.aborted),
 				}, nil
 			}
-			mockQuerier.CountBatchRowsByBatchIDAndStatusFunc = func(ctx context.Context, arg batchsqlc.CountBatchRowsByBatchIDAndStatusParams) (int64, error) {
-				return int64(0), nil
-			}
-			mockQuerier.GetBatchRowsByBatchIDSortedFunc = func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetBatchRowsByBatchIDSortedRow, error) {
-				return tt.batchRows, nil
-			}
 			mockQuerier.GetProcessedBatchRowsByBatchIDSortedFunc = func(ctx context.Context, batch uuid.UUID) ([]batchsqlc.GetProcessedBatchRowsByBatchIDSortedRow, error) {
 				return tt.processedRows, nil
 			}
@@ -141,19 +124,20 @@ func TestSummarizeBatch(t *testing.T) {
 			}
 
 			redisClient, redisMock := redismock.NewClientMock()
-			redisKey := fmt.Sprintf("ALYA_BATCHSTATUS_%s", tt.batchID.String())
+			expiry := time.Duration(ALYA_BATCHSTATUS_CACHEDUR_SEC*100) * time.Second
 
 			// Set up Redis mock expectations
-			redisMock.ExpectWatch(redisKey)
-			redisMock.ExpectGet(redisKey).SetVal("previous_status") // Simulate a different current status
 			redisMock.ExpectTxPipeline()
-			redisMock.ExpectSet(redisKey, string(tt.expectedStatus), time.Duration(ALYA_BATCHSTATUS_CACHEDUR_SEC*100)*time.Second).SetVal("OK")
+			redisMock.ExpectSet(fmt.Sprintf("alya:batch:%s:status", tt.batchID), string(tt.expectedStatus), expiry).SetVal("OK")
+			redisMock.ExpectSet(fmt.Sprintf("alya:batch:%s:result", tt.batchID), "", expiry).SetVal("OK")
+			redisMock.ExpectSet(fmt.Sprintf("alya:batch:%s:outputfiles", tt.batchID), "", expiry).SetVal("OK")
+			redisMock.ExpectSet(fmt.Sprintf("alya:batch:%s:progress", tt.batchID), "", expiry).SetVal("OK")
 			redisMock.ExpectTxPipelineExec()
 
 			jm := JobManager{
 				Queries:     mockQuerier,
 				ObjStore:    mockObjStore,
-				RedisClient: redisClient,
+				StatusCache: NewRedisStatusCache(redisClient, ALYA_CACHE_NAMESPACE),
 				// Initialize Config with a non-zero BatchStatusCacheDurSec
 				Config: JobManagerConfig{
 					BatchStatusCacheDurSec: ALYA_BATCHSTATUS_CACHEDUR_SEC,
@@ -0,0 +1,257 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/wscutils"
+)
+
+// The helpers in this file let applications work with their own Go types for the context, input and
+// result of batch jobs and slow queries, instead of JSONstr. The values are marshalled to and from JSON
+// by Alya. Since Go methods cannot have type parameters, they are functions taking the JobManager.
+
+// ErrInvalidInput is returned by TypedBatchSubmit and TypedSlowQuerySubmit when the input fails validation.
+// Nothing is submitted in that case.
+var ErrInvalidInput = errors.New("input failed validation")
+
+// TypedBatchProcessor is a BatchProcessorCtx which receives the batch context and row input unmarshalled
+// into C and I, and returns a result of type O which is marshalled into batchrows.res.
+type TypedBatchProcessor[C, I, O any] interface {
+	DoBatchJob(ctx context.Context, initBlock InitBlock, batchctx C, line int, input I) (status batchsqlc.StatusEnum, result O, messages []wscutils.ErrorMessage, blobRows map[string]string, err error)
+	MarkDone(initBlock InitBlock, batchctx C, details BatchDetails_t) error
+}
+
+// TypedSlowQueryProcessor is a SlowQueryProcessorCtx which receives the query context and input
+// unmarshalled into C and I, and returns a result of type O which is marshalled into batchrows.res.
+type TypedSlowQueryProcessor[C, I, O any] interface {
+	DoSlowQuery(ctx context.Context, initBlock InitBlock, queryctx C, input I) (status batchsqlc.StatusEnum, result O, messages []wscutils.ErrorMessage, outputFiles map[string]string, err error)
+}
+
+// TypedBatchOutput_t is the typed form of BatchOutput_t returned by TypedBatchDone.
+type TypedBatchOutput_t[O any] struct {
+	Line     int
+	Status   BatchStatus_t
+	Res      O // zero unless the row succeeded
+	Messages []wscutils.ErrorMessage
+}
+
+// RegisterTypedProcessorBatch registers a TypedBatchProcessor for a specific (app, op) combination,
+// in the same way as RegisterProcessorBatchCtx. A row whose input cannot be unmarshalled into I is
+// recorded as failed, with an ErrcodeInvalidRowInput message.
+func RegisterTypedProcessorBatch[C, I, O any](jm *JobManager, app string, op string, p TypedBatchProcessor[C, I, O]) error {
+	return jm.RegisterProcessorBatchCtx(app, op, typedBatchProcessor[C, I, O]{p})
+}
+
+// RegisterTypedProcessorSlowQuery registers a TypedSlowQueryProcessor for a specific (app, op)
+// combination, in the same way as RegisterProcessorSlowQueryCtx. A slow query whose input cannot be
+// unmarshalled into I is recorded as failed, with an ErrcodeInvalidRowInput message.
+func RegisterTypedProcessorSlowQuery[C, I, O any](jm *JobManager, app string, op string, p TypedSlowQueryProcessor[C, I, O]) error {
+	return jm.RegisterProcessorSlowQueryCtx(app, op, typedSlowQueryProcessor[C, I, O]{p})
+}
+
+// Partitioned is implemented by the inputs passed to TypedBatchSubmit which are to be processed one at
+// a time, in line order, with the other inputs with the same partition key; see BatchInput_t.
+type Partitioned interface {
+	PartitionKey() string
+}
+
+// TypedBatchSubmit marshals the batch context and inputs and submits them with BatchSubmit, numbering
+// the lines from 1 in the order of inputs. Each input is first validated with wscutils.WscValidate as
+// per its struct tags. If any input is invalid, nothing is submitted: ErrInvalidInput is returned
+// along with the validation messages of each invalid line. Inputs which implement Partitioned are
+// submitted with their partition key.
+func TypedBatchSubmit[C, I any](jm *JobManager, app, op string, batchctx C, inputs []I, waitabit bool, opts ...SubmitOption) (batchID string, invalid map[int][]wscutils.ErrorMessage, err error) {
+	invalid = make(map[int][]wscutils.ErrorMessage)
+	batchInput := make([]BatchInput_t, len(inputs))
+	for i, input := range inputs {
+		line := i + 1
+		if messages := validateInput(input); len(messages) > 0 {
+			invalid[line] = messages
+			continue
+		}
+		inputJSON, err := encodeJSON(input)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to marshal input for line %d: %v", line, err)
+		}
+		batchInput[i] = BatchInput_t{Line: line, Input: inputJSON}
+		if partitioned, ok := any(input).(Partitioned); ok {
+			batchInput[i].PartitionKey = partitioned.PartitionKey()
+		}
+	}
+	if len(invalid) > 0 {
+		return "", invalid, ErrInvalidInput
+	}
+
+	ctxJSON, err := encodeJSON(batchctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal batch context: %v", err)
+	}
+	batchID, err = jm.BatchSubmit(app, op, ctxJSON, batchInput, waitabit, opts...)
+	return batchID, nil, err
+}
+
+// TypedSlowQuerySubmit marshals the query context and input and submits them with SlowQuerySubmit.
+// The input is first validated with wscutils.WscValidate as per its struct tags. If it is invalid,
+// nothing is submitted: ErrInvalidInput is returned along with the validation messages.
+func TypedSlowQuerySubmit[C, I any](jm *JobManager, app, op string, queryctx C, input I, opts ...SubmitOption) (reqID string, invalid []wscutils.ErrorMessage, err error) {
+	if invalid = validateInput(input); len(invalid) > 0 {
+		return "", invalid, ErrInvalidInput
+	}
+	ctxJSON, err := encodeJSON(queryctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal query context: %v", err)
+	}
+	inputJSON, err := encodeJSON(input)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal input: %v", err)
+	}
+	reqID, err = jm.SlowQuerySubmit(app, op, ctxJSON, inputJSON, opts...)
+	return reqID, nil, err
+}
+
+// TypedBatchDone is like BatchDone, with the result of each successful row unmarshalled into O and
+// the messages of each row unmarshalled into a slice.
+func TypedBatchDone[O any](jm *JobManager, batchID string) (status batchsqlc.StatusEnum, batchOutput []TypedBatchOutput_t[O], outputFiles map[string]string, nsuccess, nfailed, naborted int, err error) {
+	status, rawOutput, outputFiles, nsuccess, nfailed, naborted, err := jm.BatchDone(batchID)
+	if err != nil || rawOutput == nil {
+		return status, nil, outputFiles, nsuccess, nfailed, naborted, err
+	}
+
+	batchOutput = make([]TypedBatchOutput_t[O], len(rawOutput))
+	for i, row := range rawOutput {
+		batchOutput[i] = TypedBatchOutput_t[O]{Line: row.Line, Status: row.Status}
+		if row.Status == BatchSuccess {
+			if batchOutput[i].Res, err = decodeJSON[O](row.Res); err != nil {
+				return status, nil, nil, 0, 0, 0, fmt.Errorf("failed to unmarshal result for line %d: %v", row.Line, err)
+			}
+		}
+		if batchOutput[i].Messages, err = decodeMessages(row.Messages); err != nil {
+			return status, nil, nil, 0, 0, 0, fmt.Errorf("failed to unmarshal messages for line %d: %v", row.Line, err)
+		}
+	}
+	return status, batchOutput, outputFiles, nsuccess, nfailed, naborted, nil
+}
+
+// TypedSlowQueryDone is like SlowQueryDone, with the result unmarshalled into O if the slow query succeeded.
+func TypedSlowQueryDone[O any](jm *JobManager, reqID string) (status BatchStatus_t, result O, messages []wscutils.ErrorMessage, outputfiles map[string]string, err error) {
+	status, rawResult, messages, outputfiles, err := jm.SlowQueryDone(reqID)
+	if err != nil || status != BatchSuccess {
+		return status, result, messages, outputfiles, err
+	}
+	if result, err = decodeJSON[O](rawResult); err != nil {
+		return status, result, messages, outputfiles, fmt.Errorf("failed to unmarshal result: %v", err)
+	}
+	return status, result, messages, outputfiles, nil
+}
+
+// typedBatchProcessor adapts a TypedBatchProcessor to the BatchProcessorCtx interface
+type typedBatchProcessor[C, I, O any] struct {
+	p TypedBatchProcessor[C, I, O]
+}
+
+func (t typedBatchProcessor[C, I, O]) DoBatchJob(ctx context.Context, initBlock InitBlock, context JSONstr, line int, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	batchctx, err := decodeJSON[C](context)
+	if err != nil {
+		return invalidRowInput("context", err)
+	}
+	in, err := decodeJSON[I](input)
+	if err != nil {
+		return invalidRowInput("input", err)
+	}
+
+	status, out, messages, blobRows, err := t.p.DoBatchJob(ctx, initBlock, batchctx, line, in)
+	if err != nil {
+		return status, emptyJSON(), messages, blobRows, err
+	}
+	result, err := encodeJSON(out)
+	if err != nil {
+		return batchsqlc.StatusEnumFailed, emptyJSON(), messages, blobRows, fmt.Errorf("failed to marshal result: %v", err)
+	}
+	return status, result, messages, blobRows, nil
+}
+
+func (t typedBatchProcessor[C, I, O]) MarkDone(initBlock InitBlock, context JSONstr, details BatchDetails_t) error {
+	batchctx, err := decodeJSON[C](context)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal batch context: %v", err)
+	}
+	return t.p.MarkDone(initBlock, batchctx, details)
+}
+
+// typedSlowQueryProcessor adapts a TypedSlowQueryProcessor to the SlowQueryProcessorCtx interface
+type typedSlowQueryProcessor[C, I, O any] struct {
+	p TypedSlowQueryProcessor[C, I, O]
+}
+
+func (t typedSlowQueryProcessor[C, I, O]) DoSlowQuery(ctx context.Context, initBlock InitBlock, context JSONstr, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	queryctx, err := decodeJSON[C](context)
+	if err != nil {
+		return invalidRowInput("context", err)
+	}
+	in, err := decodeJSON[I](input)
+	if err != nil {
+		return invalidRowInput("input", err)
+	}
+
+	status, out, messages, outputFiles, err := t.p.DoSlowQuery(ctx, initBlock, queryctx, in)
+	if err != nil {
+		return status, emptyJSON(), messages, outputFiles, err
+	}
+	result, err := encodeJSON(out)
+	if err != nil {
+		return batchsqlc.StatusEnumFailed, emptyJSON(), messages, outputFiles, fmt.Errorf("failed to marshal result: %v", err)
+	}
+	return status, result, messages, outputFiles, nil
+}
+
+// invalidRowInput returns the outcome of a row whose context or input cannot be unmarshalled. It is
+// recorded as failed rather than returned as an error, since retrying the row cannot help.
+func invalidRowInput(field string, err error) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	messages := []wscutils.ErrorMessage{wscutils.BuildErrorMessage(MsgIDInvalidRowInput, ErrcodeInvalidRowInput, field, err.Error())}
+	return batchsqlc.StatusEnumFailed, emptyJSON(), messages, nil, nil
+}
+
+// validateInput validates a struct as per its validate tags. Values of other kinds are not validated.
+func validateInput[I any](input I) []wscutils.ErrorMessage {
+	return wscutils.WscValidate(input, func(err validator.FieldError) []string {
+		if err.Param() != "" {
+			return []string{err.Param()}
+		}
+		return nil
+	})
+}
+
+// emptyJSON returns the result recorded for a row which did not produce one
+func emptyJSON() JSONstr {
+	return JSONstr{value: "{}", valid: true}
+}
+
+func encodeJSON[T any](v T) (JSONstr, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return JSONstr{}, err
+	}
+	return JSONstr{value: string(b), valid: true}, nil
+}
+
+func decodeJSON[T any](s JSONstr) (T, error) {
+	var v T
+	err := json.Unmarshal([]byte(s.String()), &v)
+	return v, err
+}
+
+// decodeMessages unmarshals the messages of a row, which are empty unless the row failed.
+func decodeMessages(s JSONstr) ([]wscutils.ErrorMessage, error) {
+	if !strings.HasPrefix(strings.TrimSpace(s.String()), "[") {
+		return nil, nil
+	}
+	var messages []wscutils.ErrorMessage
+	err := json.Unmarshal([]byte(s.String()), &messages)
+	return messages, err
+}
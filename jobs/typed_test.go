@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/alya/wscutils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mailContext struct {
+	Campaign string `json:"campaign"`
+}
+
+type mailInput struct {
+	To      string `json:"to" validate:"required,email"`
+	Subject string `json:"subject" validate:"required"`
+}
+
+// accountInput is an input whose rows are processed in line order per account
+type accountInput struct {
+	Account string `json:"account"`
+	Amount  int    `json:"amount"`
+}
+
+func (i accountInput) PartitionKey() string {
+	return i.Account
+}
+
+type mailResult struct {
+	MessageID string `json:"messageId"`
+}
+
+type mailProcessor struct {
+	gotContext mailContext
+	gotInput   mailInput
+}
+
+func (p *mailProcessor) DoBatchJob(ctx context.Context, initBlock InitBlock, batchctx mailContext, line int, input mailInput) (batchsqlc.StatusEnum, mailResult, []wscutils.ErrorMessage, map[string]string, error) {
+	p.gotContext = batchctx
+	p.gotInput = input
+	if input.Subject == "fail" {
+		return batchsqlc.StatusEnumFailed, mailResult{}, nil, nil, errors.New("smtp unavailable")
+	}
+	return batchsqlc.StatusEnumSuccess, mailResult{MessageID: "m1"}, nil, nil, nil
+}
+
+func (p *mailProcessor) MarkDone(initBlock InitBlock, batchctx mailContext, details BatchDetails_t) error {
+	p.gotContext = batchctx
+	return nil
+}
+
+func TestTypedBatchProcessor(t *testing.T) {
+	p := &mailProcessor{}
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+	assert.NoError(t, RegisterTypedProcessorBatch[mailContext, mailInput, mailResult](jm, "app1", "OP1", p))
+
+	adapted, exists := jm.batchprocessorfuncs["app1op1"]
+	assert.True(t, exists)
+
+	jobContext, _ := NewJSONstr(`{"campaign":"spring"}`)
+	input, _ := NewJSONstr(`{"to":"a@example.com","subject":"hello"}`)
+	status, result, messages, _, err := adapted.DoBatchJob(context.Background(), nil, jobContext, 1, input)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumSuccess, status)
+	assert.Empty(t, messages)
+	assert.Equal(t, "spring", p.gotContext.Campaign)
+	assert.Equal(t, "a@example.com", p.gotInput.To)
+	assert.JSONEq(t, `{"messageId":"m1"}`, result.String())
+
+	// Errors from the processor are passed through, with an empty result
+	input, _ = NewJSONstr(`{"to":"a@example.com","subject":"fail"}`)
+	_, result, _, _, err = adapted.DoBatchJob(context.Background(), nil, jobContext, 2, input)
+	assert.EqualError(t, err, "smtp unavailable")
+	assert.Equal(t, "{}", result.String())
+
+	// Input which does not fit the type fails the row without an error, so that it is not retried
+	input, _ = NewJSONstr(`{"to":42}`)
+	status, _, messages, _, err = adapted.DoBatchJob(context.Background(), nil, jobContext, 3, input)
+	assert.NoError(t, err)
+	assert.Equal(t, batchsqlc.StatusEnumFailed, status)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, MsgIDInvalidRowInput, messages[0].MsgID)
+	assert.Equal(t, ErrcodeInvalidRowInput, messages[0].ErrCode)
+	assert.Equal(t, "input", messages[0].Field)
+
+	assert.NoError(t, adapted.MarkDone(nil, jobContext, BatchDetails_t{}))
+}
+
+func TestTypedSubmitValidatesInput(t *testing.T) {
+	jm := NewJobManager(nil, nil, nil, nil, nil)
+
+	inputs := []mailInput{
+		{To: "a@example.com", Subject: "hello"},
+		{To: "not-an-email", Subject: "hello"},
+		{To: "c@example.com"},
+	}
+	batchID, invalid, err := TypedBatchSubmit(jm, "app1", "op1", mailContext{}, inputs, false)
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+	assert.Empty(t, batchID)
+	assert.Len(t, invalid, 2)
+	assert.Equal(t, "To", invalid[2][0].Field)
+	assert.Equal(t, "Subject", invalid[3][0].Field)
+
+	reqID, messages, err := TypedSlowQuerySubmit(jm, "app1", "op1", mailContext{}, mailInput{Subject: "hello"})
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+	assert.Empty(t, reqID)
+	assert.Len(t, messages, 1)
+}
+
+func TestDecodeMessages(t *testing.T) {
+	empty, _ := NewJSONstr("")
+	messages, err := decodeMessages(empty)
+	assert.NoError(t, err)
+	assert.Nil(t, messages)
+
+	failed, _ := NewJSONstr(`[{"msgid":9001,"errcode":"row_processing_failed","vals":["smtp unavailable"]}]`)
+	messages, err = decodeMessages(failed)
+	assert.NoError(t, err)
+	assert.Equal(t, []wscutils.ErrorMessage{{MsgID: MsgIDRowProcessingFailed, ErrCode: ErrcodeRowProcessingFailed, Vals: []string{"smtp unavailable"}}}, messages)
+}
+
+func TestTypedBatchSubmitPartitionKey(t *testing.T) {
+	jm := newMemTestJobManager(t)
+	inputs := []accountInput{{Account: "A1", Amount: 10}, {Account: "A2", Amount: 5}, {Account: "A1", Amount: -3}}
+	batchID, _, err := TypedBatchSubmit(jm, "app1", "post", mailContext{}, inputs, true)
+	assert.NoError(t, err)
+
+	rows, err := jm.Queries.GetBatchRowsByBatchID(context.Background(), uuid.MustParse(batchID))
+	assert.NoError(t, err)
+	partkeys := make(map[int32]string)
+	for _, row := range rows {
+		partkeys[row.Line] = row.Partkey.String
+	}
+	assert.Equal(t, map[int32]string{1: "A1", 2: "A2", 3: "A1"}, partkeys)
+}
@@ -1,7 +1,9 @@
 package jobs
 
 import (
+	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
@@ -21,9 +23,13 @@ type BatchJob_t struct {
 }
 
 // BatchInput_t represents a single input row for a batch job.
+// Rows with the same PartitionKey, such as the transactions of an account, are processed one at a
+// time in the order of their lines, while rows with different keys are processed in parallel. Rows
+// without a key are processed in any order.
 type BatchInput_t struct {
-	Line  int     `json:"line"`
-	Input JSONstr `json:"input"`
+	Line         int     `json:"line"`
+	Input        JSONstr `json:"input"`
+	PartitionKey string  `json:"partitionkey,omitempty"`
 }
 
 // maybe combine initblock and initializer
@@ -54,6 +60,43 @@ type BatchProcessor interface {
 	MarkDone(InitBlock InitBlock, context JSONstr, details BatchDetails_t) error
 }
 
+// SlowQueryProcessorCtx is a SlowQueryProcessor which is passed a context.Context. The context is
+// cancelled when the slow query is aborted, when the JobManager is shutting down, or when the row
+// timeout registered for the (app, op) elapses; the processor is expected to give up and return an
+// error soon after. context.Cause(ctx) tells which of these happened.
+type SlowQueryProcessorCtx interface {
+	DoSlowQuery(ctx context.Context, InitBlock InitBlock, context JSONstr, input JSONstr) (status batchsqlc.StatusEnum, result JSONstr, messages []wscutils.ErrorMessage, outputFiles map[string]string, err error)
+}
+
+// BatchProcessorCtx is a BatchProcessor which is passed a context.Context. The context is cancelled
+// when the batch is aborted, when the JobManager is shutting down, or when the row timeout registered
+// for the (app, op) elapses; the processor is expected to give up and return an error soon after.
+// context.Cause(ctx) tells which of these happened.
+type BatchProcessorCtx interface {
+	DoBatchJob(ctx context.Context, InitBlock InitBlock, context JSONstr, line int, input JSONstr) (status batchsqlc.StatusEnum, result JSONstr, messages []wscutils.ErrorMessage, blobRows map[string]string, err error)
+	MarkDone(InitBlock InitBlock, context JSONstr, details BatchDetails_t) error
+}
+
+// slowQueryProcessorAdapter lets a SlowQueryProcessor be used as a SlowQueryProcessorCtx. The
+// context is ignored, so such a processor always runs to completion.
+type slowQueryProcessorAdapter struct {
+	SlowQueryProcessor
+}
+
+func (a slowQueryProcessorAdapter) DoSlowQuery(ctx context.Context, initBlock InitBlock, context JSONstr, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	return a.SlowQueryProcessor.DoSlowQuery(initBlock, context, input)
+}
+
+// batchProcessorAdapter lets a BatchProcessor be used as a BatchProcessorCtx. The context is
+// ignored, so such a processor always runs to completion.
+type batchProcessorAdapter struct {
+	BatchProcessor
+}
+
+func (a batchProcessorAdapter) DoBatchJob(ctx context.Context, initBlock InitBlock, context JSONstr, line int, input JSONstr) (batchsqlc.StatusEnum, JSONstr, []wscutils.ErrorMessage, map[string]string, error) {
+	return a.BatchProcessor.DoBatchJob(initBlock, context, line, input)
+}
+
 type SlowQuery struct {
 	Db          *pgxpool.Pool
 	Queries     batchsqlc.Querier
@@ -90,10 +133,36 @@ func (j JSONstr) IsValid() bool {
 	return j.valid
 }
 
+// Message IDs and error codes recorded in the messages of a row for which the processor returned an error,
+// or which timed out, and which is not going to be retried. The error text is carried in the message's vals.
+// The message IDs of the jobs package start at 9001, clear of those used by applications.
+const (
+	MsgIDRowProcessingFailed   = 9001
+	ErrcodeRowProcessingFailed = "row_processing_failed"
+	MsgIDRowTimedOut           = 9002
+	ErrcodeRowTimedOut         = "row_timed_out"
+)
+
+// Message ID and error code recorded in the messages of a row whose context or input could not be
+// unmarshalled into the types expected by a typed processor (see RegisterTypedProcessorBatch).
+const (
+	MsgIDInvalidRowInput   = 9003
+	ErrcodeInvalidRowInput = "invalid_row_input"
+)
+
 // JobManagerConfig holds the configuration for the job manager.
 type JobManagerConfig struct {
-	BatchChunkNRows        int // number of rows to send to the batch processor in each chunk
-	BatchStatusCacheDurSec int // duration in seconds to cache the batch status
+	BatchChunkNRows        int    // number of rows to send to the batch processor in each chunk
+	BatchStreamCopyNRows   int    // number of rows copied at a time by BatchSubmitStream
+	BatchStatusCacheDurSec int    // duration in seconds to cache the batch status
+	NumWorkers             int    // number of worker goroutines started by Run
+	LeaseDurSec            int    // duration in seconds for which a row taken up by a worker is leased to it
+	HeartbeatIntervalSec   int    // interval in seconds between heartbeats, which renew leases and reclaim expired ones
+	MaxRowAttempts         int    // attempts after which a row whose lease keeps expiring is moved to the dead-letter state, unless its (app, op) has a retry policy
+	AbortCheckIntervalSec  int    // interval in seconds between checks for aborted batches among the rows being processed
+	SchedulerIntervalSec   int    // interval in seconds between checks for recurring batches which are due
+	PipelineIntervalSec    int    // interval in seconds between checks for pipelines whose current batch is done
+	CacheNamespace         string // prefix of the names of the keys and channels used in Redis
 }
 
 // BatchDetails_t struct
@@ -102,9 +171,29 @@ type BatchDetails_t struct {
 	App         string
 	Op          string
 	Context     JSONstr
+	InputFile   string
 	Status      batchsqlc.StatusEnum
+	ReqAt       time.Time
+	DoneAt      time.Time // zero until the batch is done
 	OutputFiles map[string]string
+	NRows       int
 	NSuccess    int
 	NFailed     int
 	NAborted    int
+	Aggregate   JSONstr // computed by the BatchReducer of the (app, op); not valid if there is none
+	// AggregateErr is the error of the last call to Reduce or Final which failed, if any, in which
+	// case Aggregate does not cover all the rows
+	AggregateErr string
+}
+
+// SlowQueryDetails_t describes a slow query, as returned by SlowQueryList
+type SlowQueryDetails_t struct {
+	ID          string
+	App         string
+	Op          string
+	Context     JSONstr
+	Status      batchsqlc.StatusEnum
+	ReqAt       time.Time
+	DoneAt      time.Time // zero until the slow query is done
+	OutputFiles map[string]string
 }
@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/remiges-tech/alya/jobs/pg/batchsqlc"
+	"github.com/remiges-tech/logharbour/logharbour"
+)
+
+// newWorkerID generates an ID for a JobManager instance. The host name and process ID are
+// included so that the value in batchrows.doneby can be traced back to a pod or process.
+func newWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+}
+
+// doneBy returns the WorkerID in the form stored in batchrows.doneby.
+func (jm *JobManager) doneBy() pgtype.Text {
+	return pgtype.Text{String: jm.WorkerID, Valid: true}
+}
+
+func (jm *JobManager) leaseDuration() time.Duration {
+	return time.Duration(jm.Config.LeaseDurSec) * time.Second
+}
+
+// runHeartbeat records a heartbeat for this instance in the workers table every
+// Config.HeartbeatIntervalSec seconds, renews the leases on the rows held by this instance
+// and reclaims rows whose lease has expired. It returns when ctx is cancelled, after
+// removing this instance from the workers table.
+func (jm *JobManager) runHeartbeat(ctx context.Context) {
+	startedAt := pgtype.Timestamp{Time: time.Now(), Valid: true}
+	interval := time.Duration(jm.Config.HeartbeatIntervalSec) * time.Second
+
+	for {
+		if err := jm.heartbeat(startedAt); err != nil {
+			log.Printf("Error recording heartbeat for worker %s: %v", jm.WorkerID, err)
+		}
+		if err := jm.reclaimExpiredLeases(); err != nil {
+			log.Printf("Error reclaiming expired leases: %v", err)
+		}
+
+		sleepWithContext(ctx, interval)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if err := jm.Queries.DeleteWorker(context.Background(), jm.WorkerID); err != nil {
+		log.Printf("Error removing worker %s: %v", jm.WorkerID, err)
+	}
+}
+
+// heartbeat records that this instance is alive and extends the leases on all the rows it holds.
+func (jm *JobManager) heartbeat(startedAt pgtype.Timestamp) error {
+	ctx := context.Background()
+	now := time.Now()
+
+	err := jm.Queries.RecordWorkerHeartbeat(ctx, batchsqlc.RecordWorkerHeartbeatParams{
+		ID:        jm.WorkerID,
+		Startedat: startedAt,
+		Heartbeat: pgtype.Timestamp{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat: %v", err)
+	}
+
+	_, err = jm.Queries.ExtendWorkerLeases(ctx, batchsqlc.ExtendWorkerLeasesParams{
+		Doneby:      jm.doneBy(),
+		Leaseexpiry: pgtype.Timestamp{Time: now.Add(jm.leaseDuration()), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extend leases: %v", err)
+	}
+	return nil
+}
+
+// reclaimExpiredLeases puts rows which are inprog but whose lease has expired back in the queue.
+// Such rows were taken up by an instance which died (or lost its database connection for longer
+// than the lease duration) before it could record their result. Rows which have already been
+// attempted as many times as the retry policy of their (app, op) allows, or Config.MaxRowAttempts
+// times if it has none, are likely to be what is killing the instances, so they are moved to the
+// dead-letter state instead, and their batches are summarized if they are now complete. Workers which have not sent a heartbeat for a lease duration are removed from the
+// workers table.
+func (jm *JobManager) reclaimExpiredLeases() error {
+	ctx := context.Background()
+	now := time.Now()
+
+	params := batchsqlc.ReclaimExpiredLeasesParams{
+		Policykeys:        make([]string, 0, len(jm.retrypolicies)),
+		Policymaxattempts: make([]int32, 0, len(jm.retrypolicies)),
+		Maxattempts:       int32(jm.Config.MaxRowAttempts),
+		Leaseexpiry:       pgtype.Timestamp{Time: now, Valid: true},
+	}
+	for key, policy := range jm.retrypolicies {
+		params.Policykeys = append(params.Policykeys, key)
+		params.Policymaxattempts = append(params.Policymaxattempts, int32(policy.MaxAttempts))
+	}
+	reclaimed, err := jm.Queries.ReclaimExpiredLeases(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to reclaim expired leases: %v", err)
+	}
+	deadBatches := make(map[uuid.UUID]bool)
+	for _, row := range reclaimed {
+		if jm.Logger != nil {
+			jm.Logger.LogDataChange("Batch row reclaimed after lease expiry", logharbour.ChangeInfo{
+				Entity: "BatchRow",
+				Op:     "LeaseExpired",
+				Changes: []logharbour.ChangeDetail{
+					{Field: "status", OldVal: batchsqlc.StatusEnumInprog, NewVal: row.Status},
+				},
+			})
+		}
+		if row.Status == batchsqlc.StatusEnumDeadletter {
+			deadBatches[row.Batch] = true
+			log.Printf("Moved row %d of batch %s to dead-letter state after repeated lease expiry", row.Rowid, row.Batch)
+		} else {
+			log.Printf("Requeued row %d of batch %s after lease expiry", row.Rowid, row.Batch)
+		}
+	}
+
+	if len(deadBatches) > 0 {
+		if err := jm.summarizeDeadLetteredBatches(deadBatches); err != nil {
+			return err
+		}
+	}
+
+	_, err = jm.Queries.DeleteStaleWorkers(ctx, pgtype.Timestamp{Time: now.Add(-jm.leaseDuration()), Valid: true})
+	if err != nil {
+		return fmt.Errorf("failed to remove stale workers: %v", err)
+	}
+	return nil
+}
+
+// summarizeDeadLetteredBatches summarizes the batches which may have been completed by moving
+// their last rows to the dead-letter state.
+func (jm *JobManager) summarizeDeadLetteredBatches(batchSet map[uuid.UUID]bool) error {
+	tx, err := jm.Store.Begin(context.Background())
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := jm.summarizeCompletedBatches(tx.Queries(), batchSet); err != nil {
+		return fmt.Errorf("error summarizing batches: %v", err)
+	}
+	if err := tx.Commit(context.Background()); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+	return nil
+}